@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"misskeyRSSbot/internal/domain/entity"
+	"misskeyRSSbot/internal/domain/repository"
+	"misskeyRSSbot/internal/infrastructure/storage"
+	"misskeyRSSbot/internal/interfaces/config"
+)
+
+// cacheFlags は各サブコマンドに共通の接続先のフラグです
+// 指定しない場合は CACHE_DSN と CACHE_DB_PATH を使います
+type cacheFlags struct {
+	dbPath string
+	dsn    string
+	file   string
+}
+
+func newCacheFlagSet(name, fileUsage string) (*flag.FlagSet, *cacheFlags) {
+	flags := &cacheFlags{}
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.StringVar(&flags.dbPath, "db", "", "SQLite cache path (default: CACHE_DB_PATH)")
+	fs.StringVar(&flags.dsn, "dsn", "", "PostgreSQL cache DSN (default: CACHE_DSN)")
+	fs.StringVar(&flags.file, "file", "-", fileUsage)
+	return fs, flags
+}
+
+// openCache はフラグか環境変数で指定された永続化キャッシュを開きます
+func (f *cacheFlags) openCache() (repository.CacheStateRepository, io.Closer, error) {
+	cacheCfg, err := config.LoadCacheConfig()
+	if err != nil {
+		return nil, nil, err
+	}
+	if f.dbPath != "" || f.dsn != "" {
+		cacheCfg = &config.CacheConfig{DBPath: f.dbPath, DSN: f.dsn}
+	}
+
+	var cacheRepo repository.CacheRepository
+	switch {
+	case cacheCfg.DSN != "":
+		if !cacheCfg.IsPostgresCache() {
+			return nil, nil, fmt.Errorf("invalid DSN: expected postgres:// or postgresql:// URL")
+		}
+		cacheRepo, err = storage.NewPostgresCacheRepository(cacheCfg.DSN)
+	case cacheCfg.DBPath != "":
+		cacheRepo, err = storage.NewSQLiteCacheRepository(cacheCfg.DBPath)
+	default:
+		return nil, nil, errors.New("no persistent cache configured (set -db/-dsn or CACHE_DB_PATH/CACHE_DSN)")
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	closer, _ := cacheRepo.(io.Closer)
+	stateRepo, ok := cacheRepo.(repository.CacheStateRepository)
+	if !ok {
+		if closer != nil {
+			closer.Close()
+		}
+		return nil, nil, errors.New("cache does not support export and import")
+	}
+	return stateRepo, closer, nil
+}
+
+func runExportCache(ctx context.Context, args []string) error {
+	fs, flags := newCacheFlagSet("export-cache", "output file (- for stdout)")
+	formatName := fs.String("format", string(storage.SnapshotFormatNDJSON), "output format: ndjson or json")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	format, err := storage.ParseSnapshotFormat(*formatName)
+	if err != nil {
+		return err
+	}
+
+	stateRepo, closer, err := flags.openCache()
+	if err != nil {
+		return err
+	}
+	if closer != nil {
+		defer closer.Close()
+	}
+
+	snapshot, err := stateRepo.ExportState(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := writeSnapshotFile(flags.file, snapshot, format); err != nil {
+		return err
+	}
+
+	log.Printf("Exported %d cache records", snapshot.Len())
+	return nil
+}
+
+// writeSnapshotFile は snapshot を path（- の場合は標準出力）に書き出します
+func writeSnapshotFile(path string, snapshot *entity.CacheSnapshot, format storage.SnapshotFormat) error {
+	if path == "-" {
+		return storage.WriteSnapshot(os.Stdout, snapshot, format)
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", path, err)
+	}
+	if err := storage.WriteSnapshot(file, snapshot, format); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
+
+func runImportCache(ctx context.Context, args []string) error {
+	fs, flags := newCacheFlagSet("import-cache", "input file (- for stdin)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	in := io.Reader(os.Stdin)
+	if flags.file != "-" {
+		file, err := os.Open(flags.file)
+		if err != nil {
+			return fmt.Errorf("failed to open %s: %w", flags.file, err)
+		}
+		defer file.Close()
+		in = file
+	}
+	// 接続する前にファイルを読み、壊れたファイルでは何も変更しない
+	snapshot, err := storage.ReadSnapshot(in)
+	if err != nil {
+		return err
+	}
+
+	stateRepo, closer, err := flags.openCache()
+	if err != nil {
+		return err
+	}
+	if closer != nil {
+		defer closer.Close()
+	}
+
+	if err := stateRepo.ImportState(ctx, snapshot); err != nil {
+		return err
+	}
+
+	log.Printf("Imported %d cache records", snapshot.Len())
+	return nil
+}
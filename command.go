@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// subcommands はボットを起動せずにキャッシュやフィードの設定を操作するサブコマンドです
+var subcommands = map[string]func(ctx context.Context, args []string) error{
+	"export-cache": runExportCache,
+	"import-cache": runImportCache,
+	"export-opml":  runExportOPML,
+	"import-opml":  runImportOPML,
+}
+
+// runSubcommand は args がサブコマンドであれば実行し、true を返します
+func runSubcommand(args []string) bool {
+	if len(args) == 0 {
+		return false
+	}
+	command, ok := subcommands[args[0]]
+	if !ok {
+		return false
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := command(ctx, args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(2)
+		}
+		log.Fatalf("%s failed: %v", args[0], err)
+	}
+	return true
+}
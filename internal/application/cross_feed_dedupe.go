@@ -0,0 +1,132 @@
+package application
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"misskeyRSSbot/internal/domain/entity"
+)
+
+// WithCrossFeedDedupe はフィードをまたいだ重複の判定を有効にします
+// 正規化したURLまたはタイトルが一致する記事を window の間に他のフィードが投稿していれば投稿しません
+// mergeSources が true の場合は、同時に見つかった重複のリンクを投稿するノートにまとめます
+func WithCrossFeedDedupe(window time.Duration, mergeSources bool) RSSFeedServiceOption {
+	return func(s *RSSFeedService) {
+		s.crossFeedWindow = window
+		s.mergeSources = mergeSources
+	}
+}
+
+// suppressCrossFeedDuplicates はフィードをまたいで重複するエントリーを投稿対象から外します
+// 優先度の高いフィード（同じ優先度なら設定順で先のフィード）のエントリーを残します
+// 既に投稿した記事と重複するエントリーは処理済みとして記録し、同じ実行で残したエントリーと重複するエントリーは
+// 残したエントリーを投稿できた後に処理済みとして記録します
+func (s *RSSFeedService) suppressCrossFeedDuplicates(ctx context.Context, batches []*feedBatch) {
+	if s.crossFeedWindow <= 0 || len(batches) == 0 {
+		return
+	}
+
+	ordered := orderByPriority(batches)
+	since := time.Now().Add(-s.crossFeedWindow)
+	claimed := make(map[string]*claim)
+	for _, batch := range ordered {
+		kept := batch.entries[:0]
+		for _, entry := range batch.entries {
+			keys := entity.StoryKeys(entry)
+			if winner := claimedByOtherFeed(claimed, keys, batch); winner != nil {
+				log.Printf("Skipping duplicate of %s from %s [%s]", winner.batch.setting.URL, batch.setting.URL, entry.Title)
+				if s.mergeSources && entry.Link != "" {
+					winner.entry.OtherSources = append(winner.entry.OtherSources, entry.Link)
+				}
+				s.deferSuppressed(ctx, winner.batch, winner.entry, batch, entry)
+				continue
+			}
+
+			posted, err := s.cacheRepo.HasRecentStory(ctx, batch.setting.URL, keys, since)
+			if err != nil {
+				log.Printf("Failed to check recent stories [%s]: %v", entry.Title, err)
+			} else if posted {
+				log.Printf("Skipping story already posted from another feed [%s]", entry.Title)
+				s.markSuppressed(ctx, batch, entry)
+				continue
+			}
+
+			for _, key := range keys {
+				if _, ok := claimed[key]; !ok {
+					claimed[key] = &claim{batch: batch, entry: entry}
+				}
+			}
+			kept = append(kept, entry)
+		}
+		batch.entries = kept
+	}
+}
+
+type claim struct {
+	batch *feedBatch
+	entry *entity.FeedEntry
+}
+
+// claimedByOtherFeed は keys のいずれかを他のフィードのエントリーが先に確保していればそれを返します
+// 同じフィード内のタイトルの一致は別の記事として扱います
+func claimedByOtherFeed(claimed map[string]*claim, keys []string, batch *feedBatch) *claim {
+	for _, key := range keys {
+		if c, ok := claimed[key]; ok && c.batch != batch {
+			return c
+		}
+	}
+	return nil
+}
+
+// duplicate は同じ実行で他のフィードのエントリーにまとめて投稿しなかったエントリーです
+type duplicate struct {
+	batch *feedBatch
+	entry *entity.FeedEntry
+}
+
+// deferSuppressed は entry を winner にまとめ、winner を投稿できたときに処理済みとして記録するようにします
+// winner を投稿できなかった場合に次の取得で entry を選び直せるよう、それまでは処理済みにせず pending として記録します
+func (s *RSSFeedService) deferSuppressed(ctx context.Context, winnerBatch *feedBatch, winner *entity.FeedEntry, batch *feedBatch, entry *entity.FeedEntry) {
+	if winnerBatch.duplicates == nil {
+		winnerBatch.duplicates = make(map[*entity.FeedEntry][]duplicate)
+	}
+	winnerBatch.duplicates[winner] = append(winnerBatch.duplicates[winner], duplicate{batch: batch, entry: entry})
+	// entry にまとめていたエントリーも winner にまとめ直す
+	if dups, ok := batch.duplicates[entry]; ok {
+		winnerBatch.duplicates[winner] = append(winnerBatch.duplicates[winner], dups...)
+		delete(batch.duplicates, entry)
+	}
+
+	if s.postStates != nil {
+		if err := s.postStates.SavePostState(ctx, entity.NewPostRecord(batch.setting.URL, entry)); err != nil {
+			log.Printf("Failed to save post state [GUID: %s]: %v", entry.GUID, err)
+		}
+	}
+}
+
+// suppressDuplicatesOf は投稿できた entry にまとめたエントリーを処理済みとして記録します
+func (s *RSSFeedService) suppressDuplicatesOf(ctx context.Context, batch *feedBatch, entry *entity.FeedEntry) {
+	for _, dup := range batch.duplicates[entry] {
+		s.markSuppressed(ctx, dup.batch, dup.entry)
+	}
+	delete(batch.duplicates, entry)
+}
+
+func (s *RSSFeedService) markSuppressed(ctx context.Context, batch *feedBatch, entry *entity.FeedEntry) {
+	if err := s.cacheRepo.MarkAsProcessed(ctx, batch.setting.URL, entry.GUID, entry.CanonicalKey); err != nil {
+		log.Printf("Failed to mark as processed [GUID: %s]: %v", entry.GUID, err)
+	}
+	if entry.HasFeedDate() && entry.Published.After(batch.suppressedLatest) {
+		batch.suppressedLatest = entry.Published
+	}
+}
+
+func (s *RSSFeedService) saveRecentStory(ctx context.Context, feedURL string, entry *entity.FeedEntry) {
+	if s.crossFeedWindow <= 0 {
+		return
+	}
+	if err := s.cacheRepo.SaveRecentStory(ctx, feedURL, entity.StoryKeys(entry), time.Now()); err != nil {
+		log.Printf("Failed to save recent story [%s]: %v", entry.Title, err)
+	}
+}
@@ -0,0 +1,173 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"misskeyRSSbot/internal/domain/entity"
+	"misskeyRSSbot/internal/interfaces/config"
+)
+
+type mockFeedsByURL struct {
+	entries map[string][]*entity.FeedEntry
+}
+
+func (m *mockFeedsByURL) Fetch(ctx context.Context, url string) ([]*entity.FeedEntry, error) {
+	return m.entries[url], nil
+}
+
+func TestRSSFeedService_ProcessAllFeeds_CrossFeedPriority(t *testing.T) {
+	ctx := context.Background()
+
+	now := time.Now()
+	feedRepo := &mockFeedsByURL{entries: map[string][]*entity.FeedEntry{
+		"https://aggregator.tld/rss": {
+			entity.NewFeedEntry("新しい路線が来年開業へ（まとめ）", "https://aggregator.tld/1?utm_source=rss", "Desc", now, "agg-1"),
+		},
+		"https://news.tld/rss": {
+			entity.NewFeedEntry("新しい路線が来年開業へ", "https://news.tld/articles/1", "Desc", now, "news-1"),
+			entity.NewFeedEntry("別の記事です、関係のない内容", "https://news.tld/articles/2", "Desc", now, "news-2"),
+		},
+		"https://mirror.tld/rss": {
+			entity.NewFeedEntry("【速報】新しい路線が来年開業へ", "https://news.tld/articles/1/", "Desc", now, "mirror-1"),
+		},
+	}}
+	noteRepo := &mockNoteRepository{}
+	cacheRepo := newMockCacheRepository()
+	cacheRepo.latestTime = now.Add(-1 * time.Hour)
+
+	service := NewRSSFeedService(feedRepo, noteRepo, cacheRepo, nil, WithCrossFeedDedupe(24*time.Hour, true))
+	err := service.ProcessAllFeeds(ctx, []config.RSSSettings{
+		{URL: "https://aggregator.tld/rss"},
+		{URL: "https://news.tld/rss", Priority: 10},
+		{URL: "https://mirror.tld/rss"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var texts []string
+	for _, note := range noteRepo.posted {
+		texts = append(texts, note.Text)
+	}
+	if len(noteRepo.posted) != 3 {
+		t.Fatalf("expected 3 notes posted, got %d: %q", len(noteRepo.posted), texts)
+	}
+
+	var merged string
+	for _, text := range texts {
+		if strings.Contains(text, "https://news.tld/articles/1\n") || strings.HasSuffix(text, "https://news.tld/articles/1") {
+			merged = text
+		}
+	}
+	if merged == "" {
+		t.Fatalf("expected the higher priority feed to win, got %q", texts)
+	}
+	if !strings.Contains(merged, "【他のソース】\nhttps://news.tld/articles/1/") {
+		t.Errorf("expected mirror link to be merged, got %q", merged)
+	}
+	if !cacheRepo.processedGUIDs[feedKey("https://mirror.tld/rss", "mirror-1")] {
+		t.Error("expected suppressed entry to be marked as processed")
+	}
+
+	// タイトルが短すぎないため、まとめ記事（タイトルが異なる）は別の記事として投稿される
+	if !strings.Contains(strings.Join(texts, "\n"), "まとめ") {
+		t.Errorf("expected aggregator entry with a different title to be posted, got %q", texts)
+	}
+}
+
+func TestRSSFeedService_ProcessAllFeeds_DuplicateKeptWhenWinnerFails(t *testing.T) {
+	ctx := context.Background()
+
+	now := time.Now()
+	feedRepo := &mockFeedsByURL{entries: map[string][]*entity.FeedEntry{
+		"https://a.tld/rss": {entity.NewFeedEntry("同じ記事のタイトルです", "https://a.tld/1", "Desc", now, "a-1")},
+		"https://b.tld/rss": {entity.NewFeedEntry("同じ記事のタイトルです", "https://b.tld/1", "Desc", now, "b-1")},
+	}}
+	noteRepo := &mockNoteRepository{err: errors.New("misskey is down")}
+	cacheRepo := newMockCacheRepository()
+	cacheRepo.latestTime = now.Add(-1 * time.Hour)
+	settings := []config.RSSSettings{{URL: "https://a.tld/rss", Priority: 10}, {URL: "https://b.tld/rss"}}
+
+	service := NewRSSFeedService(feedRepo, noteRepo, cacheRepo, nil, WithCrossFeedDedupe(24*time.Hour, false))
+	if err := service.ProcessAllFeeds(ctx, settings); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cacheRepo.processedGUIDs[feedKey("https://b.tld/rss", "b-1")] {
+		t.Fatal("expected the duplicate not to be marked as processed while the winner is unposted")
+	}
+
+	// 優先度の高いフィードから記事がなくなっても、重複していたエントリーを投稿する
+	noteRepo.err = nil
+	feedRepo.entries["https://a.tld/rss"] = nil
+	if err := service.ProcessAllFeeds(ctx, settings); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(noteRepo.posted) != 1 || !strings.Contains(noteRepo.posted[0].Text, "https://b.tld/1") {
+		t.Fatalf("expected the duplicate to be posted after the winner failed, got %d notes", len(noteRepo.posted))
+	}
+}
+
+func TestRSSFeedService_ProcessFeed_SkipsStoryPostedByAnotherFeed(t *testing.T) {
+	ctx := context.Background()
+
+	now := time.Now()
+	feedRepo := &mockFeedsByURL{entries: map[string][]*entity.FeedEntry{
+		"https://a.tld/rss": {entity.NewFeedEntry("同じ記事のタイトルです", "https://a.tld/1", "Desc", now, "a-1")},
+		"https://b.tld/rss": {entity.NewFeedEntry("同じ記事のタイトルです！", "https://b.tld/1", "Desc", now, "b-1")},
+	}}
+	noteRepo := &mockNoteRepository{}
+	cacheRepo := newMockCacheRepository()
+	cacheRepo.latestTime = now.Add(-1 * time.Hour)
+
+	service := NewRSSFeedService(feedRepo, noteRepo, cacheRepo, nil, WithCrossFeedDedupe(24*time.Hour, false))
+	for _, url := range []string{"https://a.tld/rss", "https://b.tld/rss"} {
+		if err := service.ProcessFeed(ctx, config.RSSSettings{URL: url}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	if len(noteRepo.posted) != 1 {
+		t.Fatalf("expected 1 note posted, got %d", len(noteRepo.posted))
+	}
+
+	// 判定期間を過ぎた記事は重複として扱わない
+	for key, feeds := range cacheRepo.recentStories {
+		for feed := range feeds {
+			cacheRepo.recentStories[key][feed] = now.Add(-48 * time.Hour)
+		}
+	}
+	feedRepo.entries["https://b.tld/rss"] = []*entity.FeedEntry{entity.NewFeedEntry("同じ記事のタイトルです", "https://b.tld/2", "Desc", now.Add(time.Minute), "b-2")}
+	if err := service.ProcessFeed(ctx, config.RSSSettings{URL: "https://b.tld/rss"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(noteRepo.posted) != 2 {
+		t.Fatalf("expected story outside the window to be posted, got %d notes", len(noteRepo.posted))
+	}
+}
+
+func TestRSSFeedService_ProcessAllFeeds_CrossFeedDisabled(t *testing.T) {
+	ctx := context.Background()
+
+	now := time.Now()
+	feedRepo := &mockFeedsByURL{entries: map[string][]*entity.FeedEntry{
+		"https://a.tld/rss": {entity.NewFeedEntry("同じ記事のタイトルです", "https://a.tld/1", "Desc", now, "a-1")},
+		"https://b.tld/rss": {entity.NewFeedEntry("同じ記事のタイトルです", "https://b.tld/1", "Desc", now, "b-1")},
+	}}
+	noteRepo := &mockNoteRepository{}
+	cacheRepo := newMockCacheRepository()
+	cacheRepo.latestTime = now.Add(-1 * time.Hour)
+
+	service := NewRSSFeedService(feedRepo, noteRepo, cacheRepo, nil)
+	err := service.ProcessAllFeeds(ctx, []config.RSSSettings{{URL: "https://a.tld/rss"}, {URL: "https://b.tld/rss"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(noteRepo.posted) != 2 {
+		t.Errorf("expected both notes posted when disabled, got %d", len(noteRepo.posted))
+	}
+}
@@ -0,0 +1,228 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"misskeyRSSbot/internal/domain/entity"
+	"misskeyRSSbot/internal/domain/repository"
+)
+
+const (
+	// FeedProcessingLease は RSS の取得と投稿を行うレプリカを決めるリースの名前です
+	FeedProcessingLease = "feed-processing"
+
+	defaultLeaseTTL = 30 * time.Second
+)
+
+// errLeadershipLost は LeaderContext の context がリースを失って終了したことを表します
+var errLeadershipLost = errors.New("leadership lost")
+
+// LeaderElector はキャッシュに記録したリースで、複数のレプリカのうち1つだけをリーダーにします
+// リーダーは ttl の 1/3 ごとにリースを更新し、更新が止まったリースは期限切れの後に他のレプリカが引き継ぎます
+type LeaderElector struct {
+	leases  repository.LeaseRepository
+	name    string
+	replica string
+	ttl     time.Duration
+	metrics repository.MetricsRepository
+	now     func() time.Time
+
+	mu    sync.RWMutex
+	lease entity.Lease
+	// expiry は更新できないままリースの期限が過ぎたときに watchers を終了するタイマーです
+	expiry *time.Timer
+	// watchers は LeaderContext で作成した context を終了する関数です
+	watchers map[*leaderWatch]struct{}
+}
+
+type leaderWatch struct {
+	cancel context.CancelCauseFunc
+}
+
+type LeaderElectorOption func(*LeaderElector)
+
+func WithLeaseTTL(ttl time.Duration) LeaderElectorOption {
+	return func(e *LeaderElector) {
+		if ttl > 0 {
+			e.ttl = ttl
+		}
+	}
+}
+
+func WithLeaderMetrics(metrics repository.MetricsRepository) LeaderElectorOption {
+	return func(e *LeaderElector) {
+		e.metrics = metrics
+	}
+}
+
+// LeaderStatus はリーダー選出の現在の状態です
+type LeaderStatus struct {
+	Replica   string    `json:"replica"`
+	Leader    string    `json:"leader"`
+	IsLeader  bool      `json:"is_leader"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// NewLeaderElector は replica を名前として FeedProcessingLease を取り合う LeaderElector を作成します
+func NewLeaderElector(leases repository.LeaseRepository, replica string, opts ...LeaderElectorOption) *LeaderElector {
+	e := &LeaderElector{
+		leases:   leases,
+		name:     FeedProcessingLease,
+		replica:  replica,
+		ttl:      defaultLeaseTTL,
+		now:      time.Now,
+		watchers: make(map[*leaderWatch]struct{}),
+	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e
+}
+
+// TryAcquire はリースの取得または更新を1回試み、リーダーかどうかを返します
+// リースを記録できなかった場合は、最後に取得したリースの期限まではリーダーのままとします
+func (e *LeaderElector) TryAcquire(ctx context.Context) bool {
+	wasLeader := e.IsLeader()
+
+	lease, _, err := e.leases.AcquireLease(ctx, e.name, e.replica, e.ttl)
+	if err != nil {
+		log.Printf("Failed to renew leader lease: %v", err)
+	} else {
+		e.mu.Lock()
+		e.lease = lease
+		if e.expiry != nil {
+			e.expiry.Stop()
+		}
+		if lease.IsHeldBy(e.replica, e.now()) {
+			e.expiry = time.AfterFunc(lease.ExpiresAt.Sub(e.now()), e.cancelIfNotLeader)
+		}
+		e.mu.Unlock()
+	}
+
+	isLeader := e.IsLeader()
+	if !isLeader {
+		e.cancelWatchers()
+	}
+	switch {
+	case isLeader && !wasLeader:
+		log.Printf("Became leader [replica: %s]", e.replica)
+	case !isLeader && wasLeader:
+		log.Printf("Lost leadership [replica: %s, leader: %s]", e.replica, e.Status().Leader)
+	}
+	e.recordMetrics(isLeader)
+	return isLeader
+}
+
+// Run は ctx が終了するまでリースを更新し続け、終了時にリースを解放します
+// 解放しておくことで、他のレプリカが期限切れを待たずに引き継げます
+func (e *LeaderElector) Run(ctx context.Context) {
+	ticker := time.NewTicker(e.ttl / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			e.release()
+			return
+		case <-ticker.C:
+			e.TryAcquire(ctx)
+		}
+	}
+}
+
+func (e *LeaderElector) release() {
+	if !e.IsLeader() {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := e.leases.ReleaseLease(ctx, e.name, e.replica); err != nil {
+		log.Printf("Failed to release leader lease: %v", err)
+		return
+	}
+
+	e.mu.Lock()
+	e.lease = entity.Lease{}
+	e.mu.Unlock()
+	e.cancelWatchers()
+	e.recordMetrics(false)
+	log.Printf("Released leader lease [replica: %s]", e.replica)
+}
+
+// LeaderContext はこのレプリカがリースを失ったときに終了する context を返します
+// リースの期間より長くかかる処理に渡すことで、他のレプリカがリーダーになった後に投稿を続けないようにします
+// リーダーでない場合は終了済みの context を返します。処理を終えたら返した CancelFunc を呼び出します
+func (e *LeaderElector) LeaderContext(parent context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancelCause(parent)
+	watch := &leaderWatch{cancel: cancel}
+
+	e.mu.Lock()
+	isLeader := e.lease.IsHeldBy(e.replica, e.now())
+	if isLeader {
+		e.watchers[watch] = struct{}{}
+	}
+	e.mu.Unlock()
+	if !isLeader {
+		cancel(errLeadershipLost)
+	}
+
+	return ctx, func() {
+		e.mu.Lock()
+		delete(e.watchers, watch)
+		e.mu.Unlock()
+		cancel(context.Canceled)
+	}
+}
+
+// cancelIfNotLeader はリースの期限が過ぎていれば LeaderContext の context を終了します
+func (e *LeaderElector) cancelIfNotLeader() {
+	if !e.IsLeader() {
+		e.cancelWatchers()
+	}
+}
+
+func (e *LeaderElector) cancelWatchers() {
+	e.mu.Lock()
+	watchers := e.watchers
+	e.watchers = make(map[*leaderWatch]struct{})
+	e.mu.Unlock()
+
+	for watch := range watchers {
+		watch.cancel(errLeadershipLost)
+	}
+}
+
+// IsLeader はこのレプリカが期限内のリースを持っているかどうかを返します
+func (e *LeaderElector) IsLeader() bool {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.lease.IsHeldBy(e.replica, e.now())
+}
+
+// Status は最後に確認したリーダーを返します
+func (e *LeaderElector) Status() LeaderStatus {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return LeaderStatus{
+		Replica:   e.replica,
+		Leader:    e.lease.Holder,
+		IsLeader:  e.lease.IsHeldBy(e.replica, e.now()),
+		ExpiresAt: e.lease.ExpiresAt,
+	}
+}
+
+func (e *LeaderElector) recordMetrics(isLeader bool) {
+	if e.metrics == nil {
+		return
+	}
+	value := 0.0
+	if isLeader {
+		value = 1
+	}
+	e.metrics.SetGauge("leader_election_is_leader", value, map[string]string{"replica": e.replica})
+}
@@ -0,0 +1,278 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"misskeyRSSbot/internal/domain/entity"
+	"misskeyRSSbot/internal/interfaces/config"
+)
+
+// mockLeaseRepository は clock の時刻で期限を判定するリースです
+type mockLeaseRepository struct {
+	mu     sync.Mutex
+	clock  *mockClock
+	leases map[string]entity.Lease
+	err    error
+}
+
+type mockClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *mockClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *mockClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func newMockLeaseRepository(clock *mockClock) *mockLeaseRepository {
+	return &mockLeaseRepository{clock: clock, leases: make(map[string]entity.Lease)}
+}
+
+func (m *mockLeaseRepository) AcquireLease(ctx context.Context, name, holder string, ttl time.Duration) (entity.Lease, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.err != nil {
+		return entity.Lease{}, false, m.err
+	}
+
+	now := m.clock.Now()
+	if lease, ok := m.leases[name]; ok && lease.Holder != holder && now.Before(lease.ExpiresAt) {
+		return lease, false, nil
+	}
+	lease := entity.Lease{Name: name, Holder: holder, ExpiresAt: now.Add(ttl)}
+	m.leases[name] = lease
+	return lease, true, nil
+}
+
+func (m *mockLeaseRepository) ReleaseLease(ctx context.Context, name, holder string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if lease, ok := m.leases[name]; ok && lease.Holder == holder {
+		delete(m.leases, name)
+	}
+	return nil
+}
+
+func newTestLeaderElector(leases *mockLeaseRepository, replica string, opts ...LeaderElectorOption) *LeaderElector {
+	e := NewLeaderElector(leases, replica, append([]LeaderElectorOption{WithLeaseTTL(30 * time.Second)}, opts...)...)
+	e.now = leases.clock.Now
+	return e
+}
+
+func TestLeaderElector_OnlyOneLeader(t *testing.T) {
+	ctx := context.Background()
+	clock := &mockClock{now: time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)}
+	leases := newMockLeaseRepository(clock)
+	metricsRepo := newMockMetricsRepository()
+
+	a := newTestLeaderElector(leases, "replica-a", WithLeaderMetrics(metricsRepo))
+	b := newTestLeaderElector(leases, "replica-b")
+
+	if !a.TryAcquire(ctx) {
+		t.Fatal("expected replica-a to become leader")
+	}
+	if metricsRepo.gauges["leader_election_is_leader"] != 1 {
+		t.Errorf("expected is_leader gauge 1, got %v", metricsRepo.gauges["leader_election_is_leader"])
+	}
+	if b.TryAcquire(ctx) {
+		t.Fatal("expected replica-b not to become leader while replica-a holds the lease")
+	}
+	if status := b.Status(); status.Leader != "replica-a" || status.IsLeader {
+		t.Errorf("expected replica-b to report replica-a as leader, got %+v", status)
+	}
+
+	// 更新を続ける限りリーダーは変わらない
+	clock.Advance(20 * time.Second)
+	if !a.TryAcquire(ctx) {
+		t.Fatal("expected replica-a to renew the lease")
+	}
+	clock.Advance(20 * time.Second)
+	if b.TryAcquire(ctx) {
+		t.Fatal("expected renewed lease to block replica-b")
+	}
+
+	// 更新が止まると期限切れの後に引き継がれる
+	clock.Advance(11 * time.Second)
+	if a.IsLeader() {
+		t.Error("expected replica-a to stop being leader after its lease expired")
+	}
+	if !b.TryAcquire(ctx) {
+		t.Fatal("expected replica-b to take over the expired lease")
+	}
+	if a.TryAcquire(ctx) {
+		t.Error("expected replica-a not to regain the lease held by replica-b")
+	}
+	if metricsRepo.gauges["leader_election_is_leader"] != 0 {
+		t.Errorf("expected is_leader gauge 0, got %v", metricsRepo.gauges["leader_election_is_leader"])
+	}
+}
+
+func TestLeaderElector_KeepsLeadershipUntilExpiryOnError(t *testing.T) {
+	ctx := context.Background()
+	clock := &mockClock{now: time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)}
+	leases := newMockLeaseRepository(clock)
+	e := newTestLeaderElector(leases, "replica-a")
+
+	if !e.TryAcquire(ctx) {
+		t.Fatal("expected to become leader")
+	}
+
+	leases.err = errors.New("database is unavailable")
+	clock.Advance(10 * time.Second)
+	if !e.TryAcquire(ctx) {
+		t.Error("expected to stay leader until the lease expires")
+	}
+	clock.Advance(21 * time.Second)
+	if e.TryAcquire(ctx) {
+		t.Error("expected to stop being leader after the lease expired without renewal")
+	}
+}
+
+func TestLeaderElector_RunReleasesLease(t *testing.T) {
+	clock := &mockClock{now: time.Now()}
+	leases := newMockLeaseRepository(clock)
+	a := newTestLeaderElector(leases, "replica-a")
+	b := newTestLeaderElector(leases, "replica-b")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	if !a.TryAcquire(ctx) {
+		t.Fatal("expected replica-a to become leader")
+	}
+	done := make(chan struct{})
+	go func() {
+		a.Run(ctx)
+		close(done)
+	}()
+	cancel()
+	<-done
+
+	if a.IsLeader() {
+		t.Error("expected replica-a to give up leadership after Run returned")
+	}
+	if !b.TryAcquire(context.Background()) {
+		t.Error("expected replica-b to acquire the released lease without waiting for expiry")
+	}
+}
+
+// hookNoteRepository は投稿するたびに onPost を呼び出します
+type hookNoteRepository struct {
+	posted []*entity.Note
+	onPost func()
+}
+
+func (m *hookNoteRepository) Post(ctx context.Context, note *entity.Note) error {
+	m.posted = append(m.posted, note)
+	if m.onPost != nil {
+		m.onPost()
+	}
+	return nil
+}
+
+// TestLeaderElector_LeaderContextStopsBatchOnLostLease は投稿の途中でリースを失うと、残りのエントリーを投稿しないことを確認します
+func TestLeaderElector_LeaderContextStopsBatchOnLostLease(t *testing.T) {
+	clock := &mockClock{now: time.Now()}
+	leases := newMockLeaseRepository(clock)
+	a := newTestLeaderElector(leases, "replica-a")
+	b := newTestLeaderElector(leases, "replica-b")
+	if !a.TryAcquire(context.Background()) {
+		t.Fatal("expected replica-a to become leader")
+	}
+
+	now := time.Now()
+	feedRepo := &mockFeedRepository{entries: []*entity.FeedEntry{
+		entity.NewFeedEntry("Article 1", "https://example.tld/1", "Desc", now.Add(-3*time.Minute), "guid-1"),
+		entity.NewFeedEntry("Article 2", "https://example.tld/2", "Desc", now.Add(-2*time.Minute), "guid-2"),
+		entity.NewFeedEntry("Article 3", "https://example.tld/3", "Desc", now.Add(-time.Minute), "guid-3"),
+	}}
+	cacheRepo := newMockCacheRepository()
+	cacheRepo.latestTime = now.Add(-time.Hour)
+
+	// 最初の投稿の間に replica-a の更新が止まってリースが切れ、replica-b がリーダーになる
+	noteRepo := &hookNoteRepository{}
+	noteRepo.onPost = func() {
+		noteRepo.onPost = nil
+		clock.Advance(31 * time.Second)
+		if !b.TryAcquire(context.Background()) {
+			t.Fatal("expected replica-b to take over the expired lease")
+		}
+		a.TryAcquire(context.Background())
+	}
+	service := NewRSSFeedService(feedRepo, noteRepo, cacheRepo, nil)
+
+	ctx, cancel := a.LeaderContext(context.Background())
+	defer cancel()
+	if err := service.ProcessAllFeeds(ctx, []config.RSSSettings{{URL: "https://example.tld/rss"}}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(noteRepo.posted) != 1 {
+		t.Errorf("expected posting to stop after the lease was lost, got %d notes", len(noteRepo.posted))
+	}
+	if !errors.Is(context.Cause(ctx), errLeadershipLost) {
+		t.Errorf("expected the context to end because leadership was lost, got %v", context.Cause(ctx))
+	}
+	if !cacheRepo.processedGUIDs[feedKey("https://example.tld/rss", "guid-1")] {
+		t.Error("expected the note posted before the lease was lost to be recorded")
+	}
+}
+
+func TestLeaderElector_LeaderContext(t *testing.T) {
+	t.Run("not leader", func(t *testing.T) {
+		clock := &mockClock{now: time.Now()}
+		e := newTestLeaderElector(newMockLeaseRepository(clock), "replica-a")
+
+		ctx, cancel := e.LeaderContext(context.Background())
+		defer cancel()
+		if !errors.Is(context.Cause(ctx), errLeadershipLost) {
+			t.Errorf("expected an ended context when not leader, got %v", context.Cause(ctx))
+		}
+	})
+
+	t.Run("lease expires without renewal", func(t *testing.T) {
+		// リースの期限を実際の時刻で判定し、更新できないまま期限が過ぎたら終了する
+		clock := &mockClock{now: time.Now()}
+		leases := newMockLeaseRepository(clock)
+		e := NewLeaderElector(leases, "replica-a", WithLeaseTTL(50*time.Millisecond))
+		if !e.TryAcquire(context.Background()) {
+			t.Fatal("expected to become leader")
+		}
+
+		ctx, cancel := e.LeaderContext(context.Background())
+		defer cancel()
+		select {
+		case <-ctx.Done():
+			if !errors.Is(context.Cause(ctx), errLeadershipLost) {
+				t.Errorf("expected the context to end because leadership was lost, got %v", context.Cause(ctx))
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("expected the context to end when the lease expired")
+		}
+	})
+
+	t.Run("released", func(t *testing.T) {
+		clock := &mockClock{now: time.Now()}
+		e := newTestLeaderElector(newMockLeaseRepository(clock), "replica-a")
+		if !e.TryAcquire(context.Background()) {
+			t.Fatal("expected to become leader")
+		}
+
+		ctx, cancel := e.LeaderContext(context.Background())
+		defer cancel()
+		e.release()
+		if ctx.Err() == nil {
+			t.Error("expected the context to end when the lease was released")
+		}
+	})
+}
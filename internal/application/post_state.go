@@ -0,0 +1,189 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"misskeyRSSbot/internal/domain/entity"
+	"misskeyRSSbot/internal/domain/repository"
+)
+
+// postHistoryMargin は投稿履歴を照合するときに、投稿中として記録した日時より前にさかのぼる時間です
+// ボットと Misskey のサーバーの時計のずれを吸収します
+const postHistoryMargin = 5 * time.Minute
+
+// interruptedPostMaxAge は投稿履歴を確認できないときに、投稿中のまま停止したエントリーを posting のまま残しておく期間です
+// この期間を過ぎても確認できなければ、投稿済みとみなします。古いエントリーを重複して投稿するより、
+// 投稿されていない可能性を受け入れる方がよいためです
+const interruptedPostMaxAge = 24 * time.Hour
+
+// errPostHistoryUnavailable は投稿履歴を確認できず、投稿できていたかどうかわからないことを表します
+var errPostHistoryUnavailable = errors.New("post history is unavailable")
+
+// WithPostStates は投稿の前後でエントリーの状態を記録します
+// 投稿済みの状態、処理済みのGUID、最新の公開日時は states でまとめて記録するため、途中で停止しても再投稿しません
+// history は投稿中のまま停止したエントリーを RecoverPosts で照合するために使います
+func WithPostStates(states repository.PostStateRepository, history repository.PostHistoryRepository) RSSFeedServiceOption {
+	return func(s *RSSFeedService) {
+		s.postStates = states
+		s.postHistory = history
+	}
+}
+
+// RecoverPosts は投稿中のまま停止したエントリーを投稿履歴と照合します
+// 投稿できていれば投稿済みとして記録し、投稿できていなければ pending に戻し、フィードに残っていれば次の取得で投稿し直します
+// 投稿履歴を確認できなかったエントリーは posting のまま残してエラーを返すため、成功するまで呼び出し直します
+// 投稿中のエントリーと照合しないように、フィードの処理と直列に実行します。何度呼び出しても結果は変わりません
+func (s *RSSFeedService) RecoverPosts(ctx context.Context) error {
+	if s.postStates == nil {
+		return nil
+	}
+
+	s.processMu.Lock()
+	defer s.processMu.Unlock()
+
+	records, err := s.postStates.ListPostStates(ctx, entity.PostStatePosting)
+	if err != nil {
+		return fmt.Errorf("failed to list interrupted posts: %w", err)
+	}
+
+	unchecked := 0
+	for _, record := range records {
+		posted, err := s.wasPosted(ctx, record)
+		if errors.Is(err, errPostHistoryUnavailable) {
+			log.Printf("Failed to check interrupted post, will retry [GUID: %s]: %v", record.GUID, err)
+			unchecked++
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if posted {
+			if err := s.postStates.CompletePost(ctx, record); err != nil {
+				return fmt.Errorf("failed to record recovered post [GUID: %s]: %w", record.GUID, err)
+			}
+			log.Printf("Recovered interrupted post as posted [GUID: %s]", record.GUID)
+			continue
+		}
+
+		record.State = entity.PostStatePending
+		record.UpdatedAt = time.Now()
+		if err := s.postStates.SavePostState(ctx, record); err != nil {
+			return fmt.Errorf("failed to reset interrupted post [GUID: %s]: %w", record.GUID, err)
+		}
+		log.Printf("Interrupted post was not found in post history, will retry [GUID: %s]", record.GUID)
+	}
+
+	if unchecked > 0 {
+		return fmt.Errorf("%d interrupted posts left unchecked: %w", unchecked, errPostHistoryUnavailable)
+	}
+	return nil
+}
+
+// wasPosted は投稿中のまま停止したエントリーのノートが投稿されていたかどうかを返します
+// 投稿履歴を確認できない場合は errPostHistoryUnavailable を返します
+// ただし interruptedPostMaxAge を過ぎたエントリーは、重複して投稿しないように投稿済みとみなします
+func (s *RSSFeedService) wasPosted(ctx context.Context, record *entity.PostRecord) (bool, error) {
+	var err error
+	if s.postHistory == nil {
+		err = errPostHistoryUnavailable
+	} else {
+		var posted bool
+		posted, err = s.postHistory.HasPosted(ctx, record.NoteText, record.UpdatedAt.Add(-postHistoryMargin))
+		if err == nil {
+			return posted, nil
+		}
+		if ctx.Err() != nil {
+			return false, ctx.Err()
+		}
+		err = fmt.Errorf("%w: %w", errPostHistoryUnavailable, err)
+	}
+
+	if time.Since(record.UpdatedAt) >= interruptedPostMaxAge {
+		log.Printf("Post history is unavailable for %v, assuming interrupted post was posted [GUID: %s]: %v", interruptedPostMaxAge, record.GUID, err)
+		return true, nil
+	}
+	return false, err
+}
+
+// unfinishedPosts は feedURL のエントリーのうち、投稿済みでない記録の状態をGUIDごとに返します
+// pending は投稿に失敗したエントリーや、RecoverPosts で pending に戻したエントリーです
+// posting は投稿中か、投稿中のまま停止して RecoverPosts で照合できていないエントリーです
+func (s *RSSFeedService) unfinishedPosts(ctx context.Context, feedURL string) map[string]entity.PostState {
+	unfinished := make(map[string]entity.PostState)
+	if s.postStates == nil {
+		return unfinished
+	}
+
+	records, err := s.postStates.ListUnfinishedPosts(ctx, feedURL)
+	if err != nil {
+		log.Printf("Failed to list unfinished posts [%s]: %v", feedURL, err)
+		return unfinished
+	}
+	for _, record := range records {
+		unfinished[record.GUID] = record.State
+	}
+	return unfinished
+}
+
+// markPending は投稿の対象として選んだエントリーを pending として記録します
+func (s *RSSFeedService) markPending(ctx context.Context, batch *feedBatch) {
+	if s.postStates == nil {
+		return
+	}
+	for _, entry := range batch.entries {
+		if err := s.postStates.SavePostState(ctx, entity.NewPostRecord(batch.setting.URL, entry)); err != nil {
+			log.Printf("Failed to save post state [GUID: %s]: %v", entry.GUID, err)
+		}
+	}
+}
+
+// postNote はノートを投稿し、エントリーを処理済みとして記録します
+// 投稿の状態を記録する場合は、投稿する前に posting を記録し、記録できなければ投稿しません
+// recorded は投稿済みの記録まで終えたかどうかで、false の場合は最新の公開日時を進めません
+func (s *RSSFeedService) postNote(ctx context.Context, feedURL string, entry *entity.FeedEntry, note *entity.Note) (posted, recorded bool) {
+	// 投稿した後に ctx が終了しても、再投稿しないように投稿済みの記録は続ける
+	recordCtx := context.WithoutCancel(ctx)
+
+	if s.postStates == nil {
+		if err := s.noteRepo.Post(ctx, note); err != nil {
+			log.Printf("Failed to post to Misskey [%s]: %v", entry.Title, err)
+			return false, false
+		}
+		if err := s.cacheRepo.MarkAsProcessed(recordCtx, feedURL, entry.GUID, entry.CanonicalKey); err != nil {
+			log.Printf("Failed to mark as processed [GUID: %s]: %v", entry.GUID, err)
+		}
+		return true, true
+	}
+
+	record := entity.NewPostRecord(feedURL, entry)
+	record.State = entity.PostStatePosting
+	record.NoteText = note.Text
+	if err := s.postStates.SavePostState(ctx, record); err != nil {
+		log.Printf("Failed to save post state, skipping post [%s]: %v", entry.Title, err)
+		return false, false
+	}
+
+	if err := s.noteRepo.Post(ctx, note); err != nil {
+		log.Printf("Failed to post to Misskey [%s]: %v", entry.Title, err)
+		// 停止のために中断した場合は投稿できたかどうかわからないため、posting のまま残して起動時に照合する
+		if ctx.Err() != nil {
+			return false, false
+		}
+		record.State = entity.PostStatePending
+		record.UpdatedAt = time.Now()
+		if err := s.postStates.SavePostState(ctx, record); err != nil {
+			log.Printf("Failed to save post state [GUID: %s]: %v", entry.GUID, err)
+		}
+		return false, false
+	}
+
+	if err := s.postStates.CompletePost(recordCtx, record); err != nil {
+		log.Printf("Failed to record post, will be reconciled on restart [GUID: %s]: %v", entry.GUID, err)
+		return true, false
+	}
+	return true, true
+}
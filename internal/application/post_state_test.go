@@ -0,0 +1,354 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"misskeyRSSbot/internal/domain/entity"
+	"misskeyRSSbot/internal/interfaces/config"
+)
+
+// mockPostStateRepository は mockCacheRepository に投稿の状態を記録します
+type mockPostStateRepository struct {
+	*mockCacheRepository
+	records     map[string]entity.PostRecord
+	saveErr     error
+	completeErr error
+
+	unfinishedCalls int
+}
+
+func newMockPostStateRepository() *mockPostStateRepository {
+	return &mockPostStateRepository{
+		mockCacheRepository: newMockCacheRepository(),
+		records:             make(map[string]entity.PostRecord),
+	}
+}
+
+func (m *mockPostStateRepository) SavePostState(ctx context.Context, record *entity.PostRecord) error {
+	if m.saveErr != nil {
+		return m.saveErr
+	}
+	m.records[feedKey(record.FeedURL, record.GUID)] = *record
+	return nil
+}
+
+func (m *mockPostStateRepository) CompletePost(ctx context.Context, record *entity.PostRecord) error {
+	if m.completeErr != nil {
+		return m.completeErr
+	}
+	posted := *record
+	posted.State = entity.PostStatePosted
+	m.records[feedKey(record.FeedURL, record.GUID)] = posted
+	m.MarkAsProcessed(ctx, record.FeedURL, record.GUID, record.CanonicalKey)
+	if record.Published.After(m.latestTime) {
+		m.latestTime = record.Published
+	}
+	return nil
+}
+
+func (m *mockPostStateRepository) ListPostStates(ctx context.Context, state entity.PostState) ([]*entity.PostRecord, error) {
+	var records []*entity.PostRecord
+	for _, record := range m.records {
+		if record.State == state {
+			records = append(records, &record)
+		}
+	}
+	return records, nil
+}
+
+func (m *mockPostStateRepository) ListUnfinishedPosts(ctx context.Context, feedURL string) ([]*entity.PostRecord, error) {
+	m.unfinishedCalls++
+	var records []*entity.PostRecord
+	for _, record := range m.records {
+		if record.FeedURL == feedURL && record.State != entity.PostStatePosted {
+			records = append(records, &record)
+		}
+	}
+	return records, nil
+}
+
+func (m *mockPostStateRepository) state(feedURL, guid string) entity.PostState {
+	return m.records[feedKey(feedURL, guid)].State
+}
+
+type mockPostHistoryRepository struct {
+	posted []*entity.Note
+	err    error
+	calls  int
+}
+
+func (m *mockPostHistoryRepository) HasPosted(ctx context.Context, text string, since time.Time) (bool, error) {
+	m.calls++
+	if m.err != nil {
+		return false, m.err
+	}
+	for _, note := range m.posted {
+		if strings.TrimSpace(note.Text) == strings.TrimSpace(text) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+const postStateFeedURL = "https://example.tld/rss"
+
+func postStateEntries(now time.Time) []*entity.FeedEntry {
+	return []*entity.FeedEntry{
+		entity.NewFeedEntry("Article 1", "https://example.tld/1", "Desc 1", now.Add(-time.Hour), "guid-1"),
+		entity.NewFeedEntry("Article 2", "https://example.tld/2", "Desc 2", now, "guid-2"),
+	}
+}
+
+func TestRSSFeedService_PostStates_RecordsPosted(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+
+	noteRepo := &mockNoteRepository{}
+	states := newMockPostStateRepository()
+	service := NewRSSFeedService(
+		&mockFeedRepository{entries: postStateEntries(now)}, noteRepo, states, nil,
+		WithFirstRunLatestOnly(false),
+		WithPostStates(states, &mockPostHistoryRepository{}),
+	)
+
+	if err := service.ProcessFeed(ctx, config.RSSSettings{URL: postStateFeedURL}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(noteRepo.posted) != 2 {
+		t.Fatalf("expected 2 notes posted, got %d", len(noteRepo.posted))
+	}
+	for _, guid := range []string{"guid-1", "guid-2"} {
+		if got := states.state(postStateFeedURL, guid); got != entity.PostStatePosted {
+			t.Errorf("%s: expected state posted, got %q", guid, got)
+		}
+		if !states.processedGUIDs[feedKey(postStateFeedURL, guid)] {
+			t.Errorf("%s: expected to be marked as processed", guid)
+		}
+	}
+	if record := states.records[feedKey(postStateFeedURL, "guid-2")]; record.NoteText != noteRepo.posted[1].Text {
+		t.Errorf("expected posted note text to be recorded, got %q", record.NoteText)
+	}
+	if !states.latestTime.Equal(now) {
+		t.Errorf("expected latest published time %v, got %v", now, states.latestTime)
+	}
+}
+
+func TestRSSFeedService_PostStates_PostFailure(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+
+	tests := []struct {
+		name      string
+		saveErr   error
+		postErr   error
+		wantPosts int
+		wantState entity.PostState
+	}{
+		{"post failure resets to pending", nil, errors.New("misskey unavailable"), 0, entity.PostStatePending},
+		{"state not saved skips post", errors.New("database locked"), nil, 0, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			noteRepo := &mockNoteRepository{err: tt.postErr}
+			states := newMockPostStateRepository()
+			states.saveErr = tt.saveErr
+			service := NewRSSFeedService(
+				&mockFeedRepository{entries: postStateEntries(now)}, noteRepo, states, nil,
+				WithPostStates(states, &mockPostHistoryRepository{}),
+			)
+
+			if err := service.ProcessFeed(ctx, config.RSSSettings{URL: postStateFeedURL}); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if len(noteRepo.posted) != tt.wantPosts {
+				t.Errorf("expected %d notes posted, got %d", tt.wantPosts, len(noteRepo.posted))
+			}
+			if got := states.state(postStateFeedURL, "guid-2"); got != tt.wantState {
+				t.Errorf("expected state %q, got %q", tt.wantState, got)
+			}
+			if states.processedGUIDs[feedKey(postStateFeedURL, "guid-2")] {
+				t.Error("expected entry not to be marked as processed")
+			}
+		})
+	}
+}
+
+// TestRSSFeedService_RecoverPosts は投稿したあと記録する前に停止した場合に、再起動しても再投稿しないことを確認します
+func TestRSSFeedService_RecoverPosts(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		name        string
+		history     *mockPostHistoryRepository
+		notPosted   bool
+		age         time.Duration
+		wantErr     bool
+		wantState   entity.PostState
+		wantReposts int
+	}{
+		{"found in history", &mockPostHistoryRepository{}, false, 0, false, entity.PostStatePosted, 0},
+		{"not found in history", &mockPostHistoryRepository{}, true, 0, false, entity.PostStatePending, 1},
+		{"history error keeps posting", &mockPostHistoryRepository{err: errors.New("timeout")}, false, 0, true, entity.PostStatePosting, 0},
+		{"no history keeps posting", nil, false, 0, true, entity.PostStatePosting, 0},
+		{"history error on an old post", &mockPostHistoryRepository{err: errors.New("timeout")}, false, interruptedPostMaxAge, false, entity.PostStatePosted, 0},
+		{"no history on an old post", nil, false, interruptedPostMaxAge, false, entity.PostStatePosted, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			feedRepo := &mockFeedRepository{entries: postStateEntries(now)}
+			states := newMockPostStateRepository()
+
+			// 投稿したあと投稿済みを記録する前に停止する
+			states.completeErr = errors.New("process killed")
+			crashed := &mockNoteRepository{}
+			service := NewRSSFeedService(feedRepo, crashed, states, nil, WithPostStates(states, nil))
+			if err := service.ProcessFeed(ctx, config.RSSSettings{URL: postStateFeedURL}); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			interrupted := states.records[feedKey(postStateFeedURL, "guid-2")]
+			if interrupted.State != entity.PostStatePosting {
+				t.Fatalf("expected interrupted post to stay posting, got %q", interrupted.State)
+			}
+			interrupted.UpdatedAt = interrupted.UpdatedAt.Add(-tt.age)
+			states.records[feedKey(postStateFeedURL, "guid-2")] = interrupted
+			states.completeErr = nil
+
+			if tt.history != nil && !tt.notPosted {
+				tt.history.posted = crashed.posted
+			}
+
+			restartOpt := WithPostStates(states, nil)
+			if tt.history != nil {
+				restartOpt = WithPostStates(states, tt.history)
+			}
+
+			noteRepo := &mockNoteRepository{}
+			restarted := NewRSSFeedService(feedRepo, noteRepo, states, nil, restartOpt)
+			for i := 0; i < 2; i++ {
+				err := restarted.RecoverPosts(ctx)
+				if tt.wantErr && !errors.Is(err, errPostHistoryUnavailable) {
+					t.Fatalf("expected post history error, got %v", err)
+				}
+				if !tt.wantErr && err != nil {
+					t.Fatalf("unexpected recovery error: %v", err)
+				}
+			}
+			if got := states.state(postStateFeedURL, "guid-2"); got != tt.wantState {
+				t.Errorf("expected state %q after recovery, got %q", tt.wantState, got)
+			}
+			// 照合できなかった投稿は次の RecoverPosts で照合し直す
+			wantCalls := 1
+			if tt.wantErr {
+				wantCalls = 2
+			}
+			if tt.history != nil && tt.history.calls != wantCalls {
+				t.Errorf("expected post history to be checked %d times, got %d", wantCalls, tt.history.calls)
+			}
+
+			if err := restarted.ProcessFeed(ctx, config.RSSSettings{URL: postStateFeedURL}); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(noteRepo.posted) != tt.wantReposts {
+				t.Errorf("expected %d reposts, got %d", tt.wantReposts, len(noteRepo.posted))
+			}
+		})
+	}
+}
+
+// TestRSSFeedService_PostStates_ListsUnfinishedPerFeed は投稿済みでない記録をフィードごとに1回だけ取得することを確認します
+func TestRSSFeedService_PostStates_ListsUnfinishedPerFeed(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+
+	states := newMockPostStateRepository()
+	other := entity.NewPostRecord("https://other.tld/rss", entity.NewFeedEntry("Other", "https://other.tld/1", "", now, "guid-2"))
+	states.records[feedKey(other.FeedURL, other.GUID)] = *other
+
+	noteRepo := &mockNoteRepository{}
+	service := NewRSSFeedService(
+		&mockFeedRepository{entries: postStateEntries(now)}, noteRepo, states, nil,
+		WithFirstRunLatestOnly(false),
+		WithPostStates(states, &mockPostHistoryRepository{}),
+	)
+	if err := service.ProcessFeed(ctx, config.RSSSettings{URL: postStateFeedURL}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if states.unfinishedCalls != 1 {
+		t.Errorf("expected unfinished posts to be listed once, got %d", states.unfinishedCalls)
+	}
+	// 他のフィードの pending の記録は、同じGUIDのエントリーの扱いに影響しない
+	if len(noteRepo.posted) != 2 {
+		t.Errorf("expected 2 notes posted, got %d", len(noteRepo.posted))
+	}
+}
+
+// TestRSSFeedService_RecoverPosts_RetriesPending は pending に戻したエントリーを、
+// 初回検出日時が記録済みの日時のないエントリーや、最新の公開日時より古いエントリーでも投稿し直すことを確認します
+func TestRSSFeedService_RecoverPosts_RetriesPending(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		name  string
+		entry *entity.FeedEntry
+	}{
+		{"undated entry", newUndatedEntry("Undated", "https://example.tld/undated", "guid-undated")},
+		{"entry behind the latest published time", entity.NewFeedEntry("Dated", "https://example.tld/dated", "Desc", now.Add(-2*time.Hour), "guid-dated")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			newer := entity.NewFeedEntry("Newer", "https://example.tld/newer", "Desc", now, "guid-newer")
+			feedRepo := &mockFeedRepository{entries: []*entity.FeedEntry{tt.entry, newer}}
+			states := newMockPostStateRepository()
+
+			// エントリーを投稿している間に停止した。その後の新しいエントリーは投稿済みで、最新の公開日時も進んでいる
+			states.latestTime = now
+			states.MarkAsProcessed(ctx, postStateFeedURL, newer.GUID, "")
+			if !tt.entry.HasFeedDate() {
+				if _, _, err := states.RecordFirstSeen(ctx, postStateFeedURL, tt.entry.GUID, now.Add(-time.Hour)); err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+			}
+			interrupted := entity.NewPostRecord(postStateFeedURL, tt.entry)
+			interrupted.State = entity.PostStatePosting
+			interrupted.NoteText = "interrupted note"
+			states.records[feedKey(postStateFeedURL, tt.entry.GUID)] = *interrupted
+
+			// 再起動したとき、投稿履歴に見つからないエントリーは pending に戻す
+			noteRepo := &mockNoteRepository{}
+			restarted := NewRSSFeedService(feedRepo, noteRepo, states, nil, WithPostStates(states, &mockPostHistoryRepository{}))
+			if err := restarted.RecoverPosts(ctx); err != nil {
+				t.Fatalf("unexpected recovery error: %v", err)
+			}
+			if got := states.state(postStateFeedURL, tt.entry.GUID); got != entity.PostStatePending {
+				t.Fatalf("expected interrupted post to be reset to pending, got %q", got)
+			}
+
+			for i := 0; i < 2; i++ {
+				if err := restarted.ProcessFeed(ctx, config.RSSSettings{URL: postStateFeedURL}); err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+			}
+			if len(noteRepo.posted) != 1 {
+				t.Fatalf("expected the pending entry to be posted once, got %d notes", len(noteRepo.posted))
+			}
+			if !strings.Contains(noteRepo.posted[0].Text, tt.entry.Title) {
+				t.Errorf("expected %q to be posted, got %q", tt.entry.Title, noteRepo.posted[0].Text)
+			}
+			if got := states.state(postStateFeedURL, tt.entry.GUID); got != entity.PostStatePosted {
+				t.Errorf("expected state posted after retry, got %q", got)
+			}
+		})
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"log"
 	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"misskeyRSSbot/internal/domain/entity"
@@ -18,7 +19,22 @@ type RSSFeedService struct {
 	noteRepo           repository.NoteRepository
 	cacheRepo          repository.CacheRepository
 	summarizerRepo     repository.SummarizerRepository
+	noteTemplate       *entity.NoteTemplate
+	usageTracker       *UsageTracker
+	canonicalResolver  repository.CanonicalURLResolver
+	crossFeedWindow    time.Duration
+	mergeSources       bool
+	clusterWindow      time.Duration
+	clusterMaxDistance int
+	embedder           repository.EmbeddingRepository
+	minSimilarity      float64
+	clusterMu          sync.Mutex
+	postedFingerprints []postedFingerprint
+	postStates         repository.PostStateRepository
+	postHistory        repository.PostHistoryRepository
 	firstRunLatestOnly bool
+	// processMu: ポーリングと WebSub の配信が同じエントリーを同時に投稿しないように処理を直列にする
+	processMu sync.Mutex
 }
 
 type RSSFeedServiceOption func(*RSSFeedService)
@@ -29,6 +45,25 @@ func WithFirstRunLatestOnly(enabled bool) RSSFeedServiceOption {
 	}
 }
 
+func WithNoteTemplate(tmpl *entity.NoteTemplate) RSSFeedServiceOption {
+	return func(s *RSSFeedService) {
+		s.noteTemplate = tmpl
+	}
+}
+
+func WithUsageTracker(tracker *UsageTracker) RSSFeedServiceOption {
+	return func(s *RSSFeedService) {
+		s.usageTracker = tracker
+	}
+}
+
+// WithCanonicalResolver は新しいエントリーの記事ページから rel=canonical を解決して重複判定に使います
+func WithCanonicalResolver(resolver repository.CanonicalURLResolver) RSSFeedServiceOption {
+	return func(s *RSSFeedService) {
+		s.canonicalResolver = resolver
+	}
+}
+
 func NewRSSFeedService(
 	feedRepo repository.FeedRepository,
 	noteRepo repository.NoteRepository,
@@ -49,34 +84,103 @@ func NewRSSFeedService(
 	return s
 }
 
+// feedBatch はフィードから取得した投稿対象のエントリーです
+type feedBatch struct {
+	setting    config.RSSSettings
+	entries    []*entity.FeedEntry
+	isFirstRun bool
+	// suppressedLatest: 他のフィードと重複して投稿しなかったエントリーの最新の公開日時
+	suppressedLatest time.Time
+	// fingerprints: クラスタリングで残したエントリーの特徴量（投稿後に以降の比較用として保持する）
+	fingerprints map[*entity.FeedEntry]*storyFingerprint
+	// duplicates: 残したエントリーごとの、まとめて投稿しない他のフィードのエントリー（投稿できた後に処理済みとして記録する）
+	duplicates map[*entity.FeedEntry][]duplicate
+}
+
 func (s *RSSFeedService) ProcessFeed(ctx context.Context, setting config.RSSSettings) error {
+	s.processMu.Lock()
+	defer s.processMu.Unlock()
+
+	batch, err := s.collectNewEntries(ctx, setting)
+	if err != nil {
+		return err
+	}
+	return s.processBatch(ctx, batch)
+}
+
+// ProcessEntries は WebSub で配信されたエントリーなど、取得済みのエントリーをフィードから取得したものと同じように投稿します
+func (s *RSSFeedService) ProcessEntries(ctx context.Context, setting config.RSSSettings, entries []*entity.FeedEntry) error {
+	s.processMu.Lock()
+	defer s.processMu.Unlock()
+
+	batch, err := s.newEntriesBatch(ctx, setting, entries)
+	if err != nil {
+		return err
+	}
+	return s.processBatch(ctx, batch)
+}
+
+func (s *RSSFeedService) processBatch(ctx context.Context, batch *feedBatch) error {
+	if batch == nil {
+		return nil
+	}
+
+	batches := []*feedBatch{batch}
+	s.suppressCrossFeedDuplicates(ctx, batches)
+	s.clusterStories(ctx, batches)
+	return s.postBatch(ctx, batch)
+}
+
+// collectNewEntries はフィードを取得して未投稿のエントリーを返します
+// 投稿対象がない場合は nil を返します
+func (s *RSSFeedService) collectNewEntries(ctx context.Context, setting config.RSSSettings) (*feedBatch, error) {
 	entries, err := s.feedRepo.Fetch(ctx, setting.URL)
 	if err != nil {
-		return fmt.Errorf("failed to fetch RSS feed [%s]: %w", setting.URL, err)
+		return nil, fmt.Errorf("failed to fetch RSS feed [%s]: %w", setting.URL, err)
 	}
+	return s.newEntriesBatch(ctx, setting, entries)
+}
 
+// newEntriesBatch は entries のうち未投稿のエントリーを返します
+// 投稿対象がない場合は nil を返します
+func (s *RSSFeedService) newEntriesBatch(ctx context.Context, setting config.RSSSettings, entries []*entity.FeedEntry) (*feedBatch, error) {
 	entries = filterByKeywords(entries, setting.Keywords)
 	log.Printf("Processing %d entries from %s", len(entries), setting.URL)
 
 	if len(entries) == 0 {
 		log.Printf("No entries found in RSS URL: %s", setting.URL)
-		return nil
+		return nil, nil
 	}
 
 	latestPublished, err := s.cacheRepo.GetLatestPublishedTime(ctx, setting.URL)
 	if err != nil {
-		return fmt.Errorf("failed to get latest published time: %w", err)
+		return nil, fmt.Errorf("failed to get latest published time: %w", err)
 	}
 
+	discovered := s.resolveUndatedEntries(ctx, setting.URL, entries)
+	unfinished := s.unfinishedPosts(ctx, setting.URL)
+
 	isFirstRun := latestPublished.IsZero()
-	newEntries := s.filterNewEntries(ctx, entries, latestPublished, isFirstRun)
+	newEntries := s.filterNewEntries(ctx, setting.URL, entries, latestPublished, isFirstRun, discovered, unfinished)
 
 	if len(newEntries) == 0 {
-		return nil
+		return nil, nil
 	}
 
 	sortEntriesByPublishedAsc(newEntries)
-	latestTime := s.postEntries(ctx, newEntries)
+	return &feedBatch{setting: setting, entries: newEntries, isFirstRun: isFirstRun}, nil
+}
+
+func (s *RSSFeedService) postBatch(ctx context.Context, batch *feedBatch) error {
+	setting := batch.setting
+	latestTime, latestFirstSeen := s.postEntries(ctx, batch)
+	if batch.suppressedLatest.After(latestTime) {
+		latestTime = batch.suppressedLatest
+	}
+	if latestTime.IsZero() && batch.isFirstRun {
+		// 日時のないエントリーしか投稿していない場合も、初回実行を終えたことを記録する
+		latestTime = latestFirstSeen
+	}
 
 	if !latestTime.IsZero() {
 		if err := s.cacheRepo.SaveLatestPublishedTime(ctx, setting.URL, latestTime); err != nil {
@@ -84,25 +188,64 @@ func (s *RSSFeedService) ProcessFeed(ctx context.Context, setting config.RSSSett
 		}
 	}
 
-	log.Printf("Processed %d new entries from RSS URL [%s]", len(newEntries), setting.URL)
+	log.Printf("Processed %d new entries from RSS URL [%s]", len(batch.entries), setting.URL)
 	return nil
 }
 
+// resolveUndatedEntries は日時のないエントリーの Published に初回検出日時を設定します
+// 戻り値は今回初めて検出したエントリーのGUIDです
+func (s *RSSFeedService) resolveUndatedEntries(ctx context.Context, feedURL string, entries []*entity.FeedEntry) map[string]bool {
+	discovered := make(map[string]bool)
+	now := time.Now()
+	for _, entry := range entries {
+		if entry.HasFeedDate() {
+			continue
+		}
+		firstSeen, isNew, err := s.cacheRepo.RecordFirstSeen(ctx, feedURL, entry.GUID, now)
+		if err != nil {
+			log.Printf("Failed to record first seen time [GUID: %s]: %v", entry.GUID, err)
+			firstSeen = now
+		}
+		entry.Published = firstSeen
+		if isNew {
+			discovered[entry.GUID] = true
+		}
+	}
+	return discovered
+}
+
+// filterNewEntries は entries のうち投稿の対象とするエントリーを返します
+// 投稿中のエントリーは、投稿できたかどうかを RecoverPosts で照合するまで投稿しません
 func (s *RSSFeedService) filterNewEntries(
 	ctx context.Context,
+	feedURL string,
 	entries []*entity.FeedEntry,
 	latestPublished time.Time,
 	isFirstRun bool,
+	discovered map[string]bool,
+	unfinished map[string]entity.PostState,
 ) []*entity.FeedEntry {
 	if isFirstRun && s.firstRunLatestOnly {
-		return s.findMostRecentEntry(entries)
+		latest := s.findMostRecentEntry(entries)
+		if len(latest) > 0 && unfinished[latest[0].GUID] == entity.PostStatePosting {
+			return nil
+		}
+		return latest
 	}
 
 	var newEntries []*entity.FeedEntry
+	seenKeys := make(map[string]bool)
 	for _, entry := range entries {
-		if s.shouldSkipEntry(ctx, entry, latestPublished, isFirstRun) {
+		if s.shouldSkipEntry(ctx, feedURL, entry, latestPublished, isFirstRun, discovered[entry.GUID], unfinished[entry.GUID]) {
 			continue
 		}
+		// 同じフィード内で正規化したキーが重複するエントリーは最初のものだけを投稿する
+		if entry.CanonicalKey != "" {
+			if seenKeys[entry.CanonicalKey] {
+				continue
+			}
+			seenKeys[entry.CanonicalKey] = true
+		}
 		newEntries = append(newEntries, entry)
 	}
 	return newEntries
@@ -113,8 +256,15 @@ func (s *RSSFeedService) findMostRecentEntry(entries []*entity.FeedEntry) []*ent
 		return nil
 	}
 
+	// 初回検出日時は常に新しく見えるため、フィードの日時を持つエントリーを優先する
 	mostRecent := entries[0]
 	for _, entry := range entries[1:] {
+		if entry.HasFeedDate() != mostRecent.HasFeedDate() {
+			if entry.HasFeedDate() {
+				mostRecent = entry
+			}
+			continue
+		}
 		if entry.Published.After(mostRecent.Published) {
 			mostRecent = entry
 		}
@@ -124,11 +274,14 @@ func (s *RSSFeedService) findMostRecentEntry(entries []*entity.FeedEntry) []*ent
 
 func (s *RSSFeedService) shouldSkipEntry(
 	ctx context.Context,
+	feedURL string,
 	entry *entity.FeedEntry,
 	latestPublished time.Time,
 	isFirstRun bool,
+	discovered bool,
+	unfinished entity.PostState,
 ) bool {
-	processed, err := s.cacheRepo.IsProcessed(ctx, entry.GUID)
+	processed, err := s.cacheRepo.IsProcessed(ctx, feedURL, entry.GUID, entry.CanonicalKey)
 	if err != nil {
 		log.Printf("Failed to check if processed [GUID: %s]: %v", entry.GUID, err)
 		return true
@@ -137,56 +290,167 @@ func (s *RSSFeedService) shouldSkipEntry(
 		return true
 	}
 
-	if !isFirstRun && !entry.IsNewerThan(latestPublished) {
+	if unfinished == entity.PostStatePosting {
 		return true
 	}
+	// 投稿できずに pending のまま残っているエントリーは、最新の公開日時や初回検出にかかわらず投稿し直す
+	if unfinished == entity.PostStatePending {
+		return s.isProcessedCanonical(ctx, feedURL, entry)
+	}
 
-	return false
+	// 日時のないエントリーは初回検出時のみ投稿し、重複の判定はGUIDで行う
+	if !entry.HasFeedDate() {
+		if !discovered {
+			return true
+		}
+	} else if !isFirstRun && !entry.IsNewerThan(latestPublished) {
+		return true
+	}
+
+	return s.isProcessedCanonical(ctx, feedURL, entry)
 }
 
-func (s *RSSFeedService) postEntries(ctx context.Context, entries []*entity.FeedEntry) time.Time {
-	var latestTime time.Time
+// isProcessedCanonical は rel=canonical で解決したURLが処理済みかどうかを返します
+// 解決したキーは entry.CanonicalKey に設定し、処理済みとして記録する際に使います
+func (s *RSSFeedService) isProcessedCanonical(ctx context.Context, feedURL string, entry *entity.FeedEntry) bool {
+	if s.canonicalResolver == nil || entry.Link == "" {
+		return false
+	}
 
-	for _, entry := range entries {
-		summary := s.summarizeEntry(ctx, entry)
+	resolved, err := s.canonicalResolver.ResolveCanonicalURL(ctx, entry.Link)
+	if err != nil {
+		log.Printf("Failed to resolve canonical URL [%s]: %v", entry.Link, err)
+		return false
+	}
+	key, ok := entity.CanonicalURL(resolved)
+	if !ok || key == entry.CanonicalKey {
+		return false
+	}
+	entry.CanonicalKey = key
+
+	processed, err := s.cacheRepo.IsProcessed(ctx, feedURL, entry.GUID, key)
+	if err != nil {
+		log.Printf("Failed to check if processed [GUID: %s]: %v", entry.GUID, err)
+		return true
+	}
+	return processed
+}
 
-		note := entity.NewNoteFromFeedWithSummary(entry, summary, entity.VisibilityHome)
-		if err := s.noteRepo.Post(ctx, note); err != nil {
-			log.Printf("Failed to post to Misskey [%s]: %v", entry.Title, err)
+func (s *RSSFeedService) postEntries(ctx context.Context, batch *feedBatch) (latestTime, latestFirstSeen time.Time) {
+	setting := batch.setting
+	s.markPending(ctx, batch)
+	for _, entry := range batch.entries {
+		// リーダーでなくなった場合や停止する場合は、残りのエントリーを投稿しない
+		if ctx.Err() != nil {
+			log.Printf("Stopped posting remaining entries [%s]: %v", setting.URL, context.Cause(ctx))
+			break
+		}
+
+		if setting.TagsAsHashtags {
+			entry.Tags = setting.Tags
+		}
+		summary := s.summarizeEntry(ctx, entry, setting.Summary)
+		if ctx.Err() != nil {
+			log.Printf("Stopped posting remaining entries [%s]: %v", setting.URL, context.Cause(ctx))
+			break
+		}
+
+		note := s.buildNote(entry, summary)
+		posted, recorded := s.postNote(ctx, setting.URL, entry, note)
+		if !posted {
 			continue
 		}
 
 		log.Printf("Posted to Misskey: %s", entry.Title)
 
-		if err := s.cacheRepo.MarkAsProcessed(ctx, entry.GUID); err != nil {
-			log.Printf("Failed to mark as processed [GUID: %s]: %v", entry.GUID, err)
+		s.saveRecentStory(context.WithoutCancel(ctx), setting.URL, entry)
+		s.suppressDuplicatesOf(context.WithoutCancel(ctx), batch, entry)
+		s.rememberStory(batch, entry)
+		if !recorded {
+			continue
 		}
 
+		// 初回検出日時はフィードの日時と比較できないため、最新の公開日時とは分けて返す
+		if !entry.HasFeedDate() {
+			if entry.Published.After(latestFirstSeen) {
+				latestFirstSeen = entry.Published
+			}
+			continue
+		}
 		if entry.Published.After(latestTime) {
 			latestTime = entry.Published
 		}
 	}
 
-	return latestTime
+	return latestTime, latestFirstSeen
 }
 
-func (s *RSSFeedService) summarizeEntry(ctx context.Context, entry *entity.FeedEntry) string {
-	if s.summarizerRepo == nil || !s.summarizerRepo.IsEnabled() {
-		return ""
+func (s *RSSFeedService) buildNote(entry *entity.FeedEntry, summary *entity.Summary) *entity.Note {
+	if s.noteTemplate == nil {
+		return entity.NewNoteFromFeedWithSummary(entry, summary, entity.VisibilityHome)
 	}
 
-	summary, err := s.summarizerRepo.Summarize(ctx, entry.Link, entry.Title)
+	note, err := s.noteTemplate.Render(entry, summary, entity.VisibilityHome)
+	if err != nil {
+		log.Printf("Failed to render note template [%s]: %v", entry.Title, err)
+		return entity.NewNoteFromFeedWithSummary(entry, summary, entity.VisibilityHome)
+	}
+	return note
+}
+
+func (s *RSSFeedService) summarizeEntry(
+	ctx context.Context,
+	entry *entity.FeedEntry,
+	settings config.SummarySettings,
+) *entity.Summary {
+	if settings.Disabled || s.summarizerRepo == nil || !s.summarizerRepo.IsEnabled() {
+		return nil
+	}
+	if s.usageTracker != nil && s.usageTracker.IsBudgetExceeded(ctx) {
+		log.Printf("Daily LLM budget exceeded, skipping summarization [%s]", entry.Title)
+		return nil
+	}
+
+	summary, err := s.summarizerRepo.Summarize(ctx, repository.SummarizeRequest{
+		URL:               entry.Link,
+		Title:             entry.Title,
+		SystemInstruction: settings.SystemInstruction,
+		Language:          settings.Language,
+		MaxLength:         settings.MaxLength,
+		Provider:          settings.Provider,
+		Model:             settings.Model,
+	})
 	if err != nil {
 		log.Printf("Failed to summarize [%s]: %v", entry.Title, err)
-		return ""
+		return nil
 	}
 	return summary
 }
 
+// ProcessAllFeeds はすべてのフィードから未投稿のエントリーを集めてから投稿します
+// フィードをまたいだ重複の判定を有効にしている場合は、優先度の高いフィードのエントリーを投稿します
 func (s *RSSFeedService) ProcessAllFeeds(ctx context.Context, rssSettings []config.RSSSettings) error {
+	s.processMu.Lock()
+	defer s.processMu.Unlock()
+
+	var batches []*feedBatch
 	for _, setting := range rssSettings {
-		if err := s.ProcessFeed(ctx, setting); err != nil {
+		batch, err := s.collectNewEntries(ctx, setting)
+		if err != nil {
 			log.Printf("Error processing feed %s: %v", setting.URL, err)
+			continue
+		}
+		if batch != nil {
+			batches = append(batches, batch)
+		}
+	}
+
+	s.suppressCrossFeedDuplicates(ctx, batches)
+	s.clusterStories(ctx, batches)
+
+	for _, batch := range batches {
+		if err := s.postBatch(ctx, batch); err != nil {
+			log.Printf("Error processing feed %s: %v", batch.setting.URL, err)
 		}
 	}
 	return nil
@@ -200,7 +464,7 @@ func filterByKeywords(entries []*entity.FeedEntry, keywords []string) []*entity.
 	var filtered []*entity.FeedEntry
 	for _, entry := range entries {
 		for _, k := range keywords {
-			if strings.Contains(entry.Title, k) || strings.Contains(entry.Description, k) {
+			if strings.Contains(entry.Title, k) || strings.Contains(entry.Description, k) || hasCategory(entry, k) {
 				filtered = append(filtered, entry)
 				break
 			}
@@ -209,6 +473,15 @@ func filterByKeywords(entries []*entity.FeedEntry, keywords []string) []*entity.
 	return filtered
 }
 
+func hasCategory(entry *entity.FeedEntry, category string) bool {
+	for _, c := range entry.Categories {
+		if c == category {
+			return true
+		}
+	}
+	return false
+}
+
 func sortEntriesByPublishedAsc(entries []*entity.FeedEntry) {
 	sort.Slice(entries, func(i, j int) bool {
 		return entries[i].Published.Before(entries[j].Published)
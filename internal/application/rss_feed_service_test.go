@@ -3,11 +3,15 @@ package application
 import (
 	"context"
 	"errors"
+	"io"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
 
 	"misskeyRSSbot/internal/domain/entity"
+	"misskeyRSSbot/internal/domain/repository"
+	"misskeyRSSbot/internal/infrastructure/storage"
 	"misskeyRSSbot/internal/interfaces/config"
 )
 
@@ -39,11 +43,17 @@ func (m *mockNoteRepository) Post(ctx context.Context, note *entity.Note) error
 type mockCacheRepository struct {
 	latestTime     time.Time
 	processedGUIDs map[string]bool
+	processedKeys  map[string]bool
+	firstSeen      map[string]time.Time
+	recentStories  map[string]map[string]time.Time
 }
 
 func newMockCacheRepository() *mockCacheRepository {
 	return &mockCacheRepository{
 		processedGUIDs: make(map[string]bool),
+		processedKeys:  make(map[string]bool),
+		recentStories:  make(map[string]map[string]time.Time),
+		firstSeen:      make(map[string]time.Time),
 	}
 }
 
@@ -56,26 +66,65 @@ func (m *mockCacheRepository) SaveLatestPublishedTime(ctx context.Context, rssUR
 	return nil
 }
 
-func (m *mockCacheRepository) IsProcessed(ctx context.Context, guid string) (bool, error) {
-	return m.processedGUIDs[guid], nil
+// feedKey はモックのキャッシュでフィードごとに処理済みの状態を記録するためのキーです
+func feedKey(feedURL, value string) string {
+	return feedURL + " " + value
 }
 
-func (m *mockCacheRepository) MarkAsProcessed(ctx context.Context, guid string) error {
-	m.processedGUIDs[guid] = true
+func (m *mockCacheRepository) IsProcessed(ctx context.Context, feedURL, guid, canonicalKey string) (bool, error) {
+	return m.processedGUIDs[feedKey(feedURL, guid)] || (canonicalKey != "" && m.processedKeys[feedKey(feedURL, canonicalKey)]), nil
+}
+
+func (m *mockCacheRepository) MarkAsProcessed(ctx context.Context, feedURL, guid, canonicalKey string) error {
+	m.processedGUIDs[feedKey(feedURL, guid)] = true
+	if canonicalKey != "" {
+		m.processedKeys[feedKey(feedURL, canonicalKey)] = true
+	}
+	return nil
+}
+
+func (m *mockCacheRepository) RecordFirstSeen(ctx context.Context, feedURL, guid string, seen time.Time) (time.Time, bool, error) {
+	if firstSeen, ok := m.firstSeen[feedKey(feedURL, guid)]; ok {
+		return firstSeen, false, nil
+	}
+	m.firstSeen[feedKey(feedURL, guid)] = seen
+	return seen, true, nil
+}
+
+func (m *mockCacheRepository) HasRecentStory(ctx context.Context, feedURL string, keys []string, since time.Time) (bool, error) {
+	for _, key := range keys {
+		for postedFeed, postedAt := range m.recentStories[key] {
+			if postedFeed != feedURL && !postedAt.Before(since) {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+func (m *mockCacheRepository) SaveRecentStory(ctx context.Context, feedURL string, keys []string, postedAt time.Time) error {
+	for _, key := range keys {
+		if m.recentStories[key] == nil {
+			m.recentStories[key] = make(map[string]time.Time)
+		}
+		m.recentStories[key][feedURL] = postedAt
+	}
 	return nil
 }
 
 type mockSummarizerRepository struct {
-	summary string
-	err     error
-	enabled bool
-	called  int
+	summary  *entity.Summary
+	err      error
+	enabled  bool
+	called   int
+	requests []repository.SummarizeRequest
 }
 
-func (m *mockSummarizerRepository) Summarize(ctx context.Context, url, title string) (string, error) {
+func (m *mockSummarizerRepository) Summarize(ctx context.Context, req repository.SummarizeRequest) (*entity.Summary, error) {
 	m.called++
+	m.requests = append(m.requests, req)
 	if m.err != nil {
-		return "", m.err
+		return nil, m.err
 	}
 	return m.summary, nil
 }
@@ -117,7 +166,7 @@ func TestRSSFeedService_ProcessFeed_NewEntries(t *testing.T) {
 		t.Errorf("expected most recent article (Article 3) to be posted first")
 	}
 
-	if !cacheRepo.processedGUIDs["guid-3"] {
+	if !cacheRepo.processedGUIDs[feedKey("https://example.tld/rss", "guid-3")] {
 		t.Errorf("GUID guid-3 was not marked as processed")
 	}
 }
@@ -134,7 +183,7 @@ func TestRSSFeedService_ProcessFeed_SkipProcessedEntries(t *testing.T) {
 	feedRepo := &mockFeedRepository{entries: entries}
 	noteRepo := &mockNoteRepository{}
 	cacheRepo := newMockCacheRepository()
-	cacheRepo.processedGUIDs["guid-1"] = true
+	cacheRepo.processedGUIDs[feedKey("https://example.tld/rss", "guid-1")] = true
 	cacheRepo.latestTime = now.Add(-2 * time.Hour)
 
 	service := NewRSSFeedService(feedRepo, noteRepo, cacheRepo, nil)
@@ -232,7 +281,7 @@ func TestRSSFeedService_ProcessFeed_WithSummarizer(t *testing.T) {
 	noteRepo := &mockNoteRepository{}
 	cacheRepo := newMockCacheRepository()
 	summarizerRepo := &mockSummarizerRepository{
-		summary: "これは要約されたテキストです。",
+		summary: entity.NewSummary("これは要約されたテキストです。"),
 		enabled: true,
 	}
 
@@ -273,7 +322,7 @@ func TestRSSFeedService_ProcessFeed_SummarizerDisabled(t *testing.T) {
 	noteRepo := &mockNoteRepository{}
 	cacheRepo := newMockCacheRepository()
 	summarizerRepo := &mockSummarizerRepository{
-		summary: "要約",
+		summary: entity.NewSummary("要約"),
 		enabled: false, // 無効
 	}
 
@@ -332,6 +381,203 @@ func TestRSSFeedService_ProcessFeed_SummarizerError(t *testing.T) {
 	}
 }
 
+func TestRSSFeedService_ProcessFeed_SummaryDisabledForFeed(t *testing.T) {
+	ctx := context.Background()
+
+	now := time.Now()
+	entries := []*entity.FeedEntry{
+		entity.NewFeedEntry("Article 1", "https://example.tld/1", "Description", now, "guid-1"),
+	}
+
+	feedRepo := &mockFeedRepository{entries: entries}
+	noteRepo := &mockNoteRepository{}
+	cacheRepo := newMockCacheRepository()
+	summarizerRepo := &mockSummarizerRepository{
+		summary: entity.NewSummary("要約"),
+		enabled: true,
+	}
+
+	service := NewRSSFeedService(feedRepo, noteRepo, cacheRepo, summarizerRepo)
+
+	setting := config.RSSSettings{
+		URL:     "https://example.tld/rss",
+		Summary: config.SummarySettings{Disabled: true},
+	}
+	if err := service.ProcessFeed(ctx, setting); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(noteRepo.posted) != 1 {
+		t.Fatalf("expected 1 note posted, got %d", len(noteRepo.posted))
+	}
+	if strings.Contains(noteRepo.posted[0].Text, "【要約】") {
+		t.Error("expected note not to contain summary when summarization is disabled for the feed")
+	}
+	if summarizerRepo.called != 0 {
+		t.Errorf("expected summarizer not to be called, got %d calls", summarizerRepo.called)
+	}
+}
+
+func TestRSSFeedService_ProcessFeed_SummarySettingsPassedToSummarizer(t *testing.T) {
+	ctx := context.Background()
+
+	now := time.Now()
+	entries := []*entity.FeedEntry{
+		entity.NewFeedEntry("Article 1", "https://example.tld/1", "Description", now, "guid-1"),
+	}
+
+	feedRepo := &mockFeedRepository{entries: entries}
+	noteRepo := &mockNoteRepository{}
+	cacheRepo := newMockCacheRepository()
+	summarizerRepo := &mockSummarizerRepository{
+		summary: entity.NewSummary("summary"),
+		enabled: true,
+	}
+
+	service := NewRSSFeedService(feedRepo, noteRepo, cacheRepo, summarizerRepo)
+
+	setting := config.RSSSettings{
+		URL: "https://example.tld/rss",
+		Summary: config.SummarySettings{
+			SystemInstruction: "custom prompt",
+			Language:          "English",
+			MaxLength:         200,
+			Provider:          "bedrock",
+			Model:             "custom-model",
+		},
+	}
+	if err := service.ProcessFeed(ctx, setting); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(summarizerRepo.requests) != 1 {
+		t.Fatalf("expected 1 summarize request, got %d", len(summarizerRepo.requests))
+	}
+
+	expected := repository.SummarizeRequest{
+		URL:               "https://example.tld/1",
+		Title:             "Article 1",
+		SystemInstruction: "custom prompt",
+		Language:          "English",
+		MaxLength:         200,
+		Provider:          "bedrock",
+		Model:             "custom-model",
+	}
+	if summarizerRepo.requests[0] != expected {
+		t.Errorf("expected request %+v, got %+v", expected, summarizerRepo.requests[0])
+	}
+}
+
+func TestRSSFeedService_ProcessFeed_StructuredSummary(t *testing.T) {
+	ctx := context.Background()
+
+	now := time.Now()
+	entries := []*entity.FeedEntry{
+		entity.NewFeedEntry("Article 1", "https://example.tld/1", "Description", now, "guid-1"),
+	}
+
+	testCases := []struct {
+		name     string
+		template string
+		wantText string
+	}{
+		{
+			name:     "default note format",
+			wantText: "📰 Article 1\n\n【要約】\n要約\n\nhttps://example.tld/1\n#Go #RSS",
+		},
+		{
+			name:     "note template",
+			template: "{{.Headline}}\n{{.Summary}}\n{{.Link}} {{.Hashtags}}",
+			wantText: "記事1\n要約\nhttps://example.tld/1 #Go #RSS",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			noteRepo := &mockNoteRepository{}
+			summarizerRepo := &mockSummarizerRepository{
+				summary: &entity.Summary{
+					Text:           "要約",
+					Hashtags:       []string{"Go", "#RSS"},
+					Headline:       "記事1",
+					ContentWarning: "ネタバレ",
+				},
+				enabled: true,
+			}
+
+			var opts []RSSFeedServiceOption
+			if tc.template != "" {
+				tmpl, err := entity.NewNoteTemplate(tc.template)
+				if err != nil {
+					t.Fatalf("failed to parse template: %v", err)
+				}
+				opts = append(opts, WithNoteTemplate(tmpl))
+			}
+
+			service := NewRSSFeedService(&mockFeedRepository{entries: entries}, noteRepo, newMockCacheRepository(), summarizerRepo, opts...)
+			if err := service.ProcessFeed(ctx, config.RSSSettings{URL: "https://example.tld/rss"}); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if len(noteRepo.posted) != 1 {
+				t.Fatalf("expected 1 note posted, got %d", len(noteRepo.posted))
+			}
+			if noteRepo.posted[0].Text != tc.wantText {
+				t.Errorf("expected text %q, got %q", tc.wantText, noteRepo.posted[0].Text)
+			}
+			if noteRepo.posted[0].CW != "ネタバレ" {
+				t.Errorf("expected CW 'ネタバレ', got %q", noteRepo.posted[0].CW)
+			}
+		})
+	}
+}
+
+func TestRSSFeedService_ProcessFeed_TagsAsHashtags(t *testing.T) {
+	ctx := context.Background()
+
+	testCases := []struct {
+		name           string
+		tagsAsHashtags bool
+		wantText       string
+	}{
+		{
+			name:     "tags are not hashtags by default",
+			wantText: "📰 Article 1\nhttps://example.tld/1",
+		},
+		{
+			name:           "tags as hashtags",
+			tagsAsHashtags: true,
+			wantText:       "📰 Article 1\nhttps://example.tld/1\n#Tech #Go",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			entries := []*entity.FeedEntry{
+				entity.NewFeedEntry("Article 1", "https://example.tld/1", "Description", time.Now(), "guid-1"),
+			}
+			noteRepo := &mockNoteRepository{}
+			service := NewRSSFeedService(&mockFeedRepository{entries: entries}, noteRepo, newMockCacheRepository(), nil)
+
+			setting := config.RSSSettings{
+				URL:            "https://example.tld/rss",
+				Tags:           []string{"Tech", "Go"},
+				TagsAsHashtags: tc.tagsAsHashtags,
+			}
+			if err := service.ProcessFeed(ctx, setting); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if len(noteRepo.posted) != 1 {
+				t.Fatalf("expected 1 note posted, got %d", len(noteRepo.posted))
+			}
+			if noteRepo.posted[0].Text != tc.wantText {
+				t.Errorf("expected text %q, got %q", tc.wantText, noteRepo.posted[0].Text)
+			}
+		})
+	}
+}
+
 func TestRSSFeedService_ProcessFeed_FirstRunLatestOnlyEnabled(t *testing.T) {
 	ctx := context.Background()
 
@@ -401,7 +647,7 @@ func TestRSSFeedService_ProcessFeed_FirstRunLatestOnlyDisabled_SkipProcessed(t *
 	feedRepo := &mockFeedRepository{entries: entries}
 	noteRepo := &mockNoteRepository{}
 	cacheRepo := newMockCacheRepository()
-	cacheRepo.processedGUIDs["guid-1"] = true
+	cacheRepo.processedGUIDs[feedKey("https://example.tld/rss", "guid-1")] = true
 
 	service := NewRSSFeedService(feedRepo, noteRepo, cacheRepo, nil, WithFirstRunLatestOnly(false))
 
@@ -415,6 +661,247 @@ func TestRSSFeedService_ProcessFeed_FirstRunLatestOnlyDisabled_SkipProcessed(t *
 	}
 }
 
+func TestRSSFeedService_ProcessAllFeeds_ProcessedScopedByFeed(t *testing.T) {
+	ctx := context.Background()
+
+	now := time.Now()
+	feedRepo := &mockFeedsByURL{entries: map[string][]*entity.FeedEntry{
+		"https://a.tld/rss": {entity.NewFeedEntry("Article A", "https://a.tld/1", "Desc", now, "1")},
+		"https://b.tld/rss": {entity.NewFeedEntry("Article B", "https://b.tld/1", "Desc", now, "1")},
+	}}
+	noteRepo := &mockNoteRepository{}
+	cacheRepo := newMockCacheRepository()
+	cacheRepo.latestTime = now.Add(-1 * time.Hour)
+	cacheRepo.processedGUIDs[feedKey("https://c.tld/rss", "1")] = true
+
+	service := NewRSSFeedService(feedRepo, noteRepo, cacheRepo, nil)
+	err := service.ProcessAllFeeds(ctx, []config.RSSSettings{{URL: "https://a.tld/rss"}, {URL: "https://b.tld/rss"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// 同じGUIDでも別のフィードのエントリーはそれぞれ投稿する
+	if len(noteRepo.posted) != 2 {
+		t.Fatalf("expected 2 notes posted, got %d", len(noteRepo.posted))
+	}
+	if !cacheRepo.processedGUIDs[feedKey("https://a.tld/rss", "1")] || !cacheRepo.processedGUIDs[feedKey("https://b.tld/rss", "1")] {
+		t.Error("expected GUID to be marked as processed for each feed")
+	}
+}
+
+type mockCanonicalResolver struct {
+	canonical map[string]string
+	calls     int
+}
+
+func (m *mockCanonicalResolver) ResolveCanonicalURL(ctx context.Context, url string) (string, error) {
+	m.calls++
+	if canonical, ok := m.canonical[url]; ok {
+		return canonical, nil
+	}
+	return "", errors.New("not found")
+}
+
+func TestRSSFeedService_ProcessFeed_SkipCanonicalDuplicates(t *testing.T) {
+	ctx := context.Background()
+
+	now := time.Now()
+	feedRepo := &mockFeedRepository{entries: []*entity.FeedEntry{
+		entity.NewFeedEntry("Article", "https://example.tld/1?utm_source=rss", "Desc", now, "http://example.tld/1/?utm_source=rss"),
+	}}
+	noteRepo := &mockNoteRepository{}
+	cacheRepo := newMockCacheRepository()
+	cacheRepo.latestTime = now.Add(-2 * time.Hour)
+	cacheRepo.processedGUIDs[feedKey("https://example.tld/rss", "https://example.tld/1")] = true
+	cacheRepo.processedKeys[feedKey("https://example.tld/rss", "https://example.tld/1")] = true
+
+	service := NewRSSFeedService(feedRepo, noteRepo, cacheRepo, nil)
+	if err := service.ProcessFeed(ctx, config.RSSSettings{URL: "https://example.tld/rss"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(noteRepo.posted) != 0 {
+		t.Errorf("expected duplicate with tracking parameters to be skipped, got %d notes", len(noteRepo.posted))
+	}
+}
+
+func TestRSSFeedService_ProcessFeed_WithCanonicalResolver(t *testing.T) {
+	ctx := context.Background()
+
+	now := time.Now()
+	feedRepo := &mockFeedRepository{entries: []*entity.FeedEntry{
+		entity.NewFeedEntry("Old", "https://feeds.example.tld/r/1", "Desc", now.Add(-1*time.Hour), "guid-1"),
+		entity.NewFeedEntry("Duplicate", "https://feeds.example.tld/r/2", "Desc", now, "guid-2"),
+	}}
+	noteRepo := &mockNoteRepository{}
+	cacheRepo := newMockCacheRepository()
+	cacheRepo.latestTime = now.Add(-2 * time.Hour)
+	resolver := &mockCanonicalResolver{canonical: map[string]string{
+		"https://feeds.example.tld/r/1": "https://example.tld/news/1",
+		"https://feeds.example.tld/r/2": "http://example.tld/news/1/",
+	}}
+
+	service := NewRSSFeedService(feedRepo, noteRepo, cacheRepo, nil, WithCanonicalResolver(resolver))
+	if err := service.ProcessFeed(ctx, config.RSSSettings{URL: "https://example.tld/rss"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(noteRepo.posted) != 1 {
+		t.Fatalf("expected 1 note posted, got %d", len(noteRepo.posted))
+	}
+	if !cacheRepo.processedKeys[feedKey("https://example.tld/rss", "https://example.tld/news/1")] {
+		t.Error("expected resolved canonical key to be stored")
+	}
+
+	// 処理済みのGUIDは再度解決しない
+	calls := resolver.calls
+	if err := service.ProcessFeed(ctx, config.RSSSettings{URL: "https://example.tld/rss"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resolver.calls != calls {
+		t.Errorf("expected no resolver calls for processed entries, got %d", resolver.calls-calls)
+	}
+}
+
+func newUndatedEntry(title, link, guid string) *entity.FeedEntry {
+	entry := entity.NewFeedEntry(title, link, "Desc", time.Time{}, guid)
+	entry.DateSource = entity.DateSourceFirstSeen
+	return entry
+}
+
+func TestRSSFeedService_ProcessFeed_UndatedEntriesPostedOnDiscovery(t *testing.T) {
+	ctx := context.Background()
+
+	now := time.Now()
+	feedRepo := &mockFeedRepository{entries: []*entity.FeedEntry{
+		entity.NewFeedEntry("Dated", "https://example.tld/1", "Desc", now.Add(-1*time.Hour), "guid-1"),
+		newUndatedEntry("Undated 1", "https://example.tld/2", "guid-2"),
+	}}
+	noteRepo := &mockNoteRepository{}
+	cacheRepo := newMockCacheRepository()
+	cacheRepo.latestTime = now.Add(-2 * time.Hour)
+
+	service := NewRSSFeedService(feedRepo, noteRepo, cacheRepo, nil)
+	setting := config.RSSSettings{URL: "https://example.tld/rss"}
+
+	if err := service.ProcessFeed(ctx, setting); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(noteRepo.posted) != 2 {
+		t.Fatalf("expected 2 notes posted, got %d", len(noteRepo.posted))
+	}
+	if !cacheRepo.latestTime.Equal(now.Add(-1 * time.Hour)) {
+		t.Errorf("expected latest time to ignore first seen time, got %v", cacheRepo.latestTime)
+	}
+
+	// 2回目は投稿済みのエントリーを再投稿せず、新しく現れた日時のないエントリーのみ投稿する
+	feedRepo.entries = []*entity.FeedEntry{
+		entity.NewFeedEntry("Dated", "https://example.tld/1", "Desc", now.Add(-1*time.Hour), "guid-1"),
+		newUndatedEntry("Undated 1", "https://example.tld/2", "guid-2"),
+		newUndatedEntry("Undated 2", "https://example.tld/3", "guid-3"),
+	}
+	if err := service.ProcessFeed(ctx, setting); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(noteRepo.posted) != 3 {
+		t.Fatalf("expected 3 notes posted in total, got %d", len(noteRepo.posted))
+	}
+	if !strings.Contains(noteRepo.posted[2].Text, "Undated 2") {
+		t.Errorf("expected Undated 2 to be posted, got %q", noteRepo.posted[2].Text)
+	}
+}
+
+// 保持期間の掃除の後も、フィードに残っている日時のないエントリーを再び投稿しないことを確認します
+func TestRSSFeedService_ProcessFeed_UndatedEntryNotRepostedAfterCleanup(t *testing.T) {
+	tests := []struct {
+		name     string
+		newCache func(t *testing.T) repository.CacheRepository
+		// gap は記録の日時の精度より長い、取得の間隔です（SQLite は秒単位で記録する）
+		gap time.Duration
+	}{
+		{"sqlite", func(t *testing.T) repository.CacheRepository {
+			cache, err := storage.NewSQLiteCacheRepository(filepath.Join(t.TempDir(), "cache.db"))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			t.Cleanup(func() { cache.(io.Closer).Close() })
+			return cache
+		}, 1100 * time.Millisecond},
+		{"memory", func(t *testing.T) repository.CacheRepository {
+			return storage.NewMemoryCacheRepository(0)
+		}, 10 * time.Millisecond},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+
+			feedRepo := &mockFeedRepository{entries: []*entity.FeedEntry{
+				newUndatedEntry("Undated", "https://example.tld/1", "guid-1"),
+			}}
+			noteRepo := &mockNoteRepository{}
+			cacheRepo := tt.newCache(t)
+			cleaner := cacheRepo.(interface {
+				CleanupOldGUIDs(ctx context.Context, olderThan time.Duration) (int64, error)
+			})
+
+			service := NewRSSFeedService(feedRepo, noteRepo, cacheRepo, nil)
+			setting := config.RSSSettings{URL: "https://example.tld/rss"}
+
+			if err := service.ProcessFeed(ctx, setting); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(noteRepo.posted) != 1 {
+				t.Fatalf("expected 1 note posted, got %d", len(noteRepo.posted))
+			}
+
+			// 初回検出と処理済みの記録は保持期間より古くなるが、エントリーはその後の取得でもフィードに残っている
+			time.Sleep(tt.gap)
+			cutoff := time.Now()
+			if err := service.ProcessFeed(ctx, setting); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if _, err := cleaner.CleanupOldGUIDs(ctx, time.Since(cutoff)); err != nil {
+				t.Fatalf("cleanup failed: %v", err)
+			}
+
+			if err := service.ProcessFeed(ctx, setting); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(noteRepo.posted) != 1 {
+				t.Errorf("expected the undated entry not to be posted again after cleanup, got %d notes", len(noteRepo.posted))
+			}
+		})
+	}
+}
+
+func TestRSSFeedService_ProcessFeed_FirstRunUndatedOnly(t *testing.T) {
+	ctx := context.Background()
+
+	feedRepo := &mockFeedRepository{entries: []*entity.FeedEntry{
+		newUndatedEntry("Undated 1", "https://example.tld/1", "guid-1"),
+		newUndatedEntry("Undated 2", "https://example.tld/2", "guid-2"),
+	}}
+	noteRepo := &mockNoteRepository{}
+	cacheRepo := newMockCacheRepository()
+
+	service := NewRSSFeedService(feedRepo, noteRepo, cacheRepo, nil, WithFirstRunLatestOnly(true))
+	setting := config.RSSSettings{URL: "https://example.tld/rss"}
+
+	for i := 0; i < 2; i++ {
+		if err := service.ProcessFeed(ctx, setting); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	if len(noteRepo.posted) != 1 {
+		t.Fatalf("expected 1 note posted across runs, got %d", len(noteRepo.posted))
+	}
+	if cacheRepo.latestTime.IsZero() {
+		t.Error("expected first run to be recorded")
+	}
+}
+
 func TestFilterByKeywords_MatchesTitle(t *testing.T) {
 	now := time.Now()
 	entries := []*entity.FeedEntry{
@@ -449,6 +936,25 @@ func TestFilterByKeywords_MatchesDescription(t *testing.T) {
 	}
 }
 
+func TestFilterByKeywords_MatchesCategory(t *testing.T) {
+	now := time.Now()
+	tagged := entity.NewFeedEntry("新番組のお知らせ", "https://example.tld/1", "詳細はリンク先", now, "guid-1")
+	tagged.Categories = []string{"マユリカ"}
+	entries := []*entity.FeedEntry{
+		tagged,
+		entity.NewFeedEntry("別の記事", "https://example.tld/2", "全く関係ない内容", now, "guid-2"),
+	}
+
+	filtered := filterByKeywords(entries, []string{"マユリカ"})
+
+	if len(filtered) != 1 {
+		t.Fatalf("expected 1 entry, got %d", len(filtered))
+	}
+	if filtered[0].GUID != "guid-1" {
+		t.Errorf("expected guid-1, got '%s'", filtered[0].GUID)
+	}
+}
+
 func TestFilterByKeywords_NoKeywordsReturnsAll(t *testing.T) {
 	now := time.Now()
 	entries := []*entity.FeedEntry{
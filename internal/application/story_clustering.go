@@ -0,0 +1,236 @@
+package application
+
+import (
+	"context"
+	"log"
+	"sort"
+	"time"
+
+	"misskeyRSSbot/internal/domain/entity"
+	"misskeyRSSbot/internal/domain/repository"
+)
+
+// minClusterTextRunes より短いテキストは特徴が少なく誤判定しやすいため、クラスタリングの対象にしません
+const minClusterTextRunes = 20
+
+// WithStoryClustering はタイトルと概要が似ている記事をまとめて1つのノートとして投稿します
+// window の間に公開された他のフィードの記事のうち、SimHash のハミング距離が maxDistance 以下のものを同じ記事として扱います
+// 投稿済みの記事の特徴量はメモリにのみ保持するため、再起動すると以前に投稿した記事とは比較しません
+func WithStoryClustering(window time.Duration, maxDistance int) RSSFeedServiceOption {
+	return func(s *RSSFeedService) {
+		s.clusterWindow = window
+		s.clusterMaxDistance = maxDistance
+	}
+}
+
+// WithStoryEmbeddings はクラスタリングに SimHash の代わりに埋め込みベクトルのコサイン類似度を使います
+// 埋め込みの生成に失敗した場合は SimHash で判定します
+func WithStoryEmbeddings(embedder repository.EmbeddingRepository, minSimilarity float64) RSSFeedServiceOption {
+	return func(s *RSSFeedService) {
+		s.embedder = embedder
+		s.minSimilarity = minSimilarity
+	}
+}
+
+// storyFingerprint は近似重複の判定に使うエントリーの特徴量です
+type storyFingerprint struct {
+	feedURL   string
+	simHash   uint64
+	embedding []float32
+	published time.Time
+}
+
+type clusterItem struct {
+	batch       *feedBatch
+	entry       *entity.FeedEntry
+	fingerprint *storyFingerprint
+}
+
+// clusterStories は他のフィードの似ている記事をまとめ、優先度の高いフィードのエントリーだけを投稿対象に残します
+// まとめたエントリーのリンクは残したエントリーの OtherSources に追加し、残したエントリーを投稿できた後に処理済みとして記録します
+// 最近投稿した記事と似ているエントリーは、すぐに処理済みとして記録します
+func (s *RSSFeedService) clusterStories(ctx context.Context, batches []*feedBatch) {
+	if s.clusterWindow <= 0 || len(batches) == 0 {
+		return
+	}
+
+	items := s.fingerprintEntries(ctx, orderByPriority(batches))
+	recent := s.recentFingerprints(time.Now().Add(-s.clusterWindow))
+
+	var representatives []*clusterItem
+	suppressed := make(map[*entity.FeedEntry]bool)
+	for _, item := range items {
+		if item.fingerprint == nil {
+			continue
+		}
+		if s.matchesRecent(item.fingerprint, recent) {
+			log.Printf("Skipping story similar to a recently posted one [%s]", item.entry.Title)
+			s.markSuppressed(ctx, item.batch, item.entry)
+			suppressed[item.entry] = true
+			continue
+		}
+		if winner := s.findRepresentative(item, representatives); winner != nil {
+			log.Printf("Clustering %s into %s [%s]", item.batch.setting.URL, winner.batch.setting.URL, item.entry.Title)
+			winner.entry.OtherSources = appendSource(winner.entry.OtherSources, winner.entry, item.entry)
+			s.deferSuppressed(ctx, winner.batch, winner.entry, item.batch, item.entry)
+			suppressed[item.entry] = true
+			continue
+		}
+		representatives = append(representatives, item)
+		item.batch.fingerprints[item.entry] = item.fingerprint
+	}
+
+	for _, batch := range batches {
+		kept := batch.entries[:0]
+		for _, entry := range batch.entries {
+			if !suppressed[entry] {
+				kept = append(kept, entry)
+			}
+		}
+		batch.entries = kept
+	}
+}
+
+// fingerprintEntries はすべてのエントリーの特徴量を計算します
+// テキストが短すぎるエントリーの特徴量は nil になります
+func (s *RSSFeedService) fingerprintEntries(ctx context.Context, batches []*feedBatch) []*clusterItem {
+	var items []*clusterItem
+	var texts []string
+	for _, batch := range batches {
+		if batch.fingerprints == nil {
+			batch.fingerprints = make(map[*entity.FeedEntry]*storyFingerprint)
+		}
+		for _, entry := range batch.entries {
+			item := &clusterItem{batch: batch, entry: entry}
+			text := entity.StoryText(entry)
+			if len([]rune(entity.NormalizeTitle(text))) >= minClusterTextRunes {
+				item.fingerprint = &storyFingerprint{
+					feedURL:   batch.setting.URL,
+					simHash:   entity.SimHash(text),
+					published: entry.Published,
+				}
+				texts = append(texts, text)
+			}
+			items = append(items, item)
+		}
+	}
+
+	if s.embedder == nil || len(texts) == 0 {
+		return items
+	}
+	if s.usageTracker != nil && s.usageTracker.IsBudgetExceeded(ctx) {
+		log.Printf("Daily LLM budget exceeded, clustering stories with SimHash")
+		return items
+	}
+	embeddings, err := s.embedder.Embed(ctx, texts)
+	if err != nil || len(embeddings) != len(texts) {
+		log.Printf("Failed to embed stories, falling back to SimHash: %v", err)
+		return items
+	}
+	i := 0
+	for _, item := range items {
+		if item.fingerprint != nil {
+			item.fingerprint.embedding = embeddings[i]
+			i++
+		}
+	}
+	return items
+}
+
+func (s *RSSFeedService) findRepresentative(item *clusterItem, representatives []*clusterItem) *clusterItem {
+	for _, rep := range representatives {
+		if rep.batch == item.batch {
+			continue
+		}
+		if absDuration(rep.fingerprint.published.Sub(item.fingerprint.published)) > s.clusterWindow {
+			continue
+		}
+		if s.isSimilar(rep.fingerprint, item.fingerprint) {
+			return rep
+		}
+	}
+	return nil
+}
+
+func (s *RSSFeedService) matchesRecent(fingerprint *storyFingerprint, recent []*storyFingerprint) bool {
+	for _, posted := range recent {
+		if posted.feedURL != fingerprint.feedURL && s.isSimilar(posted, fingerprint) {
+			return true
+		}
+	}
+	return false
+}
+
+// isSimilar は両方に埋め込みベクトルがあればコサイン類似度で、なければ SimHash のハミング距離で判定します
+func (s *RSSFeedService) isSimilar(a, b *storyFingerprint) bool {
+	if a.embedding != nil && b.embedding != nil {
+		return entity.CosineSimilarity(a.embedding, b.embedding) >= s.minSimilarity
+	}
+	return entity.HammingDistance(a.simHash, b.simHash) <= s.clusterMaxDistance
+}
+
+// recentFingerprints は since 以降に投稿した記事の特徴量を返し、それより古いものを破棄します
+func (s *RSSFeedService) recentFingerprints(since time.Time) []*storyFingerprint {
+	s.clusterMu.Lock()
+	defer s.clusterMu.Unlock()
+
+	kept := s.postedFingerprints[:0]
+	for _, posted := range s.postedFingerprints {
+		if posted.postedAt.After(since) {
+			kept = append(kept, posted)
+		}
+	}
+	s.postedFingerprints = kept
+
+	fingerprints := make([]*storyFingerprint, 0, len(kept))
+	for _, posted := range kept {
+		fingerprints = append(fingerprints, posted.fingerprint)
+	}
+	return fingerprints
+}
+
+// rememberStory は投稿したエントリーの特徴量を以降の実行での比較用に保持します
+func (s *RSSFeedService) rememberStory(batch *feedBatch, entry *entity.FeedEntry) {
+	fingerprint, ok := batch.fingerprints[entry]
+	if !ok || s.clusterWindow <= 0 {
+		return
+	}
+
+	s.clusterMu.Lock()
+	defer s.clusterMu.Unlock()
+	s.postedFingerprints = append(s.postedFingerprints, postedFingerprint{fingerprint: fingerprint, postedAt: time.Now()})
+}
+
+type postedFingerprint struct {
+	fingerprint *storyFingerprint
+	postedAt    time.Time
+}
+
+// appendSource は重複しないように source のリンクを追加します
+func appendSource(sources []string, winner, source *entity.FeedEntry) []string {
+	if source.Link == "" || source.Link == winner.Link {
+		return sources
+	}
+	for _, existing := range sources {
+		if existing == source.Link {
+			return sources
+		}
+	}
+	return append(sources, source.Link)
+}
+
+// orderByPriority は優先度の高い順（同じ優先度なら設定順）に並べたバッチを返します
+func orderByPriority(batches []*feedBatch) []*feedBatch {
+	ordered := append([]*feedBatch(nil), batches...)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return ordered[i].setting.Priority > ordered[j].setting.Priority
+	})
+	return ordered
+}
+
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
@@ -0,0 +1,213 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"misskeyRSSbot/internal/domain/entity"
+	"misskeyRSSbot/internal/interfaces/config"
+)
+
+type mockEmbedder struct {
+	vectors map[string][]float32
+	err     error
+	calls   int
+}
+
+func (m *mockEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	m.calls++
+	if m.err != nil {
+		return nil, m.err
+	}
+	vectors := make([][]float32, 0, len(texts))
+	for _, text := range texts {
+		var vector []float32
+		for prefix, v := range m.vectors {
+			if strings.HasPrefix(text, prefix) {
+				vector = v
+			}
+		}
+		if vector == nil {
+			vector = []float32{0, 0, 1}
+		}
+		vectors = append(vectors, vector)
+	}
+	return vectors, nil
+}
+
+func postedTexts(noteRepo *mockNoteRepository) []string {
+	var texts []string
+	for _, note := range noteRepo.posted {
+		texts = append(texts, note.Text)
+	}
+	return texts
+}
+
+func TestRSSFeedService_ProcessAllFeeds_StoryClustering(t *testing.T) {
+	ctx := context.Background()
+
+	now := time.Now()
+	feedRepo := &mockFeedsByURL{entries: map[string][]*entity.FeedEntry{
+		"https://a.tld/rss": {
+			entity.NewFeedEntry("東京都が来年度から高校授業料を完全無償化 所得制限を撤廃へ", "https://a.tld/1", "", now, "a-1"),
+		},
+		"https://b.tld/rss": {
+			entity.NewFeedEntry("東京都、来年度から高校授業料を完全無償化へ 所得制限を撤廃", "https://b.tld/1", "", now, "b-1"),
+			entity.NewFeedEntry("大阪で震度4の地震、津波の心配なし 気象庁", "https://b.tld/2", "", now, "b-2"),
+		},
+		"https://c.tld/rss": {
+			entity.NewFeedEntry("東京都 来年度から高校授業料を完全無償化へ、所得制限を撤廃", "https://c.tld/1", "", now.Add(-48*time.Hour), "c-1"),
+		},
+	}}
+	noteRepo := &mockNoteRepository{}
+	cacheRepo := newMockCacheRepository()
+	cacheRepo.latestTime = now.Add(-72 * time.Hour)
+
+	service := NewRSSFeedService(feedRepo, noteRepo, cacheRepo, nil, WithStoryClustering(24*time.Hour, 10))
+	err := service.ProcessAllFeeds(ctx, []config.RSSSettings{
+		{URL: "https://a.tld/rss"},
+		{URL: "https://b.tld/rss", Priority: 10},
+		{URL: "https://c.tld/rss"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	texts := postedTexts(noteRepo)
+	if len(texts) != 3 {
+		t.Fatalf("expected 3 notes posted, got %d: %q", len(texts), texts)
+	}
+
+	var merged string
+	for _, text := range texts {
+		if strings.Contains(text, "https://b.tld/1") {
+			merged = text
+		}
+	}
+	if !strings.Contains(merged, "【他のソース】\nhttps://a.tld/1") {
+		t.Errorf("expected the lower priority source to be listed, got %q", merged)
+	}
+	if !cacheRepo.processedGUIDs[feedKey("https://a.tld/rss", "a-1")] {
+		t.Error("expected clustered entry to be marked as processed")
+	}
+	// 公開日時が期間外の記事は同じクラスタにしない
+	if strings.Contains(merged, "https://c.tld/1") {
+		t.Errorf("expected entry outside the window to be posted separately, got %q", merged)
+	}
+}
+
+func TestRSSFeedService_ProcessFeed_StoryClusteringRecentlyPosted(t *testing.T) {
+	ctx := context.Background()
+
+	now := time.Now()
+	feedRepo := &mockFeedsByURL{entries: map[string][]*entity.FeedEntry{
+		"https://a.tld/rss": {
+			entity.NewFeedEntry("Apple unveils new iPhone 17 with faster chip and longer battery life", "https://a.tld/1", "", now, "a-1"),
+			entity.NewFeedEntry("Apple unveils iPhone 17 with faster chip and longer battery life", "https://a.tld/2", "", now, "a-2"),
+		},
+		"https://b.tld/rss": {
+			entity.NewFeedEntry("Apple unveils iPhone 17 with a faster chip and longer battery life", "https://b.tld/1", "", now.Add(time.Minute), "b-1"),
+		},
+	}}
+	noteRepo := &mockNoteRepository{}
+	cacheRepo := newMockCacheRepository()
+	cacheRepo.latestTime = now.Add(-1 * time.Hour)
+
+	service := NewRSSFeedService(feedRepo, noteRepo, cacheRepo, nil, WithStoryClustering(24*time.Hour, 10))
+	for _, url := range []string{"https://a.tld/rss", "https://b.tld/rss"} {
+		if err := service.ProcessFeed(ctx, config.RSSSettings{URL: url}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	// 同じフィード内の似た記事は別の記事として投稿し、他のフィードの似た記事は投稿しない
+	if texts := postedTexts(noteRepo); len(texts) != 2 {
+		t.Fatalf("expected 2 notes posted, got %d: %q", len(texts), texts)
+	}
+	if !cacheRepo.processedGUIDs[feedKey("https://b.tld/rss", "b-1")] {
+		t.Error("expected suppressed entry to be marked as processed")
+	}
+}
+
+func TestRSSFeedService_ProcessAllFeeds_StoryEmbeddings(t *testing.T) {
+	ctx := context.Background()
+
+	now := time.Now()
+	newFeeds := func() *mockFeedsByURL {
+		return &mockFeedsByURL{entries: map[string][]*entity.FeedEntry{
+			"https://a.tld/rss": {entity.NewFeedEntry("Central bank raises interest rates by a quarter point", "https://a.tld/1", "", now, "a-1")},
+			"https://b.tld/rss": {entity.NewFeedEntry("Policy rate lifted 25bp as inflation persists", "https://b.tld/1", "", now, "b-1")},
+		}}
+	}
+	settings := []config.RSSSettings{{URL: "https://a.tld/rss"}, {URL: "https://b.tld/rss"}}
+
+	t.Run("similar embeddings are clustered", func(t *testing.T) {
+		embedder := &mockEmbedder{vectors: map[string][]float32{
+			"Central bank": {1, 0.1, 0},
+			"Policy rate":  {1, 0.12, 0},
+		}}
+		noteRepo := &mockNoteRepository{}
+		cacheRepo := newMockCacheRepository()
+		cacheRepo.latestTime = now.Add(-1 * time.Hour)
+
+		service := NewRSSFeedService(newFeeds(), noteRepo, cacheRepo, nil,
+			WithStoryClustering(24*time.Hour, 10), WithStoryEmbeddings(embedder, 0.9))
+		if err := service.ProcessAllFeeds(ctx, settings); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		texts := postedTexts(noteRepo)
+		if len(texts) != 1 {
+			t.Fatalf("expected 1 note posted, got %d: %q", len(texts), texts)
+		}
+		if !strings.Contains(texts[0], "【他のソース】\nhttps://b.tld/1") {
+			t.Errorf("expected merged source, got %q", texts[0])
+		}
+		if embedder.calls != 1 {
+			t.Errorf("expected a single embedding request, got %d", embedder.calls)
+		}
+	})
+
+	t.Run("falls back to SimHash on error", func(t *testing.T) {
+		embedder := &mockEmbedder{err: errors.New("quota exceeded")}
+		noteRepo := &mockNoteRepository{}
+		cacheRepo := newMockCacheRepository()
+		cacheRepo.latestTime = now.Add(-1 * time.Hour)
+
+		service := NewRSSFeedService(newFeeds(), noteRepo, cacheRepo, nil,
+			WithStoryClustering(24*time.Hour, 10), WithStoryEmbeddings(embedder, 0.9))
+		if err := service.ProcessAllFeeds(ctx, settings); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if texts := postedTexts(noteRepo); len(texts) != 2 {
+			t.Fatalf("expected 2 notes posted, got %d: %q", len(texts), texts)
+		}
+	})
+	t.Run("skips embeddings when budget is exceeded", func(t *testing.T) {
+		embedder := &mockEmbedder{vectors: map[string][]float32{
+			"Central bank": {1, 0.1, 0},
+			"Policy rate":  {1, 0.12, 0},
+		}}
+		noteRepo := &mockNoteRepository{}
+		cacheRepo := newMockCacheRepository()
+		cacheRepo.latestTime = now.Add(-1 * time.Hour)
+		tracker := NewUsageTracker(&mockUsageRepository{usages: []entity.DailyUsage{{Cost: 2}}}, WithDailyBudget(1))
+
+		service := NewRSSFeedService(newFeeds(), noteRepo, cacheRepo, nil,
+			WithStoryClustering(24*time.Hour, 10), WithStoryEmbeddings(embedder, 0.9), WithUsageTracker(tracker))
+		if err := service.ProcessAllFeeds(ctx, settings); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if embedder.calls != 0 {
+			t.Errorf("expected no embedding requests, got %d", embedder.calls)
+		}
+		if texts := postedTexts(noteRepo); len(texts) != 2 {
+			t.Fatalf("expected 2 notes posted, got %d: %q", len(texts), texts)
+		}
+	})
+}
@@ -0,0 +1,142 @@
+package application
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"misskeyRSSbot/internal/domain/entity"
+	"misskeyRSSbot/internal/domain/repository"
+)
+
+type UsageTracker struct {
+	usageRepo   repository.UsageRepository
+	metrics     repository.MetricsRepository
+	prices      map[string]entity.ModelPrice
+	dailyBudget float64
+	now         func() time.Time
+
+	// unpriced は LLM_PRICES に単価がないモデルごとの最終利用日。
+	// 予算が設定されている場合、その日は予算超過として扱う。
+	mu       sync.Mutex
+	unpriced map[string]string
+}
+
+type UsageTrackerOption func(*UsageTracker)
+
+func WithModelPrices(prices map[string]entity.ModelPrice) UsageTrackerOption {
+	return func(t *UsageTracker) {
+		t.prices = prices
+	}
+}
+
+func WithDailyBudget(budget float64) UsageTrackerOption {
+	return func(t *UsageTracker) {
+		t.dailyBudget = budget
+	}
+}
+
+func WithUsageMetrics(metrics repository.MetricsRepository) UsageTrackerOption {
+	return func(t *UsageTracker) {
+		t.metrics = metrics
+	}
+}
+
+func NewUsageTracker(usageRepo repository.UsageRepository, opts ...UsageTrackerOption) *UsageTracker {
+	t := &UsageTracker{
+		usageRepo: usageRepo,
+		prices:    make(map[string]entity.ModelPrice),
+		now:       time.Now,
+		unpriced:  make(map[string]string),
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+	return t
+}
+
+func (t *UsageTracker) RecordUsage(ctx context.Context, usage entity.TokenUsage) {
+	if usage.IsZero() {
+		return
+	}
+
+	price, ok := t.prices[usage.Model]
+	if !ok {
+		t.recordUnpriced(usage)
+	}
+	cost := price.Cost(usage)
+	if err := t.usageRepo.AddTokenUsage(ctx, t.now(), usage, cost); err != nil {
+		log.Printf("Failed to record token usage [%s/%s]: %v", usage.Provider, usage.Model, err)
+	}
+
+	if t.metrics == nil {
+		return
+	}
+	labels := map[string]string{"provider": usage.Provider, "model": usage.Model}
+	t.metrics.AddCounter("llm_input_tokens_total", float64(usage.InputTokens), labels)
+	t.metrics.AddCounter("llm_output_tokens_total", float64(usage.OutputTokens), labels)
+	t.metrics.AddCounter("llm_cost_usd_total", cost, labels)
+
+	if dailyCost, err := t.DailyCost(ctx); err == nil {
+		t.metrics.SetGauge("llm_daily_cost_usd", dailyCost, nil)
+	}
+}
+
+// recordUnpriced は単価不明のモデルの利用を記録する。コストを 0 とみなすと
+// 日次予算が効かなくなるため、予算設定時はその日の残りを予算超過として扱う。
+// 警告はモデルごとに一度だけ出力する。
+func (t *UsageTracker) recordUnpriced(usage entity.TokenUsage) {
+	if t.dailyBudget <= 0 {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, seen := t.unpriced[usage.Model]; !seen {
+		log.Printf("No price configured in LLM_PRICES for model %s/%s; treating the daily LLM budget as exceeded", usage.Provider, usage.Model)
+	}
+	t.unpriced[usage.Model] = dayKey(t.now())
+}
+
+func (t *UsageTracker) usedUnpricedToday() bool {
+	today := dayKey(t.now())
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, day := range t.unpriced {
+		if day == today {
+			return true
+		}
+	}
+	return false
+}
+
+func dayKey(tm time.Time) string {
+	return tm.Format(time.DateOnly)
+}
+
+func (t *UsageTracker) DailyCost(ctx context.Context) (float64, error) {
+	usages, err := t.usageRepo.GetDailyUsage(ctx, t.now())
+	if err != nil {
+		return 0, fmt.Errorf("failed to get daily usage: %w", err)
+	}
+	return entity.TotalCost(usages), nil
+}
+
+func (t *UsageTracker) IsBudgetExceeded(ctx context.Context) bool {
+	if t.dailyBudget <= 0 {
+		return false
+	}
+	if t.usedUnpricedToday() {
+		return true
+	}
+
+	cost, err := t.DailyCost(ctx)
+	if err != nil {
+		log.Printf("Failed to check daily LLM budget: %v", err)
+		return false
+	}
+	return cost >= t.dailyBudget
+}
@@ -0,0 +1,199 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"misskeyRSSbot/internal/domain/entity"
+	"misskeyRSSbot/internal/interfaces/config"
+)
+
+type mockUsageRepository struct {
+	usages []entity.DailyUsage
+	err    error
+}
+
+func (m *mockUsageRepository) AddTokenUsage(ctx context.Context, day time.Time, usage entity.TokenUsage, cost float64) error {
+	if m.err != nil {
+		return m.err
+	}
+	m.usages = append(m.usages, entity.DailyUsage{
+		Day:          day,
+		Provider:     usage.Provider,
+		Model:        usage.Model,
+		InputTokens:  usage.InputTokens,
+		OutputTokens: usage.OutputTokens,
+		Cost:         cost,
+	})
+	return nil
+}
+
+func (m *mockUsageRepository) GetDailyUsage(ctx context.Context, day time.Time) ([]entity.DailyUsage, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	return m.usages, nil
+}
+
+type mockMetricsRepository struct {
+	counters map[string]float64
+	gauges   map[string]float64
+}
+
+func newMockMetricsRepository() *mockMetricsRepository {
+	return &mockMetricsRepository{
+		counters: make(map[string]float64),
+		gauges:   make(map[string]float64),
+	}
+}
+
+func (m *mockMetricsRepository) AddCounter(name string, value float64, labels map[string]string) {
+	m.counters[name] += value
+}
+
+func (m *mockMetricsRepository) SetGauge(name string, value float64, labels map[string]string) {
+	m.gauges[name] = value
+}
+
+func TestUsageTracker_RecordUsage(t *testing.T) {
+	ctx := context.Background()
+	usageRepo := &mockUsageRepository{}
+	metricsRepo := newMockMetricsRepository()
+
+	tracker := NewUsageTracker(
+		usageRepo,
+		WithModelPrices(map[string]entity.ModelPrice{
+			"model-a": {InputPerMillion: 1, OutputPerMillion: 2},
+		}),
+		WithUsageMetrics(metricsRepo),
+	)
+
+	tracker.RecordUsage(ctx, entity.TokenUsage{Provider: "gemini", Model: "model-a", InputTokens: 1_000_000, OutputTokens: 500_000})
+	tracker.RecordUsage(ctx, entity.TokenUsage{Provider: "gemini", Model: "unknown", InputTokens: 10, OutputTokens: 10})
+	tracker.RecordUsage(ctx, entity.TokenUsage{Provider: "gemini", Model: "model-a"})
+
+	if len(usageRepo.usages) != 2 {
+		t.Fatalf("expected 2 usage records (zero usage skipped), got %d", len(usageRepo.usages))
+	}
+	if math.Abs(usageRepo.usages[0].Cost-2) > 1e-9 {
+		t.Errorf("expected cost 2, got %v", usageRepo.usages[0].Cost)
+	}
+	if usageRepo.usages[1].Cost != 0 {
+		t.Errorf("expected zero cost for unpriced model, got %v", usageRepo.usages[1].Cost)
+	}
+
+	if metricsRepo.counters["llm_input_tokens_total"] != 1_000_010 {
+		t.Errorf("unexpected input token counter: %v", metricsRepo.counters["llm_input_tokens_total"])
+	}
+	if metricsRepo.counters["llm_output_tokens_total"] != 500_010 {
+		t.Errorf("unexpected output token counter: %v", metricsRepo.counters["llm_output_tokens_total"])
+	}
+	if math.Abs(metricsRepo.gauges["llm_daily_cost_usd"]-2) > 1e-9 {
+		t.Errorf("unexpected daily cost gauge: %v", metricsRepo.gauges["llm_daily_cost_usd"])
+	}
+}
+
+func TestUsageTracker_IsBudgetExceeded(t *testing.T) {
+	tests := []struct {
+		name     string
+		budget   float64
+		usages   []entity.DailyUsage
+		err      error
+		expected bool
+	}{
+		{"no budget", 0, []entity.DailyUsage{{Cost: 100}}, nil, false},
+		{"under budget", 1, []entity.DailyUsage{{Cost: 0.4}, {Cost: 0.5}}, nil, false},
+		{"budget reached", 1, []entity.DailyUsage{{Cost: 0.5}, {Cost: 0.5}}, nil, true},
+		{"repository error", 1, nil, errors.New("db error"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracker := NewUsageTracker(
+				&mockUsageRepository{usages: tt.usages, err: tt.err},
+				WithDailyBudget(tt.budget),
+			)
+			if got := tracker.IsBudgetExceeded(context.Background()); got != tt.expected {
+				t.Errorf("expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestRSSFeedService_ProcessFeed_BudgetExceeded(t *testing.T) {
+	ctx := context.Background()
+
+	entries := []*entity.FeedEntry{
+		entity.NewFeedEntry("Article 1", "https://example.tld/1", "Description", time.Now(), "guid-1"),
+	}
+
+	noteRepo := &mockNoteRepository{}
+	summarizerRepo := &mockSummarizerRepository{
+		summary: entity.NewSummary("要約"),
+		enabled: true,
+	}
+	tracker := NewUsageTracker(
+		&mockUsageRepository{usages: []entity.DailyUsage{{Cost: 5}}},
+		WithDailyBudget(5),
+	)
+
+	service := NewRSSFeedService(
+		&mockFeedRepository{entries: entries},
+		noteRepo,
+		newMockCacheRepository(),
+		summarizerRepo,
+		WithUsageTracker(tracker),
+	)
+
+	if err := service.ProcessFeed(ctx, config.RSSSettings{URL: "https://example.tld/rss"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if summarizerRepo.called != 0 {
+		t.Errorf("expected summarizer not to be called when budget is exceeded, got %d calls", summarizerRepo.called)
+	}
+	if len(noteRepo.posted) != 1 {
+		t.Fatalf("expected 1 note posted without summary, got %d", len(noteRepo.posted))
+	}
+}
+
+func TestUsageTracker_UnpricedModelExceedsBudget(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.Local)
+	tracker := NewUsageTracker(
+		&mockUsageRepository{},
+		WithModelPrices(map[string]entity.ModelPrice{
+			"model-a": {InputPerMillion: 1, OutputPerMillion: 2},
+		}),
+		WithDailyBudget(1),
+	)
+	tracker.now = func() time.Time { return now }
+
+	tracker.RecordUsage(ctx, entity.TokenUsage{Provider: "gemini", Model: "model-a", InputTokens: 10, OutputTokens: 10})
+	if tracker.IsBudgetExceeded(ctx) {
+		t.Fatal("expected budget not exceeded for priced model")
+	}
+
+	tracker.RecordUsage(ctx, entity.TokenUsage{Provider: "gemini", Model: "unknown", InputTokens: 10, OutputTokens: 10})
+	if !tracker.IsBudgetExceeded(ctx) {
+		t.Error("expected unpriced model usage to exceed budget")
+	}
+
+	now = now.Add(24 * time.Hour)
+	if tracker.IsBudgetExceeded(ctx) {
+		t.Error("expected budget to reset on the next day")
+	}
+}
+
+func TestUsageTracker_UnpricedModelWithoutBudget(t *testing.T) {
+	ctx := context.Background()
+	tracker := NewUsageTracker(&mockUsageRepository{})
+
+	tracker.RecordUsage(ctx, entity.TokenUsage{Provider: "gemini", Model: "unknown", InputTokens: 10, OutputTokens: 10})
+	if tracker.IsBudgetExceeded(ctx) {
+		t.Error("expected no budget check without a daily budget")
+	}
+}
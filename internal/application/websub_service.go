@@ -0,0 +1,404 @@
+package application
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"misskeyRSSbot/internal/domain/entity"
+	"misskeyRSSbot/internal/domain/repository"
+	"misskeyRSSbot/internal/interfaces/config"
+)
+
+const (
+	defaultWebSubLease = 24 * time.Hour
+	// webSubVerifyTimeout はハブが購読を確認するまで待つ時間です。過ぎた場合は失敗としてポーリングに戻します
+	webSubVerifyTimeout = 5 * time.Minute
+	// webSubRetryInterval は失敗した購読や、ハブが見つからなかったフィードの探索をもう一度試すまでの間隔です
+	webSubRetryInterval = time.Hour
+	// webSubMaxRenewBefore は購読の期限のどれだけ前に更新を要求するかの上限です
+	webSubMaxRenewBefore = time.Hour
+	// webSubQueueSize は投稿を待つ配信の数の上限です。超えた場合は空くまでハブへの応答を待たせます
+	webSubQueueSize = 16
+)
+
+// errNotWebSubLeader はリーダーでないレプリカが配信を受け付けなかったことを表します
+var errNotWebSubLeader = errors.New("not the leader")
+
+// WebSubService は WebSub のハブを広告するフィードを購読し、配信されたエントリーを RSSFeedService で投稿します
+// 購読が有効なフィードはポーリングの対象から外し、購読の要求や確認に失敗したフィードはポーリングで取得し続けます
+// 購読はキャッシュに記録してレプリカの間で共有するため、コールバックがどのレプリカに届いても確認や署名の検証ができます
+type WebSubService struct {
+	hubs        repository.WebSubHubRepository
+	subs        repository.WebSubSubscriptionRepository
+	parser      repository.FeedParser
+	feeds       *RSSFeedService
+	callbackURL string
+	lease       time.Duration
+	elector     *LeaderElector
+	now         func() time.Time
+
+	mu       sync.Mutex
+	settings map[string]config.RSSSettings
+	// noHub はハブが見つからなかったフィードと、最後に探した時刻です
+	noHub map[string]time.Time
+
+	deliveries chan webSubDelivery
+}
+
+type webSubDelivery struct {
+	id      string
+	setting config.RSSSettings
+	entries []*entity.FeedEntry
+}
+
+type WebSubServiceOption func(*WebSubService)
+
+// WithWebSubLease はハブに要求する購読の期間を設定します（ハブが別の期間を返した場合はそちらに従います）
+func WithWebSubLease(lease time.Duration) WebSubServiceOption {
+	return func(s *WebSubService) {
+		if lease > 0 {
+			s.lease = lease
+		}
+	}
+}
+
+// WithWebSubLeader はリーダーのレプリカだけが配信されたエントリーを投稿するようにします
+func WithWebSubLeader(elector *LeaderElector) WebSubServiceOption {
+	return func(s *WebSubService) {
+		s.elector = elector
+	}
+}
+
+// NewWebSubService は callbackURL の下で配信を受ける WebSubService を作成します
+// 購読ごとのコールバックは callbackURL の末尾に購読のIDを付けたURLになります
+func NewWebSubService(
+	hubs repository.WebSubHubRepository,
+	subs repository.WebSubSubscriptionRepository,
+	parser repository.FeedParser,
+	feeds *RSSFeedService,
+	callbackURL string,
+	opts ...WebSubServiceOption,
+) *WebSubService {
+	s := &WebSubService{
+		hubs:        hubs,
+		subs:        subs,
+		parser:      parser,
+		feeds:       feeds,
+		callbackURL: strings.TrimSuffix(callbackURL, "/"),
+		lease:       defaultWebSubLease,
+		now:         time.Now,
+		settings:    make(map[string]config.RSSSettings),
+		noHub:       make(map[string]time.Time),
+		deliveries:  make(chan webSubDelivery, webSubQueueSize),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Refresh は settings のフィードのうち、まだ購読していないフィードのハブを探して購読を要求します
+// 期限の近い購読の更新、確認されない購読の打ち切り、失敗した購読の再試行も行います。取得の間隔ごとに呼び出します
+func (s *WebSubService) Refresh(ctx context.Context, settings []config.RSSSettings) {
+	byFeed, err := s.subscriptionsByFeed(ctx)
+	if err != nil {
+		log.Printf("Failed to load WebSub subscriptions: %v", err)
+		return
+	}
+	for _, setting := range settings {
+		if ctx.Err() != nil {
+			return
+		}
+		s.refreshFeed(ctx, setting, byFeed[setting.URL])
+	}
+}
+
+// subscriptionsByFeed は記録した購読をフィードのURLごとに返します
+// 同じフィードに複数の購読がある場合は、最後に要求した購読を使います
+func (s *WebSubService) subscriptionsByFeed(ctx context.Context) (map[string]*entity.WebSubSubscription, error) {
+	subs, err := s.subs.ListWebSubSubscriptions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	byFeed := make(map[string]*entity.WebSubSubscription, len(subs))
+	for i := range subs {
+		sub := &subs[i]
+		if current := byFeed[sub.FeedURL]; current == nil || current.RequestedAt.Before(sub.RequestedAt) {
+			byFeed[sub.FeedURL] = sub
+		}
+	}
+	return byFeed, nil
+}
+
+func (s *WebSubService) refreshFeed(ctx context.Context, setting config.RSSSettings, sub *entity.WebSubSubscription) {
+	s.mu.Lock()
+	s.settings[setting.URL] = setting
+	now := s.now()
+	if searchedAt, ok := s.noHub[setting.URL]; ok && now.Sub(searchedAt) < webSubRetryInterval {
+		s.mu.Unlock()
+		return
+	}
+	delete(s.noHub, setting.URL)
+	s.mu.Unlock()
+
+	if sub != nil {
+		if sub.State == entity.WebSubStatePending && now.Sub(sub.RequestedAt) > webSubVerifyTimeout {
+			sub.State = entity.WebSubStateFailed
+			log.Printf("WebSub subscription was not verified by the hub, polling instead [%s]", setting.URL)
+			if err := s.save(ctx, sub); err != nil {
+				return
+			}
+		}
+		if !s.needsRequest(sub, now) {
+			return
+		}
+	}
+
+	if sub == nil || sub.State == entity.WebSubStateFailed {
+		s.discoverAndSubscribe(ctx, setting.URL, sub)
+		return
+	}
+	s.subscribe(ctx, sub)
+}
+
+// needsRequest はハブに購読を要求し直す必要があるかどうかを返します
+func (s *WebSubService) needsRequest(sub *entity.WebSubSubscription, now time.Time) bool {
+	switch sub.State {
+	case entity.WebSubStateFailed:
+		return now.Sub(sub.RequestedAt) >= webSubRetryInterval
+	case entity.WebSubStateActive:
+		// 更新の確認を待っている間は要求し直さない
+		if now.Sub(sub.RequestedAt) <= webSubVerifyTimeout {
+			return false
+		}
+		renewBefore := min(sub.ExpiresAt.Sub(sub.RequestedAt)/10, webSubMaxRenewBefore)
+		return !now.Before(sub.ExpiresAt.Add(-renewBefore))
+	default:
+		return false
+	}
+}
+
+// discoverAndSubscribe はフィードのハブを探して購読を要求します
+// 以前の購読があればIDと鍵を引き継ぎ、ハブへの確認と配信で同じコールバックURLを使います
+func (s *WebSubService) discoverAndSubscribe(ctx context.Context, feedURL string, sub *entity.WebSubSubscription) {
+	if sub == nil {
+		sub = &entity.WebSubSubscription{ID: rand.Text(), FeedURL: feedURL, Secret: rand.Text()}
+	}
+
+	hub, topic, err := s.hubs.DiscoverHub(ctx, feedURL)
+	switch {
+	case err != nil:
+		sub.State = entity.WebSubStateFailed
+		sub.RequestedAt = s.now()
+		log.Printf("Failed to discover WebSub hub, polling instead [%s]: %v", feedURL, err)
+		_ = s.save(ctx, sub)
+		return
+	case hub == "":
+		if err := s.subs.DeleteWebSubSubscription(ctx, sub.ID); err != nil {
+			log.Printf("Failed to delete WebSub subscription [%s]: %v", feedURL, err)
+		}
+		s.mu.Lock()
+		s.noHub[feedURL] = s.now()
+		s.mu.Unlock()
+		return
+	}
+	sub.Hub = hub
+	sub.Topic = topic
+
+	s.subscribe(ctx, sub)
+}
+
+// subscribe はハブに購読を要求します
+// ハブは要求に応じる前に確認することがあるため、要求する前に購読を記録します
+func (s *WebSubService) subscribe(ctx context.Context, sub *entity.WebSubSubscription) {
+	sub.RequestedAt = s.now()
+	if sub.State != entity.WebSubStateActive {
+		sub.State = entity.WebSubStatePending
+	}
+	if err := s.save(ctx, sub); err != nil {
+		return
+	}
+
+	if err := s.hubs.Subscribe(ctx, sub, s.callbackURL+"/"+sub.ID, s.lease); err != nil {
+		sub.State = entity.WebSubStateFailed
+		log.Printf("Failed to subscribe via WebSub, polling instead [%s]: %v", sub.FeedURL, err)
+		_ = s.save(ctx, sub)
+		return
+	}
+	log.Printf("Requested WebSub subscription [%s, hub: %s]", sub.FeedURL, sub.Hub)
+}
+
+func (s *WebSubService) save(ctx context.Context, sub *entity.WebSubSubscription) error {
+	if err := s.subs.SaveWebSubSubscription(ctx, *sub); err != nil {
+		log.Printf("Failed to save WebSub subscription [%s]: %v", sub.FeedURL, err)
+		return err
+	}
+	return nil
+}
+
+// PolledFeeds は settings のうち、WebSub の購読が有効でなくポーリングで取得するフィードを返します
+// 投稿できなかった配信があったフィードは、購読が有効でも1回だけポーリングの対象に戻します
+// 購読を読み込めない場合は、すべてのフィードをポーリングします
+func (s *WebSubService) PolledFeeds(ctx context.Context, settings []config.RSSSettings) []config.RSSSettings {
+	byFeed, err := s.subscriptionsByFeed(ctx)
+	if err != nil {
+		log.Printf("Failed to load WebSub subscriptions, polling all feeds: %v", err)
+		return settings
+	}
+
+	now := s.now()
+	var polled []config.RSSSettings
+	for _, setting := range settings {
+		sub := byFeed[setting.URL]
+		if sub != nil && sub.IsActive(now) && !sub.Missed {
+			continue
+		}
+		if sub != nil && sub.Missed {
+			sub.Missed = false
+			_ = s.save(ctx, sub)
+		}
+		polled = append(polled, setting)
+	}
+	return polled
+}
+
+// VerifyIntent はハブからの購読の確認に応じるかどうかを返し、応じる場合は購読を有効にします
+// 購読の解除は要求しないため、hub.mode=unsubscribe の確認には応じません
+func (s *WebSubService) VerifyIntent(ctx context.Context, id, mode, topic string, lease time.Duration) (bool, error) {
+	sub, ok, err := s.subs.GetWebSubSubscription(ctx, id)
+	if err != nil {
+		return false, err
+	}
+	if !ok || sub.Topic != topic || mode != "subscribe" {
+		return false, nil
+	}
+
+	if lease <= 0 {
+		lease = s.lease
+	}
+	now := s.now()
+	wasActive := sub.IsActive(now)
+	sub.State = entity.WebSubStateActive
+	sub.ExpiresAt = now.Add(lease)
+	if err := s.save(ctx, &sub); err != nil {
+		return false, err
+	}
+	if !wasActive {
+		log.Printf("WebSub subscription verified, stopped polling [%s, lease: %v]", sub.FeedURL, lease)
+	}
+	return true, nil
+}
+
+// Deny はハブが購読を拒否したことを記録し、フィードをポーリングに戻します
+func (s *WebSubService) Deny(ctx context.Context, id, topic, reason string) error {
+	sub, ok, err := s.subs.GetWebSubSubscription(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !ok || sub.Topic != topic {
+		return nil
+	}
+	sub.State = entity.WebSubStateFailed
+	if err := s.save(ctx, &sub); err != nil {
+		return err
+	}
+	log.Printf("WebSub subscription denied by the hub, polling instead [%s]: %s", sub.FeedURL, reason)
+	return nil
+}
+
+// Lookup は id の購読を返します
+func (s *WebSubService) Lookup(ctx context.Context, id string) (entity.WebSubSubscription, bool, error) {
+	return s.subs.GetWebSubSubscription(ctx, id)
+}
+
+// Deliver は id の購読に配信されたフィードを読み取り、Run で投稿するために待ち行列に入れます
+// 読み取れない内容は無視します。待ち行列が空くのを待つ間に ctx が終了した場合はエラーを返します
+// リーダーでない場合は、ハブに再送させるためにエラーを返し、リーダーが次の取得でフィードをポーリングするように記録します
+func (s *WebSubService) Deliver(ctx context.Context, id string, body io.Reader) error {
+	sub, ok, err := s.subs.GetWebSubSubscription(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("unknown WebSub subscription: %s", id)
+	}
+	if s.elector != nil && !s.elector.IsLeader() {
+		log.Printf("Rejected WebSub content: not the leader [%s]", sub.FeedURL)
+		s.markMissed(ctx, id)
+		return errNotWebSubLeader
+	}
+
+	s.mu.Lock()
+	setting, ok := s.settings[sub.FeedURL]
+	s.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("WebSub content for a feed that is not configured: %s", sub.FeedURL)
+	}
+
+	entries, err := s.parser.Parse(body)
+	if err != nil {
+		log.Printf("Ignored invalid WebSub content [%s]: %v", setting.URL, err)
+		return nil
+	}
+	log.Printf("Received %d entries via WebSub [%s]", len(entries), setting.URL)
+
+	select {
+	case s.deliveries <- webSubDelivery{id: id, setting: setting, entries: entries}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// markMissed は id の購読のフィードを、購読が有効でも次の取得でポーリングするように記録します
+func (s *WebSubService) markMissed(ctx context.Context, id string) {
+	sub, ok, err := s.subs.GetWebSubSubscription(ctx, id)
+	if err != nil {
+		log.Printf("Failed to load WebSub subscription [%s]: %v", id, err)
+		return
+	}
+	if !ok || sub.Missed {
+		return
+	}
+	sub.Missed = true
+	_ = s.save(ctx, &sub)
+}
+
+// Run は ctx が終了するまで配信されたエントリーを順に投稿します
+func (s *WebSubService) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case delivery := <-s.deliveries:
+			s.process(ctx, delivery)
+		}
+	}
+}
+
+// process は配信されたエントリーを投稿します
+// 受け付けた後にリーダーでなくなった場合は、投稿できなかったエントリーを次のポーリングで取得し直します
+func (s *WebSubService) process(ctx context.Context, delivery webSubDelivery) {
+	leaderCtx := ctx
+	if s.elector != nil {
+		var cancel context.CancelFunc
+		leaderCtx, cancel = s.elector.LeaderContext(ctx)
+		defer cancel()
+	}
+	if leaderCtx.Err() == nil {
+		if err := s.feeds.ProcessEntries(leaderCtx, delivery.setting, delivery.entries); err != nil {
+			log.Printf("Error processing WebSub content %s: %v", delivery.setting.URL, err)
+		}
+	}
+	if errors.Is(context.Cause(leaderCtx), errLeadershipLost) {
+		log.Printf("Lost leadership while processing WebSub content, polling it next time [%s]", delivery.setting.URL)
+		s.markMissed(ctx, delivery.id)
+	}
+}
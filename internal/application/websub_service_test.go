@@ -0,0 +1,391 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"misskeyRSSbot/internal/domain/entity"
+	"misskeyRSSbot/internal/domain/repository"
+	"misskeyRSSbot/internal/infrastructure/storage"
+	"misskeyRSSbot/internal/interfaces/config"
+)
+
+type subscribeRequest struct {
+	sub      entity.WebSubSubscription
+	callback string
+	lease    time.Duration
+}
+
+type mockWebSubHubRepository struct {
+	hubs         map[string]string
+	discoverErr  error
+	subscribeErr error
+	discovered   int
+	requests     []subscribeRequest
+}
+
+func (m *mockWebSubHubRepository) DiscoverHub(ctx context.Context, feedURL string) (string, string, error) {
+	m.discovered++
+	if m.discoverErr != nil {
+		return "", "", m.discoverErr
+	}
+	hub := m.hubs[feedURL]
+	if hub == "" {
+		return "", "", nil
+	}
+	return hub, feedURL + "#self", nil
+}
+
+func (m *mockWebSubHubRepository) Subscribe(ctx context.Context, sub *entity.WebSubSubscription, callbackURL string, lease time.Duration) error {
+	m.requests = append(m.requests, subscribeRequest{sub: *sub, callback: callbackURL, lease: lease})
+	return m.subscribeErr
+}
+
+type mockFeedParser struct {
+	entries []*entity.FeedEntry
+	err     error
+}
+
+func (m *mockFeedParser) Parse(r io.Reader) ([]*entity.FeedEntry, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	return m.entries, nil
+}
+
+const (
+	hubFeedURL   = "https://example.tld/hub.xml"
+	plainFeedURL = "https://example.tld/plain.xml"
+)
+
+var webSubTestSettings = []config.RSSSettings{{URL: hubFeedURL}, {URL: plainFeedURL}}
+
+func newTestWebSubService(hubs *mockWebSubHubRepository, clock *mockClock, opts ...WebSubServiceOption) *WebSubService {
+	service := NewRSSFeedService(&mockFeedRepository{}, &mockNoteRepository{}, newMockCacheRepository(), nil)
+	s := NewWebSubService(hubs, newTestWebSubStore(), &mockFeedParser{}, service, "https://bot.example.tld/websub/", opts...)
+	s.now = clock.Now
+	return s
+}
+
+func newTestWebSubStore() repository.WebSubSubscriptionRepository {
+	return storage.NewMemoryCacheRepository(0).(repository.WebSubSubscriptionRepository)
+}
+
+func verify(t *testing.T, s *WebSubService, id, mode, topic string, lease time.Duration) bool {
+	t.Helper()
+	ok, err := s.VerifyIntent(context.Background(), id, mode, topic, lease)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return ok
+}
+
+func polledURLs(s *WebSubService) []string {
+	var urls []string
+	for _, setting := range s.PolledFeeds(context.Background(), webSubTestSettings) {
+		urls = append(urls, setting.URL)
+	}
+	return urls
+}
+
+func TestWebSubService_SubscribeAndVerify(t *testing.T) {
+	ctx := context.Background()
+	clock := &mockClock{now: time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)}
+	hubs := &mockWebSubHubRepository{hubs: map[string]string{hubFeedURL: "https://hub.example.tld/"}}
+	s := newTestWebSubService(hubs, clock, WithWebSubLease(10*time.Hour))
+
+	s.Refresh(ctx, webSubTestSettings)
+	if len(hubs.requests) != 1 {
+		t.Fatalf("expected 1 subscription request, got %d", len(hubs.requests))
+	}
+	req := hubs.requests[0]
+	if req.sub.Topic != hubFeedURL+"#self" || req.sub.Hub != "https://hub.example.tld/" || req.sub.Secret == "" {
+		t.Errorf("unexpected subscription: %+v", req.sub)
+	}
+	if req.callback != "https://bot.example.tld/websub/"+req.sub.ID || req.lease != 10*time.Hour {
+		t.Errorf("unexpected callback %q or lease %v", req.callback, req.lease)
+	}
+
+	// 確認されるまではポーリングを続ける
+	if got := polledURLs(s); len(got) != 2 {
+		t.Errorf("expected both feeds to be polled before verification, got %v", got)
+	}
+
+	if verify(t, s, req.sub.ID, "subscribe", "https://other.example.tld/", time.Hour) {
+		t.Error("expected verification with another topic to be refused")
+	}
+	if verify(t, s, req.sub.ID, "unsubscribe", req.sub.Topic, 0) {
+		t.Error("expected unsubscribe verification to be refused")
+	}
+	if verify(t, s, "unknown", "subscribe", req.sub.Topic, time.Hour) {
+		t.Error("expected verification of an unknown subscription to be refused")
+	}
+	if !verify(t, s, req.sub.ID, "subscribe", req.sub.Topic, 0) {
+		t.Fatal("expected verification to be accepted")
+	}
+
+	if got := polledURLs(s); len(got) != 1 || got[0] != plainFeedURL {
+		t.Errorf("expected only the feed without a hub to be polled, got %v", got)
+	}
+	sub, ok, _ := s.Lookup(ctx, req.sub.ID)
+	if !ok || !sub.ExpiresAt.Equal(clock.Now().Add(10*time.Hour)) {
+		t.Errorf("expected requested lease to be used when the hub sends none, got %+v", sub)
+	}
+
+	s.Refresh(ctx, webSubTestSettings)
+	if hubs.discovered != 2 || len(hubs.requests) != 1 {
+		t.Errorf("expected no further discovery or requests, got %d discoveries and %d requests", hubs.discovered, len(hubs.requests))
+	}
+}
+
+func TestWebSubService_RenewsBeforeExpiry(t *testing.T) {
+	ctx := context.Background()
+	clock := &mockClock{now: time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)}
+	hubs := &mockWebSubHubRepository{hubs: map[string]string{hubFeedURL: "https://hub.example.tld/"}}
+	s := newTestWebSubService(hubs, clock)
+
+	s.Refresh(ctx, webSubTestSettings)
+	id := hubs.requests[0].sub.ID
+	verify(t, s, id, "subscribe", hubFeedURL+"#self", 10*time.Hour)
+
+	clock.Advance(8 * time.Hour)
+	s.Refresh(ctx, webSubTestSettings)
+	if len(hubs.requests) != 1 {
+		t.Fatalf("expected no renewal 2 hours before expiry, got %d requests", len(hubs.requests))
+	}
+
+	clock.Advance(time.Hour + time.Minute)
+	s.Refresh(ctx, webSubTestSettings)
+	if len(hubs.requests) != 2 || hubs.requests[1].sub.ID != id {
+		t.Fatalf("expected renewal with the same callback, got %+v", hubs.requests)
+	}
+	if got := polledURLs(s); len(got) != 1 {
+		t.Errorf("expected subscription to stay active while renewing, got polled %v", got)
+	}
+
+	// 更新が確認されないまま期限が過ぎるとポーリングに戻る
+	clock.Advance(time.Hour)
+	if got := polledURLs(s); len(got) != 2 {
+		t.Errorf("expected polling after the lease expired, got %v", got)
+	}
+}
+
+func TestWebSubService_FallsBackToPolling(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("subscription rejected", func(t *testing.T) {
+		clock := &mockClock{now: time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)}
+		hubs := &mockWebSubHubRepository{
+			hubs:         map[string]string{hubFeedURL: "https://hub.example.tld/"},
+			subscribeErr: errors.New("hub rejected subscription: status 400"),
+		}
+		s := newTestWebSubService(hubs, clock)
+
+		s.Refresh(ctx, webSubTestSettings)
+		if got := polledURLs(s); len(got) != 2 {
+			t.Errorf("expected polling after a rejected subscription, got %v", got)
+		}
+
+		clock.Advance(30 * time.Minute)
+		s.Refresh(ctx, webSubTestSettings)
+		if len(hubs.requests) != 1 {
+			t.Errorf("expected no retry before the retry interval, got %d requests", len(hubs.requests))
+		}
+
+		hubs.subscribeErr = nil
+		clock.Advance(30 * time.Minute)
+		s.Refresh(ctx, webSubTestSettings)
+		if len(hubs.requests) != 2 || hubs.requests[1].sub.ID != hubs.requests[0].sub.ID {
+			t.Errorf("expected retry with the same subscription, got %+v", hubs.requests)
+		}
+	})
+
+	t.Run("not verified", func(t *testing.T) {
+		clock := &mockClock{now: time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)}
+		hubs := &mockWebSubHubRepository{hubs: map[string]string{hubFeedURL: "https://hub.example.tld/"}}
+		s := newTestWebSubService(hubs, clock)
+
+		s.Refresh(ctx, webSubTestSettings)
+		clock.Advance(webSubVerifyTimeout + time.Second)
+		s.Refresh(ctx, webSubTestSettings)
+
+		sub, _, _ := s.Lookup(ctx, hubs.requests[0].sub.ID)
+		if sub.State != entity.WebSubStateFailed {
+			t.Errorf("expected unverified subscription to fail, got %s", sub.State)
+		}
+	})
+
+	t.Run("denied", func(t *testing.T) {
+		clock := &mockClock{now: time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)}
+		hubs := &mockWebSubHubRepository{hubs: map[string]string{hubFeedURL: "https://hub.example.tld/"}}
+		s := newTestWebSubService(hubs, clock)
+
+		s.Refresh(ctx, webSubTestSettings)
+		sub := hubs.requests[0].sub
+		verify(t, s, sub.ID, "subscribe", sub.Topic, time.Hour)
+		s.Deny(ctx, sub.ID, sub.Topic, "topic removed")
+		if got := polledURLs(s); len(got) != 2 {
+			t.Errorf("expected polling after the hub denied the subscription, got %v", got)
+		}
+	})
+
+	t.Run("no hub", func(t *testing.T) {
+		clock := &mockClock{now: time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)}
+		hubs := &mockWebSubHubRepository{}
+		s := newTestWebSubService(hubs, clock)
+
+		s.Refresh(ctx, webSubTestSettings)
+		clock.Advance(30 * time.Minute)
+		s.Refresh(ctx, webSubTestSettings)
+		if hubs.discovered != 2 {
+			t.Errorf("expected no rediscovery before the retry interval, got %d discoveries", hubs.discovered)
+		}
+
+		hubs.hubs = map[string]string{hubFeedURL: "https://hub.example.tld/"}
+		clock.Advance(30 * time.Minute)
+		s.Refresh(ctx, webSubTestSettings)
+		if len(hubs.requests) != 1 {
+			t.Errorf("expected subscription after the feed started advertising a hub, got %d requests", len(hubs.requests))
+		}
+	})
+
+	t.Run("discovery error", func(t *testing.T) {
+		clock := &mockClock{now: time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)}
+		hubs := &mockWebSubHubRepository{discoverErr: errors.New("connection refused")}
+		s := newTestWebSubService(hubs, clock)
+
+		s.Refresh(ctx, webSubTestSettings)
+		if got := polledURLs(s); len(got) != 2 {
+			t.Errorf("expected polling after a discovery error, got %v", got)
+		}
+
+		hubs.discoverErr = nil
+		hubs.hubs = map[string]string{hubFeedURL: "https://hub.example.tld/"}
+		clock.Advance(webSubRetryInterval)
+		s.Refresh(ctx, webSubTestSettings)
+		if len(hubs.requests) != 1 {
+			t.Errorf("expected subscription after retrying discovery, got %d requests", len(hubs.requests))
+		}
+	})
+}
+
+func TestWebSubService_Deliver(t *testing.T) {
+	ctx := context.Background()
+	clock := &mockClock{now: time.Now()}
+	hubs := &mockWebSubHubRepository{hubs: map[string]string{hubFeedURL: "https://hub.example.tld/"}}
+	parser := &mockFeedParser{entries: []*entity.FeedEntry{
+		entity.NewFeedEntry("Pushed", "https://example.tld/pushed", "", clock.Now(), "pushed-1"),
+	}}
+	noteRepo := &mockNoteRepository{}
+	cacheRepo := newMockCacheRepository()
+	cacheRepo.latestTime = clock.Now().Add(-time.Hour)
+	feeds := NewRSSFeedService(&mockFeedRepository{}, noteRepo, cacheRepo, nil)
+	s := NewWebSubService(hubs, newTestWebSubStore(), parser, feeds, "https://bot.example.tld/websub")
+	s.now = clock.Now
+
+	s.Refresh(ctx, []config.RSSSettings{{URL: hubFeedURL, Keywords: []string{"Pushed"}}})
+	id := hubs.requests[0].sub.ID
+
+	if err := s.Deliver(ctx, "unknown", strings.NewReader("")); err == nil {
+		t.Error("expected error for an unknown subscription, got nil")
+	}
+
+	if err := s.Deliver(ctx, id, strings.NewReader("<feed/>")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	delivery := <-s.deliveries
+	if delivery.setting.URL != hubFeedURL || len(delivery.setting.Keywords) != 1 {
+		t.Errorf("expected the feed's settings to be used, got %+v", delivery.setting)
+	}
+	s.process(ctx, delivery)
+	if len(noteRepo.posted) != 1 || !strings.Contains(noteRepo.posted[0].Text, "Pushed") {
+		t.Fatalf("expected the pushed entry to be posted, got %+v", noteRepo.posted)
+	}
+
+	// 同じエントリーが再び配信されても投稿しない
+	s.process(ctx, delivery)
+	if len(noteRepo.posted) != 1 {
+		t.Errorf("expected redelivered entry to be skipped, got %d posts", len(noteRepo.posted))
+	}
+
+	parser.err = errors.New("failed to parse RSS feed")
+	if err := s.Deliver(ctx, id, strings.NewReader("broken")); err != nil {
+		t.Errorf("expected invalid content to be ignored, got %v", err)
+	}
+	if len(s.deliveries) != 0 {
+		t.Errorf("expected invalid content not to be queued, got %d", len(s.deliveries))
+	}
+}
+
+func TestWebSubService_SharedBetweenReplicas(t *testing.T) {
+	ctx := context.Background()
+	clock := &mockClock{now: time.Now()}
+	leases := newMockLeaseRepository(clock)
+	store := newTestWebSubStore()
+	hubs := &mockWebSubHubRepository{hubs: map[string]string{hubFeedURL: "https://hub.example.tld/"}}
+	parser := &mockFeedParser{entries: []*entity.FeedEntry{
+		entity.NewFeedEntry("Pushed", "https://example.tld/pushed", "", clock.Now(), "pushed-1"),
+	}}
+
+	newReplica := func(name string) (*WebSubService, *LeaderElector, *mockNoteRepository) {
+		elector := newTestLeaderElector(leases, name)
+		noteRepo := &mockNoteRepository{}
+		feeds := NewRSSFeedService(&mockFeedRepository{}, noteRepo, newMockCacheRepository(), nil)
+		s := NewWebSubService(hubs, store, parser, feeds, "https://bot.example.tld/websub", WithWebSubLeader(elector))
+		s.now = clock.Now
+		return s, elector, noteRepo
+	}
+	leader, leaderElector, noteRepo := newReplica("replica-a")
+	follower, followerElector, _ := newReplica("replica-b")
+	if !leaderElector.TryAcquire(ctx) || followerElector.TryAcquire(ctx) {
+		t.Fatal("expected replica-a to be the only leader")
+	}
+
+	leader.Refresh(ctx, webSubTestSettings)
+	sub := hubs.requests[0].sub
+
+	// リーダーが要求した購読を、他のレプリカに届いた確認でも有効にできる
+	if !verify(t, follower, sub.ID, "subscribe", sub.Topic, time.Hour) {
+		t.Fatal("expected the follower to verify the leader's subscription")
+	}
+	if got := polledURLs(leader); len(got) != 1 || got[0] != plainFeedURL {
+		t.Fatalf("expected the leader to stop polling the verified feed, got %v", got)
+	}
+	if got, ok, err := follower.Lookup(ctx, sub.ID); err != nil || !ok || got.Secret != sub.Secret {
+		t.Errorf("expected the follower to find the subscription's secret, got %+v, %v (err=%v)", got, ok, err)
+	}
+
+	// 他のレプリカに届いた配信はハブに再送させ、リーダーは次の取得でポーリングする
+	if err := follower.Deliver(ctx, sub.ID, strings.NewReader("<feed/>")); !errors.Is(err, errNotWebSubLeader) {
+		t.Errorf("expected errNotWebSubLeader, got %v", err)
+	}
+	if len(follower.deliveries) != 0 {
+		t.Errorf("expected rejected content not to be queued, got %d", len(follower.deliveries))
+	}
+	if got := polledURLs(leader); len(got) != 2 {
+		t.Errorf("expected the feed with a rejected delivery to be polled, got %v", got)
+	}
+	if got := polledURLs(leader); len(got) != 1 {
+		t.Errorf("expected the feed to be polled only once, got %v", got)
+	}
+
+	// 受け付けた後にリーダーでなくなった配信も、次の取得でポーリングする
+	if err := leader.Deliver(ctx, sub.ID, strings.NewReader("<feed/>")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	clock.Advance(defaultLeaseTTL + time.Second)
+	if !followerElector.TryAcquire(ctx) || leaderElector.TryAcquire(ctx) {
+		t.Fatal("expected replica-b to take over the lease")
+	}
+	leader.process(ctx, <-leader.deliveries)
+	if len(noteRepo.posted) != 0 {
+		t.Errorf("expected a replica that lost the lease not to post, got %d posts", len(noteRepo.posted))
+	}
+	if got := polledURLs(follower); len(got) != 2 {
+		t.Errorf("expected the new leader to poll the feed with a dropped delivery, got %v", got)
+	}
+}
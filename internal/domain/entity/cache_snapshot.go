@@ -0,0 +1,46 @@
+package entity
+
+import "time"
+
+// CacheSnapshot はバックエンドの間で移行できるキャッシュの状態です
+// FeedURL が空の記録は、フィードごとに記録する前の状態としてすべてのフィードに適用します
+type CacheSnapshot struct {
+	LatestPublished []LatestPublished
+	Processed       []ProcessedGUID
+	FirstSeen       []FirstSeen
+	RecentStories   []RecentStory
+	Posts           []PostRecord
+}
+
+// LatestPublished はフィードごとの投稿済みの最新の公開日時です
+type LatestPublished struct {
+	FeedURL     string
+	PublishedAt time.Time
+}
+
+// ProcessedGUID は処理済みのエントリーです
+type ProcessedGUID struct {
+	FeedURL      string
+	GUID         string
+	CanonicalKey string
+	ProcessedAt  time.Time
+}
+
+// FirstSeen は日時のないエントリーを初めて検出した日時です
+type FirstSeen struct {
+	FeedURL string
+	GUID    string
+	SeenAt  time.Time
+}
+
+// RecentStory はフィード間の重複を判定するために記録した、投稿済みの記事のキーです
+type RecentStory struct {
+	Key      string
+	FeedURL  string
+	PostedAt time.Time
+}
+
+// Len は記録の総数を返します
+func (s *CacheSnapshot) Len() int {
+	return len(s.LatestPublished) + len(s.Processed) + len(s.FirstSeen) + len(s.RecentStories) + len(s.Posts)
+}
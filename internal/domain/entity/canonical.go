@@ -0,0 +1,90 @@
+package entity
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// trackingParams はURLから取り除くトラッキング用のクエリパラメータです（utm_ で始まるものも取り除く）
+var trackingParams = map[string]bool{
+	"fbclid":  true,
+	"gclid":   true,
+	"dclid":   true,
+	"msclkid": true,
+	"yclid":   true,
+	"mc_cid":  true,
+	"mc_eid":  true,
+	"igshid":  true,
+	"_ga":     true,
+}
+
+// CanonicalKey は重複判定に使うエントリーのキーを返します
+// GUIDがURLの場合は CanonicalURL で正規化し、URL以外のGUID（tag: など）はそのまま使います
+func CanonicalKey(guid string) string {
+	if canonical, ok := CanonicalURL(guid); ok {
+		return canonical
+	}
+	return strings.TrimSpace(guid)
+}
+
+// CanonicalURL はトラッキングパラメータやスキーム・ホスト・末尾のスラッシュの違いを吸収したURLを返します
+// http/https 以外のURLの場合は false を返します
+func CanonicalURL(raw string) (string, bool) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || u.Host == "" {
+		return "", false
+	}
+	scheme := strings.ToLower(u.Scheme)
+	if scheme != "http" && scheme != "https" {
+		return "", false
+	}
+
+	// http と https は同じ記事として扱う
+	u.Scheme = "https"
+	u.User = nil
+	u.Fragment = ""
+	u.RawFragment = ""
+
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	if port := u.Port(); port != "" && port != "80" && port != "443" {
+		host += ":" + port
+	}
+	u.Host = host
+
+	u.Path = strings.TrimRight(u.Path, "/")
+	u.RawPath = strings.TrimRight(u.RawPath, "/")
+
+	query := u.Query()
+	for key := range query {
+		lower := strings.ToLower(key)
+		if strings.HasPrefix(lower, "utm_") || trackingParams[lower] {
+			query.Del(key)
+		}
+	}
+	u.RawQuery = encodeSortedQuery(query)
+	u.ForceQuery = false
+
+	return u.String(), true
+}
+
+func encodeSortedQuery(query url.Values) string {
+	if len(query) == 0 {
+		return ""
+	}
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var parts []string
+	for _, key := range keys {
+		values := append([]string(nil), query[key]...)
+		sort.Strings(values)
+		for _, value := range values {
+			parts = append(parts, url.QueryEscape(key)+"="+url.QueryEscape(value))
+		}
+	}
+	return strings.Join(parts, "&")
+}
@@ -0,0 +1,77 @@
+package entity
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCanonicalURL(t *testing.T) {
+	tests := []struct {
+		name   string
+		raw    string
+		want   string
+		wantOK bool
+	}{
+		{
+			name:   "tracking parameters removed",
+			raw:    "https://example.tld/news/1?utm_source=rss&utm_medium=feed&fbclid=abc&id=2",
+			want:   "https://example.tld/news/1?id=2",
+			wantOK: true,
+		},
+		{
+			name:   "scheme host and trailing slash normalized",
+			raw:    "http://Example.TLD:80/news/1/#comments",
+			want:   "https://example.tld/news/1",
+			wantOK: true,
+		},
+		{
+			name:   "query sorted",
+			raw:    "https://example.tld/search?b=2&a=1",
+			want:   "https://example.tld/search?a=1&b=2",
+			wantOK: true,
+		},
+		{
+			name:   "non default port kept",
+			raw:    "https://example.tld:8443/",
+			want:   "https://example.tld:8443",
+			wantOK: true,
+		},
+		{
+			name: "not a url",
+			raw:  "tag:example.tld,2024:1",
+		},
+		{
+			name: "unsupported scheme",
+			raw:  "ftp://example.tld/file",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := CanonicalURL(tt.raw)
+			if ok != tt.wantOK {
+				t.Fatalf("expected ok=%v, got %v", tt.wantOK, ok)
+			}
+			if got != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestCanonicalKey(t *testing.T) {
+	if got := CanonicalKey("tag:example.tld,2024:1"); got != "tag:example.tld,2024:1" {
+		t.Errorf("expected opaque GUID to be kept, got %q", got)
+	}
+
+	a := CanonicalKey("http://example.tld/news/1/?utm_campaign=a")
+	b := CanonicalKey("https://example.tld/news/1?utm_campaign=b")
+	if a != b {
+		t.Errorf("expected same key, got %q and %q", a, b)
+	}
+
+	entry := NewFeedEntry("Title", "https://example.tld/news/1", "Desc", time.Time{}, "https://example.tld/news/1?fbclid=x")
+	if entry.CanonicalKey != "https://example.tld/news/1" {
+		t.Errorf("expected canonical key to be set, got %q", entry.CanonicalKey)
+	}
+}
@@ -6,20 +6,61 @@ type FeedEntry struct {
 	Title       string
 	Link        string
 	Description string
-	Published   time.Time
-	GUID        string
+	// Content: content:encoded（RSS）・content（Atom）・content_html（JSON Feed）の本文
+	Content   string
+	Published time.Time
+	// DateSource: Published の由来（公開日時がないフィードでは更新日時や初回検出日時を使う）
+	DateSource DateSource
+	Updated    time.Time
+	GUID       string
+	// CanonicalKey: 重複判定に使う正規化したキー（GUIDと合わせてキャッシュに保存する）
+	CanonicalKey string
+	Authors      []string
+	Categories   []string
+	Enclosures   []Enclosure
+	// ImageURL: エントリーの代表画像のURL
+	ImageURL string
+	// OtherSources: 同じ記事を掲載していた他のフィードのエントリーのリンク（ソースをまとめて投稿する場合）
+	OtherSources []string
+	// Tags: フィードの設定（RSS_URL_N_TAGS）でノートに付けるハッシュタグ
+	Tags []string
+}
+
+// DateSource は FeedEntry.Published に使った日時の種類です
+type DateSource int
+
+const (
+	// DateSourcePublished: フィードの公開日時
+	DateSourcePublished DateSource = iota
+	// DateSourceUpdated: 公開日時がないためフィードの更新日時を使用
+	DateSourceUpdated
+	// DateSourceFirstSeen: 日時がないため初めて検出した日時を使用
+	DateSourceFirstSeen
+)
+
+// Enclosure はエントリーに添付されたメディアです（RSS の enclosure、Atom の rel="enclosure"、JSON Feed の attachments）
+type Enclosure struct {
+	URL    string
+	Type   string
+	Length int64
 }
 
 func NewFeedEntry(title, link, description string, published time.Time, guid string) *FeedEntry {
 	return &FeedEntry{
-		Title:       title,
-		Link:        link,
-		Description: description,
-		Published:   published,
-		GUID:        guid,
+		Title:        title,
+		Link:         link,
+		Description:  description,
+		Published:    published,
+		GUID:         guid,
+		CanonicalKey: CanonicalKey(guid),
 	}
 }
 
+// HasFeedDate はフィードに記載された日時（公開日時または更新日時）を持つかどうかを返します
+func (f *FeedEntry) HasFeedDate() bool {
+	return f.DateSource != DateSourceFirstSeen
+}
+
 func (f *FeedEntry) IsNewerThan(t time.Time) bool {
 	return f.Published.After(t)
 }
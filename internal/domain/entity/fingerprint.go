@@ -0,0 +1,83 @@
+package entity
+
+import (
+	"hash/fnv"
+	"html"
+	"math"
+	"math/bits"
+	"regexp"
+	"strings"
+)
+
+// simHashShingleRunes は SimHash の特徴量に使う文字 n-gram の長さです
+// 分かち書きをしない日本語でも同じ方法で扱えるように、単語ではなく文字単位で区切ります
+const simHashShingleRunes = 3
+
+var htmlTagPattern = regexp.MustCompile(`<[^>]*>`)
+
+// StoryText は近似重複の判定に使うテキスト（タイトルとHTMLタグを除いた概要）を返します
+func StoryText(entry *FeedEntry) string {
+	description := html.UnescapeString(htmlTagPattern.ReplaceAllString(entry.Description, " "))
+	return strings.TrimSpace(entry.Title + "\n" + strings.Join(strings.Fields(description), " "))
+}
+
+// SimHash はテキストの 64bit SimHash を返します
+// NormalizeTitle と同じ正規化をしたテキストの文字 n-gram を特徴量とし、似たテキストほどハミング距離が小さくなります
+func SimHash(text string) uint64 {
+	runes := []rune(NormalizeTitle(text))
+	if len(runes) == 0 {
+		return 0
+	}
+
+	var weights [64]int
+	add := func(shingle string) {
+		h := fnv.New64a()
+		_, _ = h.Write([]byte(shingle))
+		sum := h.Sum64()
+		for i := 0; i < 64; i++ {
+			if sum&(1<<uint(i)) != 0 {
+				weights[i]++
+			} else {
+				weights[i]--
+			}
+		}
+	}
+
+	if len(runes) < simHashShingleRunes {
+		add(string(runes))
+	}
+	for i := 0; i+simHashShingleRunes <= len(runes); i++ {
+		add(string(runes[i : i+simHashShingleRunes]))
+	}
+
+	var hash uint64
+	for i, weight := range weights {
+		if weight > 0 {
+			hash |= 1 << uint(i)
+		}
+	}
+	return hash
+}
+
+// HammingDistance は2つの SimHash の異なるビットの数を返します
+func HammingDistance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// CosineSimilarity は2つの埋め込みベクトルのコサイン類似度を返します
+// 次元が異なる場合やゼロベクトルの場合は 0 を返します
+func CosineSimilarity(a, b []float32) float64 {
+	if len(a) == 0 || len(a) != len(b) {
+		return 0
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
@@ -0,0 +1,91 @@
+package entity
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestSimHash_NearDuplicates(t *testing.T) {
+	testCases := []struct {
+		name    string
+		a       string
+		b       string
+		similar bool
+	}{
+		{
+			name:    "reworded japanese headline",
+			a:       "東京都、来年度から高校授業料を完全無償化へ 所得制限を撤廃",
+			b:       "東京都が来年度から高校授業料を完全無償化 所得制限を撤廃へ",
+			similar: true,
+		},
+		{
+			name:    "reworded english headline",
+			a:       "Apple unveils new iPhone 17 with faster chip and longer battery life",
+			b:       "Apple unveils iPhone 17 with a faster chip and longer battery life",
+			similar: true,
+		},
+		{
+			name:    "unrelated japanese headlines",
+			a:       "東京都、来年度から高校授業料を完全無償化へ 所得制限を撤廃",
+			b:       "大阪で震度4の地震 津波の心配なし",
+			similar: false,
+		},
+		{
+			name:    "unrelated english headlines",
+			a:       "Apple unveils new iPhone 17 with faster chip and longer battery life",
+			b:       "Google releases Android 16 beta for Pixel phones",
+			similar: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			distance := HammingDistance(SimHash(tc.a), SimHash(tc.b))
+			if got := distance <= 10; got != tc.similar {
+				t.Fatalf("expected similar=%v, got distance %d", tc.similar, distance)
+			}
+		})
+	}
+}
+
+func TestSimHash_IgnoresFormatting(t *testing.T) {
+	a := SimHash("ＡＩ規制法案が可決、来年施行へ")
+	b := SimHash("AI規制法案が可決 来年施行へ！")
+	if a != b {
+		t.Fatalf("expected identical hashes, got distance %d", HammingDistance(a, b))
+	}
+	if SimHash("") != 0 {
+		t.Error("expected 0 for empty text")
+	}
+}
+
+func TestStoryText(t *testing.T) {
+	entry := NewFeedEntry("Title", "https://example.tld/1", "<p>Hello&amp;<b>world</b></p>\n<p>again</p>", time.Time{}, "guid")
+	if got := StoryText(entry); got != "Title\nHello& world again" {
+		t.Fatalf("unexpected story text: %q", got)
+	}
+}
+
+func TestCosineSimilarity(t *testing.T) {
+	testCases := []struct {
+		name string
+		a    []float32
+		b    []float32
+		want float64
+	}{
+		{name: "identical", a: []float32{1, 2, 3}, b: []float32{1, 2, 3}, want: 1},
+		{name: "orthogonal", a: []float32{1, 0}, b: []float32{0, 1}, want: 0},
+		{name: "opposite", a: []float32{1, 0}, b: []float32{-1, 0}, want: -1},
+		{name: "dimension mismatch", a: []float32{1, 0}, b: []float32{1}, want: 0},
+		{name: "zero vector", a: []float32{0, 0}, b: []float32{1, 0}, want: 0},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := CosineSimilarity(tc.a, tc.b); math.Abs(got-tc.want) > 1e-9 {
+				t.Fatalf("expected %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,16 @@
+package entity
+
+import "time"
+
+// Lease はレプリカの間で同時に1つだけが持てるリースです
+// 期限までに更新されなかったリースは他のレプリカが引き継げます
+type Lease struct {
+	Name      string
+	Holder    string
+	ExpiresAt time.Time
+}
+
+// IsHeldBy は now の時点で holder がリースを持っているかどうかを返します
+func (l Lease) IsHeldBy(holder string, now time.Time) bool {
+	return l.Holder == holder && now.Before(l.ExpiresAt)
+}
@@ -1,6 +1,9 @@
 package entity
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+)
 
 type NoteVisibility string
 
@@ -13,11 +16,15 @@ const (
 
 type Note struct {
 	Text       string
+	CW         string
 	Visibility NoteVisibility
 }
 
 func NewNoteFromFeed(entry *FeedEntry, visibility NoteVisibility) *Note {
-	text := fmt.Sprintf("📰 %s\n%s", entry.Title, entry.Link)
+	text := fmt.Sprintf("📰 %s\n%s", entry.Title, entry.Link) + otherSourcesText(entry)
+	if hashtags := hashtagText(entry.Tags); hashtags != "" {
+		text = fmt.Sprintf("%s\n%s", text, hashtags)
+	}
 	return &Note{
 		Text:       text,
 		Visibility: visibility,
@@ -31,13 +38,33 @@ func NewNote(text string, visibility NoteVisibility) *Note {
 	}
 }
 
-func NewNoteFromFeedWithSummary(entry *FeedEntry, summary string, visibility NoteVisibility) *Note {
-	if summary == "" {
+func NewNoteFromFeedWithSummary(entry *FeedEntry, summary *Summary, visibility NoteVisibility) *Note {
+	if summary.IsEmpty() {
 		return NewNoteFromFeed(entry, visibility)
 	}
-	text := fmt.Sprintf("📰 %s\n\n【要約】\n%s\n\n%s", entry.Title, summary, entry.Link)
+	text := fmt.Sprintf("📰 %s\n\n【要約】\n%s\n\n%s", entry.Title, summary.Text, entry.Link) + otherSourcesText(entry)
+	if hashtags := noteHashtags(entry, summary); hashtags != "" {
+		text = fmt.Sprintf("%s\n%s", text, hashtags)
+	}
 	return &Note{
 		Text:       text,
+		CW:         summary.ContentWarning,
 		Visibility: visibility,
 	}
 }
+
+func otherSourcesText(entry *FeedEntry) string {
+	if len(entry.OtherSources) == 0 {
+		return ""
+	}
+	return "\n\n【他のソース】\n" + strings.Join(entry.OtherSources, "\n")
+}
+
+// noteHashtags はフィードのタグと要約のハッシュタグを合わせたハッシュタグを返します
+func noteHashtags(entry *FeedEntry, summary *Summary) string {
+	tags := append([]string(nil), entry.Tags...)
+	if summary != nil {
+		tags = append(tags, summary.Hashtags...)
+	}
+	return hashtagText(tags)
+}
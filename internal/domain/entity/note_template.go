@@ -0,0 +1,78 @@
+package entity
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+type NoteTemplate struct {
+	tmpl *template.Template
+}
+
+type noteTemplateData struct {
+	Title          string
+	Link           string
+	Description    string
+	Content        string
+	Author         string
+	Authors        []string
+	Categories     []string
+	ImageURL       string
+	Enclosures     []Enclosure
+	OtherSources   []string
+	Summary        string
+	Hashtags       string
+	Language       string
+	Sentiment      string
+	Headline       string
+	ContentWarning string
+}
+
+func NewNoteTemplate(text string) (*NoteTemplate, error) {
+	tmpl, err := template.New("note").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse note template: %w", err)
+	}
+	return &NoteTemplate{tmpl: tmpl}, nil
+}
+
+func (t *NoteTemplate) Render(entry *FeedEntry, summary *Summary, visibility NoteVisibility) (*Note, error) {
+	data := noteTemplateData{
+		Title:        entry.Title,
+		Link:         entry.Link,
+		Description:  entry.Description,
+		Content:      entry.Content,
+		Author:       strings.Join(entry.Authors, ", "),
+		Authors:      entry.Authors,
+		Categories:   entry.Categories,
+		ImageURL:     entry.ImageURL,
+		Enclosures:   entry.Enclosures,
+		OtherSources: entry.OtherSources,
+		Hashtags:     hashtagText(entry.Tags),
+	}
+	if !summary.IsEmpty() {
+		data.Summary = summary.Text
+		data.Hashtags = noteHashtags(entry, summary)
+		data.Language = summary.Language
+		data.Sentiment = string(summary.Sentiment)
+		data.Headline = summary.Headline
+		data.ContentWarning = summary.ContentWarning
+	}
+
+	var builder strings.Builder
+	if err := t.tmpl.Execute(&builder, data); err != nil {
+		return nil, fmt.Errorf("failed to render note template: %w", err)
+	}
+
+	text := strings.TrimSpace(builder.String())
+	if text == "" {
+		return nil, fmt.Errorf("note template rendered empty text")
+	}
+
+	return &Note{
+		Text:       text,
+		CW:         data.ContentWarning,
+		Visibility: visibility,
+	}, nil
+}
@@ -0,0 +1,91 @@
+package entity
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNewNoteTemplate_InvalidTemplate(t *testing.T) {
+	if _, err := NewNoteTemplate("{{.Title"); err == nil {
+		t.Error("expected error for invalid template, got nil")
+	}
+}
+
+func TestNoteTemplate_Render(t *testing.T) {
+	entry := NewFeedEntry("Title", "https://example.tld/1", "Desc", time.Now(), "guid-1")
+	entry.Authors = []string{"Alice", "Bob"}
+	entry.Categories = []string{"Go", "Misskey"}
+	entry.ImageURL = "https://example.tld/1.png"
+
+	tests := []struct {
+		name      string
+		template  string
+		summary   *Summary
+		wantText  string
+		wantCW    string
+		wantError bool
+	}{
+		{
+			name:     "without summary",
+			template: "{{.Title}} {{.Link}}{{if .Summary}} {{.Summary}}{{end}}",
+			wantText: "Title https://example.tld/1",
+		},
+		{
+			name:     "with structured summary",
+			template: "[{{.Sentiment}}] {{.Headline}}\n{{.Summary}}\n{{.Hashtags}}",
+			summary: &Summary{
+				Text:           "要約",
+				Hashtags:       []string{"Go"},
+				Sentiment:      SentimentNeutral,
+				Headline:       "見出し",
+				ContentWarning: "注意",
+			},
+			wantText: "[neutral] 見出し\n要約\n#Go",
+			wantCW:   "注意",
+		},
+		{
+			name:     "feed metadata",
+			template: "{{.Title}} by {{.Author}}{{range .Categories}} #{{.}}{{end}}\n{{.ImageURL}}",
+			wantText: "Title by Alice, Bob #Go #Misskey\nhttps://example.tld/1.png",
+		},
+		{
+			name:      "unknown field",
+			template:  "{{.Unknown}}",
+			wantError: true,
+		},
+		{
+			name:      "empty output",
+			template:  "{{.Summary}}",
+			wantError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, err := NewNoteTemplate(tt.template)
+			if err != nil {
+				t.Fatalf("failed to parse template: %v", err)
+			}
+
+			note, err := tmpl.Render(entry, tt.summary, VisibilityHome)
+			if tt.wantError {
+				if err == nil {
+					t.Fatalf("expected error, got note %+v", note)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if note.Text != tt.wantText {
+				t.Errorf("expected text %q, got %q", tt.wantText, note.Text)
+			}
+			if note.CW != tt.wantCW {
+				t.Errorf("expected CW %q, got %q", tt.wantCW, note.CW)
+			}
+			if note.Visibility != VisibilityHome {
+				t.Errorf("expected visibility %v, got %v", VisibilityHome, note.Visibility)
+			}
+		})
+	}
+}
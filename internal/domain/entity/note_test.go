@@ -1,6 +1,7 @@
 package entity
 
 import (
+	"strings"
 	"testing"
 	"time"
 )
@@ -21,6 +22,36 @@ func TestNewNoteFromFeed(t *testing.T) {
 	}
 }
 
+func TestNewNoteFromFeed_OtherSources(t *testing.T) {
+	entry := NewFeedEntry("Test Article", "https://example.tld/article", "Description", time.Now(), "guid-1")
+	entry.OtherSources = []string{"https://mirror.tld/article", "https://aggregator.tld/article"}
+
+	note := NewNoteFromFeed(entry, VisibilityHome)
+
+	expectedText := "📰 Test Article\nhttps://example.tld/article\n\n【他のソース】\nhttps://mirror.tld/article\nhttps://aggregator.tld/article"
+	if note.Text != expectedText {
+		t.Errorf("expected text '%s', got '%s'", expectedText, note.Text)
+	}
+}
+
+func TestNewNote_Tags(t *testing.T) {
+	entry := NewFeedEntry("Test Article", "https://example.tld/article", "Description", time.Now(), "guid-1")
+	entry.Tags = []string{"Tech", "Go Lang"}
+
+	note := NewNoteFromFeed(entry, VisibilityHome)
+	expectedText := "📰 Test Article\nhttps://example.tld/article\n#Tech #Go_Lang"
+	if note.Text != expectedText {
+		t.Errorf("expected text '%s', got '%s'", expectedText, note.Text)
+	}
+
+	// 要約のハッシュタグはフィードのタグの後に付け、重複は1つにまとめる
+	summary := &Summary{Text: "要約", Hashtags: []string{"Go_Lang", "RSS"}}
+	note = NewNoteFromFeedWithSummary(entry, summary, VisibilityHome)
+	if !strings.HasSuffix(note.Text, "\n#Tech #Go_Lang #RSS") {
+		t.Errorf("expected feed tags and summary hashtags, got '%s'", note.Text)
+	}
+}
+
 func TestNewNote(t *testing.T) {
 	note := NewNote("Test content", VisibilityPublic)
 
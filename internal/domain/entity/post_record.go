@@ -0,0 +1,44 @@
+package entity
+
+import "time"
+
+// PostState はエントリーを投稿する処理の進み具合です
+type PostState string
+
+const (
+	// PostStatePending は投稿の対象として選んだが、まだ投稿していない状態です
+	PostStatePending PostState = "pending"
+	// PostStatePosting は Misskey に投稿を依頼し、結果を記録する前の状態です
+	// この状態のまま停止した場合は、起動時に投稿履歴で投稿できたかどうかを確認します
+	PostStatePosting PostState = "posting"
+	// PostStatePosted は投稿し、処理済みとして記録した状態です
+	PostStatePosted PostState = "posted"
+)
+
+// PostRecord はエントリーごとの投稿の状態です
+type PostRecord struct {
+	FeedURL      string
+	GUID         string
+	CanonicalKey string
+	// Published: フィードの公開日時（日時のないエントリーはゼロ値で、最新の公開日時を更新しません）
+	Published time.Time
+	State     PostState
+	// NoteText: 投稿するノートの本文（投稿履歴と照合するために記録します）
+	NoteText  string
+	UpdatedAt time.Time
+}
+
+// NewPostRecord は entry を投稿する前の記録を作成します
+func NewPostRecord(feedURL string, entry *FeedEntry) *PostRecord {
+	record := &PostRecord{
+		FeedURL:      feedURL,
+		GUID:         entry.GUID,
+		CanonicalKey: entry.CanonicalKey,
+		State:        PostStatePending,
+		UpdatedAt:    time.Now(),
+	}
+	if entry.HasFeedDate() {
+		record.Published = entry.Published
+	}
+	return record
+}
@@ -0,0 +1,47 @@
+package entity
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// minStoryTitleRunes より短いタイトルは「お知らせ」のような汎用的なものが多いため、フィードをまたいだ重複判定に使いません
+const minStoryTitleRunes = 10
+
+// StoryKeys はフィードをまたいで同じ記事かどうかを判定するためのキーを返します
+// 正規化したURL（リンク・正規化したキー）と正規化したタイトルが対象です
+func StoryKeys(entry *FeedEntry) []string {
+	var keys []string
+	seen := make(map[string]bool)
+	add := func(key string) {
+		if key != "" && !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+
+	if canonical, ok := CanonicalURL(entry.Link); ok {
+		add("url:" + canonical)
+	}
+	if canonical, ok := CanonicalURL(entry.CanonicalKey); ok {
+		add("url:" + canonical)
+	}
+	if title := NormalizeTitle(entry.Title); len([]rune(title)) >= minStoryTitleRunes {
+		add("title:" + title)
+	}
+	return keys
+}
+
+// NormalizeTitle は全角・半角や大文字・小文字、記号や空白の違いを取り除いたタイトルを返します
+func NormalizeTitle(title string) string {
+	title = strings.ToLower(norm.NFKC.String(title))
+	var builder strings.Builder
+	for _, r := range title {
+		if unicode.IsLetter(r) || unicode.IsNumber(r) {
+			builder.WriteRune(r)
+		}
+	}
+	return builder.String()
+}
@@ -0,0 +1,40 @@
+package entity
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestNormalizeTitle(t *testing.T) {
+	tests := []struct {
+		title string
+		want  string
+	}{
+		{title: "Go 1.24 Released!", want: "go124released"},
+		{title: "ＧＯ　１．２４　released", want: "go124released"},
+		{title: "【速報】新しい路線が開業", want: "速報新しい路線が開業"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			if got := NormalizeTitle(tt.title); got != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestStoryKeys(t *testing.T) {
+	entry := NewFeedEntry("Go 1.24 Released!", "http://example.tld/go/?utm_source=rss", "Desc", time.Now(), "tag:example.tld,2024:1")
+	want := []string{"url:https://example.tld/go", "title:go124released"}
+	if got := StoryKeys(entry); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+
+	short := NewFeedEntry("お知らせ", "https://example.tld/news", "Desc", time.Now(), "https://example.tld/news")
+	want = []string{"url:https://example.tld/news"}
+	if got := StoryKeys(short); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected short titles to be ignored, got %v", got)
+	}
+}
@@ -0,0 +1,51 @@
+package entity
+
+import "strings"
+
+type Sentiment string
+
+const (
+	SentimentPositive Sentiment = "positive"
+	SentimentNeutral  Sentiment = "neutral"
+	SentimentNegative Sentiment = "negative"
+)
+
+type Summary struct {
+	Text           string
+	Hashtags       []string
+	Language       string
+	Sentiment      Sentiment
+	Headline       string
+	ContentWarning string
+}
+
+func NewSummary(text string) *Summary {
+	return &Summary{Text: text}
+}
+
+func (s *Summary) IsEmpty() bool {
+	return s == nil || strings.TrimSpace(s.Text) == ""
+}
+
+func (s *Summary) HashtagText() string {
+	if s == nil {
+		return ""
+	}
+	return hashtagText(s.Hashtags)
+}
+
+// hashtagText は tags を「#」付きのハッシュタグにして空白で区切ります
+// 空白を含むタグは「_」でつなぎ、同じタグは1つにまとめます
+func hashtagText(tags []string) string {
+	hashtags := make([]string, 0, len(tags))
+	seen := make(map[string]bool)
+	for _, tag := range tags {
+		tag = strings.Join(strings.Fields(strings.TrimPrefix(strings.TrimSpace(tag), "#")), "_")
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		hashtags = append(hashtags, "#"+tag)
+	}
+	return strings.Join(hashtags, " ")
+}
@@ -0,0 +1,43 @@
+package entity
+
+import "testing"
+
+func TestSummary_IsEmpty(t *testing.T) {
+	tests := []struct {
+		name     string
+		summary  *Summary
+		expected bool
+	}{
+		{"nil summary", nil, true},
+		{"blank text", NewSummary("  "), true},
+		{"with text", NewSummary("要約"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.summary.IsEmpty(); got != tt.expected {
+				t.Errorf("expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestSummary_HashtagText(t *testing.T) {
+	tests := []struct {
+		name     string
+		summary  *Summary
+		expected string
+	}{
+		{"nil summary", nil, ""},
+		{"no hashtags", NewSummary("要約"), ""},
+		{"normalize hashtags", &Summary{Hashtags: []string{"Go", "#RSS", " ", "Misskey Bot"}}, "#Go #RSS #Misskey_Bot"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.summary.HashtagText(); got != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,40 @@
+package entity
+
+import "time"
+
+type TokenUsage struct {
+	Provider     string
+	Model        string
+	InputTokens  int64
+	OutputTokens int64
+}
+
+func (u TokenUsage) IsZero() bool {
+	return u.InputTokens == 0 && u.OutputTokens == 0
+}
+
+type ModelPrice struct {
+	InputPerMillion  float64
+	OutputPerMillion float64
+}
+
+func (p ModelPrice) Cost(usage TokenUsage) float64 {
+	return (float64(usage.InputTokens)*p.InputPerMillion + float64(usage.OutputTokens)*p.OutputPerMillion) / 1_000_000
+}
+
+type DailyUsage struct {
+	Day          time.Time
+	Provider     string
+	Model        string
+	InputTokens  int64
+	OutputTokens int64
+	Cost         float64
+}
+
+func TotalCost(usages []DailyUsage) float64 {
+	var total float64
+	for _, u := range usages {
+		total += u.Cost
+	}
+	return total
+}
@@ -0,0 +1,37 @@
+package entity
+
+import (
+	"math"
+	"testing"
+)
+
+func TestModelPrice_Cost(t *testing.T) {
+	tests := []struct {
+		name     string
+		price    ModelPrice
+		usage    TokenUsage
+		expected float64
+	}{
+		{"zero usage", ModelPrice{InputPerMillion: 1, OutputPerMillion: 2}, TokenUsage{}, 0},
+		{"input and output", ModelPrice{InputPerMillion: 0.5, OutputPerMillion: 1.5}, TokenUsage{InputTokens: 2_000_000, OutputTokens: 1_000_000}, 2.5},
+		{"no price", ModelPrice{}, TokenUsage{InputTokens: 1000, OutputTokens: 1000}, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.price.Cost(tt.usage); math.Abs(got-tt.expected) > 1e-9 {
+				t.Errorf("expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestTotalCost(t *testing.T) {
+	usages := []DailyUsage{{Cost: 0.25}, {Cost: 0.5}}
+	if got := TotalCost(usages); math.Abs(got-0.75) > 1e-9 {
+		t.Errorf("expected 0.75, got %v", got)
+	}
+	if got := TotalCost(nil); got != 0 {
+		t.Errorf("expected 0, got %v", got)
+	}
+}
@@ -0,0 +1,37 @@
+package entity
+
+import "time"
+
+// WebSubState は WebSub の購読の状態です
+type WebSubState string
+
+const (
+	// WebSubStatePending はハブに購読を要求し、コールバックでの確認を待っている状態です
+	WebSubStatePending WebSubState = "pending"
+	// WebSubStateActive はハブが購読を確認し、更新を配信している状態です
+	WebSubStateActive WebSubState = "active"
+	// WebSubStateFailed は購読の要求か確認に失敗した状態です。フィードはポーリングで取得します
+	WebSubStateFailed WebSubState = "failed"
+)
+
+// WebSubSubscription はフィードのハブへの購読です
+// ID はコールバックURLの末尾に付け、配信がどの購読のものかを判別します
+type WebSubSubscription struct {
+	ID      string
+	FeedURL string
+	Hub     string
+	Topic   string
+	// Secret: 配信された内容の HMAC 署名の検証に使う鍵
+	Secret      string
+	State       WebSubState
+	RequestedAt time.Time
+	// ExpiresAt: ハブが確認した購読の期限
+	ExpiresAt time.Time
+	// Missed: 投稿できなかった配信があり、購読が有効でも次の取得でポーリングするかどうか
+	Missed bool
+}
+
+// IsActive は now の時点でハブが更新を配信しているかどうかを返します
+func (s *WebSubSubscription) IsActive(now time.Time) bool {
+	return s.State == WebSubStateActive && now.Before(s.ExpiresAt)
+}
@@ -7,7 +7,18 @@ import (
 
 type CacheRepository interface {
 	GetLatestPublishedTime(ctx context.Context, rssURL string) (time.Time, error)
+	// SaveLatestPublishedTime は published が記録済みの日時より新しい場合のみ更新し、古い日時では巻き戻しません
 	SaveLatestPublishedTime(ctx context.Context, rssURL string, published time.Time) error
-	IsProcessed(ctx context.Context, guid string) (bool, error)
-	MarkAsProcessed(ctx context.Context, guid string) error
+	// IsProcessed は feedURL のエントリーのうち guid または canonicalKey が一致するものを処理済みかどうかを返します
+	// 処理済みの状態はフィードごとに記録するため、他のフィードの同じGUIDとは区別します
+	IsProcessed(ctx context.Context, feedURL, guid, canonicalKey string) (bool, error)
+	// MarkAsProcessed は feedURL の guid を正規化したキーとともに処理済みとして記録します
+	MarkAsProcessed(ctx context.Context, feedURL, guid, canonicalKey string) error
+	// RecordFirstSeen は feedURL の guid を初めて検出した日時を返します
+	// 未記録の場合は seen を記録して返し、isNew に true を返します
+	RecordFirstSeen(ctx context.Context, feedURL, guid string, seen time.Time) (firstSeen time.Time, isNew bool, err error)
+	// HasRecentStory は feedURL 以外のフィードが since 以降に keys のいずれかと一致する記事を投稿したかどうかを返します
+	HasRecentStory(ctx context.Context, feedURL string, keys []string, since time.Time) (bool, error)
+	// SaveRecentStory は feedURL が投稿した記事のキーを記録します
+	SaveRecentStory(ctx context.Context, feedURL string, keys []string, postedAt time.Time) error
 }
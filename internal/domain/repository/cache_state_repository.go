@@ -0,0 +1,17 @@
+package repository
+
+import (
+	"context"
+
+	"misskeyRSSbot/internal/domain/entity"
+)
+
+// CacheStateRepository はキャッシュの状態を書き出し、別のキャッシュに取り込むためのインターフェースです
+type CacheStateRepository interface {
+	// ExportState はキャッシュに記録したすべての状態を返します
+	ExportState(ctx context.Context) (*entity.CacheSnapshot, error)
+	// ImportState は snapshot を既存の状態に統合します
+	// 最新の公開日時は新しい方、初回検出日時は古い方を残し、処理済みのGUIDは既存の記録を優先します
+	// 投稿の状態は更新日時の新しい方を残します
+	ImportState(ctx context.Context, snapshot *entity.CacheSnapshot) error
+}
@@ -0,0 +1,8 @@
+package repository
+
+import "context"
+
+// CanonicalURLResolver は記事ページの <link rel="canonical"> から正規のURLを解決します
+type CanonicalURLResolver interface {
+	ResolveCanonicalURL(ctx context.Context, url string) (string, error)
+}
@@ -0,0 +1,9 @@
+package repository
+
+import "context"
+
+// EmbeddingRepository はテキストの埋め込みベクトルを生成するインターフェース
+type EmbeddingRepository interface {
+	// Embed は texts と同じ順序で埋め込みベクトルを返します
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+}
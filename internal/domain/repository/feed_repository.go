@@ -2,6 +2,7 @@ package repository
 
 import (
 	"context"
+	"io"
 
 	"misskeyRSSbot/internal/domain/entity"
 )
@@ -9,3 +10,9 @@ import (
 type FeedRepository interface {
 	Fetch(ctx context.Context, url string) ([]*entity.FeedEntry, error)
 }
+
+// FeedParser は取得済みのフィードの本文からエントリーを読み取ります
+// WebSub で配信された内容のように、取得せずに受け取ったフィードに使います
+type FeedParser interface {
+	Parse(r io.Reader) ([]*entity.FeedEntry, error)
+}
@@ -0,0 +1,17 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"misskeyRSSbot/internal/domain/entity"
+)
+
+// LeaseRepository はレプリカの間で共有するリースを記録します
+type LeaseRepository interface {
+	// AcquireLease は holder が name のリースを取得または更新し、期限を ttl 後にします
+	// 他のレプリカが期限内のリースを持っている場合は取得せず、acquired に false と現在のリースを返します
+	AcquireLease(ctx context.Context, name, holder string, ttl time.Duration) (lease entity.Lease, acquired bool, err error)
+	// ReleaseLease は holder が持つ name のリースを解放します
+	ReleaseLease(ctx context.Context, name, holder string) error
+}
@@ -0,0 +1,6 @@
+package repository
+
+type MetricsRepository interface {
+	AddCounter(name string, value float64, labels map[string]string)
+	SetGauge(name string, value float64, labels map[string]string)
+}
@@ -0,0 +1,28 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"misskeyRSSbot/internal/domain/entity"
+)
+
+// PostStateRepository はエントリーごとの投稿の状態を記録します
+// 投稿の前後で状態を記録し、投稿中に停止しても再起動時に重複して投稿しないようにします
+type PostStateRepository interface {
+	// SavePostState は record の状態を記録します
+	SavePostState(ctx context.Context, record *entity.PostRecord) error
+	// CompletePost は record を投稿済みとして記録します
+	// 処理済みのGUIDと最新の公開日時も同時に更新し、途中で失敗した場合はいずれも更新しません
+	CompletePost(ctx context.Context, record *entity.PostRecord) error
+	// ListPostStates は state の記録を更新日時の古い順に返します
+	ListPostStates(ctx context.Context, state entity.PostState) ([]*entity.PostRecord, error)
+	// ListUnfinishedPosts は feedURL の投稿済みでない（pending または posting の）記録を更新日時の古い順に返します
+	ListUnfinishedPosts(ctx context.Context, feedURL string) ([]*entity.PostRecord, error)
+}
+
+// PostHistoryRepository はボットが投稿したノートの履歴を参照します
+type PostHistoryRepository interface {
+	// HasPosted は since 以降に text と同じ本文のノートを投稿したかどうかを返します
+	HasPosted(ctx context.Context, text string, since time.Time) (bool, error)
+}
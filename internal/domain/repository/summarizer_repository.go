@@ -1,14 +1,34 @@
 package repository
 
-import "context"
+import (
+	"context"
+
+	"misskeyRSSbot/internal/domain/entity"
+)
+
+// SummarizeRequest は要約対象の記事とフィードごとの要約設定を表します
+type SummarizeRequest struct {
+	// URL: 記事のURL（LLMがアクセスして内容を取得）
+	URL string
+	// Title: 記事タイトル（コンテキスト情報として使用）
+	Title string
+	// SystemInstruction: 空でなければプロバイダ既定のシステムプロンプトを置き換える
+	SystemInstruction string
+	// Language: 出力言語（空の場合はプロバイダ既定）
+	Language string
+	// MaxLength: 要約の最大文字数（0の場合は指定なし）
+	MaxLength int
+	// Provider: 使用するLLMプロバイダ（空の場合は既定のプロバイダ）
+	Provider string
+	// Model: 使用するモデル（空の場合はプロバイダ既定のモデル）
+	Model string
+}
 
 // SummarizerRepository はコンテンツの要約機能を提供するインターフェース
 type SummarizerRepository interface {
-	// Summarize はURLの記事を要約します
-	// url: 記事のURL（LLMがアクセスして内容を取得）
-	// title: 記事タイトル（コンテキスト情報として使用）
-	// 戻り値: 要約文字列, エラー
-	Summarize(ctx context.Context, url, title string) (string, error)
+	// Summarize はリクエストで指定された記事を要約します
+	// 戻り値: 要約（構造化出力が無効な場合は本文のみ）, エラー
+	Summarize(ctx context.Context, req SummarizeRequest) (*entity.Summary, error)
 
 	// IsEnabled は要約機能が有効かどうかを返します
 	IsEnabled() bool
@@ -0,0 +1,17 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"misskeyRSSbot/internal/domain/entity"
+)
+
+type UsageRepository interface {
+	AddTokenUsage(ctx context.Context, day time.Time, usage entity.TokenUsage, cost float64) error
+	GetDailyUsage(ctx context.Context, day time.Time) ([]entity.DailyUsage, error)
+}
+
+type UsageRecorder interface {
+	RecordUsage(ctx context.Context, usage entity.TokenUsage)
+}
@@ -0,0 +1,31 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"misskeyRSSbot/internal/domain/entity"
+)
+
+// WebSubHubRepository は WebSub のハブを探し、購読を要求します
+type WebSubHubRepository interface {
+	// DiscoverHub はフィードが rel="hub" で示すハブと、rel="self" で示す topic のURLを返します
+	// ハブを示していない場合は hub が空になります
+	DiscoverHub(ctx context.Context, feedURL string) (hub, topic string, err error)
+	// Subscribe は sub のハブに callbackURL への配信を要求します
+	// ハブは要求を受け付けた後、callbackURL への GET で購読を確認します
+	Subscribe(ctx context.Context, sub *entity.WebSubSubscription, callbackURL string, lease time.Duration) error
+}
+
+// WebSubSubscriptionRepository は WebSub の購読をレプリカの間で共有します
+// コールバックのURLは全レプリカで共通のため、どのレプリカに届いた確認や配信でも購読を見つけられるようにします
+type WebSubSubscriptionRepository interface {
+	// SaveWebSubSubscription は sub を記録します。同じIDの購読があれば置き換えます
+	SaveWebSubSubscription(ctx context.Context, sub entity.WebSubSubscription) error
+	// GetWebSubSubscription は id の購読を返します。記録がない場合は ok が false になります
+	GetWebSubSubscription(ctx context.Context, id string) (sub entity.WebSubSubscription, ok bool, err error)
+	// ListWebSubSubscriptions は記録したすべての購読を返します
+	ListWebSubSubscriptions(ctx context.Context) ([]entity.WebSubSubscription, error)
+	// DeleteWebSubSubscription は id の購読を削除します
+	DeleteWebSubSubscription(ctx context.Context, id string) error
+}
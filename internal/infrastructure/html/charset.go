@@ -0,0 +1,82 @@
+package html
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html/charset"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/unicode"
+)
+
+// metaCharsetScanBytes はmetaタグの文字コード宣言を探す範囲です
+// HTML仕様のプレスキャンは1024バイトですが、古い日本語サイトでは
+// 宣言の前に長いコメントやスクリプトが置かれていることがあるため広めに取ります
+const metaCharsetScanBytes = 4096
+
+var (
+	metaCharsetPattern = regexp.MustCompile(`(?i)<meta\s[^>]*charset\s*=\s*["']?\s*([a-z0-9_:.\-]+)`)
+	iso2022JPEscape    = []byte("\x1b$B")
+)
+
+// decodeHTML はレスポンスボディをUTF-8に変換します
+// BOM、Content-Typeのcharset、metaタグの宣言の順に文字コードを判定し、
+// いずれもない場合はISO-2022-JPのエスケープシーケンスを含むかを確認し、それ以外はUTF-8として扱います
+func decodeHTML(body []byte, contentType string) ([]byte, error) {
+	enc, name := detectEncoding(body, contentType)
+	if enc == nil || enc == encoding.Nop {
+		return body, nil
+	}
+
+	decoded, err := enc.NewDecoder().Bytes(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %w", name, err)
+	}
+	return decoded, nil
+}
+
+func detectEncoding(body []byte, contentType string) (encoding.Encoding, string) {
+	if enc, name := encodingFromBOM(body); enc != nil {
+		return enc, name
+	}
+
+	if _, params, err := mime.ParseMediaType(contentType); err == nil {
+		if enc, name := charset.Lookup(params["charset"]); enc != nil {
+			return enc, name
+		}
+	}
+
+	head := body[:min(len(body), metaCharsetScanBytes)]
+	if match := metaCharsetPattern.FindSubmatch(head); match != nil {
+		label := string(match[1])
+		// UTF-16の宣言はASCII互換のバイト列の中に書かれているため、実際にはUTF-8として扱う（HTML仕様と同じ）
+		if strings.HasPrefix(strings.ToLower(label), "utf-16") {
+			label = "utf-8"
+		}
+		if enc, name := charset.Lookup(label); enc != nil {
+			return enc, name
+		}
+	}
+
+	// ISO-2022-JPは7ビットのためUTF-8としても妥当になるので、先にエスケープシーケンスを確認する
+	if bytes.Contains(body, iso2022JPEscape) {
+		return japanese.ISO2022JP, "iso-2022-jp"
+	}
+	return encoding.Nop, "utf-8"
+}
+
+func encodingFromBOM(body []byte) (encoding.Encoding, string) {
+	switch {
+	case bytes.HasPrefix(body, []byte{0xEF, 0xBB, 0xBF}):
+		return unicode.UTF8BOM, "utf-8"
+	case bytes.HasPrefix(body, []byte{0xFE, 0xFF}):
+		return unicode.UTF16(unicode.BigEndian, unicode.ExpectBOM), "utf-16be"
+	case bytes.HasPrefix(body, []byte{0xFF, 0xFE}):
+		return unicode.UTF16(unicode.LittleEndian, unicode.ExpectBOM), "utf-16le"
+	}
+	return nil, ""
+}
@@ -0,0 +1,123 @@
+package html
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const charsetFixtureText = "県内の老舗旅館が今年で創業百年を迎え、地元の住民や常連客を招いた記念行事が開かれました。\n\n" +
+	"行事では、創業当時の写真や宿帳が展示され、四代目の館主が、これまで旅館を支えてくれた人たちへの感謝を述べました。\n\n" +
+	"館主は「次の百年も、地域とともに歩んでいきたい」と話していました。"
+
+func TestFetchArticleText_Charset(t *testing.T) {
+	testCases := []struct {
+		name        string
+		fixture     string
+		contentType string
+	}{
+		{
+			name:        "Shift_JIS from Content-Type",
+			fixture:     "shift_jis.html",
+			contentType: "text/html; charset=Shift_JIS",
+		},
+		{
+			name:        "EUC-JP from meta http-equiv",
+			fixture:     "euc-jp.html",
+			contentType: "text/html",
+		},
+		{
+			name:        "ISO-2022-JP from meta charset",
+			fixture:     "iso-2022-jp.html",
+			contentType: "text/html",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			body, err := os.ReadFile(filepath.Join("testdata", "charset", tc.fixture))
+			if err != nil {
+				t.Fatalf("failed to read fixture: %v", err)
+			}
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", tc.contentType)
+				_, _ = w.Write(body)
+			}))
+			defer server.Close()
+
+			got, err := NewFetcher(server.Client(), nil).FetchArticleText(context.Background(), server.URL)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != charsetFixtureText {
+				t.Fatalf("expected %q, got %q", charsetFixtureText, got)
+			}
+		})
+	}
+}
+
+func TestDetectEncoding(t *testing.T) {
+	testCases := []struct {
+		name        string
+		body        []byte
+		contentType string
+		want        string
+	}{
+		{
+			name:        "UTF-8 BOM wins over Content-Type",
+			body:        []byte("\xEF\xBB\xBF<html></html>"),
+			contentType: "text/html; charset=Shift_JIS",
+			want:        "utf-8",
+		},
+		{
+			name: "UTF-16LE BOM",
+			body: []byte{0xFF, 0xFE, '<', 0x00},
+			want: "utf-16le",
+		},
+		{
+			name:        "Content-Type wins over meta",
+			body:        []byte(`<meta charset="EUC-JP">`),
+			contentType: "text/html; charset=shift_jis",
+			want:        "shift_jis",
+		},
+		{
+			name:        "unknown Content-Type charset falls back to meta",
+			body:        []byte(`<meta charset="EUC-JP">`),
+			contentType: "text/html; charset=x-unknown",
+			want:        "euc-jp",
+		},
+		{
+			name: "meta http-equiv",
+			body: []byte(`<meta http-equiv="Content-Type" content="text/html; charset=Shift_JIS">`),
+			want: "shift_jis",
+		},
+		{
+			name: "meta UTF-16 is treated as UTF-8",
+			body: []byte(`<meta charset="utf-16">`),
+			want: "utf-8",
+		},
+		{
+			name: "undeclared ISO-2022-JP",
+			body: []byte("<p>\x1b$B$3$s$K$A$O\x1b(B</p>"),
+			want: "iso-2022-jp",
+		},
+		{
+			name: "undeclared defaults to UTF-8",
+			body: []byte("<p>こんにちは</p>"),
+			want: "utf-8",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, got := detectEncoding(tc.body, tc.contentType)
+			if got != tc.want {
+				t.Fatalf("expected %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
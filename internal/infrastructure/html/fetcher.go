@@ -5,11 +5,13 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
-	"strings"
-	"time"
+	"net/url"
 
 	"github.com/PuerkitoBio/goquery"
+
+	"misskeyRSSbot/internal/infrastructure/httpclient"
 )
 
 const (
@@ -17,49 +19,247 @@ const (
 	maxTextChars = 8000
 )
 
-func FetchArticleText(ctx context.Context, url string, timeout time.Duration) (string, error) {
-	client := &http.Client{Timeout: timeout}
+// Fetcher は記事ページを取得して本文を抽出します
+type Fetcher struct {
+	client      *http.Client
+	rules       *Rules
+	maxPDFPages int
+}
+
+// FetcherOption は Fetcher の設定を変更するオプションです
+type FetcherOption func(*Fetcher)
+
+// WithMaxPDFPages はPDFからテキストを抽出する最大ページ数を設定します（0以下の場合は既定値）
+func WithMaxPDFPages(pages int) FetcherOption {
+	return func(f *Fetcher) {
+		if pages > 0 {
+			f.maxPDFPages = pages
+		}
+	}
+}
+
+// NewFetcher は client で記事を取得する Fetcher を作成します
+// client が nil の場合は、プライベートアドレスなどへの接続を拒否する既定の取得用クライアントを使用します
+func NewFetcher(client *http.Client, rules *Rules, opts ...FetcherOption) *Fetcher {
+	if client == nil {
+		client = httpclient.NewDefaultFetchClient()
+	}
+	f := &Fetcher{client: client, rules: rules, maxPDFPages: DefaultMaxPDFPages}
+	for _, opt := range opts {
+		opt(f)
+	}
+	return f
+}
+
+// FetchArticleText は記事ページを取得して本文テキストを返します
+// ホストのルールがあればそれを優先し、なければ汎用の抽出にフォールバックします
+// PDFの場合は先頭から設定したページ数までのテキストを返します
+func (f *Fetcher) FetchArticleText(ctx context.Context, url string) (string, error) {
+	resp, err := fetchResource(ctx, f.client, url)
+	if err != nil {
+		return "", err
+	}
+
+	if resp.pdf {
+		text, err := extractPDFText(resp.body, f.maxPDFPages)
+		if err != nil {
+			return "", err
+		}
+		if text == "" {
+			return "", fmt.Errorf("empty pdf content")
+		}
+		return truncateText(text), nil
+	}
+
+	doc, err := resp.document()
+	if err != nil {
+		return "", err
+	}
+
+	rule, hasRule := f.rules.Lookup(doc.Url.Hostname())
+	if hasRule {
+		if linked := followLinkedDocument(ctx, f.client, doc, rule); linked != nil {
+			doc = linked
+			if linkedRule, ok := f.rules.Lookup(doc.Url.Hostname()); ok {
+				rule = linkedRule
+			}
+		}
+	}
+
+	var text string
+	if hasRule {
+		text = extractWithRule(doc, rule)
+	}
+	if text == "" {
+		text = extractArticleText(doc)
+	}
+	if text == "" {
+		return "", fmt.Errorf("empty article content")
+	}
+
+	return truncateText(text), nil
+}
+
+// ResolveCanonicalURL は記事ページの <link rel="canonical"> のURLを返します
+// canonical がない場合はリダイレクト後のURLを返します
+func (f *Fetcher) ResolveCanonicalURL(ctx context.Context, url string) (string, error) {
+	resp, err := fetchResource(ctx, f.client, url)
+	if err != nil {
+		return "", err
+	}
+	if resp.pdf {
+		return resp.url.String(), nil
+	}
+
+	doc, err := resp.document()
+	if err != nil {
+		return "", err
+	}
+
+	href, ok := doc.Find(`link[rel="canonical"]`).First().Attr("href")
+	if !ok || href == "" {
+		return doc.Url.String(), nil
+	}
+	canonical, err := doc.Url.Parse(href)
+	if err != nil || (canonical.Scheme != "http" && canonical.Scheme != "https") {
+		return doc.Url.String(), nil
+	}
+	return canonical.String(), nil
+}
+
+func truncateText(text string) string {
+	if len([]rune(text)) > maxTextChars {
+		return string([]rune(text)[:maxTextChars])
+	}
+	return text
+}
+
+// fetchedResource は取得したレスポンスのボディです
+type fetchedResource struct {
+	body        []byte
+	contentType string
+	url         *url.URL
+	pdf         bool
+}
+
+// fetchResource は url を取得してボディを返します
+// HTMLは maxHTMLBytes で切り詰め、PDFは途中で切ると解析できないため maxPDFBytes まで読み込みます
+func fetchResource(ctx context.Context, client *http.Client, url string) (*fetchedResource, error) {
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
-		return "", fmt.Errorf("failed to create request: %w", err)
+		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
 	resp, err := client.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("failed to fetch url: %w", err)
+		return nil, fmt.Errorf("failed to fetch url: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode >= http.StatusBadRequest {
-		return "", fmt.Errorf("unexpected status code: %s", resp.Status)
+		return nil, fmt.Errorf("unexpected status code: %s", resp.Status)
 	}
 
 	body, err := io.ReadAll(io.LimitReader(resp.Body, maxHTMLBytes))
 	if err != nil {
-		return "", fmt.Errorf("failed to read response body: %w", err)
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	contentType := resp.Header.Get("Content-Type")
+	pdf := isPDF(contentType, body)
+	if pdf && int64(len(body)) == maxHTMLBytes {
+		rest, err := io.ReadAll(io.LimitReader(resp.Body, maxPDFBytes-maxHTMLBytes+1))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read response body: %w", err)
+		}
+		body = append(body, rest...)
+		if int64(len(body)) > maxPDFBytes {
+			return nil, fmt.Errorf("pdf exceeds %d bytes", maxPDFBytes)
+		}
+	}
+
+	return &fetchedResource{
+		body:        body,
+		contentType: contentType,
+		url:         resp.Request.URL,
+		pdf:         pdf,
+	}, nil
+}
+
+func (r *fetchedResource) document() (*goquery.Document, error) {
+	body, err := decodeHTML(r.body, r.contentType)
+	if err != nil {
+		return nil, err
 	}
 
 	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
 	if err != nil {
-		return "", fmt.Errorf("failed to parse html: %w", err)
+		return nil, fmt.Errorf("failed to parse html: %w", err)
 	}
+	doc.Url = r.url
 
-	text := strings.TrimSpace(doc.Find("article").Text())
-	if text == "" {
-		text = strings.TrimSpace(doc.Find("main").Text())
+	return doc, nil
+}
+
+func fetchDocument(ctx context.Context, client *http.Client, url string) (*goquery.Document, error) {
+	resp, err := fetchResource(ctx, client, url)
+	if err != nil {
+		return nil, err
 	}
-	if text == "" {
-		text = strings.TrimSpace(doc.Text())
+	if resp.pdf {
+		return nil, fmt.Errorf("unexpected pdf content")
 	}
+	return resp.document()
+}
 
-	text = strings.Join(strings.Fields(text), " ")
-	if text == "" {
-		return "", fmt.Errorf("empty article content")
+// followLinkedDocument はルールに従って rel=amphtml / rel=canonical のリンク先を取得します
+// リンク先の取得に失敗した場合は nil を返し、元のページから抽出します
+func followLinkedDocument(ctx context.Context, client *http.Client, doc *goquery.Document, rule SiteRule) *goquery.Document {
+	var rels []string
+	if rule.FollowAMPHTML {
+		rels = append(rels, "amphtml")
+	}
+	if rule.FollowCanonical {
+		rels = append(rels, "canonical")
 	}
 
-	if len([]rune(text)) > maxTextChars {
-		text = string([]rune(text)[:maxTextChars])
+	for _, rel := range rels {
+		href, ok := doc.Find(fmt.Sprintf(`link[rel=%q]`, rel)).First().Attr("href")
+		if !ok || href == "" {
+			continue
+		}
+		target, err := doc.Url.Parse(href)
+		if err != nil || (target.Scheme != "http" && target.Scheme != "https") {
+			continue
+		}
+		if target.String() == doc.Url.String() {
+			continue
+		}
+
+		linked, err := fetchDocument(ctx, client, target.String())
+		if err != nil {
+			log.Printf("Failed to fetch %s link %s: %v", rel, target, err)
+			continue
+		}
+		return linked
+	}
+	return nil
+}
+
+func extractWithRule(doc *goquery.Document, rule SiteRule) string {
+	for _, selector := range rule.RemoveSelectors {
+		doc.Find(selector).Remove()
 	}
 
-	return text, nil
+	for _, selector := range rule.ContentSelectors {
+		content := doc.Find(selector)
+		if content.Length() == 0 {
+			continue
+		}
+		content.Find(boilerplateSelector).Remove()
+		if text := renderBlocks(content.Nodes...); text != "" {
+			return text
+		}
+	}
+	return ""
 }
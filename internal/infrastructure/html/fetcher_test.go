@@ -2,11 +2,13 @@ package html
 
 import (
 	"context"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
-	"time"
+
+	"misskeyRSSbot/internal/infrastructure/httpclient"
 )
 
 func TestFetchArticleText(t *testing.T) {
@@ -66,7 +68,7 @@ func TestFetchArticleText(t *testing.T) {
 			defer server.Close()
 
 			ctx := context.Background()
-			got, err := FetchArticleText(ctx, server.URL, 5*time.Second)
+			got, err := NewFetcher(server.Client(), nil).FetchArticleText(ctx, server.URL)
 			if tc.wantError {
 				if err == nil {
 					t.Fatalf("expected error, got nil")
@@ -82,3 +84,53 @@ func TestFetchArticleText(t *testing.T) {
 		})
 	}
 }
+
+func TestResolveCanonicalURL(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/with-canonical", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><head><link rel="canonical" href="/articles/1"></head><body></body></html>`))
+	})
+	mux.HandleFunc("/redirect", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/without-canonical", http.StatusFound)
+	})
+	mux.HandleFunc("/without-canonical", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><head></head><body></body></html>`))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	tests := []struct {
+		name string
+		path string
+		want string
+	}{
+		{name: "canonical link", path: "/with-canonical", want: server.URL + "/articles/1"},
+		{name: "final url without canonical", path: "/redirect", want: server.URL + "/without-canonical"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewFetcher(server.Client(), nil).ResolveCanonicalURL(context.Background(), server.URL+tt.path)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestNewFetcher_DefaultClientBlocksPrivateAddresses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("<html><article>internal</article></html>"))
+	}))
+	defer server.Close()
+
+	_, err := NewFetcher(nil, nil).FetchArticleText(context.Background(), server.URL)
+	if !errors.Is(err, httpclient.ErrBlockedAddress) {
+		t.Fatalf("expected blocked address error, got %v", err)
+	}
+}
@@ -0,0 +1,84 @@
+package html
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"strings"
+
+	"github.com/ledongthuc/pdf"
+)
+
+const (
+	maxPDFBytes = int64(20 * 1024 * 1024)
+
+	// DefaultMaxPDFPages は本文を抽出するPDFの先頭ページ数の既定値です
+	DefaultMaxPDFPages = 20
+)
+
+var pdfMagic = []byte("%PDF-")
+
+// isPDF は Content-Type またはファイル先頭のマジックバイトからPDFかどうかを判定します
+// Content-Type が application/octet-stream などでもPDFを返すサーバーがあるため、両方を確認します
+func isPDF(contentType string, body []byte) bool {
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil && mediaType == "application/pdf" {
+		return true
+	}
+	return bytes.HasPrefix(bytes.TrimLeft(body[:min(len(body), 1024)], "\x00\t\n\r "), pdfMagic)
+}
+
+// extractPDFText はPDFの先頭 maxPages ページからテキストを抽出します
+// ページ内の行は改行、ページ間は空行で区切ります
+func extractPDFText(body []byte, maxPages int) (text string, err error) {
+	// 壊れたPDFでライブラリがpanicすることがあるため、エラーとして扱う
+	defer func() {
+		if r := recover(); r != nil {
+			text = ""
+			err = fmt.Errorf("failed to parse pdf: %v", r)
+		}
+	}()
+
+	reader, err := pdf.NewReader(bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		return "", fmt.Errorf("failed to parse pdf: %w", err)
+	}
+
+	pages := reader.NumPage()
+	if maxPages > 0 && pages > maxPages {
+		pages = maxPages
+	}
+
+	fonts := make(map[string]*pdf.Font)
+	var blocks []string
+	for i := 1; i <= pages; i++ {
+		page := reader.Page(i)
+		if page.V.IsNull() {
+			continue
+		}
+		for _, name := range page.Fonts() {
+			if _, ok := fonts[name]; !ok {
+				font := page.Font(name)
+				fonts[name] = &font
+			}
+		}
+
+		pageText, err := page.GetPlainText(fonts)
+		if err != nil {
+			return "", fmt.Errorf("failed to extract text from pdf page %d: %w", i, err)
+		}
+		if block := normalizePDFLines(pageText); block != "" {
+			blocks = append(blocks, block)
+		}
+	}
+	return strings.Join(blocks, "\n\n"), nil
+}
+
+func normalizePDFLines(text string) string {
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		if normalized := normalizeSpace(line); normalized != "" {
+			lines = append(lines, normalized)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
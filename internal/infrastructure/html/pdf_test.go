@@ -0,0 +1,108 @@
+package html
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFetchArticleText_PDF(t *testing.T) {
+	pdf, err := os.ReadFile(filepath.Join("testdata", "pdf", "report.pdf"))
+	if err != nil {
+		t.Fatalf("failed to read fixture: %v", err)
+	}
+
+	testCases := []struct {
+		name        string
+		contentType string
+		maxPages    int
+		want        []string
+		notWant     []string
+	}{
+		{
+			name:        "application/pdf content type",
+			contentType: "application/pdf",
+			want: []string{
+				"Annual Report on Regional Transport\nPrepared by the Ministry of Transport",
+				"Rail ridership recovered to 95 percent of pre-pandemic levels.",
+				"Investment in accessibility will continue through the next fiscal year.",
+			},
+		},
+		{
+			name:        "detected by magic bytes",
+			contentType: "application/octet-stream",
+			want:        []string{"Annual Report on Regional Transport", "2. Outlook"},
+		},
+		{
+			name:        "page limit",
+			contentType: "application/pdf",
+			maxPages:    2,
+			want:        []string{"Annual Report on Regional Transport", "1. Summary"},
+			notWant:     []string{"2. Outlook"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", tc.contentType)
+				_, _ = w.Write(pdf)
+			}))
+			defer server.Close()
+
+			got, err := NewFetcher(server.Client(), nil, WithMaxPDFPages(tc.maxPages)).FetchArticleText(context.Background(), server.URL)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			for _, want := range tc.want {
+				if !strings.Contains(got, want) {
+					t.Errorf("expected %q in %q", want, got)
+				}
+			}
+			for _, notWant := range tc.notWant {
+				if strings.Contains(got, notWant) {
+					t.Errorf("unexpected %q in %q", notWant, got)
+				}
+			}
+		})
+	}
+}
+
+func TestFetchArticleText_BrokenPDF(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/pdf")
+		_, _ = w.Write([]byte("%PDF-1.4\nbroken"))
+	}))
+	defer server.Close()
+
+	if _, err := NewFetcher(server.Client(), nil).FetchArticleText(context.Background(), server.URL); err == nil {
+		t.Fatal("expected error, got nil")
+	}
+}
+
+func TestIsPDF(t *testing.T) {
+	testCases := []struct {
+		name        string
+		contentType string
+		body        string
+		want        bool
+	}{
+		{name: "content type", contentType: "application/pdf", body: "", want: true},
+		{name: "content type with params", contentType: "Application/PDF; name=report.pdf", body: "", want: true},
+		{name: "magic bytes", contentType: "application/octet-stream", body: "%PDF-1.7\n", want: true},
+		{name: "magic bytes after whitespace", contentType: "", body: "\r\n%PDF-1.4", want: true},
+		{name: "html", contentType: "text/html", body: "<html></html>", want: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := isPDF(tc.contentType, []byte(tc.body)); got != tc.want {
+				t.Fatalf("expected %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,333 @@
+package html
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+const (
+	minParagraphChars     = 25
+	minArticleChars       = 140
+	siblingScoreRatio     = 0.2
+	minSiblingScore       = 10
+	longSiblingChars      = 80
+	maxSiblingLinkDensity = 0.25
+	maxBlockLinkDensity   = 0.5
+)
+
+var (
+	boilerplateSelector = strings.Join([]string{
+		"script", "style", "noscript", "template", "iframe", "svg", "canvas",
+		"button", "input", "select", "textarea", "dialog",
+		"nav", "aside", "footer",
+		"[hidden]", "[aria-hidden=true]",
+		"[role=navigation]", "[role=banner]", "[role=complementary]", "[role=contentinfo]", "[role=dialog]",
+	}, ", ")
+
+	unlikelyCandidatePattern = regexp.MustCompile(`(?i)-ad-|\bads?\b|ad-break|advert|agegate|banner|breadcrumb|combx|comment|community|consent|cookie|disqus|extra|footer|gdpr|header|menu|newsletter|pager|pagination|popup|ranking|recommend|related|remark|replies|rss|share|shoutbox|sidebar|skyscraper|social|sponsor|subscribe|supplemental`)
+	maybeCandidatePattern    = regexp.MustCompile(`(?i)and|article|body|column|content|entry|honbun|main|shadow|story`)
+	positiveClassPattern     = regexp.MustCompile(`(?i)article|body|content|entry|hentry|h-entry|honbun|kiji|main|page|post|text|blog|story`)
+	negativeClassPattern     = regexp.MustCompile(`(?i)-ad-|\bads?\b|advert|hidden|banner|combx|comment|com-|consent|contact|cookie|foot|footer|footnote|gdpr|masthead|media|menu|meta|nav|outbrain|promo|ranking|recommend|related|scroll|share|shoutbox|sidebar|skyscraper|sponsor|shopping|tags|tool|widget`)
+	commaPattern             = regexp.MustCompile(`[,、，]`)
+	sentenceEndPattern       = regexp.MustCompile(`(\.|。|！|？|!|\?)( |$)`)
+)
+
+var blockElements = map[atom.Atom]bool{
+	atom.Address: true, atom.Article: true, atom.Blockquote: true, atom.Br: true,
+	atom.Dd: true, atom.Div: true, atom.Dl: true, atom.Dt: true,
+	atom.Figcaption: true, atom.Figure: true, atom.H1: true, atom.H2: true,
+	atom.H3: true, atom.H4: true, atom.H5: true, atom.H6: true,
+	atom.Header: true, atom.Hr: true, atom.Li: true, atom.Main: true,
+	atom.Ol: true, atom.P: true, atom.Pre: true, atom.Section: true,
+	atom.Table: true, atom.Td: true, atom.Th: true, atom.Tr: true, atom.Ul: true,
+}
+
+type candidateScores map[*html.Node]float64
+
+func extractArticleText(doc *goquery.Document) string {
+	// 入力欄を取り除く前に、検索やログインのような小さなフォームを見分けておく
+	forms := boilerplateForms(doc.Selection)
+	removeBoilerplate(doc.Selection)
+
+	if text := extractByScore(doc.Selection, forms); utf8.RuneCountInString(text) >= minArticleChars {
+		return text
+	}
+
+	removeForms(forms, nil)
+
+	for _, selector := range []string{"article", "main", "body"} {
+		if text := renderBlocks(doc.Find(selector).Nodes...); text != "" {
+			return text
+		}
+	}
+	return renderBlocks(doc.Selection.Nodes...)
+}
+
+func removeBoilerplate(root *goquery.Selection) {
+	root.Find(boilerplateSelector).Remove()
+
+	root.Find("*").Each(func(_ int, s *goquery.Selection) {
+		node := s.Get(0)
+		switch node.DataAtom {
+		case atom.Html, atom.Body, atom.Article, atom.Main:
+			return
+		}
+		if node.Parent == nil {
+			return
+		}
+		matchString := classAndID(s)
+		if matchString == "" {
+			return
+		}
+		if unlikelyCandidatePattern.MatchString(matchString) && !maybeCandidatePattern.MatchString(matchString) {
+			s.Remove()
+		}
+	})
+}
+
+// boilerplateForms は本文でないと判断できるフォームを返します
+// ASP.NET の WebForms のようにページ全体を1つのフォームで囲むサイトがあるため、フォームをすべて取り除くことはせず、
+// 文章が短いフォーム、リンクばかりのフォーム、段落より入力欄の多いフォームだけを対象にします
+func boilerplateForms(root *goquery.Selection) []*html.Node {
+	var forms []*html.Node
+	root.Find("form").Each(func(_ int, s *goquery.Selection) {
+		length := utf8.RuneCountInString(normalizeSpace(s.Text()))
+		controls := s.Find("input:not([type=hidden]), select, textarea, button").Length()
+		paragraphs := s.Find("p").Length()
+		if length < minArticleChars || linkDensity(s) > maxBlockLinkDensity || controls > paragraphs {
+			forms = append(forms, s.Get(0))
+		}
+	})
+	return forms
+}
+
+// removeForms は forms のうち keep を含まないフォームを取り除きます
+func removeForms(forms []*html.Node, keep *html.Node) {
+	for _, form := range forms {
+		if form.Parent == nil || contains(form, keep) {
+			continue
+		}
+		form.Parent.RemoveChild(form)
+	}
+}
+
+func contains(ancestor, node *html.Node) bool {
+	for ; node != nil; node = node.Parent {
+		if node == ancestor {
+			return true
+		}
+	}
+	return false
+}
+
+func extractByScore(root *goquery.Selection, forms []*html.Node) string {
+	scores := scoreParagraphs(root)
+	if len(scores) == 0 {
+		return ""
+	}
+
+	var top *html.Node
+	var topScore float64
+	for node, score := range scores {
+		score *= 1 - linkDensity(goquery.NewDocumentFromNode(node).Selection)
+		scores[node] = score
+		if top == nil || score > topScore {
+			top = node
+			topScore = score
+		}
+	}
+	removeForms(forms, top)
+	if top == nil || top.Parent == nil {
+		return renderBlocks(top)
+	}
+
+	threshold := max(minSiblingScore, topScore*siblingScoreRatio)
+	var nodes []*html.Node
+	for sibling := top.Parent.FirstChild; sibling != nil; sibling = sibling.NextSibling {
+		if sibling.Type != html.ElementNode {
+			continue
+		}
+		if sibling == top || scores[sibling] >= threshold || isContentParagraph(sibling) {
+			nodes = append(nodes, sibling)
+		}
+	}
+	cleanConditionally(nodes)
+	return renderBlocks(nodes...)
+}
+
+// cleanConditionally は本文候補の中に残った、リンクばかりのブロックや
+// クラス名から本文でないと判断できるブロックを取り除きます
+func cleanConditionally(nodes []*html.Node) {
+	for _, node := range nodes {
+		goquery.NewDocumentFromNode(node).Find("div, section, ul, ol, dl, table").Each(func(_ int, s *goquery.Selection) {
+			if classWeight(s) < 0 || linkDensity(s) > maxBlockLinkDensity {
+				s.Remove()
+			}
+		})
+	}
+}
+
+func scoreParagraphs(root *goquery.Selection) candidateScores {
+	scores := make(candidateScores)
+	root.Find("p, pre, td, blockquote, div").Each(func(_ int, s *goquery.Selection) {
+		node := s.Get(0)
+		if node.DataAtom == atom.Div && hasBlockChild(node) {
+			return
+		}
+
+		text := normalizeSpace(s.Text())
+		length := utf8.RuneCountInString(text)
+		if length < minParagraphChars {
+			return
+		}
+
+		score := 1 + float64(len(commaPattern.FindAllString(text, -1))) + min(float64(length/100), 3)
+
+		parent := node.Parent
+		if parent == nil || parent.Type != html.ElementNode {
+			return
+		}
+		addCandidateScore(scores, parent, score)
+
+		if grandparent := parent.Parent; grandparent != nil && grandparent.Type == html.ElementNode {
+			addCandidateScore(scores, grandparent, score/2)
+		}
+	})
+	return scores
+}
+
+func addCandidateScore(scores candidateScores, node *html.Node, score float64) {
+	if _, ok := scores[node]; !ok {
+		scores[node] = initialScore(node)
+	}
+	scores[node] += score
+}
+
+func initialScore(node *html.Node) float64 {
+	var score float64
+	switch node.DataAtom {
+	case atom.Div, atom.Article, atom.Main, atom.Section:
+		score = 5
+	case atom.Pre, atom.Td, atom.Blockquote:
+		score = 3
+	case atom.Address, atom.Ol, atom.Ul, atom.Dl, atom.Dd, atom.Dt, atom.Li, atom.Form:
+		score = -3
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6, atom.Th:
+		score = -5
+	}
+	return score + classWeight(goquery.NewDocumentFromNode(node).Selection)
+}
+
+func classWeight(s *goquery.Selection) float64 {
+	var weight float64
+	for _, attr := range []string{"class", "id"} {
+		value, ok := s.Attr(attr)
+		if !ok || value == "" {
+			continue
+		}
+		if negativeClassPattern.MatchString(value) {
+			weight -= 25
+		}
+		if positiveClassPattern.MatchString(value) {
+			weight += 25
+		}
+	}
+	return weight
+}
+
+func classAndID(s *goquery.Selection) string {
+	class, _ := s.Attr("class")
+	id, _ := s.Attr("id")
+	return strings.TrimSpace(class + " " + id)
+}
+
+func linkDensity(s *goquery.Selection) float64 {
+	textLength := utf8.RuneCountInString(normalizeSpace(s.Text()))
+	if textLength == 0 {
+		return 0
+	}
+
+	var linkLength int
+	s.Find("a").Each(func(_ int, link *goquery.Selection) {
+		linkLength += utf8.RuneCountInString(normalizeSpace(link.Text()))
+	})
+	return float64(linkLength) / float64(textLength)
+}
+
+func isContentParagraph(node *html.Node) bool {
+	if node.DataAtom != atom.P {
+		return false
+	}
+
+	s := goquery.NewDocumentFromNode(node).Selection
+	text := normalizeSpace(s.Text())
+	length := utf8.RuneCountInString(text)
+	density := linkDensity(s)
+
+	if length > longSiblingChars {
+		return density < maxSiblingLinkDensity
+	}
+	return length > 0 && density == 0 && sentenceEndPattern.MatchString(text)
+}
+
+func hasBlockChild(node *html.Node) bool {
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type == html.ElementNode && blockElements[child.DataAtom] && child.DataAtom != atom.Br {
+			return true
+		}
+	}
+	return false
+}
+
+func renderBlocks(nodes ...*html.Node) string {
+	var blocks []string
+	var current strings.Builder
+
+	flush := func() {
+		if text := normalizeSpace(current.String()); text != "" {
+			blocks = append(blocks, text)
+		}
+		current.Reset()
+	}
+
+	var walk func(node *html.Node)
+	walk = func(node *html.Node) {
+		switch node.Type {
+		case html.TextNode:
+			current.WriteString(node.Data)
+			return
+		case html.ElementNode, html.DocumentNode:
+		default:
+			return
+		}
+
+		isBlock := blockElements[node.DataAtom]
+		if isBlock {
+			flush()
+		}
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			walk(child)
+		}
+		if isBlock {
+			flush()
+		}
+	}
+
+	for _, node := range nodes {
+		if node == nil {
+			continue
+		}
+		walk(node)
+		flush()
+	}
+	return strings.Join(blocks, "\n\n")
+}
+
+func normalizeSpace(text string) string {
+	return strings.Join(strings.Fields(text), " ")
+}
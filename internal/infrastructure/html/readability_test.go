@@ -0,0 +1,95 @@
+package html
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+var updateGolden = flag.Bool("update", false, "update golden files")
+
+func TestExtractArticleText_Golden(t *testing.T) {
+	fixtures, err := filepath.Glob(filepath.Join("testdata", "*.html"))
+	if err != nil {
+		t.Fatalf("failed to list fixtures: %v", err)
+	}
+	if len(fixtures) == 0 {
+		t.Fatal("no fixtures found")
+	}
+
+	for _, fixture := range fixtures {
+		name := strings.TrimSuffix(filepath.Base(fixture), ".html")
+		t.Run(name, func(t *testing.T) {
+			f, err := os.Open(fixture)
+			if err != nil {
+				t.Fatalf("failed to open fixture: %v", err)
+			}
+			defer f.Close()
+
+			doc, err := goquery.NewDocumentFromReader(f)
+			if err != nil {
+				t.Fatalf("failed to parse fixture: %v", err)
+			}
+			got := extractArticleText(doc) + "\n"
+
+			golden := filepath.Join("testdata", name+".golden")
+			if *updateGolden {
+				if err := os.WriteFile(golden, []byte(got), 0o644); err != nil {
+					t.Fatalf("failed to update golden file: %v", err)
+				}
+			}
+
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("failed to read golden file: %v", err)
+			}
+			if got != string(want) {
+				t.Errorf("extracted text mismatch\n--- got ---\n%s\n--- want ---\n%s", got, want)
+			}
+		})
+	}
+}
+
+func TestExtractArticleText_DropsBoilerplate(t *testing.T) {
+	body := `<html><body>
+<nav><a href="/">Home</a> <a href="/news">News</a></nav>
+<div class="cookie-banner"><p>We use cookies to improve your experience on this website, please accept them.</p></div>
+<div class="article-body">
+<p>The first paragraph of the story has enough text to be scored as real content by the extractor.</p>
+<p>The second paragraph continues the story, with commas, clauses, and more detail for the reader.</p>
+</div>
+<form action="/signup"><p>Get the morning briefing delivered to your inbox every weekday.</p><input type="email" name="email"><button>Sign up</button></form>
+<ul class="related"><li><a href="/a">Another story about something else entirely</a></li></ul>
+</body></html>`
+
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("failed to parse html: %v", err)
+	}
+
+	got := extractArticleText(doc)
+	want := "The first paragraph of the story has enough text to be scored as real content by the extractor.\n\n" +
+		"The second paragraph continues the story, with commas, clauses, and more detail for the reader."
+	if got != want {
+		t.Fatalf("expected %q, got %q", want, got)
+	}
+}
+
+func TestRenderBlocks_PreservesParagraphBreaks(t *testing.T) {
+	body := "<div><h2>Title</h2><p>First <b>bold</b>\n line</p>text after<br>next line<ul><li>one</li><li>two</li></ul></div>"
+
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("failed to parse html: %v", err)
+	}
+
+	got := renderBlocks(doc.Find("div").Nodes...)
+	want := "Title\n\nFirst bold line\n\ntext after\n\nnext line\n\none\n\ntwo"
+	if got != want {
+		t.Fatalf("expected %q, got %q", want, got)
+	}
+}
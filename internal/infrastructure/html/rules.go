@@ -0,0 +1,73 @@
+package html
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/andybalholm/cascadia"
+)
+
+// SiteRule はホストごとの本文抽出ルールです
+type SiteRule struct {
+	// Host: 対象ホスト（サブドメインにも適用される）
+	Host string
+	// ContentSelectors: 本文とみなす要素のCSSセレクタ（先に一致したものを使用）
+	ContentSelectors []string
+	// RemoveSelectors: 抽出前に取り除く要素のCSSセレクタ
+	RemoveSelectors []string
+	// FollowAMPHTML: rel=amphtml のリンク先から本文を取得する
+	FollowAMPHTML bool
+	// FollowCanonical: rel=canonical のリンク先から本文を取得する
+	FollowCanonical bool
+}
+
+// Rules はホスト名からSiteRuleを引くレジストリです
+type Rules struct {
+	byHost map[string]SiteRule
+}
+
+func NewRules(rules []SiteRule) (*Rules, error) {
+	byHost := make(map[string]SiteRule, len(rules))
+	for _, rule := range rules {
+		host := normalizeHost(rule.Host)
+		if host == "" {
+			return nil, fmt.Errorf("extraction rule host is required")
+		}
+		if _, ok := byHost[host]; ok {
+			return nil, fmt.Errorf("duplicate extraction rule for host %s", host)
+		}
+		for _, selector := range append(append([]string{}, rule.ContentSelectors...), rule.RemoveSelectors...) {
+			if _, err := cascadia.Compile(selector); err != nil {
+				return nil, fmt.Errorf("invalid selector %q for host %s: %w", selector, host, err)
+			}
+		}
+		rule.Host = host
+		byHost[host] = rule
+	}
+	return &Rules{byHost: byHost}, nil
+}
+
+// Lookup はホストに一致するルールを返します
+// 完全一致がなければ親ドメインのルールを順に探します（news.example.com → example.com）
+func (r *Rules) Lookup(host string) (SiteRule, bool) {
+	if r == nil || len(r.byHost) == 0 {
+		return SiteRule{}, false
+	}
+
+	host = normalizeHost(host)
+	for host != "" {
+		if rule, ok := r.byHost[host]; ok {
+			return rule, true
+		}
+		_, parent, found := strings.Cut(host, ".")
+		if !found {
+			break
+		}
+		host = parent
+	}
+	return SiteRule{}, false
+}
+
+func normalizeHost(host string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(host)), ".")
+}
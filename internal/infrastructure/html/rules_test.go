@@ -0,0 +1,172 @@
+package html
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestNewRules(t *testing.T) {
+	testCases := []struct {
+		name      string
+		rules     []SiteRule
+		wantError bool
+	}{
+		{
+			name:  "valid rules",
+			rules: []SiteRule{{Host: "example.com", ContentSelectors: []string{"div.body"}, RemoveSelectors: []string{".ad"}}},
+		},
+		{
+			name:      "missing host",
+			rules:     []SiteRule{{ContentSelectors: []string{"div.body"}}},
+			wantError: true,
+		},
+		{
+			name:      "duplicate host",
+			rules:     []SiteRule{{Host: "example.com"}, {Host: "Example.com"}},
+			wantError: true,
+		},
+		{
+			name:      "invalid selector",
+			rules:     []SiteRule{{Host: "example.com", RemoveSelectors: []string{"div["}}},
+			wantError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := NewRules(tc.rules)
+			if tc.wantError && err == nil {
+				t.Fatal("expected error, got nil")
+			}
+			if !tc.wantError && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestRules_Lookup(t *testing.T) {
+	rules, err := NewRules([]SiteRule{
+		{Host: "example.com", ContentSelectors: []string{"#parent"}},
+		{Host: "news.example.com", ContentSelectors: []string{"#child"}},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	testCases := []struct {
+		host     string
+		want     string
+		wantFind bool
+	}{
+		{host: "example.com", want: "#parent", wantFind: true},
+		{host: "NEWS.example.com", want: "#child", wantFind: true},
+		{host: "www.example.com", want: "#parent", wantFind: true},
+		{host: "sub.news.example.com", want: "#child", wantFind: true},
+		{host: "example.org"},
+		{host: "badexample.com"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.host, func(t *testing.T) {
+			rule, ok := rules.Lookup(tc.host)
+			if ok != tc.wantFind {
+				t.Fatalf("expected found=%v, got %v", tc.wantFind, ok)
+			}
+			if ok && rule.ContentSelectors[0] != tc.want {
+				t.Fatalf("expected %s, got %s", tc.want, rule.ContentSelectors[0])
+			}
+		})
+	}
+
+	var nilRules *Rules
+	if _, ok := nilRules.Lookup("example.com"); ok {
+		t.Fatal("expected nil rules to match nothing")
+	}
+}
+
+func TestFetchArticleText_WithRules(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/article", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<html><head><link rel="amphtml" href="/article/amp"></head><body>
+<div class="body">Original <span class="ad">AD</span> body</div>
+<article>Generic article text</article>
+</body></html>`)
+	})
+	mux.HandleFunc("/article/amp", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<html><body><div class="body"><p>AMP first</p><div class="related">Related</div><p>AMP second</p></div></body></html>`)
+	})
+	mux.HandleFunc("/broken-amp", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<html><head><link rel="amphtml" href="/missing"></head><body><div class="body">Fallback body</div></body></html>`)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	serverURL, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatalf("failed to parse server url: %v", err)
+	}
+
+	testCases := []struct {
+		name string
+		path string
+		rule *SiteRule
+		want string
+	}{
+		{
+			name: "no rule uses generic extraction",
+			path: "/article",
+			want: "Generic article text",
+		},
+		{
+			name: "content and remove selectors",
+			path: "/article",
+			rule: &SiteRule{ContentSelectors: []string{"div.body"}, RemoveSelectors: []string{".ad"}},
+			want: "Original body",
+		},
+		{
+			name: "unmatched selector falls back to generic extraction",
+			path: "/article",
+			rule: &SiteRule{ContentSelectors: []string{"#missing"}},
+			want: "Generic article text",
+		},
+		{
+			name: "follow amphtml",
+			path: "/article",
+			rule: &SiteRule{ContentSelectors: []string{"div.body"}, RemoveSelectors: []string{".related"}, FollowAMPHTML: true},
+			want: "AMP first\n\nAMP second",
+		},
+		{
+			name: "failed amphtml fetch uses original page",
+			path: "/broken-amp",
+			rule: &SiteRule{ContentSelectors: []string{"div.body"}, FollowAMPHTML: true},
+			want: "Fallback body",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var rules *Rules
+			if tc.rule != nil {
+				rule := *tc.rule
+				rule.Host = serverURL.Hostname()
+				rules, err = NewRules([]SiteRule{rule})
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+			}
+
+			got, err := NewFetcher(server.Client(), rules).FetchArticleText(context.Background(), server.URL+tc.path)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tc.want {
+				t.Fatalf("expected %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,301 @@
+package httpclient
+
+import (
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/andybalholm/brotli"
+)
+
+var (
+	// ErrBlockedScheme は http/https 以外のスキームへのリクエストを拒否した場合のエラーです
+	ErrBlockedScheme = errors.New("blocked scheme")
+	// ErrDisallowedByRobots は robots.txt で取得が禁止されているURLへのリクエストを拒否した場合のエラーです
+	ErrDisallowedByRobots = errors.New("disallowed by robots.txt")
+)
+
+const (
+	DefaultUserAgent = "misskeyRSSbot/1.0"
+
+	defaultTimeout             = 30 * time.Second
+	defaultDialTimeout         = 10 * time.Second
+	defaultMaxIdleConns        = 100
+	defaultMaxIdleConnsPerHost = 4
+	defaultIdleConnTimeout     = 90 * time.Second
+	defaultMaxRedirects        = 10
+)
+
+type Config struct {
+	// UserAgent: すべてのリクエストに付与するUser-Agent（空の場合は DefaultUserAgent）
+	UserAgent string
+	// ProxyURL: HTTP(S)プロキシのURL（空の場合はプロキシを使用しない）
+	ProxyURL string
+	// Timeout: リクエスト全体のタイムアウト（0の場合は30秒）
+	Timeout time.Duration
+	// MaxIdleConnsPerHost: ホストごとに保持するアイドル接続数（0の場合は4）
+	MaxIdleConnsPerHost int
+	// IdleConnTimeout: アイドル接続を閉じるまでの時間（0の場合は90秒）
+	IdleConnTimeout time.Duration
+	// HostInterval: 同じホストへのリクエストの最小間隔（フィード・記事の取得のみ、0の場合は制限なし）
+	HostInterval time.Duration
+	// RespectRobotsTxt: robots.txt で禁止されたURLを取得しない（フィード・記事の取得のみ）
+	RespectRobotsTxt bool
+	// Allowlist: プライベートアドレスでも接続を許可するホスト・アドレス帯
+	Allowlist *Allowlist
+	// Resolver: 名前解決に使うリゾルバ（nilの場合は net.DefaultResolver）
+	Resolver Resolver
+}
+
+// Factory は設定を共有したHTTPクライアントを作成します
+// 同じ Factory から作成したクライアントはホストごとの取得間隔と robots.txt のキャッシュを共有します
+type Factory struct {
+	cfg         Config
+	proxy       *url.URL
+	resolver    Resolver
+	hostLimiter *hostLimiter
+	robots      *robotsCache
+}
+
+func NewFactory(cfg Config) (*Factory, error) {
+	if cfg.UserAgent == "" {
+		cfg.UserAgent = DefaultUserAgent
+	}
+	if cfg.Timeout == 0 {
+		cfg.Timeout = defaultTimeout
+	}
+	if cfg.MaxIdleConnsPerHost == 0 {
+		cfg.MaxIdleConnsPerHost = defaultMaxIdleConnsPerHost
+	}
+	if cfg.IdleConnTimeout == 0 {
+		cfg.IdleConnTimeout = defaultIdleConnTimeout
+	}
+
+	var proxy *url.URL
+	if cfg.ProxyURL != "" {
+		parsed, err := url.Parse(cfg.ProxyURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy URL: %w", err)
+		}
+		if parsed.Scheme != "http" && parsed.Scheme != "https" || parsed.Host == "" {
+			return nil, fmt.Errorf("invalid proxy URL: %s", cfg.ProxyURL)
+		}
+		proxy = parsed
+	}
+
+	var resolver Resolver = net.DefaultResolver
+	if cfg.Resolver != nil {
+		resolver = cfg.Resolver
+	}
+
+	factory := &Factory{
+		cfg:         cfg,
+		proxy:       proxy,
+		resolver:    resolver,
+		hostLimiter: newHostLimiter(cfg.HostInterval),
+	}
+	if cfg.RespectRobotsTxt {
+		factory.robots = newRobotsCache(cfg.UserAgent)
+	}
+	return factory, nil
+}
+
+// NewFetchClient はフィードや記事など外部から与えられたURLを取得するためのクライアントを作成します
+// プライベート・ループバック・リンクローカル等への接続と、http/https 以外のスキームを拒否します
+// リダイレクト先も同じ検査を通ります
+func (f *Factory) NewFetchClient() *http.Client {
+	allowlist := f.cfg.Allowlist
+	if f.proxy != nil {
+		// プロキシ自体は社内ネットワークにあることが多いため接続を許可する
+		allowlist = allowlist.withHost(f.proxy.Hostname())
+	}
+	dialer := &safeDialer{
+		dialer:    &net.Dialer{Timeout: defaultDialTimeout, KeepAlive: 30 * time.Second},
+		resolver:  f.resolver,
+		allowlist: allowlist,
+	}
+
+	transport := f.newTransport(dialer.DialContext)
+	fetch := &fetchTransport{
+		next:        &commonTransport{next: transport, userAgent: f.cfg.UserAgent},
+		hostLimiter: f.hostLimiter,
+		robots:      f.robots,
+	}
+	if f.proxy != nil {
+		// プロキシ経由の場合は接続先の名前解決をプロキシが行うため、送信前に検査する
+		// プロキシはこの検査とは別に名前解決するので、その間にDNSの応答が変わる（DNSリバインディング）と
+		// 検査を通った名前でプライベートアドレスに接続されうる。プロキシ側でも内部ネットワークへの接続を拒否すること
+		fetch.checkHost = dialer.checkHost
+	}
+
+	return &http.Client{
+		Timeout:   f.cfg.Timeout,
+		Transport: fetch,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= defaultMaxRedirects {
+				return fmt.Errorf("stopped after %d redirects", defaultMaxRedirects)
+			}
+			return checkScheme(req)
+		},
+	}
+}
+
+// NewDefaultFetchClient は既定の設定で NewFetchClient と同じ検査を行うクライアントを作成します
+// クライアントを渡されなかった取得処理が、SSRF対策を通らずに接続しないようにするために使います
+func NewDefaultFetchClient() *http.Client {
+	factory, err := NewFactory(Config{})
+	if err != nil {
+		// プロキシを指定しない既定の設定では失敗しない
+		panic(err)
+	}
+	return factory.NewFetchClient()
+}
+
+// NewAPIClient は設定で指定したAPI（Misskeyなど）を呼び出すためのクライアントを作成します
+// User-Agent・プロキシ・タイムアウト・接続プールの設定のみを適用します
+func (f *Factory) NewAPIClient() *http.Client {
+	dialer := &net.Dialer{Timeout: defaultDialTimeout, KeepAlive: 30 * time.Second}
+	return &http.Client{
+		Timeout:   f.cfg.Timeout,
+		Transport: &commonTransport{next: f.newTransport(dialer.DialContext), userAgent: f.cfg.UserAgent},
+	}
+}
+
+func (f *Factory) newTransport(dial func(ctx context.Context, network, address string) (net.Conn, error)) *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// 環境変数のプロキシは使用せず、設定されたプロキシのみを使う
+	transport.Proxy = nil
+	if f.proxy != nil {
+		transport.Proxy = http.ProxyURL(f.proxy)
+	}
+	transport.DialContext = dial
+	transport.MaxIdleConns = defaultMaxIdleConns
+	transport.MaxIdleConnsPerHost = f.cfg.MaxIdleConnsPerHost
+	transport.IdleConnTimeout = f.cfg.IdleConnTimeout
+	// gzip と brotli の展開は commonTransport で行う
+	transport.DisableCompression = true
+	return transport
+}
+
+// commonTransport はUser-Agentの付与とレスポンスの展開を行います
+type commonTransport struct {
+	next      http.RoundTripper
+	userAgent string
+}
+
+func (t *commonTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set("User-Agent", t.userAgent)
+
+	requestedEncoding := req.Header.Get("Accept-Encoding") == ""
+	if requestedEncoding && req.Method != http.MethodHead {
+		req.Header.Set("Accept-Encoding", "gzip, br")
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil || !requestedEncoding {
+		return resp, err
+	}
+
+	var body io.ReadCloser
+	switch strings.ToLower(resp.Header.Get("Content-Encoding")) {
+	case "gzip":
+		body = &lazyGzipReader{body: resp.Body}
+	case "br":
+		body = &readCloser{Reader: brotli.NewReader(resp.Body), Closer: resp.Body}
+	default:
+		return resp, nil
+	}
+
+	resp.Body = body
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+	return resp, nil
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// lazyGzipReader は最初の Read でgzipヘッダーを読み込みます（空のボディでエラーにしないため）
+type lazyGzipReader struct {
+	body io.ReadCloser
+	zr   *gzip.Reader
+	err  error
+}
+
+func (r *lazyGzipReader) Read(p []byte) (int, error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+	if r.zr == nil {
+		r.zr, r.err = gzip.NewReader(r.body)
+		if r.err != nil {
+			return 0, r.err
+		}
+	}
+	return r.zr.Read(p)
+}
+
+func (r *lazyGzipReader) Close() error {
+	return r.body.Close()
+}
+
+// fetchTransport は外部URLの取得時にスキーム・robots.txt・ホストごとの取得間隔を確認します
+type fetchTransport struct {
+	next        http.RoundTripper
+	hostLimiter *hostLimiter
+	robots      *robotsCache
+	checkHost   func(ctx context.Context, host string) ([]netip.Addr, error)
+}
+
+func (t *fetchTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := checkScheme(req); err != nil {
+		return nil, err
+	}
+	if t.checkHost != nil {
+		if _, err := t.checkHost(req.Context(), req.URL.Hostname()); err != nil {
+			return nil, err
+		}
+	}
+
+	if t.robots != nil && !isRobotsTxt(req.URL) {
+		allowed, err := t.robots.allowed(req.Context(), req.URL, t.fetchRobotsTxt)
+		if err != nil {
+			return nil, err
+		}
+		if !allowed {
+			return nil, fmt.Errorf("%w: %s", ErrDisallowedByRobots, req.URL)
+		}
+	}
+
+	if err := t.hostLimiter.wait(req.Context(), req.URL.Host); err != nil {
+		return nil, err
+	}
+	return t.next.RoundTrip(req)
+}
+
+func (t *fetchTransport) fetchRobotsTxt(req *http.Request) (*http.Response, error) {
+	client := &http.Client{Transport: t, Timeout: defaultTimeout}
+	return client.Do(req)
+}
+
+func checkScheme(req *http.Request) error {
+	switch req.URL.Scheme {
+	case "http", "https":
+		return nil
+	default:
+		return fmt.Errorf("%w: %s", ErrBlockedScheme, req.URL.Scheme)
+	}
+}
@@ -0,0 +1,386 @@
+package httpclient
+
+import (
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+)
+
+type stubResolver map[string][]netip.Addr
+
+func (r stubResolver) LookupNetIP(_ context.Context, _, host string) ([]netip.Addr, error) {
+	addrs, ok := r[host]
+	if !ok {
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+	return addrs, nil
+}
+
+func newFetchClient(t *testing.T, cfg Config) *http.Client {
+	t.Helper()
+	factory, err := NewFactory(cfg)
+	if err != nil {
+		t.Fatalf("failed to create factory: %v", err)
+	}
+	return factory.NewFetchClient()
+}
+
+func TestIsBlockedIP(t *testing.T) {
+	testCases := []struct {
+		addr string
+		want bool
+	}{
+		{addr: "127.0.0.1", want: true},
+		{addr: "10.1.2.3", want: true},
+		{addr: "172.16.0.1", want: true},
+		{addr: "192.168.1.1", want: true},
+		{addr: "169.254.169.254", want: true},
+		{addr: "100.64.0.1", want: true},
+		{addr: "0.0.0.0", want: true},
+		{addr: "224.0.0.1", want: true},
+		{addr: "::1", want: true},
+		{addr: "fe80::1", want: true},
+		{addr: "fd00::1", want: true},
+		{addr: "::ffff:127.0.0.1", want: true},
+		{addr: "::ffff:169.254.169.254", want: true},
+		{addr: "93.184.216.34", want: false},
+		{addr: "2606:2800:220:1:248:1893:25c8:1946", want: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.addr, func(t *testing.T) {
+			if got := IsBlockedIP(netip.MustParseAddr(tc.addr)); got != tc.want {
+				t.Fatalf("expected %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestParseAllowlist(t *testing.T) {
+	allowlist, err := ParseAllowlist([]string{"Internal.Example.", " 10.0.0.0/8 ", "192.168.1.10", ""})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !allowlist.allowsHost("internal.example") {
+		t.Error("expected host to be allowed")
+	}
+	if allowlist.allowsHost("other.example") {
+		t.Error("expected other host to be rejected")
+	}
+	if !allowlist.allowsAddr(netip.MustParseAddr("10.20.30.40")) {
+		t.Error("expected address in CIDR to be allowed")
+	}
+	if !allowlist.allowsAddr(netip.MustParseAddr("192.168.1.10")) {
+		t.Error("expected single address to be allowed")
+	}
+	if allowlist.allowsAddr(netip.MustParseAddr("192.168.1.11")) {
+		t.Error("expected neighbouring address to be rejected")
+	}
+
+	if _, err := ParseAllowlist([]string{"10.0.0.0/99"}); err == nil {
+		t.Error("expected error for invalid CIDR")
+	}
+}
+
+func TestFactory_NewFetchClient(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/redirect-internal":
+			_, port, _ := net.SplitHostPort(r.Host)
+			http.Redirect(w, r, fmt.Sprintf("http://internal.example:%s/", port), http.StatusFound)
+		case "/redirect-metadata":
+			http.Redirect(w, r, "http://169.254.169.254/latest/meta-data/", http.StatusFound)
+		case "/redirect-file":
+			http.Redirect(w, r, "file:///etc/passwd", http.StatusFound)
+		default:
+			fmt.Fprint(w, "ok")
+		}
+	}))
+	defer server.Close()
+
+	serverURL, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatalf("failed to parse server url: %v", err)
+	}
+	port := serverURL.Port()
+	loopback := []netip.Addr{netip.MustParseAddr("127.0.0.1")}
+
+	resolver := stubResolver{
+		"allowed.example":  loopback,
+		"internal.example": loopback,
+		"rebind.example":   {netip.MustParseAddr("93.184.216.34"), netip.MustParseAddr("127.0.0.1")},
+	}
+
+	allowlist, err := ParseAllowlist([]string{"allowed.example"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	client := newFetchClient(t, Config{Allowlist: allowlist, Resolver: resolver})
+
+	testCases := []struct {
+		name    string
+		url     string
+		wantErr error
+	}{
+		{
+			name: "allowlisted host",
+			url:  fmt.Sprintf("http://allowed.example:%s/", port),
+		},
+		{
+			name:    "host resolving to loopback",
+			url:     fmt.Sprintf("http://internal.example:%s/", port),
+			wantErr: ErrBlockedAddress,
+		},
+		{
+			name:    "any resolved address is blocked",
+			url:     fmt.Sprintf("http://rebind.example:%s/", port),
+			wantErr: ErrBlockedAddress,
+		},
+		{
+			name:    "literal metadata address",
+			url:     "http://169.254.169.254/latest/meta-data/",
+			wantErr: ErrBlockedAddress,
+		},
+		{
+			name:    "literal IPv6 loopback",
+			url:     fmt.Sprintf("http://[::1]:%s/", port),
+			wantErr: ErrBlockedAddress,
+		},
+		{
+			name:    "redirect to internal host",
+			url:     fmt.Sprintf("http://allowed.example:%s/redirect-internal", port),
+			wantErr: ErrBlockedAddress,
+		},
+		{
+			name:    "redirect to metadata address",
+			url:     fmt.Sprintf("http://allowed.example:%s/redirect-metadata", port),
+			wantErr: ErrBlockedAddress,
+		},
+		{
+			name:    "redirect to file scheme",
+			url:     fmt.Sprintf("http://allowed.example:%s/redirect-file", port),
+			wantErr: ErrBlockedScheme,
+		},
+		{
+			name:    "ftp scheme",
+			url:     "ftp://allowed.example/file",
+			wantErr: ErrBlockedScheme,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, tc.url, nil)
+			if err != nil {
+				t.Fatalf("failed to create request: %v", err)
+			}
+
+			resp, err := client.Do(req)
+			if tc.wantErr != nil {
+				if err == nil {
+					resp.Body.Close()
+					t.Fatalf("expected %v, got nil", tc.wantErr)
+				}
+				if !errors.Is(err, tc.wantErr) {
+					t.Fatalf("expected %v, got %v", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			defer resp.Body.Close()
+
+			body, _ := io.ReadAll(resp.Body)
+			if string(body) != "ok" {
+				t.Fatalf("expected body %q, got %q", "ok", body)
+			}
+		})
+	}
+}
+
+func TestFactory_NewFetchClient_AllowlistedCIDR(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "ok")
+	}))
+	defer server.Close()
+
+	allowlist, err := ParseAllowlist([]string{"127.0.0.0/8"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	client := newFetchClient(t, Config{Allowlist: allowlist, Resolver: stubResolver{}})
+
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	resp.Body.Close()
+}
+
+func TestFactory_UserAgentAndCompression(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("User-Agent"); got != "testbot/2.0" {
+			t.Errorf("expected User-Agent %q, got %q", "testbot/2.0", got)
+		}
+		if got := r.Header.Get("Accept-Encoding"); got != "gzip, br" {
+			t.Errorf("expected Accept-Encoding %q, got %q", "gzip, br", got)
+		}
+
+		switch r.URL.Path {
+		case "/gzip":
+			w.Header().Set("Content-Encoding", "gzip")
+			zw := gzip.NewWriter(w)
+			fmt.Fprint(zw, "gzip body")
+			zw.Close()
+		case "/br":
+			w.Header().Set("Content-Encoding", "br")
+			bw := brotli.NewWriter(w)
+			fmt.Fprint(bw, "brotli body")
+			bw.Close()
+		default:
+			fmt.Fprint(w, "plain body")
+		}
+	}))
+	defer server.Close()
+
+	allowlist, err := ParseAllowlist([]string{"127.0.0.1"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	factory, err := NewFactory(Config{UserAgent: "testbot/2.0", Allowlist: allowlist})
+	if err != nil {
+		t.Fatalf("failed to create factory: %v", err)
+	}
+
+	clients := map[string]*http.Client{
+		"fetch": factory.NewFetchClient(),
+		"api":   factory.NewAPIClient(),
+	}
+	want := map[string]string{
+		"/gzip":  "gzip body",
+		"/br":    "brotli body",
+		"/plain": "plain body",
+	}
+
+	for name, client := range clients {
+		for path, wantBody := range want {
+			t.Run(name+path, func(t *testing.T) {
+				resp, err := client.Get(server.URL + path)
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				defer resp.Body.Close()
+
+				body, err := io.ReadAll(resp.Body)
+				if err != nil {
+					t.Fatalf("failed to read body: %v", err)
+				}
+				if string(body) != wantBody {
+					t.Fatalf("expected %q, got %q", wantBody, body)
+				}
+				if resp.Header.Get("Content-Encoding") != "" {
+					t.Errorf("expected Content-Encoding to be removed, got %q", resp.Header.Get("Content-Encoding"))
+				}
+			})
+		}
+	}
+}
+
+func TestFactory_Proxy(t *testing.T) {
+	var proxied []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = append(proxied, r.URL.String())
+		fmt.Fprint(w, "via proxy")
+	}))
+	defer proxy.Close()
+
+	resolver := stubResolver{
+		"public.example":   {netip.MustParseAddr("93.184.216.34")},
+		"internal.example": {netip.MustParseAddr("10.0.0.5")},
+	}
+	factory, err := NewFactory(Config{ProxyURL: proxy.URL, Resolver: resolver})
+	if err != nil {
+		t.Fatalf("failed to create factory: %v", err)
+	}
+	client := factory.NewFetchClient()
+
+	resp, err := client.Get("http://public.example/feed.xml")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "via proxy" {
+		t.Fatalf("expected response from proxy, got %q", body)
+	}
+	if len(proxied) != 1 || proxied[0] != "http://public.example/feed.xml" {
+		t.Fatalf("unexpected proxied requests: %v", proxied)
+	}
+
+	// プロキシ経由でも内部アドレスへのリクエストは送信前に拒否する
+	if _, err := client.Get("http://internal.example/"); !errors.Is(err, ErrBlockedAddress) {
+		t.Fatalf("expected %v, got %v", ErrBlockedAddress, err)
+	}
+	if len(proxied) != 1 {
+		t.Fatalf("expected blocked request not to reach the proxy, got %v", proxied)
+	}
+}
+
+func TestNewFactory_InvalidProxy(t *testing.T) {
+	for _, proxyURL := range []string{"socks5://127.0.0.1:1080", "://bad", "http://"} {
+		if _, err := NewFactory(Config{ProxyURL: proxyURL}); err == nil {
+			t.Errorf("%s: expected error, got nil", proxyURL)
+		}
+	}
+}
+
+func TestFactory_RobotsTxt(t *testing.T) {
+	var robotsRequests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			robotsRequests++
+			fmt.Fprint(w, strings.Join([]string{
+				"User-agent: *",
+				"Disallow: /",
+				"",
+				"User-agent: testbot",
+				"Disallow: /private/",
+			}, "\n"))
+			return
+		}
+		fmt.Fprint(w, "ok")
+	}))
+	defer server.Close()
+
+	allowlist, err := ParseAllowlist([]string{"127.0.0.1"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	client := newFetchClient(t, Config{UserAgent: "testbot/1.0", RespectRobotsTxt: true, Allowlist: allowlist})
+
+	resp, err := client.Get(server.URL + "/public/article")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	resp.Body.Close()
+
+	if _, err := client.Get(server.URL + "/private/article"); !errors.Is(err, ErrDisallowedByRobots) {
+		t.Fatalf("expected %v, got %v", ErrDisallowedByRobots, err)
+	}
+	if robotsRequests != 1 {
+		t.Fatalf("expected robots.txt to be fetched once, got %d", robotsRequests)
+	}
+}
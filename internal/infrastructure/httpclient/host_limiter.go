@@ -0,0 +1,68 @@
+package httpclient
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// hostLimiterPruneSize を超えたら、待ち時間の過ぎたホストを削除する
+const hostLimiterPruneSize = 1024
+
+// hostLimiter は同じホストへのリクエストが interval 以上の間隔になるように待機させます
+type hostLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     map[string]time.Time
+	now      func() time.Time
+}
+
+func newHostLimiter(interval time.Duration) *hostLimiter {
+	return &hostLimiter{
+		interval: interval,
+		next:     make(map[string]time.Time),
+		now:      time.Now,
+	}
+}
+
+func (l *hostLimiter) wait(ctx context.Context, host string) error {
+	if l == nil || l.interval <= 0 {
+		return nil
+	}
+
+	delay := l.reserve(host)
+	if delay <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// reserve は host の次の枠を予約し、その枠までの待ち時間を返します
+func (l *hostLimiter) reserve(host string) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	if len(l.next) >= hostLimiterPruneSize {
+		for h, next := range l.next {
+			if next.Before(now) {
+				delete(l.next, h)
+			}
+		}
+	}
+
+	slot := l.next[host]
+	if slot.Before(now) {
+		slot = now
+	}
+	l.next[host] = slot.Add(l.interval)
+	return slot.Sub(now)
+}
@@ -0,0 +1,54 @@
+package httpclient
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestHostLimiter_Reserve(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	limiter := newHostLimiter(2 * time.Second)
+	limiter.now = func() time.Time { return now }
+
+	if delay := limiter.reserve("a.example"); delay != 0 {
+		t.Fatalf("expected first request to proceed immediately, got %v", delay)
+	}
+	if delay := limiter.reserve("a.example"); delay != 2*time.Second {
+		t.Fatalf("expected second request to wait 2s, got %v", delay)
+	}
+	if delay := limiter.reserve("a.example"); delay != 4*time.Second {
+		t.Fatalf("expected third request to wait 4s, got %v", delay)
+	}
+	if delay := limiter.reserve("b.example"); delay != 0 {
+		t.Fatalf("expected other host not to wait, got %v", delay)
+	}
+
+	now = now.Add(10 * time.Second)
+	if delay := limiter.reserve("a.example"); delay != 0 {
+		t.Fatalf("expected request after interval to proceed immediately, got %v", delay)
+	}
+}
+
+func TestHostLimiter_WaitCanceled(t *testing.T) {
+	limiter := newHostLimiter(time.Hour)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	if err := limiter.wait(ctx, "a.example"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	cancel()
+	if err := limiter.wait(ctx, "a.example"); err == nil {
+		t.Fatal("expected error for canceled context")
+	}
+}
+
+func TestHostLimiter_Disabled(t *testing.T) {
+	var limiter *hostLimiter
+	if err := limiter.wait(context.Background(), "a.example"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := newHostLimiter(0).wait(context.Background(), "a.example"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
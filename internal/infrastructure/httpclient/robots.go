@@ -0,0 +1,210 @@
+package httpclient
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	robotsTTL      = 24 * time.Hour
+	robotsErrorTTL = 10 * time.Minute
+	maxRobotsBytes = int64(512 * 1024)
+)
+
+// robotsCache はホストごとの robots.txt をキャッシュして、URLの取得可否を判定します
+// 取得結果の扱いは RFC 9309 に従います（4xxは全許可、5xxや接続エラーは全禁止）
+type robotsCache struct {
+	mu      sync.Mutex
+	agent   string
+	entries map[string]robotsEntry
+	now     func() time.Time
+}
+
+type robotsEntry struct {
+	rules   robotsRules
+	expires time.Time
+}
+
+func newRobotsCache(userAgent string) *robotsCache {
+	return &robotsCache{
+		agent:   productToken(userAgent),
+		entries: make(map[string]robotsEntry),
+		now:     time.Now,
+	}
+}
+
+func (c *robotsCache) allowed(ctx context.Context, target *url.URL, fetch func(*http.Request) (*http.Response, error)) (bool, error) {
+	key := target.Scheme + "://" + target.Host
+
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	c.mu.Unlock()
+
+	if !ok || c.now().After(entry.expires) {
+		rules, ttl, err := c.fetch(ctx, key, fetch)
+		if err != nil {
+			return false, err
+		}
+		entry = robotsEntry{rules: rules, expires: c.now().Add(ttl)}
+
+		c.mu.Lock()
+		c.entries[key] = entry
+		c.mu.Unlock()
+	}
+
+	path := target.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	if target.RawQuery != "" {
+		path += "?" + target.RawQuery
+	}
+	return entry.rules.allows(path), nil
+}
+
+func (c *robotsCache) fetch(ctx context.Context, origin string, fetch func(*http.Request) (*http.Response, error)) (robotsRules, time.Duration, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, origin+"/robots.txt", nil)
+	if err != nil {
+		return robotsRules{}, 0, err
+	}
+
+	resp, err := fetch(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return robotsRules{}, 0, ctx.Err()
+		}
+		return robotsRules{disallowAll: true}, robotsErrorTTL, nil
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= http.StatusInternalServerError:
+		return robotsRules{disallowAll: true}, robotsErrorTTL, nil
+	case resp.StatusCode >= http.StatusBadRequest:
+		return robotsRules{}, robotsTTL, nil
+	}
+	return parseRobotsTxt(io.LimitReader(resp.Body, maxRobotsBytes), c.agent), robotsTTL, nil
+}
+
+type robotsRule struct {
+	allow   bool
+	pattern string
+}
+
+type robotsRules struct {
+	disallowAll bool
+	rules       []robotsRule
+}
+
+// allows は最も長く一致したルールに従って判定します（同じ長さなら Allow を優先）
+func (r robotsRules) allows(path string) bool {
+	if r.disallowAll {
+		return false
+	}
+
+	allowed := true
+	matched := -1
+	for _, rule := range r.rules {
+		if rule.pattern == "" || !matchRobotsPattern(rule.pattern, path) {
+			continue
+		}
+		length := len(rule.pattern)
+		if length > matched || (length == matched && rule.allow) {
+			matched = length
+			allowed = rule.allow
+		}
+	}
+	return allowed
+}
+
+// parseRobotsTxt は agent に一致するグループのルールを返します
+// 一致するグループがなければ "*" のグループを使います
+func parseRobotsTxt(r io.Reader, agent string) robotsRules {
+	var specific, wildcard []robotsRule
+	var foundSpecific bool
+	var groupSpecific, groupWildcard, inRules bool
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "user-agent":
+			if inRules {
+				groupSpecific, groupWildcard, inRules = false, false, false
+			}
+			if value == "*" {
+				groupWildcard = true
+			} else if strings.EqualFold(value, agent) {
+				groupSpecific = true
+				foundSpecific = true
+			}
+		case "allow", "disallow":
+			inRules = true
+			rule := robotsRule{allow: key == "allow", pattern: value}
+			if groupSpecific {
+				specific = append(specific, rule)
+			}
+			if groupWildcard {
+				wildcard = append(wildcard, rule)
+			}
+		}
+	}
+
+	if foundSpecific {
+		return robotsRules{rules: specific}
+	}
+	return robotsRules{rules: wildcard}
+}
+
+// matchRobotsPattern は "*"（任意の文字列）と末尾の "$"（終端）に対応したパスの前方一致です
+func matchRobotsPattern(pattern, path string) bool {
+	anchored := strings.HasSuffix(pattern, "$")
+	if anchored {
+		pattern = strings.TrimSuffix(pattern, "$")
+	}
+
+	parts := strings.Split(pattern, "*")
+	if !strings.HasPrefix(path, parts[0]) {
+		return false
+	}
+	pos := len(parts[0])
+
+	for i, part := range parts[1:] {
+		if anchored && i == len(parts)-2 {
+			return strings.HasSuffix(path[pos:], part)
+		}
+		index := strings.Index(path[pos:], part)
+		if index < 0 {
+			return false
+		}
+		pos += index + len(part)
+	}
+	return !anchored || pos == len(path)
+}
+
+// productToken は User-Agent の先頭のトークン（"misskeyRSSbot/1.0" なら "misskeyRSSbot"）を返します
+func productToken(userAgent string) string {
+	token, _, _ := strings.Cut(strings.TrimSpace(userAgent), " ")
+	token, _, _ = strings.Cut(token, "/")
+	return token
+}
+
+func isRobotsTxt(u *url.URL) bool {
+	return u.Path == "/robots.txt"
+}
@@ -0,0 +1,68 @@
+package httpclient
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseRobotsTxt(t *testing.T) {
+	robotsTxt := `# comment
+User-agent: *
+Disallow: /admin/
+Allow: /admin/public/
+
+User-agent: otherbot
+User-agent: MisskeyRSSbot
+Disallow: /feeds/private
+Disallow: /*.pdf$
+Allow: /feeds/private/ok
+`
+
+	testCases := []struct {
+		name  string
+		agent string
+		path  string
+		want  bool
+	}{
+		{name: "wildcard group disallow", agent: "somebot", path: "/admin/settings", want: false},
+		{name: "wildcard group longer allow wins", agent: "somebot", path: "/admin/public/page", want: true},
+		{name: "wildcard group default allow", agent: "somebot", path: "/news/1", want: true},
+		{name: "specific group replaces wildcard", agent: "misskeyRSSbot", path: "/admin/settings", want: true},
+		{name: "specific group disallow prefix", agent: "misskeyRSSbot", path: "/feeds/private/1", want: false},
+		{name: "specific group longer allow wins", agent: "misskeyRSSbot", path: "/feeds/private/ok/1", want: true},
+		{name: "wildcard with end anchor", agent: "misskeyRSSbot", path: "/reports/2024.pdf", want: false},
+		{name: "end anchor does not match longer path", agent: "misskeyRSSbot", path: "/reports/2024.pdf?download=1", want: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rules := parseRobotsTxt(strings.NewReader(robotsTxt), tc.agent)
+			if got := rules.allows(tc.path); got != tc.want {
+				t.Fatalf("expected %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestRobotsRules_EmptyDisallowAllowsAll(t *testing.T) {
+	rules := parseRobotsTxt(strings.NewReader("User-agent: *\nDisallow:\n"), "bot")
+	if !rules.allows("/anything") {
+		t.Fatal("expected empty Disallow to allow everything")
+	}
+	if (robotsRules{disallowAll: true}).allows("/") {
+		t.Fatal("expected disallowAll to reject everything")
+	}
+}
+
+func TestProductToken(t *testing.T) {
+	testCases := map[string]string{
+		"misskeyRSSbot/1.0":                    "misskeyRSSbot",
+		"misskeyRSSbot/1.0 (+https://example)": "misskeyRSSbot",
+		"plainbot":                             "plainbot",
+	}
+	for userAgent, want := range testCases {
+		if got := productToken(userAgent); got != want {
+			t.Errorf("%s: expected %q, got %q", userAgent, want, got)
+		}
+	}
+}
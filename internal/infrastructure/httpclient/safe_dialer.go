@@ -0,0 +1,180 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+)
+
+// ErrBlockedAddress は接続先がプライベート・ループバック・リンクローカル等のアドレスだった場合のエラーです
+var ErrBlockedAddress = errors.New("blocked address")
+
+// Resolver はホスト名をIPアドレスに解決します（テストではスタブに差し替える）
+type Resolver interface {
+	LookupNetIP(ctx context.Context, network, host string) ([]netip.Addr, error)
+}
+
+// blockedPrefixes は net/netip の判定メソッドでカバーされない予約済みアドレス帯です
+var blockedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("192.0.2.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("198.51.100.0/24"),
+	netip.MustParsePrefix("203.0.113.0/24"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+	netip.MustParsePrefix("2001:db8::/32"),
+}
+
+// IsBlockedIP は外部からの取得で接続してはいけないアドレスかどうかを返します
+func IsBlockedIP(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsValid() ||
+		addr.IsLoopback() ||
+		addr.IsPrivate() ||
+		addr.IsLinkLocalUnicast() ||
+		addr.IsLinkLocalMulticast() ||
+		addr.IsInterfaceLocalMulticast() ||
+		addr.IsMulticast() ||
+		addr.IsUnspecified() {
+		return true
+	}
+	for _, prefix := range blockedPrefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// Allowlist はSSRF対策の対象外にするホスト名とアドレス帯です
+type Allowlist struct {
+	hosts    map[string]bool
+	prefixes []netip.Prefix
+}
+
+// ParseAllowlist は "internal.example.com,10.0.0.0/8,192.168.1.10" のような
+// ホスト名・CIDR・IPアドレスの一覧を解釈します
+func ParseAllowlist(entries []string) (*Allowlist, error) {
+	allowlist := &Allowlist{hosts: make(map[string]bool)}
+	for _, entry := range entries {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		if entry == "" {
+			continue
+		}
+		if prefix, err := netip.ParsePrefix(entry); err == nil {
+			allowlist.prefixes = append(allowlist.prefixes, prefix.Masked())
+			continue
+		}
+		if addr, err := netip.ParseAddr(entry); err == nil {
+			allowlist.prefixes = append(allowlist.prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		if strings.ContainsAny(entry, "/:") {
+			return nil, fmt.Errorf("invalid allowlist entry: %q", entry)
+		}
+		allowlist.hosts[strings.TrimSuffix(entry, ".")] = true
+	}
+	return allowlist, nil
+}
+
+// withHost は host を追加した Allowlist のコピーを返します
+func (a *Allowlist) withHost(host string) *Allowlist {
+	copied := &Allowlist{hosts: make(map[string]bool)}
+	if a != nil {
+		for allowed := range a.hosts {
+			copied.hosts[allowed] = true
+		}
+		copied.prefixes = append(copied.prefixes, a.prefixes...)
+	}
+	copied.hosts[strings.TrimSuffix(strings.ToLower(host), ".")] = true
+	return copied
+}
+
+func (a *Allowlist) allowsHost(host string) bool {
+	if a == nil {
+		return false
+	}
+	return a.hosts[strings.TrimSuffix(strings.ToLower(host), ".")]
+}
+
+func (a *Allowlist) allowsAddr(addr netip.Addr) bool {
+	if a == nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range a.prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// safeDialer はDNS解決後のアドレスを検査してから接続するダイアラーです
+// 解決済みのIPアドレスに直接接続するため、検査後にDNSの応答が変わっても（DNSリバインディング）影響を受けません
+type safeDialer struct {
+	dialer    *net.Dialer
+	resolver  Resolver
+	allowlist *Allowlist
+}
+
+func (d *safeDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+
+	addrs, err := d.checkHost(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+
+	var lastErr error
+	for _, addr := range addrs {
+		conn, err := d.dialer.DialContext(ctx, network, net.JoinHostPort(addr.Unmap().String(), port))
+		if err == nil {
+			return conn, nil
+		}
+		lastErr = err
+	}
+	return nil, lastErr
+}
+
+// checkHost はホストを名前解決し、接続が許可されないアドレスが含まれていればエラーを返します
+func (d *safeDialer) checkHost(ctx context.Context, host string) ([]netip.Addr, error) {
+	addrs, err := d.resolve(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+
+	if d.allowlist.allowsHost(host) {
+		return addrs, nil
+	}
+	for _, addr := range addrs {
+		if !d.allowlist.allowsAddr(addr) && IsBlockedIP(addr) {
+			return nil, fmt.Errorf("%w: %s resolves to %s", ErrBlockedAddress, host, addr)
+		}
+	}
+	return addrs, nil
+}
+
+func (d *safeDialer) resolve(ctx context.Context, host string) ([]netip.Addr, error) {
+	if addr, err := netip.ParseAddr(host); err == nil {
+		return []netip.Addr{addr}, nil
+	}
+
+	addrs, err := d.resolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve %s: %w", host, err)
+	}
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("failed to resolve %s: no addresses", host)
+	}
+	return addrs, nil
+}
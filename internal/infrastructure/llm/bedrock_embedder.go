@@ -0,0 +1,114 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	"github.com/aws/smithy-go/auth/bearer"
+
+	"misskeyRSSbot/internal/domain/entity"
+	"misskeyRSSbot/internal/domain/repository"
+)
+
+// bedrockEmbedder は Amazon Titan Text Embeddings の形式（inputText → embedding）で埋め込みベクトルを生成します
+type bedrockEmbedder struct {
+	client   *bedrockruntime.Client
+	modelID  string
+	timeout  time.Duration
+	recorder repository.UsageRecorder
+}
+
+type titanEmbeddingRequest struct {
+	InputText string `json:"inputText"`
+}
+
+type titanEmbeddingResponse struct {
+	Embedding           []float32 `json:"embedding"`
+	InputTextTokenCount int64     `json:"inputTextTokenCount"`
+}
+
+func newBedrockEmbedder(ctx context.Context, cfg Config) (repository.EmbeddingRepository, error) {
+	if cfg.Model == "" {
+		return nil, fmt.Errorf("bedrock embedding model ID is required")
+	}
+	if cfg.APIKey == "" {
+		return nil, fmt.Errorf("bedrock bearer token is required (set LLM_API_KEY)")
+	}
+	if cfg.Region == "" {
+		return nil, fmt.Errorf("bedrock region is required (set LLM_REGION)")
+	}
+
+	timeout := cfg.Timeout
+	if timeout == 0 {
+		timeout = 30 * time.Second
+	}
+
+	sdkConfig, err := config.LoadDefaultConfig(ctx, config.WithRegion(cfg.Region))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load aws config: %w", err)
+	}
+
+	sdkConfig.BearerAuthTokenProvider = bearer.NewTokenCache(bearer.StaticTokenProvider{
+		Token: bearer.Token{Value: cfg.APIKey},
+	})
+	sdkConfig.AuthSchemePreference = []string{"httpBearerAuth"}
+
+	return &bedrockEmbedder{
+		client:   bedrockruntime.NewFromConfig(sdkConfig),
+		modelID:  cfg.Model,
+		timeout:  timeout,
+		recorder: cfg.UsageRecorder,
+	}, nil
+}
+
+func (e *bedrockEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	ctx, cancel := context.WithTimeout(ctx, e.timeout)
+	defer cancel()
+
+	// Titan の埋め込みモデルは1リクエストにつき1テキストのみ受け付ける
+	vectors := make([][]float32, 0, len(texts))
+	usage := entity.TokenUsage{Provider: "bedrock", Model: e.modelID}
+	// 途中で失敗しても、それまでに課金されたトークンは記録する
+	defer func() { recordUsage(context.WithoutCancel(ctx), e.recorder, usage) }()
+	for _, text := range texts {
+		body, err := json.Marshal(titanEmbeddingRequest{InputText: text})
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode embedding request: %w", err)
+		}
+
+		resp, err := e.client.InvokeModel(ctx, &bedrockruntime.InvokeModelInput{
+			ModelId:     aws.String(e.modelID),
+			Body:        body,
+			ContentType: aws.String("application/json"),
+			Accept:      aws.String("application/json"),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to invoke bedrock embedding model: %w", err)
+		}
+
+		vector, tokens, err := parseTitanEmbedding(resp.Body)
+		usage.InputTokens += tokens
+		if err != nil {
+			return nil, err
+		}
+		vectors = append(vectors, vector)
+	}
+	return vectors, nil
+}
+
+// parseTitanEmbedding は埋め込みベクトルと入力トークン数を返します
+func parseTitanEmbedding(body []byte) ([]float32, int64, error) {
+	var resp titanEmbeddingResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, 0, fmt.Errorf("failed to decode bedrock embedding response: %w", err)
+	}
+	if len(resp.Embedding) == 0 {
+		return nil, resp.InputTextTokenCount, fmt.Errorf("empty embedding in bedrock response")
+	}
+	return resp.Embedding, resp.InputTextTokenCount, nil
+}
@@ -9,9 +9,11 @@ import (
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/document"
 	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
 	"github.com/aws/smithy-go/auth/bearer"
 
+	"misskeyRSSbot/internal/domain/entity"
 	"misskeyRSSbot/internal/domain/repository"
 	htmlfetcher "misskeyRSSbot/internal/infrastructure/html"
 )
@@ -21,7 +23,11 @@ type bedrockSummarizer struct {
 	modelID      string
 	maxTokens    int32
 	systemPrompt string
+	language     string
+	structured   bool
 	timeout      time.Duration
+	recorder     repository.UsageRecorder
+	fetcher      *htmlfetcher.Fetcher
 }
 
 const (
@@ -52,6 +58,12 @@ func newBedrockSummarizer(ctx context.Context, cfg Config) (repository.Summarize
 		timeout = 30 * time.Second
 	}
 
+	// 記事は SSRF 対策や robots.txt の確認を行う共通の HTTP クライアントで取得するため、呼び出し側で用意する
+	fetcher := cfg.ArticleFetcher
+	if fetcher == nil {
+		return nil, fmt.Errorf("bedrock summarizer requires an article fetcher")
+	}
+
 	maxTokens := bedrockDefaultMaxTokens
 	if cfg.MaxTokens > 0 {
 		maxTokens = int32(cfg.MaxTokens)
@@ -74,43 +86,58 @@ func newBedrockSummarizer(ctx context.Context, cfg Config) (repository.Summarize
 		modelID:      cfg.Model,
 		maxTokens:    maxTokens,
 		systemPrompt: systemInstruction,
+		language:     cfg.Language,
+		structured:   cfg.StructuredOutput,
 		timeout:      timeout,
+		recorder:     cfg.UsageRecorder,
+		fetcher:      fetcher,
 	}, nil
 }
 
-func (s *bedrockSummarizer) Summarize(ctx context.Context, url, title string) (string, error) {
+func (s *bedrockSummarizer) Summarize(ctx context.Context, req repository.SummarizeRequest) (*entity.Summary, error) {
 	ctx, cancel := context.WithTimeout(ctx, s.timeout)
 	defer cancel()
 
-	articleText, err := htmlfetcher.FetchArticleText(ctx, url, s.timeout)
+	articleText, err := s.fetcher.FetchArticleText(ctx, req.URL)
 	if err != nil {
-		return "", fmt.Errorf("failed to fetch article text: %w", err)
+		return nil, fmt.Errorf("failed to fetch article text: %w", err)
 	}
 
-	prompt := fmt.Sprintf("記事タイトル: %s\n記事URL: %s\n\n記事本文:\n%s", title, url, articleText)
-	input := s.buildConverseInput(prompt)
+	prompt := fmt.Sprintf("記事タイトル: %s\n記事URL: %s\n\n記事本文:\n%s", req.Title, req.URL, articleText)
+	input := s.buildConverseInput(req, prompt)
 	resp, err := s.client.Converse(ctx, input)
 	if err != nil {
-		return "", fmt.Errorf("failed to invoke bedrock model: %w", err)
+		return nil, fmt.Errorf("failed to invoke bedrock model: %w", err)
+	}
+
+	recordUsage(ctx, s.recorder, bedrockTokenUsage(aws.ToString(input.ModelId), resp.Usage))
+
+	if s.structured {
+		return parseToolSummary(resp)
 	}
 
-	summary, err := s.parseResponse(resp)
+	text, err := s.parseResponse(resp)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	return summary, nil
+	return parseSummary(text, false)
 }
 
 func (s *bedrockSummarizer) IsEnabled() bool {
 	return true
 }
 
-func (s *bedrockSummarizer) buildConverseInput(prompt string) *bedrockruntime.ConverseInput {
+func (s *bedrockSummarizer) buildConverseInput(req repository.SummarizeRequest, prompt string) *bedrockruntime.ConverseInput {
 	temperature := float32(0.3)
 
+	modelID := s.modelID
+	if req.Model != "" {
+		modelID = req.Model
+	}
+
 	return &bedrockruntime.ConverseInput{
-		ModelId: aws.String(s.modelID),
+		ModelId: aws.String(modelID),
 		Messages: []types.Message{
 			{
 				Role: types.ConversationRoleUser,
@@ -120,13 +147,63 @@ func (s *bedrockSummarizer) buildConverseInput(prompt string) *bedrockruntime.Co
 			},
 		},
 		System: []types.SystemContentBlock{
-			&types.SystemContentBlockMemberText{Value: s.systemPrompt},
+			&types.SystemContentBlockMemberText{
+				Value: buildSystemInstruction(s.systemPrompt, s.language, req),
+			},
 		},
 		InferenceConfig: &types.InferenceConfiguration{
 			MaxTokens:   aws.Int32(s.maxTokens),
 			Temperature: aws.Float32(temperature),
 		},
+		ToolConfig: s.structuredToolConfig(),
+	}
+}
+
+// summaryToolName は構造化出力を受け取るツールの名前です
+const summaryToolName = "record_summary"
+
+// structuredToolConfig は構造化出力のスキーマを入力に持つツールを返し、モデルにそのツールの呼び出しを強制します
+// Converse API のネイティブな構造化出力で、ツールの入力がそのまま要約になります
+// ツールの利用（tool choice の指定）に対応したモデルが必要です
+func (s *bedrockSummarizer) structuredToolConfig() *types.ToolConfiguration {
+	if !s.structured {
+		return nil
+	}
+	return &types.ToolConfiguration{
+		Tools: []types.Tool{
+			&types.ToolMemberToolSpec{
+				Value: types.ToolSpecification{
+					Name:        aws.String(summaryToolName),
+					Description: aws.String("記事の要約を記録する"),
+					InputSchema: &types.ToolInputSchemaMemberJson{Value: document.NewLazyDocument(structuredOutputSchema)},
+				},
+			},
+		},
+		ToolChoice: &types.ToolChoiceMemberTool{
+			Value: types.SpecificToolChoice{Name: aws.String(summaryToolName)},
+		},
+	}
+}
+
+// parseToolSummary は summaryToolName のツール呼び出しの入力を構造化出力として解釈します
+func parseToolSummary(resp *bedrockruntime.ConverseOutput) (*entity.Summary, error) {
+	messageOutput, ok := resp.Output.(*types.ConverseOutputMemberMessage)
+	if !ok {
+		return nil, fmt.Errorf("unexpected bedrock response output type: %T", resp.Output)
+	}
+
+	for _, block := range messageOutput.Value.Content {
+		toolUse, ok := block.(*types.ContentBlockMemberToolUse)
+		if !ok || aws.ToString(toolUse.Value.Name) != summaryToolName || toolUse.Value.Input == nil {
+			continue
+		}
+		input, err := toolUse.Value.Input.MarshalSmithyDocument()
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode bedrock tool input: %w", err)
+		}
+		return parseStructuredSummary(string(input))
 	}
+	return nil, fmt.Errorf("no %s tool use in bedrock response", summaryToolName)
 }
 
 func (s *bedrockSummarizer) parseResponse(resp *bedrockruntime.ConverseOutput) (string, error) {
@@ -161,3 +238,13 @@ func (s *bedrockSummarizer) parseResponse(resp *bedrockruntime.ConverseOutput) (
 	}
 	return summary, nil
 }
+
+func bedrockTokenUsage(modelID string, tokenUsage *types.TokenUsage) entity.TokenUsage {
+	usage := entity.TokenUsage{Provider: "bedrock", Model: modelID}
+	if tokenUsage == nil {
+		return usage
+	}
+	usage.InputTokens = int64(aws.ToInt32(tokenUsage.InputTokens))
+	usage.OutputTokens = int64(aws.ToInt32(tokenUsage.OutputTokens))
+	return usage
+}
@@ -5,8 +5,13 @@ import (
 	"testing"
 	"time"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/document"
 	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+
+	"misskeyRSSbot/internal/domain/entity"
+	"misskeyRSSbot/internal/domain/repository"
 )
 
 func TestBedrockSummarizerBuildConverseInput(t *testing.T) {
@@ -18,7 +23,7 @@ func TestBedrockSummarizerBuildConverseInput(t *testing.T) {
 		timeout:      customTimeout,
 	}
 
-	input := s.buildConverseInput("hello")
+	input := s.buildConverseInput(repository.SummarizeRequest{}, "hello")
 	if input.ModelId == nil || *input.ModelId != "test-model" {
 		t.Fatalf("expected model ID to be set, got %v", input.ModelId)
 	}
@@ -69,6 +74,35 @@ func TestBedrockSummarizerBuildConverseInput(t *testing.T) {
 	}
 }
 
+func TestBedrockSummarizerBuildConverseInputWithOverrides(t *testing.T) {
+	s := &bedrockSummarizer{
+		modelID:      "default-model",
+		maxTokens:    256,
+		systemPrompt: "system prompt",
+		language:     DefaultLanguage,
+	}
+
+	input := s.buildConverseInput(repository.SummarizeRequest{
+		SystemInstruction: "feed prompt",
+		Language:          "English",
+		MaxLength:         100,
+		Model:             "feed-model",
+	}, "hello")
+
+	if input.ModelId == nil || *input.ModelId != "feed-model" {
+		t.Fatalf("expected model ID override, got %v", input.ModelId)
+	}
+
+	systemBlock, ok := input.System[0].(*types.SystemContentBlockMemberText)
+	if !ok {
+		t.Fatalf("expected system text block, got %T", input.System[0])
+	}
+	want := "feed prompt\n- Englishで出力する\n- 100文字以内にまとめる"
+	if systemBlock.Value != want {
+		t.Fatalf("expected system prompt %q, got %q", want, systemBlock.Value)
+	}
+}
+
 func TestBedrockSummarizerParseResponse(t *testing.T) {
 	testCases := []struct {
 		name      string
@@ -139,6 +173,57 @@ func TestBedrockSummarizerParseResponse(t *testing.T) {
 	}
 }
 
+func TestBedrockSummarizerStructuredOutput(t *testing.T) {
+	s := &bedrockSummarizer{modelID: "test-model", maxTokens: 256, systemPrompt: "system prompt", structured: true}
+
+	input := s.buildConverseInput(repository.SummarizeRequest{}, "hello")
+	if input.ToolConfig == nil || len(input.ToolConfig.Tools) != 1 {
+		t.Fatalf("expected a summary tool, got %+v", input.ToolConfig)
+	}
+	choice, ok := input.ToolConfig.ToolChoice.(*types.ToolChoiceMemberTool)
+	if !ok || aws.ToString(choice.Value.Name) != summaryToolName {
+		t.Errorf("expected the summary tool to be forced, got %+v", input.ToolConfig.ToolChoice)
+	}
+	if systemBlock := input.System[0].(*types.SystemContentBlockMemberText); systemBlock.Value != "system prompt" {
+		t.Errorf("expected schema not to be added to the system prompt, got %q", systemBlock.Value)
+	}
+	if (&bedrockSummarizer{}).buildConverseInput(repository.SummarizeRequest{}, "hello").ToolConfig != nil {
+		t.Error("expected no tool config without structured output")
+	}
+
+	toolUse := func(name string) *bedrockruntime.ConverseOutput {
+		return &bedrockruntime.ConverseOutput{
+			Output: &types.ConverseOutputMemberMessage{Value: types.Message{
+				Role: types.ConversationRoleAssistant,
+				Content: []types.ContentBlock{
+					&types.ContentBlockMemberText{Value: "要約を記録します"},
+					&types.ContentBlockMemberToolUse{Value: types.ToolUseBlock{
+						Name:      aws.String(name),
+						ToolUseId: aws.String("tool-1"),
+						Input: document.NewLazyDocument(map[string]any{
+							"summary":   "要約",
+							"hashtags":  []string{"Go"},
+							"sentiment": "positive",
+							"extra":     true,
+						}),
+					}},
+				},
+			}},
+		}
+	}
+
+	summary, err := parseToolSummary(toolUse(summaryToolName))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if summary.Text != "要約" || summary.Sentiment != entity.SentimentPositive || len(summary.Hashtags) != 1 {
+		t.Errorf("unexpected summary: %+v", summary)
+	}
+	if _, err := parseToolSummary(toolUse("other_tool")); err == nil {
+		t.Error("expected error without the summary tool use, got nil")
+	}
+}
+
 func TestNewBedrockSummarizerRequiresRegion(t *testing.T) {
 	cfg := Config{
 		Provider: "bedrock",
@@ -151,3 +236,21 @@ func TestNewBedrockSummarizerRequiresRegion(t *testing.T) {
 		t.Fatalf("expected error when region is empty, got nil")
 	}
 }
+
+func TestBedrockTokenUsage(t *testing.T) {
+	usage := bedrockTokenUsage("test-model", &types.TokenUsage{
+		InputTokens:  aws.Int32(200),
+		OutputTokens: aws.Int32(40),
+	})
+
+	if usage.Provider != "bedrock" || usage.Model != "test-model" {
+		t.Errorf("unexpected provider/model: %+v", usage)
+	}
+	if usage.InputTokens != 200 || usage.OutputTokens != 40 {
+		t.Errorf("unexpected token counts: %+v", usage)
+	}
+
+	if empty := bedrockTokenUsage("test-model", nil); !empty.IsZero() {
+		t.Errorf("expected zero usage without usage block, got %+v", empty)
+	}
+}
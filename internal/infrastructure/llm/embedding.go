@@ -0,0 +1,23 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+
+	"misskeyRSSbot/internal/domain/repository"
+)
+
+// NewEmbeddingRepository は近似重複の判定に使う埋め込みベクトルを生成するリポジトリを作成します
+// cfg.Model には埋め込みモデル（gemini-embedding-001、amazon.titan-embed-text-v2:0 など）を指定します
+func NewEmbeddingRepository(ctx context.Context, cfg Config) (repository.EmbeddingRepository, error) {
+	switch cfg.Provider {
+	case "gemini":
+		return newGeminiEmbedder(ctx, cfg)
+	case "bedrock":
+		return newBedrockEmbedder(ctx, cfg)
+	case "noop", "":
+		return nil, fmt.Errorf("embeddings require an LLM provider (set LLM_PROVIDER)")
+	default:
+		return nil, fmt.Errorf("unknown LLM provider: %s", cfg.Provider)
+	}
+}
@@ -0,0 +1,91 @@
+package llm
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"google.golang.org/genai"
+)
+
+func TestNewEmbeddingRepository_Validation(t *testing.T) {
+	testCases := []struct {
+		name    string
+		config  Config
+		wantErr string
+	}{
+		{name: "no provider", config: Config{}, wantErr: "require an LLM provider"},
+		{name: "unknown provider", config: Config{Provider: "unknown"}, wantErr: "unknown LLM provider"},
+		{name: "gemini without API key", config: Config{Provider: "gemini", Model: "gemini-embedding-001"}, wantErr: "API key is required"},
+		{name: "gemini without model", config: Config{Provider: "gemini", APIKey: "key"}, wantErr: "embedding model name is required"},
+		{name: "bedrock without model", config: Config{Provider: "bedrock", APIKey: "token", Region: "us-east-1"}, wantErr: "embedding model ID is required"},
+		{name: "bedrock without region", config: Config{Provider: "bedrock", APIKey: "token", Model: "amazon.titan-embed-text-v2:0"}, wantErr: "region is required"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := NewEmbeddingRepository(context.Background(), tc.config)
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Fatalf("expected error containing %q, got %v", tc.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestGeminiEmbeddings(t *testing.T) {
+	resp := &genai.EmbedContentResponse{Embeddings: []*genai.ContentEmbedding{
+		{Values: []float32{0.1, 0.2}},
+		{Values: []float32{0.3, 0.4}},
+	}}
+	vectors, err := geminiEmbeddings(resp, 2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(vectors) != 2 || vectors[1][0] != 0.3 {
+		t.Fatalf("unexpected vectors: %v", vectors)
+	}
+
+	if _, err := geminiEmbeddings(resp, 3); err == nil {
+		t.Error("expected error for missing embeddings, got nil")
+	}
+	if _, err := geminiEmbeddings(&genai.EmbedContentResponse{Embeddings: []*genai.ContentEmbedding{{}}}, 1); err == nil {
+		t.Error("expected error for empty embedding, got nil")
+	}
+}
+
+func TestParseTitanEmbedding(t *testing.T) {
+	vector, tokens, err := parseTitanEmbedding([]byte(`{"embedding":[0.5,-0.25],"inputTextTokenCount":3}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(vector) != 2 || vector[1] != -0.25 {
+		t.Fatalf("unexpected vector: %v", vector)
+	}
+	if tokens != 3 {
+		t.Errorf("expected 3 input tokens, got %d", tokens)
+	}
+
+	if _, _, err := parseTitanEmbedding([]byte(`{"embedding":[]}`)); err == nil {
+		t.Error("expected error for empty embedding, got nil")
+	}
+	if _, _, err := parseTitanEmbedding([]byte(`not json`)); err == nil {
+		t.Error("expected error for invalid JSON, got nil")
+	}
+}
+
+func TestGeminiEmbeddingUsage(t *testing.T) {
+	texts := []string{"hello world!", "日本語"}
+
+	withStats := &genai.EmbedContentResponse{Embeddings: []*genai.ContentEmbedding{
+		{Statistics: &genai.ContentEmbeddingStatistics{TokenCount: 3}},
+		{Statistics: &genai.ContentEmbeddingStatistics{TokenCount: 2}},
+	}}
+	if usage := geminiEmbeddingUsage("model", withStats, texts); usage.InputTokens != 5 || usage.OutputTokens != 0 {
+		t.Errorf("expected 5 input tokens from statistics, got %+v", usage)
+	}
+
+	withoutStats := &genai.EmbedContentResponse{Embeddings: []*genai.ContentEmbedding{{}, {}}}
+	if usage := geminiEmbeddingUsage("model", withoutStats, texts); usage.InputTokens != 7 {
+		t.Errorf("expected 7 estimated input tokens, got %+v", usage)
+	}
+}
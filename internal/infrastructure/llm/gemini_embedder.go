@@ -0,0 +1,106 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"google.golang.org/genai"
+
+	"misskeyRSSbot/internal/domain/entity"
+	"misskeyRSSbot/internal/domain/repository"
+)
+
+type geminiEmbedder struct {
+	client   *genai.Client
+	model    string
+	timeout  time.Duration
+	recorder repository.UsageRecorder
+}
+
+func newGeminiEmbedder(ctx context.Context, cfg Config) (repository.EmbeddingRepository, error) {
+	if cfg.APIKey == "" {
+		return nil, fmt.Errorf("gemini API key is required")
+	}
+	if cfg.Model == "" {
+		return nil, fmt.Errorf("gemini embedding model name is required")
+	}
+
+	timeout := cfg.Timeout
+	if timeout == 0 {
+		timeout = 30 * time.Second
+	}
+
+	client, err := genai.NewClient(ctx, &genai.ClientConfig{
+		APIKey: cfg.APIKey,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create gemini client: %w", err)
+	}
+
+	return &geminiEmbedder{
+		client:   client,
+		model:    cfg.Model,
+		timeout:  timeout,
+		recorder: cfg.UsageRecorder,
+	}, nil
+}
+
+func (e *geminiEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	if len(texts) == 0 {
+		return nil, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, e.timeout)
+	defer cancel()
+
+	contents := make([]*genai.Content, 0, len(texts))
+	for _, text := range texts {
+		contents = append(contents, genai.NewContentFromText(text, genai.RoleUser))
+	}
+
+	resp, err := e.client.Models.EmbedContent(ctx, e.model, contents, &genai.EmbedContentConfig{
+		TaskType: "SEMANTIC_SIMILARITY",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed content: %w", err)
+	}
+	recordUsage(ctx, e.recorder, geminiEmbeddingUsage(e.model, resp, texts))
+	return geminiEmbeddings(resp, len(texts))
+}
+
+// geminiEmbeddingUsage は埋め込みの入力トークン数を返します
+// Gemini API は埋め込みのトークン数を返さない（Vertex AI のみ）ため、その場合は
+// UTF-8 で3バイトを1トークンとして見積もります。英語では多め、日本語ではおおよそ実数になります
+func geminiEmbeddingUsage(model string, resp *genai.EmbedContentResponse, texts []string) entity.TokenUsage {
+	usage := entity.TokenUsage{Provider: "gemini", Model: model}
+	if resp != nil {
+		for _, embedding := range resp.Embeddings {
+			if embedding != nil && embedding.Statistics != nil {
+				usage.InputTokens += int64(embedding.Statistics.TokenCount)
+			}
+		}
+	}
+	if usage.InputTokens > 0 {
+		return usage
+	}
+	for _, text := range texts {
+		usage.InputTokens += int64(len(text)+2) / 3
+	}
+	return usage
+}
+
+func geminiEmbeddings(resp *genai.EmbedContentResponse, expected int) ([][]float32, error) {
+	if resp == nil || len(resp.Embeddings) != expected {
+		return nil, fmt.Errorf("unexpected number of embeddings from gemini API")
+	}
+
+	vectors := make([][]float32, 0, expected)
+	for _, embedding := range resp.Embeddings {
+		if embedding == nil || len(embedding.Values) == 0 {
+			return nil, fmt.Errorf("empty embedding in gemini response")
+		}
+		vectors = append(vectors, embedding.Values)
+	}
+	return vectors, nil
+}
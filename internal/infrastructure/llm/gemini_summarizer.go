@@ -3,11 +3,14 @@ package llm
 import (
 	"context"
 	"fmt"
+	"strings"
 	"time"
 
 	"google.golang.org/genai"
 
+	"misskeyRSSbot/internal/domain/entity"
 	"misskeyRSSbot/internal/domain/repository"
+	htmlfetcher "misskeyRSSbot/internal/infrastructure/html"
 )
 
 type geminiSummarizer struct {
@@ -15,7 +18,11 @@ type geminiSummarizer struct {
 	model        string
 	maxTokens    *int32
 	systemPrompt string
+	language     string
+	structured   bool
 	timeout      time.Duration
+	recorder     repository.UsageRecorder
+	fetcher      *htmlfetcher.Fetcher
 }
 
 func newGeminiSummarizer(ctx context.Context, cfg Config) (repository.SummarizerRepository, error) {
@@ -55,50 +62,102 @@ func newGeminiSummarizer(ctx context.Context, cfg Config) (repository.Summarizer
 		model:        cfg.Model,
 		maxTokens:    maxTokens,
 		systemPrompt: systemInstruction,
+		language:     cfg.Language,
+		structured:   cfg.StructuredOutput,
 		timeout:      timeout,
+		recorder:     cfg.UsageRecorder,
+		fetcher:      cfg.ArticleFetcher,
 	}, nil
 }
 
-func (s *geminiSummarizer) Summarize(ctx context.Context, url, title string) (string, error) {
+func (s *geminiSummarizer) Summarize(ctx context.Context, req repository.SummarizeRequest) (*entity.Summary, error) {
 	ctx, cancel := context.WithTimeout(ctx, s.timeout)
 	defer cancel()
 
-	userPrompt := fmt.Sprintf("以下のURLの記事を要約してください。\n\n記事タイトル: %s\n記事URL: %s", title, url)
-
-	systemInstruction := genai.NewContentFromText(s.systemPrompt, genai.RoleUser)
+	userPrompt, config, err := s.buildRequest(ctx, req)
+	if err != nil {
+		return nil, err
+	}
 	userContent := genai.NewContentFromText(userPrompt, genai.RoleUser)
 
+	model := s.model
+	if req.Model != "" {
+		model = req.Model
+	}
+
+	resp, err := s.client.Models.GenerateContent(ctx, model, []*genai.Content{userContent}, config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate content: %w", err)
+	}
+
+	recordUsage(ctx, s.recorder, geminiTokenUsage(model, resp.UsageMetadata))
+
+	return s.parseResponse(resp)
+}
+
+// buildRequest はユーザープロンプトと生成の設定を返します
+// 構造化出力では記事の本文を取得してプロンプトに含め、responseJsonSchema でスキーマを指定します
+// URLContext ツールは responseJsonSchema と併用できないため、本文を取得できない場合のみ URLContext で記事を読ませ、
+// スキーマはプロンプトで指示します
+func (s *geminiSummarizer) buildRequest(ctx context.Context, req repository.SummarizeRequest) (string, *genai.GenerateContentConfig, error) {
 	temperature := float32(0.3)
 	config := &genai.GenerateContentConfig{
-		Temperature:       &temperature,
-		SystemInstruction: systemInstruction,
-		Tools: []*genai.Tool{
-			{
-				URLContext: &genai.URLContext{},
-			},
-		},
+		Temperature: &temperature,
 	}
 	if s.maxTokens != nil {
 		config.MaxOutputTokens = *s.maxTokens
 	}
 
-	resp, err := s.client.Models.GenerateContent(ctx, s.model, []*genai.Content{userContent}, config)
-	if err != nil {
-		return "", fmt.Errorf("failed to generate content: %w", err)
+	systemPrompt := buildSystemInstruction(s.systemPrompt, s.language, req)
+	if s.structured && s.fetcher != nil {
+		articleText, err := s.fetcher.FetchArticleText(ctx, req.URL)
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to fetch article text: %w", err)
+		}
+		config.SystemInstruction = genai.NewContentFromText(systemPrompt, genai.RoleUser)
+		config.ResponseMIMEType = "application/json"
+		config.ResponseJsonSchema = structuredOutputSchema
+		return fmt.Sprintf("以下の記事を要約してください。\n\n記事タイトル: %s\n記事URL: %s\n\n記事本文:\n%s", req.Title, req.URL, articleText), config, nil
+	}
+
+	config.SystemInstruction = genai.NewContentFromText(appendStructuredOutputInstruction(systemPrompt, s.structured), genai.RoleUser)
+	config.Tools = []*genai.Tool{
+		{
+			URLContext: &genai.URLContext{},
+		},
 	}
+	return fmt.Sprintf("以下のURLの記事を要約してください。\n\n記事タイトル: %s\n記事URL: %s", req.Title, req.URL), config, nil
+}
 
+func geminiTokenUsage(model string, metadata *genai.GenerateContentResponseUsageMetadata) entity.TokenUsage {
+	usage := entity.TokenUsage{Provider: "gemini", Model: model}
+	if metadata == nil {
+		return usage
+	}
+	usage.InputTokens = int64(metadata.PromptTokenCount) + int64(metadata.ToolUsePromptTokenCount)
+	usage.OutputTokens = int64(metadata.CandidatesTokenCount) + int64(metadata.ThoughtsTokenCount)
+	return usage
+}
+
+func (s *geminiSummarizer) parseResponse(resp *genai.GenerateContentResponse) (*entity.Summary, error) {
 	if len(resp.Candidates) == 0 {
-		return "", fmt.Errorf("no candidates returned from gemini API")
+		return nil, fmt.Errorf("no candidates returned from gemini API")
 	}
 
 	candidate := resp.Candidates[0]
 	if candidate.Content == nil || len(candidate.Content.Parts) == 0 {
-		return "", fmt.Errorf("no content in candidate response")
+		return nil, fmt.Errorf("no content in candidate response")
 	}
 
-	summary := candidate.Content.Parts[0].Text
+	var builder strings.Builder
+	for _, part := range candidate.Content.Parts {
+		if part == nil || part.Thought {
+			continue
+		}
+		builder.WriteString(part.Text)
+	}
 
-	return summary, nil
+	return parseSummary(builder.String(), s.structured)
 }
 
 func (s *geminiSummarizer) IsEnabled() bool {
@@ -2,9 +2,16 @@ package llm
 
 import (
 	"context"
+	"net/http"
+	"net/http/httptest"
 	"strings"
 	"testing"
 	"time"
+
+	"google.golang.org/genai"
+
+	"misskeyRSSbot/internal/domain/repository"
+	htmlfetcher "misskeyRSSbot/internal/infrastructure/html"
 )
 
 func TestGeminiSummarizer_NewGeminiSummarizer_NoAPIKey(t *testing.T) {
@@ -126,3 +133,143 @@ func TestGeminiSummarizer_CustomConfig(t *testing.T) {
 		t.Errorf("expected custom instruction '%s', got '%s'", customInstruction, cfg.SystemInstruction)
 	}
 }
+
+func TestGeminiSummarizer_ParseResponse(t *testing.T) {
+	testCases := []struct {
+		name       string
+		resp       *genai.GenerateContentResponse
+		structured bool
+		want       string
+		wantError  bool
+	}{
+		{
+			name: "joins text parts",
+			resp: &genai.GenerateContentResponse{Candidates: []*genai.Candidate{
+				{Content: genai.NewContentFromParts([]*genai.Part{{Text: "前半"}, {Text: "後半"}}, genai.RoleModel)},
+			}},
+			want: "前半後半",
+		},
+		{
+			name: "skips thought parts",
+			resp: &genai.GenerateContentResponse{Candidates: []*genai.Candidate{
+				{Content: genai.NewContentFromParts([]*genai.Part{{Text: "考え中", Thought: true}, {Text: "要約"}}, genai.RoleModel)},
+			}},
+			want: "要約",
+		},
+		{
+			name: "structured output",
+			resp: &genai.GenerateContentResponse{Candidates: []*genai.Candidate{
+				{Content: genai.NewContentFromText(`{"summary":"要約","hashtags":["Go"]}`, genai.RoleModel)},
+			}},
+			structured: true,
+			want:       "要約",
+		},
+		{
+			name:      "no candidates",
+			resp:      &genai.GenerateContentResponse{},
+			wantError: true,
+		},
+		{
+			name:      "no content",
+			resp:      &genai.GenerateContentResponse{Candidates: []*genai.Candidate{{}}},
+			wantError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := &geminiSummarizer{structured: tc.structured}
+			got, err := s.parseResponse(tc.resp)
+			if tc.wantError {
+				if err == nil {
+					t.Fatal("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got.Text != tc.want {
+				t.Errorf("expected %q, got %q", tc.want, got.Text)
+			}
+		})
+	}
+}
+
+func TestGeminiSummarizer_BuildRequest(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("<html><article>記事の本文です。</article></html>"))
+	}))
+	defer server.Close()
+
+	testCases := []struct {
+		name         string
+		structured   bool
+		fetcher      *htmlfetcher.Fetcher
+		wantNative   bool
+		wantInPrompt string
+	}{
+		{name: "free-form text uses URL context", fetcher: htmlfetcher.NewFetcher(nil, nil)},
+		{name: "structured without fetcher instructs schema in prompt", structured: true},
+		{name: "structured with fetcher uses response schema", structured: true, fetcher: htmlfetcher.NewFetcher(server.Client(), nil), wantNative: true, wantInPrompt: "記事の本文です。"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := &geminiSummarizer{systemPrompt: "system", structured: tc.structured, fetcher: tc.fetcher}
+			prompt, config, err := s.buildRequest(context.Background(), repository.SummarizeRequest{Title: "タイトル", URL: server.URL})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			systemPrompt := config.SystemInstruction.Parts[0].Text
+			if tc.wantNative {
+				if config.ResponseMIMEType != "application/json" || config.ResponseJsonSchema == nil {
+					t.Errorf("expected native JSON schema, got %q %v", config.ResponseMIMEType, config.ResponseJsonSchema)
+				}
+				if len(config.Tools) != 0 {
+					t.Errorf("expected no tools with response schema, got %d", len(config.Tools))
+				}
+				if strings.Contains(systemPrompt, `"content_warning"`) {
+					t.Errorf("expected schema not to be repeated in the prompt, got %q", systemPrompt)
+				}
+			} else {
+				if config.ResponseJsonSchema != nil {
+					t.Error("expected no response schema")
+				}
+				if len(config.Tools) != 1 || config.Tools[0].URLContext == nil {
+					t.Errorf("expected URL context tool, got %+v", config.Tools)
+				}
+				if got := strings.Contains(systemPrompt, `"content_warning"`); got != tc.structured {
+					t.Errorf("expected schema in prompt=%v, got %q", tc.structured, systemPrompt)
+				}
+			}
+			if !strings.Contains(prompt, tc.wantInPrompt) {
+				t.Errorf("expected prompt to contain %q, got %q", tc.wantInPrompt, prompt)
+			}
+		})
+	}
+}
+
+func TestGeminiTokenUsage(t *testing.T) {
+	usage := geminiTokenUsage("gemini-test", &genai.GenerateContentResponseUsageMetadata{
+		PromptTokenCount:        100,
+		ToolUsePromptTokenCount: 20,
+		CandidatesTokenCount:    30,
+		ThoughtsTokenCount:      5,
+	})
+
+	if usage.Provider != "gemini" || usage.Model != "gemini-test" {
+		t.Errorf("unexpected provider/model: %+v", usage)
+	}
+	if usage.InputTokens != 120 {
+		t.Errorf("expected 120 input tokens, got %d", usage.InputTokens)
+	}
+	if usage.OutputTokens != 35 {
+		t.Errorf("expected 35 output tokens, got %d", usage.OutputTokens)
+	}
+
+	if empty := geminiTokenUsage("gemini-test", nil); !empty.IsZero() {
+		t.Errorf("expected zero usage without metadata, got %+v", empty)
+	}
+}
@@ -3,6 +3,7 @@ package llm
 import (
 	"context"
 
+	"misskeyRSSbot/internal/domain/entity"
 	"misskeyRSSbot/internal/domain/repository"
 )
 
@@ -12,8 +13,8 @@ func newNoopSummarizer() repository.SummarizerRepository {
 	return &noopSummarizer{}
 }
 
-func (s *noopSummarizer) Summarize(ctx context.Context, url, title string) (string, error) {
-	return "", nil
+func (s *noopSummarizer) Summarize(ctx context.Context, req repository.SummarizeRequest) (*entity.Summary, error) {
+	return nil, nil
 }
 
 func (s *noopSummarizer) IsEnabled() bool {
@@ -3,20 +3,25 @@ package llm
 import (
 	"context"
 	"testing"
+
+	"misskeyRSSbot/internal/domain/repository"
 )
 
 func TestNoopSummarizer_Summarize(t *testing.T) {
 	summarizer := newNoopSummarizer()
 
 	ctx := context.Background()
-	summary, err := summarizer.Summarize(ctx, "http://example.com/article", "test title")
+	summary, err := summarizer.Summarize(ctx, repository.SummarizeRequest{
+		URL:   "http://example.com/article",
+		Title: "test title",
+	})
 
 	if err != nil {
 		t.Errorf("expected no error, got %v", err)
 	}
 
-	if summary != "" {
-		t.Errorf("expected empty summary, got %s", summary)
+	if summary != nil {
+		t.Errorf("expected nil summary, got %+v", summary)
 	}
 }
 
@@ -0,0 +1,290 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+	"google.golang.org/genai"
+
+	"misskeyRSSbot/internal/domain/entity"
+	"misskeyRSSbot/internal/domain/repository"
+)
+
+type ResilienceConfig struct {
+	MaxConcurrency    int
+	RequestsPerMinute int
+	MaxRetries        int
+	RetryBaseDelay    time.Duration
+	RetryMaxDelay     time.Duration
+	Metrics           repository.MetricsRepository
+}
+
+// Resilience は LLM 呼び出しの同時実行数・レート制限・再試行をまとめて管理します
+// 要約と埋め込みで同じ Resilience を使うと、両者の呼び出しが同じ上限を共有します
+type Resilience struct {
+	slots          chan struct{}
+	limiter        *requestLimiter
+	maxRetries     int
+	retryBaseDelay time.Duration
+	retryMaxDelay  time.Duration
+	metrics        repository.MetricsRepository
+	jitter         func(max time.Duration) time.Duration
+	sleep          func(ctx context.Context, d time.Duration) error
+}
+
+const (
+	defaultRetryBaseDelay = 1 * time.Second
+	defaultRetryMaxDelay  = 30 * time.Second
+)
+
+func NewResilience(cfg ResilienceConfig) *Resilience {
+	retryBaseDelay := cfg.RetryBaseDelay
+	if retryBaseDelay <= 0 {
+		retryBaseDelay = defaultRetryBaseDelay
+	}
+	retryMaxDelay := cfg.RetryMaxDelay
+	if retryMaxDelay < retryBaseDelay {
+		retryMaxDelay = max(defaultRetryMaxDelay, retryBaseDelay)
+	}
+
+	r := &Resilience{
+		maxRetries:     max(cfg.MaxRetries, 0),
+		retryBaseDelay: retryBaseDelay,
+		retryMaxDelay:  retryMaxDelay,
+		metrics:        cfg.Metrics,
+		jitter:         fullJitter,
+		sleep:          sleepContext,
+	}
+	if cfg.MaxConcurrency > 0 {
+		r.slots = make(chan struct{}, cfg.MaxConcurrency)
+	}
+	if cfg.RequestsPerMinute > 0 {
+		r.limiter = newRequestLimiter(time.Minute / time.Duration(cfg.RequestsPerMinute))
+	}
+	return r
+}
+
+// Summarizer は inner の呼び出しに同時実行数・レート制限・再試行を適用した要約リポジトリを返します
+func (r *Resilience) Summarizer(inner repository.SummarizerRepository) repository.SummarizerRepository {
+	return &resilientSummarizer{Resilience: r, inner: inner}
+}
+
+// Embedder は inner の呼び出しに同時実行数・レート制限・再試行を適用した埋め込みリポジトリを返します
+func (r *Resilience) Embedder(inner repository.EmbeddingRepository, provider string) repository.EmbeddingRepository {
+	return &resilientEmbedder{Resilience: r, inner: inner, provider: provider}
+}
+
+type resilientSummarizer struct {
+	*Resilience
+	inner repository.SummarizerRepository
+}
+
+func (s *resilientSummarizer) Summarize(ctx context.Context, req repository.SummarizeRequest) (*entity.Summary, error) {
+	var summary *entity.Summary
+	err := s.call(ctx, fmt.Sprintf("summarization [%s]", req.Title), req.Provider, func(ctx context.Context) error {
+		var err error
+		summary, err = s.inner.Summarize(ctx, req)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return summary, nil
+}
+
+func (s *resilientSummarizer) IsEnabled() bool {
+	return s.inner.IsEnabled()
+}
+
+type resilientEmbedder struct {
+	*Resilience
+	inner    repository.EmbeddingRepository
+	provider string
+}
+
+func (e *resilientEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	var vectors [][]float32
+	err := e.call(ctx, "embedding", e.provider, func(ctx context.Context) error {
+		var err error
+		vectors, err = e.inner.Embed(ctx, texts)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return vectors, nil
+}
+
+// call は同時実行枠とレート制限を確保して fn を呼び出し、一時的なエラーであれば指数バックオフで再試行します
+func (r *Resilience) call(ctx context.Context, operation, provider string, fn func(ctx context.Context) error) error {
+	if err := r.acquire(ctx); err != nil {
+		return fmt.Errorf("failed to acquire LLM slot: %w", err)
+	}
+	defer r.release()
+
+	var lastErr error
+	for attempt := 0; attempt <= r.maxRetries; attempt++ {
+		if attempt > 0 {
+			delay := r.backoff(attempt)
+			log.Printf("Retrying %s in %v (attempt %d/%d): %v", operation, delay, attempt, r.maxRetries, lastErr)
+			r.addCounter("llm_retries_total", provider)
+			if err := r.sleep(ctx, delay); err != nil {
+				return fmt.Errorf("retry aborted: %w", errors.Join(err, lastErr))
+			}
+		}
+
+		if r.limiter != nil {
+			if err := r.limiter.Wait(ctx); err != nil {
+				return fmt.Errorf("LLM rate limiter error: %w", err)
+			}
+		}
+
+		err := fn(ctx)
+		if err == nil {
+			return nil
+		}
+		lastErr = err
+
+		if ctx.Err() != nil || !isTransientError(err) {
+			return err
+		}
+	}
+
+	r.addCounter("llm_retry_exhausted_total", provider)
+	return fmt.Errorf("%s failed after %d retries: %w", operation, r.maxRetries, lastErr)
+}
+
+func (r *Resilience) acquire(ctx context.Context) error {
+	if r.slots == nil {
+		return nil
+	}
+	select {
+	case r.slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (r *Resilience) release() {
+	if r.slots == nil {
+		return
+	}
+	<-r.slots
+}
+
+func (r *Resilience) backoff(attempt int) time.Duration {
+	delay := r.retryBaseDelay << (attempt - 1)
+	if delay <= 0 || delay > r.retryMaxDelay {
+		delay = r.retryMaxDelay
+	}
+	return r.jitter(delay)
+}
+
+func (r *Resilience) addCounter(name, provider string) {
+	if r.metrics == nil {
+		return
+	}
+	r.metrics.AddCounter(name, 1, map[string]string{"provider": provider})
+}
+
+func isTransientError(err error) bool {
+	var geminiErr genai.APIError
+	if errors.As(err, &geminiErr) {
+		return isTransientStatus(geminiErr.Code)
+	}
+
+	var throttling *types.ThrottlingException
+	var unavailable *types.ServiceUnavailableException
+	var notReady *types.ModelNotReadyException
+	var internal *types.InternalServerException
+	var modelTimeout *types.ModelTimeoutException
+	switch {
+	case errors.As(err, &throttling),
+		errors.As(err, &unavailable),
+		errors.As(err, &notReady),
+		errors.As(err, &internal),
+		errors.As(err, &modelTimeout):
+		return true
+	}
+
+	var statusErr interface{ HTTPStatusCode() int }
+	if errors.As(err, &statusErr) {
+		return isTransientStatus(statusErr.HTTPStatusCode())
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+func isTransientStatus(code int) bool {
+	switch code {
+	case http.StatusTooManyRequests,
+		http.StatusInternalServerError,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
+func fullJitter(max time.Duration) time.Duration {
+	if max <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int64N(int64(max) + 1))
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+type requestLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+func newRequestLimiter(interval time.Duration) *requestLimiter {
+	return &requestLimiter{interval: interval}
+}
+
+func (l *requestLimiter) Wait(ctx context.Context) error {
+	l.mu.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	wait := l.next.Sub(now)
+	l.next = l.next.Add(l.interval)
+	l.mu.Unlock()
+
+	if wait <= 0 {
+		return nil
+	}
+	if err := sleepContext(ctx, wait); err != nil {
+		l.mu.Lock()
+		l.next = l.next.Add(-l.interval)
+		l.mu.Unlock()
+		return err
+	}
+	return nil
+}
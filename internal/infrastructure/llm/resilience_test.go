@@ -0,0 +1,285 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+	"google.golang.org/genai"
+
+	"misskeyRSSbot/internal/domain/entity"
+	"misskeyRSSbot/internal/domain/repository"
+)
+
+type flakySummarizer struct {
+	mu       sync.Mutex
+	errs     []error
+	calls    int
+	inFlight atomic.Int32
+	peak     atomic.Int32
+	delay    time.Duration
+}
+
+func (s *flakySummarizer) Summarize(ctx context.Context, req repository.SummarizeRequest) (*entity.Summary, error) {
+	current := s.inFlight.Add(1)
+	defer s.inFlight.Add(-1)
+	for {
+		peak := s.peak.Load()
+		if current <= peak || s.peak.CompareAndSwap(peak, current) {
+			break
+		}
+	}
+	if s.delay > 0 {
+		time.Sleep(s.delay)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls++
+	if len(s.errs) > 0 {
+		err := s.errs[0]
+		s.errs = s.errs[1:]
+		if err != nil {
+			return nil, err
+		}
+	}
+	return entity.NewSummary("ok"), nil
+}
+
+func (s *flakySummarizer) IsEnabled() bool {
+	return true
+}
+
+func newTestResilience(cfg ResilienceConfig) *Resilience {
+	r := NewResilience(cfg)
+	r.sleep = func(ctx context.Context, d time.Duration) error { return ctx.Err() }
+	return r
+}
+
+func newTestResilientSummarizer(inner repository.SummarizerRepository, cfg ResilienceConfig) *resilientSummarizer {
+	r := newTestResilience(cfg)
+	return r.Summarizer(inner).(*resilientSummarizer)
+}
+
+func TestResilientSummarizer_RetriesTransientErrors(t *testing.T) {
+	testCases := []struct {
+		name      string
+		errs      []error
+		wantCalls int
+		wantError bool
+	}{
+		{
+			name:      "success on first attempt",
+			wantCalls: 1,
+		},
+		{
+			name:      "gemini 429 then success",
+			errs:      []error{fmt.Errorf("failed to generate content: %w", genai.APIError{Code: http.StatusTooManyRequests})},
+			wantCalls: 2,
+		},
+		{
+			name: "bedrock throttling twice then success",
+			errs: []error{
+				fmt.Errorf("failed to invoke bedrock model: %w", &types.ThrottlingException{}),
+				&types.ServiceUnavailableException{},
+			},
+			wantCalls: 3,
+		},
+		{
+			name:      "non-transient error is not retried",
+			errs:      []error{genai.APIError{Code: http.StatusBadRequest}},
+			wantCalls: 1,
+			wantError: true,
+		},
+		{
+			name: "retries exhausted",
+			errs: []error{
+				genai.APIError{Code: http.StatusServiceUnavailable},
+				genai.APIError{Code: http.StatusServiceUnavailable},
+				genai.APIError{Code: http.StatusServiceUnavailable},
+				genai.APIError{Code: http.StatusServiceUnavailable},
+			},
+			wantCalls: 4,
+			wantError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			inner := &flakySummarizer{errs: tc.errs}
+			s := newTestResilientSummarizer(inner, ResilienceConfig{MaxRetries: 3})
+
+			_, err := s.Summarize(context.Background(), repository.SummarizeRequest{})
+			if tc.wantError && err == nil {
+				t.Fatal("expected error, got nil")
+			}
+			if !tc.wantError && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if inner.calls != tc.wantCalls {
+				t.Errorf("expected %d calls, got %d", tc.wantCalls, inner.calls)
+			}
+		})
+	}
+}
+
+func TestResilientSummarizer_StopsRetryingWhenContextCanceled(t *testing.T) {
+	inner := &flakySummarizer{errs: []error{genai.APIError{Code: http.StatusTooManyRequests}}}
+	s := newTestResilientSummarizer(inner, ResilienceConfig{MaxRetries: 3})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := s.Summarize(ctx, repository.SummarizeRequest{}); err == nil {
+		t.Fatal("expected error, got nil")
+	}
+	if inner.calls > 1 {
+		t.Errorf("expected at most 1 call after cancellation, got %d", inner.calls)
+	}
+}
+
+func TestResilientSummarizer_LimitsConcurrency(t *testing.T) {
+	inner := &flakySummarizer{delay: 20 * time.Millisecond}
+	s := newTestResilientSummarizer(inner, ResilienceConfig{MaxConcurrency: 2})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := s.Summarize(context.Background(), repository.SummarizeRequest{}); err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if peak := inner.peak.Load(); peak > 2 {
+		t.Errorf("expected at most 2 concurrent calls, got %d", peak)
+	}
+	if inner.calls != 6 {
+		t.Errorf("expected 6 calls, got %d", inner.calls)
+	}
+}
+
+type flakyEmbedder struct {
+	summarizer *flakySummarizer
+}
+
+func (e *flakyEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	if _, err := e.summarizer.Summarize(ctx, repository.SummarizeRequest{}); err != nil {
+		return nil, err
+	}
+	return [][]float32{{1}}, nil
+}
+
+func TestResilientEmbedder_RetriesTransientErrors(t *testing.T) {
+	inner := &flakySummarizer{errs: []error{genai.APIError{Code: http.StatusTooManyRequests}}}
+	e := newTestResilience(ResilienceConfig{MaxRetries: 3}).Embedder(&flakyEmbedder{summarizer: inner}, "gemini")
+
+	vectors, err := e.Embed(context.Background(), []string{"text"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(vectors) != 1 {
+		t.Errorf("expected 1 vector, got %d", len(vectors))
+	}
+	if inner.calls != 2 {
+		t.Errorf("expected 2 calls, got %d", inner.calls)
+	}
+}
+
+func TestResilience_SharesConcurrencyBetweenSummarizerAndEmbedder(t *testing.T) {
+	inner := &flakySummarizer{delay: 20 * time.Millisecond}
+	r := newTestResilience(ResilienceConfig{MaxConcurrency: 2})
+	s := r.Summarizer(inner)
+	e := r.Embedder(&flakyEmbedder{summarizer: inner}, "gemini")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			if _, err := s.Summarize(context.Background(), repository.SummarizeRequest{}); err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			if _, err := e.Embed(context.Background(), []string{"text"}); err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if peak := inner.peak.Load(); peak > 2 {
+		t.Errorf("expected at most 2 concurrent calls, got %d", peak)
+	}
+}
+
+func TestResilience_Backoff(t *testing.T) {
+	s := NewResilience(ResilienceConfig{
+		RetryBaseDelay: 100 * time.Millisecond,
+		RetryMaxDelay:  300 * time.Millisecond,
+	})
+	s.jitter = func(max time.Duration) time.Duration { return max }
+
+	expected := []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 300 * time.Millisecond, 300 * time.Millisecond}
+	for i, want := range expected {
+		if got := s.backoff(i + 1); got != want {
+			t.Errorf("attempt %d: expected %v, got %v", i+1, want, got)
+		}
+	}
+}
+
+func TestIsTransientError(t *testing.T) {
+	testCases := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"gemini 429", genai.APIError{Code: 429}, true},
+		{"gemini 503", fmt.Errorf("wrapped: %w", genai.APIError{Code: 503}), true},
+		{"gemini 400", genai.APIError{Code: 400}, false},
+		{"bedrock throttling", &types.ThrottlingException{}, true},
+		{"bedrock model not ready", &types.ModelNotReadyException{}, true},
+		{"bedrock validation", &types.ValidationException{}, false},
+		{"plain error", errors.New("boom"), false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := isTransientError(tc.err); got != tc.want {
+				t.Errorf("expected %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestRequestLimiter_Wait(t *testing.T) {
+	limiter := newRequestLimiter(30 * time.Millisecond)
+	ctx := context.Background()
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if err := limiter.Wait(ctx); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 60*time.Millisecond {
+		t.Errorf("expected at least 60ms for 3 requests, got %v", elapsed)
+	}
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	if err := limiter.Wait(canceled); err == nil {
+		t.Error("expected error when context is canceled")
+	}
+}
@@ -0,0 +1,61 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+
+	"misskeyRSSbot/internal/domain/entity"
+	"misskeyRSSbot/internal/domain/repository"
+)
+
+type routerSummarizer struct {
+	defaultSummarizer repository.SummarizerRepository
+	providers         map[string]repository.SummarizerRepository
+}
+
+func NewRouterSummarizer(
+	defaultSummarizer repository.SummarizerRepository,
+	providers map[string]repository.SummarizerRepository,
+) repository.SummarizerRepository {
+	if len(providers) == 0 {
+		return defaultSummarizer
+	}
+	return &routerSummarizer{
+		defaultSummarizer: defaultSummarizer,
+		providers:         providers,
+	}
+}
+
+func (s *routerSummarizer) Summarize(ctx context.Context, req repository.SummarizeRequest) (*entity.Summary, error) {
+	summarizer, err := s.resolve(req.Provider)
+	if err != nil {
+		return nil, err
+	}
+	if !summarizer.IsEnabled() {
+		return nil, nil
+	}
+	return summarizer.Summarize(ctx, req)
+}
+
+func (s *routerSummarizer) IsEnabled() bool {
+	if s.defaultSummarizer.IsEnabled() {
+		return true
+	}
+	for _, summarizer := range s.providers {
+		if summarizer.IsEnabled() {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *routerSummarizer) resolve(provider string) (repository.SummarizerRepository, error) {
+	if provider == "" {
+		return s.defaultSummarizer, nil
+	}
+	summarizer, ok := s.providers[provider]
+	if !ok {
+		return nil, fmt.Errorf("LLM provider not configured: %s", provider)
+	}
+	return summarizer, nil
+}
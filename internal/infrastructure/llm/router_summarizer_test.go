@@ -0,0 +1,114 @@
+package llm
+
+import (
+	"context"
+	"testing"
+
+	"misskeyRSSbot/internal/domain/entity"
+	"misskeyRSSbot/internal/domain/repository"
+)
+
+type stubSummarizer struct {
+	summary string
+	enabled bool
+	called  int
+}
+
+func (s *stubSummarizer) Summarize(ctx context.Context, req repository.SummarizeRequest) (*entity.Summary, error) {
+	s.called++
+	return entity.NewSummary(s.summary), nil
+}
+
+func (s *stubSummarizer) IsEnabled() bool {
+	return s.enabled
+}
+
+func TestNewRouterSummarizer_NoProvidersReturnsDefault(t *testing.T) {
+	defaultSummarizer := &stubSummarizer{enabled: true}
+
+	got := NewRouterSummarizer(defaultSummarizer, nil)
+	if got != repository.SummarizerRepository(defaultSummarizer) {
+		t.Errorf("expected default summarizer to be returned as is, got %T", got)
+	}
+}
+
+func TestRouterSummarizer_Summarize(t *testing.T) {
+	testCases := []struct {
+		name      string
+		provider  string
+		want      string
+		wantError bool
+	}{
+		{name: "default provider", provider: "", want: "default"},
+		{name: "routed provider", provider: "bedrock", want: "bedrock"},
+		{name: "unknown provider", provider: "unknown", wantError: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			router := NewRouterSummarizer(
+				&stubSummarizer{summary: "default", enabled: true},
+				map[string]repository.SummarizerRepository{
+					"bedrock": &stubSummarizer{summary: "bedrock", enabled: true},
+				},
+			)
+
+			got, err := router.Summarize(context.Background(), repository.SummarizeRequest{Provider: tc.provider})
+			if tc.wantError {
+				if err == nil {
+					t.Fatal("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got.Text != tc.want {
+				t.Errorf("expected %q, got %q", tc.want, got.Text)
+			}
+		})
+	}
+}
+
+func TestRouterSummarizer_IsEnabled(t *testing.T) {
+	testCases := []struct {
+		name           string
+		defaultEnabled bool
+		routedEnabled  bool
+		want           bool
+	}{
+		{"default enabled", true, false, true},
+		{"only routed provider enabled", false, true, true},
+		{"all disabled", false, false, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			router := NewRouterSummarizer(
+				&stubSummarizer{enabled: tc.defaultEnabled},
+				map[string]repository.SummarizerRepository{
+					"gemini": &stubSummarizer{enabled: tc.routedEnabled},
+				},
+			)
+			if got := router.IsEnabled(); got != tc.want {
+				t.Errorf("expected %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestRouterSummarizer_SkipsDisabledProvider(t *testing.T) {
+	routed := &stubSummarizer{summary: "noop", enabled: false}
+	router := NewRouterSummarizer(
+		&stubSummarizer{summary: "default", enabled: true},
+		map[string]repository.SummarizerRepository{"noop": routed},
+	)
+
+	got, err := router.Summarize(context.Background(), repository.SummarizeRequest{Provider: "noop"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != nil || routed.called != 0 {
+		t.Errorf("expected disabled provider to be skipped, got %+v (%d calls)", got, routed.called)
+	}
+}
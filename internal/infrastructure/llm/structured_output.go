@@ -0,0 +1,109 @@
+package llm
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"misskeyRSSbot/internal/domain/entity"
+)
+
+// structuredOutputSchema は構造化出力の JSON スキーマです
+// ネイティブの構造化出力（Gemini の responseJsonSchema、Bedrock Converse のツール入力）にそのまま渡し、
+// 使えない場合はプロンプトで指示します
+var structuredOutputSchema = map[string]any{
+	"type":     "object",
+	"required": []string{"summary", "hashtags", "language", "sentiment", "headline", "content_warning"},
+	"properties": map[string]any{
+		"summary":         map[string]any{"type": "string", "description": "記事の要約"},
+		"hashtags":        map[string]any{"type": "array", "maxItems": maxStructuredHashtags, "items": map[string]any{"type": "string"}, "description": "記事に関連するハッシュタグ（#は付けない）"},
+		"language":        map[string]any{"type": "string", "description": "元記事の言語（BCP 47 言語タグ）"},
+		"sentiment":       map[string]any{"type": "string", "enum": []string{"positive", "neutral", "negative"}},
+		"headline":        map[string]any{"type": "string", "description": "記事タイトルを出力言語に翻訳した1行の見出し"},
+		"content_warning": map[string]any{"type": "string", "description": "閲覧注意が必要な内容の場合はその注意書き、不要な場合は空文字列"},
+	},
+}
+
+// structuredOutputInstruction はネイティブの構造化出力を使えない場合にシステムインストラクションへ追加する指示です
+// Gemini の URLContext ツールは responseSchema と併用できないため、記事の本文を取得できない場合に使います
+var structuredOutputInstruction = func() string {
+	schema, err := json.MarshalIndent(structuredOutputSchema, "", "  ")
+	if err != nil {
+		panic(err)
+	}
+	return "\n- 出力は次のJSONスキーマに従うJSONオブジェクトのみとし、前後に説明文やコードブロックを付けない\n" + string(schema)
+}()
+
+const maxStructuredHashtags = 5
+
+type structuredSummary struct {
+	Summary        *string  `json:"summary"`
+	Hashtags       []string `json:"hashtags"`
+	Language       string   `json:"language"`
+	Sentiment      string   `json:"sentiment"`
+	Headline       string   `json:"headline"`
+	ContentWarning string   `json:"content_warning"`
+}
+
+func appendStructuredOutputInstruction(systemInstruction string, structured bool) string {
+	if !structured {
+		return systemInstruction
+	}
+	return systemInstruction + structuredOutputInstruction
+}
+
+func parseSummary(text string, structured bool) (*entity.Summary, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil, fmt.Errorf("empty summary in LLM response")
+	}
+	if !structured {
+		return entity.NewSummary(text), nil
+	}
+	return parseStructuredSummary(text)
+}
+
+// parseStructuredSummary は構造化出力の JSON を解釈します
+// スキーマにないフィールドは無視し、必須の summary 以外は欠けていても既定値で補います
+func parseStructuredSummary(text string) (*entity.Summary, error) {
+	var out structuredSummary
+	if err := json.Unmarshal([]byte(trimCodeFence(text)), &out); err != nil {
+		return nil, fmt.Errorf("failed to decode structured summary: %w", err)
+	}
+
+	if out.Summary == nil || strings.TrimSpace(*out.Summary) == "" {
+		return nil, fmt.Errorf("structured summary is missing \"summary\"")
+	}
+
+	sentiment := entity.Sentiment(strings.ToLower(strings.TrimSpace(out.Sentiment)))
+	switch sentiment {
+	case entity.SentimentPositive, entity.SentimentNeutral, entity.SentimentNegative:
+	default:
+		sentiment = entity.SentimentNeutral
+	}
+
+	hashtags := out.Hashtags
+	if len(hashtags) > maxStructuredHashtags {
+		hashtags = hashtags[:maxStructuredHashtags]
+	}
+
+	return &entity.Summary{
+		Text:           strings.TrimSpace(*out.Summary),
+		Hashtags:       hashtags,
+		Language:       strings.TrimSpace(out.Language),
+		Sentiment:      sentiment,
+		Headline:       strings.TrimSpace(out.Headline),
+		ContentWarning: strings.TrimSpace(out.ContentWarning),
+	}, nil
+}
+
+func trimCodeFence(text string) string {
+	if !strings.HasPrefix(text, "```") {
+		return text
+	}
+	text = strings.TrimPrefix(text, "```")
+	if newline := strings.IndexByte(text, '\n'); newline >= 0 {
+		text = text[newline+1:]
+	}
+	return strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(text), "```"))
+}
@@ -0,0 +1,129 @@
+package llm
+
+import (
+	"strings"
+	"testing"
+
+	"misskeyRSSbot/internal/domain/entity"
+)
+
+func TestParseSummary(t *testing.T) {
+	testCases := []struct {
+		name       string
+		text       string
+		structured bool
+		want       *entity.Summary
+		wantError  bool
+	}{
+		{
+			name: "free-form text",
+			text: "  要約です。 ",
+			want: &entity.Summary{Text: "要約です。"},
+		},
+		{
+			name:      "empty text",
+			text:      "  ",
+			wantError: true,
+		},
+		{
+			name:       "structured json",
+			text:       `{"summary":"要約","hashtags":["Go"],"language":"en","sentiment":"Positive","headline":"見出し","content_warning":""}`,
+			structured: true,
+			want: &entity.Summary{
+				Text:      "要約",
+				Hashtags:  []string{"Go"},
+				Language:  "en",
+				Sentiment: entity.SentimentPositive,
+				Headline:  "見出し",
+			},
+		},
+		{
+			name:       "structured json in code fence",
+			text:       "```json\n{\"summary\":\"要約\",\"hashtags\":[],\"language\":\"ja\",\"sentiment\":\"\",\"headline\":\"\",\"content_warning\":\"暴力的な描写\"}\n```",
+			structured: true,
+			want: &entity.Summary{
+				Text:           "要約",
+				Hashtags:       []string{},
+				Language:       "ja",
+				Sentiment:      entity.SentimentNeutral,
+				ContentWarning: "暴力的な描写",
+			},
+		},
+		{
+			name:       "structured json without summary",
+			text:       `{"hashtags":["Go"]}`,
+			structured: true,
+			wantError:  true,
+		},
+		{
+			name:       "structured json with unknown field",
+			text:       `{"summary":"要約","rating":5,"sentiment":"negative"}`,
+			structured: true,
+			want:       &entity.Summary{Text: "要約", Sentiment: entity.SentimentNegative},
+		},
+		{
+			name:       "structured json with invalid sentiment",
+			text:       `{"summary":"要約","sentiment":"angry"}`,
+			structured: true,
+			want:       &entity.Summary{Text: "要約", Sentiment: entity.SentimentNeutral},
+		},
+		{
+			name:       "structured json with wrong field type",
+			text:       `{"summary":"要約","hashtags":"Go"}`,
+			structured: true,
+			wantError:  true,
+		},
+		{
+			name:       "structured mode with plain text",
+			text:       "要約です。",
+			structured: true,
+			wantError:  true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := parseSummary(tc.text, tc.structured)
+			if tc.wantError {
+				if err == nil {
+					t.Fatalf("expected error, got %+v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if got.Text != tc.want.Text || got.Language != tc.want.Language || got.Sentiment != tc.want.Sentiment ||
+				got.Headline != tc.want.Headline || got.ContentWarning != tc.want.ContentWarning {
+				t.Errorf("expected %+v, got %+v", tc.want, got)
+			}
+			if strings.Join(got.Hashtags, ",") != strings.Join(tc.want.Hashtags, ",") {
+				t.Errorf("expected hashtags %v, got %v", tc.want.Hashtags, got.Hashtags)
+			}
+		})
+	}
+}
+
+func TestParseSummary_LimitsHashtags(t *testing.T) {
+	text := `{"summary":"要約","hashtags":["a","b","c","d","e","f","g"]}`
+
+	got, err := parseSummary(text, true)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(got.Hashtags) != maxStructuredHashtags {
+		t.Errorf("expected %d hashtags, got %d", maxStructuredHashtags, len(got.Hashtags))
+	}
+}
+
+func TestAppendStructuredOutputInstruction(t *testing.T) {
+	if got := appendStructuredOutputInstruction("base", false); got != "base" {
+		t.Errorf("expected instruction unchanged, got %q", got)
+	}
+
+	got := appendStructuredOutputInstruction("base", true)
+	if !strings.HasPrefix(got, "base\n") || !strings.Contains(got, `"content_warning"`) {
+		t.Errorf("expected schema to be appended, got %q", got)
+	}
+}
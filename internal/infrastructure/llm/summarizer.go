@@ -3,9 +3,12 @@ package llm
 import (
 	"context"
 	"fmt"
+	"strings"
 	"time"
 
+	"misskeyRSSbot/internal/domain/entity"
 	"misskeyRSSbot/internal/domain/repository"
+	htmlfetcher "misskeyRSSbot/internal/infrastructure/html"
 )
 
 type Config struct {
@@ -15,14 +18,22 @@ type Config struct {
 	Region            string
 	MaxTokens         int
 	SystemInstruction string
+	Language          string
+	StructuredOutput  bool
 	Timeout           time.Duration
+	UsageRecorder     repository.UsageRecorder
+	// ArticleFetcher は記事の本文を取得する Fetcher です。記事を自分で取得するプロバイダー（bedrock）では必須です
+	// gemini では構造化出力でネイティブのスキーマ指定を使うために本文を取得します
+	ArticleFetcher *htmlfetcher.Fetcher
 }
 
 const DefaultSystemInstruction = `あなたは記事要約の専門家です。
 以下の記事を簡潔に要約してください。
 - 3〜5文程度で要点をまとめる
-- 重要な情報を優先する
-- 日本語で出力する`
+- 重要な情報を優先する`
+
+// DefaultLanguage は言語を指定せずに DefaultSystemInstruction を使う場合の出力の言語です
+const DefaultLanguage = "日本語"
 
 func NewSummarizerRepository(ctx context.Context, cfg Config) (repository.SummarizerRepository, error) {
 	switch cfg.Provider {
@@ -36,3 +47,36 @@ func NewSummarizerRepository(ctx context.Context, cfg Config) (repository.Summar
 		return nil, fmt.Errorf("unknown LLM provider: %s", cfg.Provider)
 	}
 }
+
+func buildSystemInstruction(base, defaultLanguage string, req repository.SummarizeRequest) string {
+	instruction := base
+	if req.SystemInstruction != "" {
+		instruction = req.SystemInstruction
+	}
+
+	language := req.Language
+	if language == "" {
+		language = defaultLanguage
+	}
+	// 独自の指示は出力する言語を含められるため、言語を指定した場合だけ言語の行を加える
+	if language == "" && instruction == DefaultSystemInstruction {
+		language = DefaultLanguage
+	}
+
+	var builder strings.Builder
+	builder.WriteString(strings.TrimRight(instruction, "\n"))
+	if language != "" {
+		fmt.Fprintf(&builder, "\n- %sで出力する", language)
+	}
+	if req.MaxLength > 0 {
+		fmt.Fprintf(&builder, "\n- %d文字以内にまとめる", req.MaxLength)
+	}
+	return builder.String()
+}
+
+func recordUsage(ctx context.Context, recorder repository.UsageRecorder, usage entity.TokenUsage) {
+	if recorder == nil {
+		return
+	}
+	recorder.RecordUsage(ctx, usage)
+}
@@ -5,6 +5,9 @@ import (
 	"strings"
 	"testing"
 	"time"
+
+	"misskeyRSSbot/internal/domain/repository"
+	htmlfetcher "misskeyRSSbot/internal/infrastructure/html"
 )
 
 func TestNewSummarizerRepository_Gemini(t *testing.T) {
@@ -61,10 +64,11 @@ func TestNewSummarizerRepository_Noop(t *testing.T) {
 
 func TestNewSummarizerRepository_Bedrock(t *testing.T) {
 	cfg := Config{
-		Provider: "bedrock",
-		APIKey:   "test-token",
-		Model:    "anthropic.claude-3-haiku-20240307-v1:0",
-		Region:   "us-east-1",
+		Provider:       "bedrock",
+		APIKey:         "test-token",
+		Model:          "anthropic.claude-3-haiku-20240307-v1:0",
+		Region:         "us-east-1",
+		ArticleFetcher: htmlfetcher.NewFetcher(nil, nil),
 	}
 
 	repo, err := NewSummarizerRepository(context.TODO(), cfg)
@@ -122,6 +126,20 @@ func TestNewSummarizerRepository_BedrockNoModel(t *testing.T) {
 	}
 }
 
+func TestNewSummarizerRepository_BedrockNoArticleFetcher(t *testing.T) {
+	cfg := Config{
+		Provider: "bedrock",
+		APIKey:   "test-token",
+		Model:    "anthropic.claude-3-haiku-20240307-v1:0",
+		Region:   "us-east-1",
+	}
+
+	_, err := NewSummarizerRepository(context.TODO(), cfg)
+	if err == nil {
+		t.Error("expected error when no article fetcher is given, got nil")
+	}
+}
+
 func TestNewSummarizerRepository_BedrockNoToken(t *testing.T) {
 	cfg := Config{
 		Provider: "bedrock",
@@ -216,3 +234,58 @@ func TestConfig_CustomValues(t *testing.T) {
 		t.Errorf("expected custom instruction '%s', got '%s'", customInstruction, gs.systemPrompt)
 	}
 }
+
+func TestBuildSystemInstruction(t *testing.T) {
+	testCases := []struct {
+		name            string
+		base            string
+		defaultLanguage string
+		req             repository.SummarizeRequest
+		want            string
+	}{
+		{
+			name: "built-in instruction defaults to Japanese",
+			base: DefaultSystemInstruction,
+			want: DefaultSystemInstruction + "\n- 日本語で出力する",
+		},
+		{
+			name:            "built-in instruction with configured language",
+			base:            DefaultSystemInstruction,
+			defaultLanguage: "English",
+			want:            DefaultSystemInstruction + "\n- Englishで出力する",
+		},
+		{
+			name: "custom instruction passes through unchanged",
+			base: "Summarize the article in English.",
+			want: "Summarize the article in English.",
+		},
+		{
+			name: "feed instruction passes through unchanged",
+			base: DefaultSystemInstruction,
+			req:  repository.SummarizeRequest{SystemInstruction: "feed\n"},
+			want: "feed",
+		},
+		{
+			name:            "custom instruction with configured language",
+			base:            "base",
+			defaultLanguage: "English",
+			want:            "base\n- Englishで出力する",
+		},
+		{
+			name:            "override language and max length",
+			base:            "base",
+			defaultLanguage: "English",
+			req:             repository.SummarizeRequest{Language: "Français", MaxLength: 120},
+			want:            "base\n- Françaisで出力する\n- 120文字以内にまとめる",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := buildSystemInstruction(tc.base, tc.defaultLanguage, tc.req)
+			if got != tc.want {
+				t.Errorf("expected %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
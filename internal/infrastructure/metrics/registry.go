@@ -0,0 +1,126 @@
+package metrics
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"misskeyRSSbot/internal/domain/repository"
+)
+
+type metricKind string
+
+const (
+	kindCounter metricKind = "counter"
+	kindGauge   metricKind = "gauge"
+)
+
+type series struct {
+	labels map[string]string
+	value  float64
+}
+
+type metric struct {
+	kind   metricKind
+	series map[string]*series
+}
+
+type registry struct {
+	mu      sync.Mutex
+	metrics map[string]*metric
+}
+
+func NewRegistry() repository.MetricsRepository {
+	return &registry{
+		metrics: make(map[string]*metric),
+	}
+}
+
+func (r *registry) AddCounter(name string, value float64, labels map[string]string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	s := r.series(name, kindCounter, labels)
+	s.value += value
+}
+
+func (r *registry) SetGauge(name string, value float64, labels map[string]string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	s := r.series(name, kindGauge, labels)
+	s.value = value
+}
+
+func (r *registry) series(name string, kind metricKind, labels map[string]string) *series {
+	m, ok := r.metrics[name]
+	if !ok {
+		m = &metric{kind: kind, series: make(map[string]*series)}
+		r.metrics[name] = m
+	}
+
+	key := formatLabels(labels)
+	s, ok := m.series[key]
+	if !ok {
+		copied := make(map[string]string, len(labels))
+		for k, v := range labels {
+			copied[k] = v
+		}
+		s = &series{labels: copied}
+		m.series[key] = s
+	}
+	return s
+}
+
+func (r *registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	_, _ = w.Write([]byte(r.render()))
+}
+
+func (r *registry) render() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	names := make([]string, 0, len(r.metrics))
+	for name := range r.metrics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var builder strings.Builder
+	for _, name := range names {
+		m := r.metrics[name]
+		fmt.Fprintf(&builder, "# TYPE %s %s\n", name, m.kind)
+
+		keys := make([]string, 0, len(m.series))
+		for key := range m.series {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			fmt.Fprintf(&builder, "%s%s %g\n", name, key, m.series[key].value)
+		}
+	}
+	return builder.String()
+}
+
+func formatLabels(labels map[string]string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		pairs = append(pairs, fmt.Sprintf("%s=%q", k, labels[k]))
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
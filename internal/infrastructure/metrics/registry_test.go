@@ -0,0 +1,59 @@
+package metrics
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestRegistry_ServeHTTP(t *testing.T) {
+	reg := NewRegistry()
+	reg.AddCounter("llm_tokens_total", 10, map[string]string{"model": "m1", "direction": "input"})
+	reg.AddCounter("llm_tokens_total", 5, map[string]string{"direction": "input", "model": "m1"})
+	reg.AddCounter("llm_tokens_total", 3, map[string]string{"model": "m1", "direction": "output"})
+	reg.SetGauge("llm_daily_cost_usd", 1.5, nil)
+	reg.SetGauge("llm_daily_cost_usd", 0.25, nil)
+
+	handler, ok := reg.(http.Handler)
+	if !ok {
+		t.Fatal("expected registry to implement http.Handler")
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	body, _ := io.ReadAll(rec.Body)
+	want := strings.Join([]string{
+		"# TYPE llm_daily_cost_usd gauge",
+		"llm_daily_cost_usd 0.25",
+		"# TYPE llm_tokens_total counter",
+		`llm_tokens_total{direction="input",model="m1"} 15`,
+		`llm_tokens_total{direction="output",model="m1"} 3`,
+		"",
+	}, "\n")
+	if string(body) != want {
+		t.Errorf("expected:\n%s\ngot:\n%s", want, body)
+	}
+}
+
+func TestRegistry_ConcurrentAccess(t *testing.T) {
+	reg := NewRegistry()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			reg.AddCounter("requests_total", 1, map[string]string{"status": "ok"})
+		}()
+	}
+	wg.Wait()
+
+	body := reg.(*registry).render()
+	if !strings.Contains(body, `requests_total{status="ok"} 50`) {
+		t.Errorf("expected counter to be 50, got:\n%s", body)
+	}
+}
@@ -213,3 +213,78 @@ func TestNoteRepository_Post_LocalOnlyTrue(t *testing.T) {
 		t.Errorf("expected localOnly to be true, got '%v'", receivedPayload["localOnly"])
 	}
 }
+
+func TestNoteRepository_Post_ContentWarning(t *testing.T) {
+	testCases := []struct {
+		name   string
+		cw     string
+		wantCW interface{}
+	}{
+		{name: "with content warning", cw: "ネタバレ", wantCW: "ネタバレ"},
+		{name: "without content warning", cw: "", wantCW: nil},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var receivedPayload map[string]interface{}
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				json.Unmarshal(body, &receivedPayload)
+				w.WriteHeader(http.StatusOK)
+			}))
+			defer server.Close()
+
+			repo := &noteRepository{
+				host:        server.URL,
+				authToken:   "test-token",
+				client:      &http.Client{Timeout: 30 * time.Second},
+				rateLimiter: newRateLimiter(3, 10*time.Second),
+			}
+
+			note := entity.NewNote("Test note", entity.VisibilityHome)
+			note.CW = tc.cw
+
+			if err := repo.Post(context.Background(), note); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if receivedPayload["cw"] != tc.wantCW {
+				t.Errorf("expected cw %v, got %v", tc.wantCW, receivedPayload["cw"])
+			}
+		})
+	}
+}
+
+type headerTransport struct {
+	userAgent string
+}
+
+func (t *headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set("User-Agent", t.userAgent)
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestNewNoteRepository_UsesConfiguredClient(t *testing.T) {
+	var receivedUserAgent string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		receivedUserAgent = r.Header.Get("User-Agent")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"createdNote": {"id": "note123"}}`))
+	}))
+	defer server.Close()
+
+	repo := NewNoteRepository(Config{
+		Host:      server.URL,
+		AuthToken: "test-token",
+		Client:    &http.Client{Transport: &headerTransport{userAgent: "testbot/1.0"}},
+	})
+
+	if err := repo.Post(context.Background(), entity.NewNote("hello", entity.VisibilityHome)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if receivedUserAgent != "testbot/1.0" {
+		t.Errorf("expected User-Agent 'testbot/1.0', got '%s'", receivedUserAgent)
+	}
+}
@@ -80,6 +80,10 @@ type noteRepository struct {
 	client      *http.Client
 	rateLimiter *rateLimiter
 	localOnly   bool
+
+	mu sync.Mutex
+	// userID: 投稿履歴を取得するアカウントのID（初めて参照したときに取得します）
+	userID string
 }
 
 type Config struct {
@@ -88,8 +92,12 @@ type Config struct {
 	MaxPermits     int
 	RefillInterval time.Duration
 	LocalOnly      bool
+	// Client: APIの呼び出しに使うクライアント（nilの場合はタイムアウト30秒の既定のクライアント）
+	Client *http.Client
 }
 
+// NewNoteRepository は Misskey にノートを投稿するリポジトリを作成します
+// 返す値は repository.PostHistoryRepository も実装します
 func NewNoteRepository(cfg Config) repository.NoteRepository {
 	maxPermits := cfg.MaxPermits
 	if maxPermits == 0 {
@@ -100,10 +108,15 @@ func NewNoteRepository(cfg Config) repository.NoteRepository {
 		refillInterval = 10 * time.Second
 	}
 
+	client := cfg.Client
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+
 	return &noteRepository{
 		host:        cfg.Host,
 		authToken:   cfg.AuthToken,
-		client:      &http.Client{Timeout: 30 * time.Second},
+		client:      client,
 		rateLimiter: newRateLimiter(maxPermits, refillInterval),
 		localOnly:   cfg.LocalOnly,
 	}
@@ -120,19 +133,16 @@ func (r *noteRepository) Post(ctx context.Context, note *entity.Note) error {
 		"visibility": string(note.Visibility),
 		"localOnly":  r.localOnly,
 	}
+	if note.CW != "" {
+		notePayload["cw"] = note.CW
+	}
 
 	payload, err := json.Marshal(notePayload)
 	if err != nil {
 		return fmt.Errorf("failed to serialize note: %w", err)
 	}
 
-	url := r.host
-	if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
-		url = "https://" + url
-	}
-	url = url + "/api/notes/create"
-
-	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(payload))
+	req, err := http.NewRequestWithContext(ctx, "POST", r.apiURL("notes/create"), bytes.NewBuffer(payload))
 	if err != nil {
 		return fmt.Errorf("failed to create HTTP request: %w", err)
 	}
@@ -151,3 +161,12 @@ func (r *noteRepository) Post(ctx context.Context, note *entity.Note) error {
 
 	return nil
 }
+
+// apiURL は Misskey の API のエンドポイントのURLを返します
+func (r *noteRepository) apiURL(endpoint string) string {
+	url := r.host
+	if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+		url = "https://" + url
+	}
+	return url + "/api/" + endpoint
+}
@@ -0,0 +1,118 @@
+package misskey
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// historyPageSize は投稿履歴を1回に取得するノートの数です（Misskey の上限）
+	historyPageSize = 100
+	// historyMaxPages は投稿履歴をさかのぼるページ数の上限です
+	historyMaxPages = 10
+)
+
+type historyNote struct {
+	ID   string  `json:"id"`
+	Text *string `json:"text"`
+}
+
+// HasPosted はボットのアカウントが since 以降に text と同じ本文のノートを投稿したかどうかを返します
+// Misskey は本文の前後の空白を取り除いて保存するため、空白を除いて比較します
+func (r *noteRepository) HasPosted(ctx context.Context, text string, since time.Time) (bool, error) {
+	userID, err := r.accountID(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	want := strings.TrimSpace(text)
+	params := map[string]interface{}{
+		"userId":      userID,
+		"limit":       historyPageSize,
+		"sinceDate":   since.UnixMilli(),
+		"withReplies": false,
+	}
+	for page := 0; page < historyMaxPages; page++ {
+		var notes []historyNote
+		if err := r.callAPI(ctx, "users/notes", params, &notes); err != nil {
+			return false, fmt.Errorf("failed to get post history: %w", err)
+		}
+
+		lastID := ""
+		for _, note := range notes {
+			if note.Text != nil && strings.TrimSpace(*note.Text) == want {
+				return true, nil
+			}
+			if note.ID > lastID {
+				lastID = note.ID
+			}
+		}
+		if len(notes) < historyPageSize {
+			return false, nil
+		}
+
+		// ノートのIDは作成日時の順に並ぶため、取得した最も新しいノートより後を次に取得する
+		delete(params, "sinceDate")
+		params["sinceId"] = lastID
+	}
+	return false, nil
+}
+
+// accountID はトークンのアカウントのIDを返します
+func (r *noteRepository) accountID(ctx context.Context) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.userID != "" {
+		return r.userID, nil
+	}
+
+	var account struct {
+		ID string `json:"id"`
+	}
+	if err := r.callAPI(ctx, "i", map[string]interface{}{}, &account); err != nil {
+		return "", fmt.Errorf("failed to get account: %w", err)
+	}
+	if account.ID == "" {
+		return "", fmt.Errorf("failed to get account: empty user id")
+	}
+	r.userID = account.ID
+	return r.userID, nil
+}
+
+// callAPI は endpoint に params を送信し、応答を out に読み込みます
+func (r *noteRepository) callAPI(ctx context.Context, endpoint string, params map[string]interface{}, out interface{}) error {
+	body := map[string]interface{}{"i": r.authToken}
+	for key, value := range params {
+		body[key] = value
+	}
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("failed to serialize request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", r.apiURL(endpoint), bytes.NewBuffer(payload))
+	if err != nil {
+		return fmt.Errorf("failed to create HTTP request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request to Misskey API: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("misskey API returned non-OK status: %d", resp.StatusCode)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package misskey
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// newHistoryServer はボットのアカウント（bot-user）が notes を古い順に投稿した Misskey のように応答します
+func newHistoryServer(t *testing.T, notes []string, requests *[]map[string]interface{}) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&payload)
+		if payload["i"] != "test-token" {
+			t.Errorf("expected auth token, got %v", payload["i"])
+		}
+
+		switch r.URL.Path {
+		case "/api/i":
+			w.Write([]byte(`{"id": "bot-user"}`))
+		case "/api/users/notes":
+			*requests = append(*requests, payload)
+			if payload["userId"] != "bot-user" {
+				t.Errorf("expected userId bot-user, got %v", payload["userId"])
+			}
+			start := 0
+			if sinceID, ok := payload["sinceId"].(string); ok {
+				fmt.Sscanf(sinceID, "note%03d", &start)
+				start++
+			}
+			limit := int(payload["limit"].(float64))
+			var page []map[string]interface{}
+			for i := start; i < len(notes) && len(page) < limit; i++ {
+				page = append(page, map[string]interface{}{"id": fmt.Sprintf("note%03d", i), "text": notes[i]})
+			}
+			json.NewEncoder(w).Encode(page)
+		default:
+			t.Errorf("unexpected path: %s", r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func TestNoteRepository_HasPosted(t *testing.T) {
+	notes := make([]string, historyPageSize+5)
+	for i := range notes {
+		notes[i] = fmt.Sprintf("📰 Article %d\nhttps://example.tld/%d", i, i)
+	}
+
+	tests := []struct {
+		name      string
+		text      string
+		want      bool
+		wantPages int
+	}{
+		{"first page", notes[3], true, 1},
+		{"second page", notes[historyPageSize+2], true, 2},
+		{"surrounding whitespace", "  " + notes[4] + "\n", true, 1},
+		{"not posted", "📰 Missing\nhttps://example.tld/missing", false, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests []map[string]interface{}
+			server := newHistoryServer(t, notes, &requests)
+			defer server.Close()
+
+			repo := NewNoteRepository(Config{Host: server.URL, AuthToken: "test-token"}).(*noteRepository)
+			since := time.Now().Add(-time.Hour)
+
+			posted, err := repo.HasPosted(context.Background(), tt.text, since)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if posted != tt.want {
+				t.Errorf("expected posted=%v, got %v", tt.want, posted)
+			}
+			if len(requests) != tt.wantPages {
+				t.Fatalf("expected %d history requests, got %d", tt.wantPages, len(requests))
+			}
+			if requests[0]["sinceDate"] != float64(since.UnixMilli()) {
+				t.Errorf("expected sinceDate %d, got %v", since.UnixMilli(), requests[0]["sinceDate"])
+			}
+		})
+	}
+}
+
+func TestNoteRepository_HasPosted_AccountError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	repo := NewNoteRepository(Config{Host: server.URL, AuthToken: "invalid-token"}).(*noteRepository)
+	if _, err := repo.HasPosted(context.Background(), "text", time.Now()); err == nil {
+		t.Error("expected error for unauthorized account, got nil")
+	}
+}
@@ -3,20 +3,36 @@ package rss
 import (
 	"context"
 	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"misskeyRSSbot/internal/domain/entity"
 	"misskeyRSSbot/internal/domain/repository"
+	"misskeyRSSbot/internal/infrastructure/httpclient"
 
 	"github.com/mmcdole/gofeed"
+	ext "github.com/mmcdole/gofeed/extensions"
+	"github.com/mmcdole/gofeed/json"
 )
 
 type feedRepository struct {
 	parser *gofeed.Parser
 }
 
-func NewFeedRepository() repository.FeedRepository {
+// NewFeedRepository はフィードを取得するリポジトリを作成します
+// client が nil の場合は、プライベートアドレスなどへの接続を拒否する既定の取得用クライアントを使用します
+func NewFeedRepository(client *http.Client) repository.FeedRepository {
+	if client == nil {
+		client = httpclient.NewDefaultFetchClient()
+	}
+	parser := gofeed.NewParser()
+	parser.JSONTranslator = &jsonTranslator{}
+	parser.Client = client
 	return &feedRepository{
-		parser: gofeed.NewParser(),
+		parser: parser,
 	}
 }
 
@@ -25,14 +41,22 @@ func (r *feedRepository) Fetch(ctx context.Context, url string) ([]*entity.FeedE
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse RSS feed: %w", err)
 	}
+	return feedEntries(feed), nil
+}
+
+// Parse は WebSub で配信されたフィードなど、取得済みの本文からエントリーを読み取ります
+func (r *feedRepository) Parse(body io.Reader) ([]*entity.FeedEntry, error) {
+	feed, err := r.parser.Parse(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse RSS feed: %w", err)
+	}
+	return feedEntries(feed), nil
+}
 
+func feedEntries(feed *gofeed.Feed) []*entity.FeedEntry {
 	entries := make([]*entity.FeedEntry, 0, len(feed.Items))
 
 	for _, item := range feed.Items {
-		if item.PublishedParsed == nil {
-			continue
-		}
-
 		guid := item.GUID
 		if guid == "" {
 			guid = item.Link
@@ -42,12 +66,150 @@ func (r *feedRepository) Fetch(ctx context.Context, url string) ([]*entity.FeedE
 			item.Title,
 			item.Link,
 			item.Description,
-			*item.PublishedParsed,
+			time.Time{},
 			guid,
 		)
+		entry.Content = item.Content
+		if item.UpdatedParsed != nil {
+			entry.Updated = *item.UpdatedParsed
+		}
+		// 公開日時がなければ更新日時を使い、どちらもなければ初回検出日時をサービス側で設定する
+		switch {
+		case item.PublishedParsed != nil:
+			entry.Published = *item.PublishedParsed
+		case item.UpdatedParsed != nil:
+			entry.Published = *item.UpdatedParsed
+			entry.DateSource = entity.DateSourceUpdated
+		default:
+			entry.DateSource = entity.DateSourceFirstSeen
+		}
+		entry.Authors = itemAuthors(item)
+		entry.Categories = itemCategories(item)
+		entry.Enclosures = itemEnclosures(item)
+		entry.ImageURL = itemImageURL(item, entry.Enclosures)
 
 		entries = append(entries, entry)
 	}
 
-	return entries, nil
+	return entries
+}
+
+// jsonTranslator は JSON Feed の attachments のサイズを enclosure の Length に設定します
+// gofeed の既定の変換では Length に duration_in_seconds が入るため上書きします
+type jsonTranslator struct {
+	gofeed.DefaultJSONTranslator
+}
+
+func (t *jsonTranslator) Translate(feed interface{}) (*gofeed.Feed, error) {
+	result, err := t.DefaultJSONTranslator.Translate(feed)
+	if err != nil {
+		return nil, err
+	}
+
+	jsonFeed, ok := feed.(*json.Feed)
+	if !ok || len(jsonFeed.Items) != len(result.Items) {
+		return result, nil
+	}
+	for i, item := range jsonFeed.Items {
+		if item.Attachments == nil {
+			continue
+		}
+		for j, attachment := range *item.Attachments {
+			if j >= len(result.Items[i].Enclosures) {
+				break
+			}
+			length := ""
+			if attachment.SizeInBytes > 0 {
+				length = strconv.FormatInt(attachment.SizeInBytes, 10)
+			}
+			result.Items[i].Enclosures[j].Length = length
+		}
+	}
+	return result, nil
+}
+
+func itemAuthors(item *gofeed.Item) []string {
+	var authors []string
+	for _, author := range item.Authors {
+		if author == nil {
+			continue
+		}
+		name := strings.TrimSpace(author.Name)
+		if name == "" {
+			name = strings.TrimSpace(author.Email)
+		}
+		if name != "" {
+			authors = append(authors, name)
+		}
+	}
+	return authors
+}
+
+// itemCategories は重複と空白を除いたカテゴリーを返します
+// RSS の category・itunes:keywords・dc:subject、Atom の category term、JSON Feed の tags が対象です
+func itemCategories(item *gofeed.Item) []string {
+	var categories []string
+	seen := make(map[string]bool)
+	for _, category := range item.Categories {
+		category = strings.TrimSpace(category)
+		if category == "" || seen[category] {
+			continue
+		}
+		seen[category] = true
+		categories = append(categories, category)
+	}
+	return categories
+}
+
+func itemEnclosures(item *gofeed.Item) []entity.Enclosure {
+	var enclosures []entity.Enclosure
+	for _, enclosure := range item.Enclosures {
+		if enclosure == nil || enclosure.URL == "" {
+			continue
+		}
+		length, _ := strconv.ParseInt(enclosure.Length, 10, 64)
+		enclosures = append(enclosures, entity.Enclosure{
+			URL:    enclosure.URL,
+			Type:   enclosure.Type,
+			Length: length,
+		})
+	}
+	return enclosures
+}
+
+// itemImageURL はエントリーの代表画像を返します
+// image（itunes:image・JSON Feed の image）、media:thumbnail、画像の media:content、画像の enclosure の順に探します
+func itemImageURL(item *gofeed.Item, enclosures []entity.Enclosure) string {
+	if item.Image != nil && item.Image.URL != "" {
+		return item.Image.URL
+	}
+
+	media := item.Extensions["media"]
+	for _, thumbnail := range mediaElements(media, "thumbnail") {
+		if url := thumbnail.Attrs["url"]; url != "" {
+			return url
+		}
+	}
+	for _, content := range mediaElements(media, "content") {
+		url := content.Attrs["url"]
+		if url != "" && (content.Attrs["medium"] == "image" || strings.HasPrefix(content.Attrs["type"], "image/")) {
+			return url
+		}
+	}
+
+	for _, enclosure := range enclosures {
+		if strings.HasPrefix(enclosure.Type, "image/") {
+			return enclosure.URL
+		}
+	}
+	return ""
+}
+
+// mediaElements は Media RSS の要素を返します（media:group の中の要素も含む）
+func mediaElements(media map[string][]ext.Extension, name string) []ext.Extension {
+	elements := append([]ext.Extension(nil), media[name]...)
+	for _, group := range media["group"] {
+		elements = append(elements, group.Children[name]...)
+	}
+	return elements
 }
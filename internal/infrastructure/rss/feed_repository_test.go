@@ -2,10 +2,17 @@ package rss
 
 import (
 	"context"
+	"errors"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
+
+	"misskeyRSSbot/internal/domain/entity"
+	"misskeyRSSbot/internal/domain/repository"
+	"misskeyRSSbot/internal/infrastructure/httpclient"
 )
 
 func TestFeedRepository_Fetch_Success(t *testing.T) {
@@ -37,7 +44,7 @@ func TestFeedRepository_Fetch_Success(t *testing.T) {
 	}))
 	defer server.Close()
 
-	repo := NewFeedRepository()
+	repo := NewFeedRepository(server.Client())
 	ctx := context.Background()
 
 	entries, err := repo.Fetch(ctx, server.URL)
@@ -81,7 +88,7 @@ func TestFeedRepository_Fetch_EmptyGUID(t *testing.T) {
 	}))
 	defer server.Close()
 
-	repo := NewFeedRepository()
+	repo := NewFeedRepository(server.Client())
 	ctx := context.Background()
 
 	entries, err := repo.Fetch(ctx, server.URL)
@@ -98,33 +105,25 @@ func TestFeedRepository_Fetch_EmptyGUID(t *testing.T) {
 	}
 }
 
-func TestFeedRepository_Fetch_SkipNoPubDate(t *testing.T) {
-	rssXML := `<?xml version="1.0" encoding="UTF-8"?>
-<rss version="2.0">
-	<channel>
-		<title>Test Feed</title>
-		<item>
-			<title>Article With Date</title>
-			<link>https://example.com/article1</link>
-			<guid>guid-1</guid>
-			<pubDate>Mon, 02 Jan 2006 15:04:05 MST</pubDate>
-		</item>
-		<item>
-			<title>Article Without Date</title>
-			<link>https://example.com/article2</link>
-			<guid>guid-2</guid>
-		</item>
-	</channel>
-</rss>`
+func TestFeedRepository_Fetch_DateFallback(t *testing.T) {
+	jsonFeed := `{
+	"version": "https://jsonfeed.org/version/1.1",
+	"title": "Test Feed",
+	"items": [
+		{"id": "guid-1", "url": "https://example.com/article1", "title": "Article With Date", "date_published": "2006-01-02T15:04:05Z", "date_modified": "2006-01-04T15:04:05Z"},
+		{"id": "guid-2", "url": "https://example.com/article2", "title": "Article With Updated", "date_modified": "2006-01-03T15:04:05Z"},
+		{"id": "guid-3", "url": "https://example.com/article3", "title": "Article Without Date"}
+	]
+}`
 
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "application/rss+xml")
+		w.Header().Set("Content-Type", "application/feed+json")
 		w.WriteHeader(http.StatusOK)
-		w.Write([]byte(rssXML))
+		w.Write([]byte(jsonFeed))
 	}))
 	defer server.Close()
 
-	repo := NewFeedRepository()
+	repo := NewFeedRepository(server.Client())
 	ctx := context.Background()
 
 	entries, err := repo.Fetch(ctx, server.URL)
@@ -132,17 +131,166 @@ func TestFeedRepository_Fetch_SkipNoPubDate(t *testing.T) {
 		t.Fatalf("unexpected error: %v", err)
 	}
 
-	if len(entries) != 1 {
-		t.Fatalf("expected 1 entry (items without pubDate should be skipped), got %d", len(entries))
+	if len(entries) != 3 {
+		t.Fatalf("expected 3 entries (items without pubDate should be kept), got %d", len(entries))
 	}
 
-	if entries[0].Title != "Article With Date" {
-		t.Errorf("expected 'Article With Date', got '%s'", entries[0].Title)
+	if entries[0].DateSource != entity.DateSourcePublished || !entries[0].Published.Equal(time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC)) {
+		t.Errorf("expected published date, got %v (%v)", entries[0].Published, entries[0].DateSource)
+	}
+	if entries[1].DateSource != entity.DateSourceUpdated || !entries[1].Published.Equal(time.Date(2006, 1, 3, 15, 4, 5, 0, time.UTC)) {
+		t.Errorf("expected updated date as fallback, got %v (%v)", entries[1].Published, entries[1].DateSource)
+	}
+	if entries[2].DateSource != entity.DateSourceFirstSeen || !entries[2].Published.IsZero() {
+		t.Errorf("expected undated entry, got %v (%v)", entries[2].Published, entries[2].DateSource)
+	}
+}
+
+func TestFeedRepository_Fetch_Metadata(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		want        entity.FeedEntry
+	}{
+		{
+			name:        "rss",
+			contentType: "application/rss+xml",
+			body: `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0" xmlns:content="http://purl.org/rss/1.0/modules/content/" xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:media="http://search.yahoo.com/mrss/">
+	<channel>
+		<title>Test Feed</title>
+		<item>
+			<title>Article 1</title>
+			<link>https://example.com/article1</link>
+			<description>Description 1</description>
+			<content:encoded><![CDATA[<p>Full content</p>]]></content:encoded>
+			<dc:creator>Alice</dc:creator>
+			<category>Go</category>
+			<category>Misskey</category>
+			<category>Go</category>
+			<guid>guid-1</guid>
+			<pubDate>Mon, 02 Jan 2006 15:04:05 GMT</pubDate>
+			<enclosure url="https://example.com/episode.mp3" length="1024" type="audio/mpeg"/>
+			<media:thumbnail url="https://example.com/thumb.jpg"/>
+		</item>
+	</channel>
+</rss>`,
+			want: entity.FeedEntry{
+				Content:    "<p>Full content</p>",
+				Authors:    []string{"Alice"},
+				Categories: []string{"Go", "Misskey"},
+				Enclosures: []entity.Enclosure{{URL: "https://example.com/episode.mp3", Type: "audio/mpeg", Length: 1024}},
+				ImageURL:   "https://example.com/thumb.jpg",
+			},
+		},
+		{
+			name:        "atom",
+			contentType: "application/atom+xml",
+			body: `<?xml version="1.0" encoding="UTF-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom">
+	<title>Test Feed</title>
+	<entry>
+		<title>Article 1</title>
+		<link rel="alternate" href="https://example.com/article1"/>
+		<link rel="enclosure" href="https://example.com/photo.png" type="image/png" length="2048"/>
+		<id>guid-1</id>
+		<published>2006-01-02T15:04:05Z</published>
+		<updated>2006-01-03T15:04:05Z</updated>
+		<author><name>Alice</name></author>
+		<author><name>Bob</name></author>
+		<category term="Go"/>
+		<summary>Description 1</summary>
+		<content type="html">&lt;p&gt;Full content&lt;/p&gt;</content>
+	</entry>
+</feed>`,
+			want: entity.FeedEntry{
+				Content:    "<p>Full content</p>",
+				Updated:    time.Date(2006, 1, 3, 15, 4, 5, 0, time.UTC),
+				Authors:    []string{"Alice", "Bob"},
+				Categories: []string{"Go"},
+				Enclosures: []entity.Enclosure{{URL: "https://example.com/photo.png", Type: "image/png", Length: 2048}},
+				ImageURL:   "https://example.com/photo.png",
+			},
+		},
+		{
+			name:        "json feed",
+			contentType: "application/feed+json",
+			body: `{
+	"version": "https://jsonfeed.org/version/1.1",
+	"title": "Test Feed",
+	"items": [
+		{
+			"id": "guid-1",
+			"url": "https://example.com/article1",
+			"title": "Article 1",
+			"summary": "Description 1",
+			"content_html": "<p>Full content</p>",
+			"image": "https://example.com/image.jpg",
+			"date_published": "2006-01-02T15:04:05Z",
+			"date_modified": "2006-01-03T15:04:05Z",
+			"authors": [{"name": "Alice"}],
+			"tags": ["Go", "Misskey"],
+			"attachments": [{"url": "https://example.com/episode.mp3", "mime_type": "audio/mpeg", "size_in_bytes": 1024}]
+		}
+	]
+}`,
+			want: entity.FeedEntry{
+				Content:    "<p>Full content</p>",
+				Updated:    time.Date(2006, 1, 3, 15, 4, 5, 0, time.UTC),
+				Authors:    []string{"Alice"},
+				Categories: []string{"Go", "Misskey"},
+				Enclosures: []entity.Enclosure{{URL: "https://example.com/episode.mp3", Type: "audio/mpeg", Length: 1024}},
+				ImageURL:   "https://example.com/image.jpg",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", tt.contentType)
+				w.WriteHeader(http.StatusOK)
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			entries, err := NewFeedRepository(server.Client()).Fetch(context.Background(), server.URL)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(entries) != 1 {
+				t.Fatalf("expected 1 entry, got %d", len(entries))
+			}
+
+			got := entries[0]
+			if got.Title != "Article 1" || got.Link != "https://example.com/article1" || got.GUID != "guid-1" || got.Description != "Description 1" {
+				t.Errorf("unexpected basic fields: %+v", got)
+			}
+			if got.Content != tt.want.Content {
+				t.Errorf("expected content %q, got %q", tt.want.Content, got.Content)
+			}
+			if !got.Updated.Equal(tt.want.Updated) {
+				t.Errorf("expected updated %v, got %v", tt.want.Updated, got.Updated)
+			}
+			if !reflect.DeepEqual(got.Authors, tt.want.Authors) {
+				t.Errorf("expected authors %v, got %v", tt.want.Authors, got.Authors)
+			}
+			if !reflect.DeepEqual(got.Categories, tt.want.Categories) {
+				t.Errorf("expected categories %v, got %v", tt.want.Categories, got.Categories)
+			}
+			if !reflect.DeepEqual(got.Enclosures, tt.want.Enclosures) {
+				t.Errorf("expected enclosures %+v, got %+v", tt.want.Enclosures, got.Enclosures)
+			}
+			if got.ImageURL != tt.want.ImageURL {
+				t.Errorf("expected image %q, got %q", tt.want.ImageURL, got.ImageURL)
+			}
+		})
 	}
 }
 
 func TestFeedRepository_Fetch_InvalidURL(t *testing.T) {
-	repo := NewFeedRepository()
+	repo := NewFeedRepository(nil)
 	ctx := context.Background()
 
 	_, err := repo.Fetch(ctx, "http://invalid-url-that-does-not-exist-12345.com/feed")
@@ -159,7 +307,7 @@ func TestFeedRepository_Fetch_InvalidXML(t *testing.T) {
 	}))
 	defer server.Close()
 
-	repo := NewFeedRepository()
+	repo := NewFeedRepository(server.Client())
 	ctx := context.Background()
 
 	_, err := repo.Fetch(ctx, server.URL)
@@ -177,7 +325,7 @@ func TestFeedRepository_Fetch_ContextCancellation(t *testing.T) {
 	}))
 	defer server.Close()
 
-	repo := NewFeedRepository()
+	repo := NewFeedRepository(server.Client())
 	ctx, cancel := context.WithCancel(context.Background())
 	cancel()
 
@@ -187,3 +335,48 @@ func TestFeedRepository_Fetch_ContextCancellation(t *testing.T) {
 	}
 }
 
+
+func TestFeedRepository_Parse(t *testing.T) {
+	atomXML := `<?xml version="1.0" encoding="UTF-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom">
+	<title>Pushed Feed</title>
+	<link rel="hub" href="https://hub.example.com/"/>
+	<entry>
+		<title>Pushed Article</title>
+		<link href="https://example.com/pushed"/>
+		<id>urn:pushed-1</id>
+		<updated>2024-05-01T09:00:00Z</updated>
+	</entry>
+</feed>`
+
+	parser := NewFeedRepository(nil).(repository.FeedParser)
+	entries, err := parser.Parse(strings.NewReader(atomXML))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("expected 1 entry, got %d", len(entries))
+	}
+	if entries[0].GUID != "urn:pushed-1" || entries[0].Link != "https://example.com/pushed" {
+		t.Errorf("unexpected entry: %+v", entries[0])
+	}
+	if !entries[0].Published.Equal(time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)) {
+		t.Errorf("expected updated date as published, got %v", entries[0].Published)
+	}
+
+	if _, err := parser.Parse(strings.NewReader("not a feed")); err == nil {
+		t.Error("expected error for invalid feed, got nil")
+	}
+}
+
+func TestNewFeedRepository_DefaultClientBlocksPrivateAddresses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`<rss version="2.0"><channel><title>Internal</title></channel></rss>`))
+	}))
+	defer server.Close()
+
+	_, err := NewFeedRepository(nil).Fetch(context.Background(), server.URL)
+	if !errors.Is(err, httpclient.ErrBlockedAddress) {
+		t.Fatalf("expected blocked address error, got %v", err)
+	}
+}
@@ -0,0 +1,1008 @@
+// Package cachetest は repository.CacheRepository の実装が満たすべき振る舞いを確認する共通のテストです
+//
+// 各実装のテストから Run に空のキャッシュを作成する関数を渡して実行します
+//
+//	func TestMemoryCache_Conformance(t *testing.T) {
+//		cachetest.Run(t, func(t *testing.T) repository.CacheRepository {
+//			return NewMemoryCacheRepository(0)
+//		})
+//	}
+//
+// 日時は永続化するキャッシュに合わせて秒単位で比較します
+package cachetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"misskeyRSSbot/internal/domain/entity"
+	"misskeyRSSbot/internal/domain/repository"
+)
+
+// Factory は空のキャッシュを作成します
+// キャッシュを閉じる必要があれば t.Cleanup で登録してください
+type Factory func(t *testing.T) repository.CacheRepository
+
+// Cleaner は古い記録を削除できるキャッシュです
+type Cleaner interface {
+	CleanupOldGUIDs(ctx context.Context, olderThan time.Duration) (int64, error)
+}
+
+const (
+	feedA = "https://a.example.tld/rss"
+	feedB = "https://b.example.tld/rss"
+)
+
+// Run は newCache が作成するキャッシュで共通のテストを実行します
+// UsageRepository や Cleaner、CacheStateRepository、PostStateRepository、LeaseRepository、WebSubSubscriptionRepository を実装していないキャッシュでは、該当するテストをスキップします
+func Run(t *testing.T, newCache Factory) {
+	t.Helper()
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, cache repository.CacheRepository)
+	}{
+		{"FirstRun", testFirstRun},
+		{"LatestPublishedMonotonic", testLatestPublishedMonotonic},
+		{"LatestPublishedPerFeed", testLatestPublishedPerFeed},
+		{"ProcessedMarking", testProcessedMarking},
+		{"ProcessedScopedByFeed", testProcessedScopedByFeed},
+		{"FirstSeen", testFirstSeen},
+		{"RecentStories", testRecentStories},
+		{"TokenUsage", testTokenUsage},
+		{"Concurrency", testConcurrency},
+		{"Cleanup", testCleanup},
+		{"ExportImport", testExportImport},
+		{"PostStates", testPostStates},
+		{"Leases", testLeases},
+		{"WebSubSubscriptions", testWebSubSubscriptions},
+		{"ContextCancellation", testContextCancellation},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.fn(t, newCache(t))
+		})
+	}
+}
+
+func now() time.Time {
+	return time.Now().Truncate(time.Second)
+}
+
+// testFirstRun は何も記録していないキャッシュが初回実行として扱える状態を返すことを確認します
+func testFirstRun(t *testing.T, cache repository.CacheRepository) {
+	ctx := context.Background()
+
+	latest, err := cache.GetLatestPublishedTime(ctx, feedA)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !latest.IsZero() {
+		t.Errorf("expected zero latest published time, got %v", latest)
+	}
+
+	processed, err := cache.IsProcessed(ctx, feedA, "guid-1", "https://example.tld/1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if processed {
+		t.Error("expected nothing to be processed")
+	}
+
+	posted, err := cache.HasRecentStory(ctx, feedA, []string{"url:https://example.tld/1"}, time.Time{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if posted {
+		t.Error("expected no recent stories")
+	}
+
+	if usageRepo, ok := cache.(repository.UsageRepository); ok {
+		usages, err := usageRepo.GetDailyUsage(ctx, now())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(usages) != 0 {
+			t.Errorf("expected no usage, got %+v", usages)
+		}
+	}
+}
+
+// testLatestPublishedMonotonic は最新の公開日時が古い日時で巻き戻らないことを確認します
+func testLatestPublishedMonotonic(t *testing.T, cache repository.CacheRepository) {
+	ctx := context.Background()
+	base := now()
+
+	steps := []struct {
+		save time.Time
+		want time.Time
+	}{
+		{save: base, want: base},
+		{save: base.Add(time.Hour), want: base.Add(time.Hour)},
+		{save: base.Add(-time.Hour), want: base.Add(time.Hour)},
+		{save: base.Add(time.Hour), want: base.Add(time.Hour)},
+		{save: base.Add(2 * time.Hour), want: base.Add(2 * time.Hour)},
+	}
+	for i, step := range steps {
+		if err := cache.SaveLatestPublishedTime(ctx, feedA, step.save); err != nil {
+			t.Fatalf("step %d: unexpected error: %v", i, err)
+		}
+		latest, err := cache.GetLatestPublishedTime(ctx, feedA)
+		if err != nil {
+			t.Fatalf("step %d: unexpected error: %v", i, err)
+		}
+		if !latest.Equal(step.want) {
+			t.Errorf("step %d: expected %v, got %v", i, step.want, latest)
+		}
+	}
+}
+
+func testLatestPublishedPerFeed(t *testing.T, cache repository.CacheRepository) {
+	ctx := context.Background()
+	timeA := now()
+	timeB := timeA.Add(-24 * time.Hour)
+
+	if err := cache.SaveLatestPublishedTime(ctx, feedA, timeA); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := cache.SaveLatestPublishedTime(ctx, feedB, timeB); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for feedURL, want := range map[string]time.Time{feedA: timeA, feedB: timeB} {
+		latest, err := cache.GetLatestPublishedTime(ctx, feedURL)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !latest.Equal(want) {
+			t.Errorf("%s: expected %v, got %v", feedURL, want, latest)
+		}
+	}
+}
+
+func testProcessedMarking(t *testing.T, cache repository.CacheRepository) {
+	ctx := context.Background()
+
+	if err := cache.MarkAsProcessed(ctx, feedA, "http://example.tld/1?utm_source=rss", "https://example.tld/1"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := cache.MarkAsProcessed(ctx, feedA, "http://example.tld/1?utm_source=rss", "https://example.tld/1"); err != nil {
+		t.Fatalf("duplicate mark should not fail: %v", err)
+	}
+	if err := cache.MarkAsProcessed(ctx, feedA, "it's a 'quoted' guid", ""); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name         string
+		guid         string
+		canonicalKey string
+		want         bool
+	}{
+		{name: "same guid", guid: "http://example.tld/1?utm_source=rss", want: true},
+		{name: "same canonical key", guid: "https://example.tld/1/", canonicalKey: "https://example.tld/1", want: true},
+		{name: "empty canonical key", guid: "https://example.tld/2", want: false},
+		{name: "different canonical key", guid: "https://example.tld/2", canonicalKey: "https://example.tld/2", want: false},
+		{name: "quoted guid", guid: "it's a 'quoted' guid", want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := cache.IsProcessed(ctx, feedA, tt.guid, tt.canonicalKey)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func testProcessedScopedByFeed(t *testing.T, cache repository.CacheRepository) {
+	ctx := context.Background()
+
+	if err := cache.MarkAsProcessed(ctx, feedA, "1", "https://example.tld/1"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// 同じGUIDでも別のフィードなら記録できる
+	if err := cache.MarkAsProcessed(ctx, feedB, "2", "https://example.tld/2"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name         string
+		feedURL      string
+		guid         string
+		canonicalKey string
+		want         bool
+	}{
+		{name: "same feed", feedURL: feedA, guid: "1", want: true},
+		{name: "other feed same guid", feedURL: feedB, guid: "1", want: false},
+		{name: "other feed same canonical key", feedURL: feedB, guid: "3", canonicalKey: "https://example.tld/1", want: false},
+		{name: "other feed own guid", feedURL: feedB, guid: "2", want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := cache.IsProcessed(ctx, tt.feedURL, tt.guid, tt.canonicalKey)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func testFirstSeen(t *testing.T, cache repository.CacheRepository) {
+	ctx := context.Background()
+	first := now().Add(-time.Hour)
+
+	seen, isNew, err := cache.RecordFirstSeen(ctx, feedA, "guid-1", first)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !isNew || !seen.Equal(first) {
+		t.Errorf("expected new record at %v, got %v (new=%v)", first, seen, isNew)
+	}
+
+	seen, isNew, err = cache.RecordFirstSeen(ctx, feedA, "guid-1", now())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if isNew || !seen.Equal(first) {
+		t.Errorf("expected existing record at %v, got %v (new=%v)", first, seen, isNew)
+	}
+
+	_, isNew, err = cache.RecordFirstSeen(ctx, feedB, "guid-1", now())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !isNew {
+		t.Error("expected first seen to be recorded per feed")
+	}
+}
+
+func testRecentStories(t *testing.T, cache repository.CacheRepository) {
+	ctx := context.Background()
+	base := now()
+	keys := []string{"url:https://example.tld/1", "title:example"}
+
+	if err := cache.SaveRecentStory(ctx, feedA, keys, base.Add(-2*time.Hour)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		feedURL string
+		keys    []string
+		since   time.Time
+		want    bool
+	}{
+		{name: "other feed within window", feedURL: feedB, keys: []string{"title:example"}, since: base.Add(-24 * time.Hour), want: true},
+		{name: "same feed", feedURL: feedA, keys: keys, since: base.Add(-24 * time.Hour), want: false},
+		{name: "outside window", feedURL: feedB, keys: keys, since: base.Add(-1 * time.Hour), want: false},
+		{name: "window boundary", feedURL: feedB, keys: keys, since: base.Add(-2 * time.Hour), want: true},
+		{name: "different story", feedURL: feedB, keys: []string{"url:https://example.tld/2"}, since: base.Add(-24 * time.Hour), want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := cache.HasRecentStory(ctx, tt.feedURL, tt.keys, tt.since)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func testTokenUsage(t *testing.T, cache repository.CacheRepository) {
+	usageRepo, ok := cache.(repository.UsageRepository)
+	if !ok {
+		t.Skip("cache does not implement UsageRepository")
+	}
+	ctx := context.Background()
+
+	day := time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)
+	additions := []struct {
+		day   time.Time
+		usage entity.TokenUsage
+		cost  float64
+	}{
+		{day, entity.TokenUsage{Provider: "gemini", Model: "m1", InputTokens: 100, OutputTokens: 10}, 0.5},
+		{day.Add(time.Hour), entity.TokenUsage{Provider: "gemini", Model: "m1", InputTokens: 50, OutputTokens: 5}, 0.25},
+		{day, entity.TokenUsage{Provider: "bedrock", Model: "m2", InputTokens: 1, OutputTokens: 1}, 0.01},
+		{day.Add(24 * time.Hour), entity.TokenUsage{Provider: "gemini", Model: "m1", InputTokens: 7, OutputTokens: 7}, 1},
+	}
+	for _, a := range additions {
+		if err := usageRepo.AddTokenUsage(ctx, a.day, a.usage, a.cost); err != nil {
+			t.Fatalf("failed to add usage: %v", err)
+		}
+	}
+
+	usages, err := usageRepo.GetDailyUsage(ctx, day)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(usages) != 2 {
+		t.Fatalf("expected 2 usage rows, got %+v", usages)
+	}
+	if usages[0].Provider != "bedrock" || usages[1].Provider != "gemini" {
+		t.Errorf("expected usage ordered by provider, got %+v", usages)
+	}
+	if usages[1].InputTokens != 150 || usages[1].OutputTokens != 15 || usages[1].Cost != 0.75 {
+		t.Errorf("unexpected aggregated usage: %+v", usages[1])
+	}
+	if !usages[1].Day.Equal(time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("expected day to be truncated, got %v", usages[1].Day)
+	}
+}
+
+// testConcurrency は複数のゴルーチン（レプリカ）から同時に記録しても結果が一貫することを確認します
+func testConcurrency(t *testing.T, cache repository.CacheRepository) {
+	ctx := context.Background()
+	const workers = 8
+	const perWorker = 10
+	base := now()
+	seenAt := base.Add(-time.Hour)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, workers*(perWorker+3))
+	var newMu sync.Mutex
+	var newCount int
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < perWorker; i++ {
+				guid := fmt.Sprintf("guid-%d-%d", w, i)
+				if err := cache.MarkAsProcessed(ctx, feedA, guid, ""); err != nil {
+					errs <- err
+				}
+			}
+			if err := cache.MarkAsProcessed(ctx, feedA, "shared", "https://example.tld/shared"); err != nil {
+				errs <- err
+			}
+			if err := cache.SaveLatestPublishedTime(ctx, feedA, base.Add(time.Duration(w)*time.Minute)); err != nil {
+				errs <- err
+			}
+			seen, isNew, err := cache.RecordFirstSeen(ctx, feedA, "undated", seenAt.Add(time.Duration(w)*time.Second))
+			if err != nil {
+				errs <- err
+				return
+			}
+			if isNew {
+				newMu.Lock()
+				newCount++
+				newMu.Unlock()
+			}
+			if !isNew && seen.IsZero() {
+				errs <- fmt.Errorf("worker %d: expected existing first seen time", w)
+			}
+		}(w)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	if newCount != 1 {
+		t.Errorf("expected exactly one worker to record the first seen time, got %d", newCount)
+	}
+	seen, isNew, err := cache.RecordFirstSeen(ctx, feedA, "undated", base)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if isNew || seen.Before(seenAt) || seen.After(seenAt.Add(workers*time.Second)) {
+		t.Errorf("expected one of the workers' first seen times, got %v (new=%v)", seen, isNew)
+	}
+
+	for w := 0; w < workers; w++ {
+		for i := 0; i < perWorker; i++ {
+			processed, err := cache.IsProcessed(ctx, feedA, fmt.Sprintf("guid-%d-%d", w, i), "")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !processed {
+				t.Errorf("expected guid-%d-%d to be processed", w, i)
+			}
+		}
+	}
+
+	latest, err := cache.GetLatestPublishedTime(ctx, feedA)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := base.Add((workers - 1) * time.Minute); !latest.Equal(want) {
+		t.Errorf("expected latest published time %v, got %v", want, latest)
+	}
+}
+
+// testCleanup は保持期間より古い記録だけを削除することを確認します
+// 初回検出日時はフィードで最後に検出した日時で判定し、フィードに残っている日時のないエントリーを再び投稿させない
+func testCleanup(t *testing.T, cache repository.CacheRepository) {
+	cleaner, ok := cache.(Cleaner)
+	if !ok {
+		t.Skip("cache does not implement CleanupOldGUIDs")
+	}
+	ctx := context.Background()
+	base := now()
+
+	for _, guid := range []string{"processed", "still-listed"} {
+		if err := cache.MarkAsProcessed(ctx, feedA, guid, ""); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	for _, seen := range []struct {
+		guid string
+		at   time.Time
+	}{
+		{"old-undated", base.Add(-48 * time.Hour)},
+		{"recent-undated", base.Add(-time.Hour)},
+		// 48時間前に初めて検出し、1時間前にもフィードに残っていたエントリー
+		{"still-listed", base.Add(-48 * time.Hour)},
+		{"still-listed", base.Add(-time.Hour)},
+	} {
+		if _, _, err := cache.RecordFirstSeen(ctx, feedA, seen.guid, seen.at); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if err := cache.SaveRecentStory(ctx, feedB, []string{"url:old"}, base.Add(-48*time.Hour)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := cache.SaveRecentStory(ctx, feedB, []string{"url:recent"}, base.Add(-time.Hour)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	deleted, err := cleaner.CleanupOldGUIDs(ctx, 24*time.Hour)
+	if err != nil {
+		t.Fatalf("cleanup failed: %v", err)
+	}
+	if deleted != 2 {
+		t.Errorf("expected 2 deleted, got %d", deleted)
+	}
+
+	processed, err := cache.IsProcessed(ctx, feedA, "processed", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !processed {
+		t.Error("expected recently processed GUID to be kept")
+	}
+	if _, isNew, err := cache.RecordFirstSeen(ctx, feedA, "old-undated", base); err != nil || !isNew {
+		t.Errorf("expected first seen time no longer in the feed to be deleted, got new=%v err=%v", isNew, err)
+	}
+	if _, isNew, err := cache.RecordFirstSeen(ctx, feedA, "recent-undated", base); err != nil || isNew {
+		t.Errorf("expected recent first seen time to be kept, got new=%v err=%v", isNew, err)
+	}
+	firstSeen, isNew, err := cache.RecordFirstSeen(ctx, feedA, "still-listed", base)
+	if err != nil || isNew || !firstSeen.Equal(base.Add(-48*time.Hour)) {
+		t.Errorf("expected first seen time of an entry still in the feed to be kept, got %v new=%v err=%v", firstSeen, isNew, err)
+	}
+	for key, want := range map[string]bool{"url:old": false, "url:recent": true} {
+		posted, err := cache.HasRecentStory(ctx, feedA, []string{key}, time.Time{})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if posted != want {
+			t.Errorf("%s: expected posted=%v, got %v", key, want, posted)
+		}
+	}
+
+	// 負の保持期間は現在より後の時点を基準にするため、フィードで検出し続けているもの以外はすべて削除される
+	if _, _, err := cache.RecordFirstSeen(ctx, feedA, "still-listed", base.Add(2*time.Hour)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := cleaner.CleanupOldGUIDs(ctx, -time.Hour); err != nil {
+		t.Fatalf("cleanup failed: %v", err)
+	}
+	for guid, want := range map[string]bool{"processed": false, "still-listed": true} {
+		processed, err := cache.IsProcessed(ctx, feedA, guid, "")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if processed != want {
+			t.Errorf("%s: expected processed=%v after cleanup, got %v", guid, want, processed)
+		}
+	}
+}
+
+// testExportImport は書き出した状態を取り込むと、既存の記録と統合されることを確認します
+func testExportImport(t *testing.T, cache repository.CacheRepository) {
+	stateRepo, ok := cache.(repository.CacheStateRepository)
+	if !ok {
+		t.Skip("cache does not implement CacheStateRepository")
+	}
+	ctx := context.Background()
+	base := now()
+
+	if err := cache.SaveLatestPublishedTime(ctx, feedA, base.Add(-time.Hour)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := cache.SaveLatestPublishedTime(ctx, feedB, base); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := cache.MarkAsProcessed(ctx, feedA, "guid-1", "url:1"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, _, err := cache.RecordFirstSeen(ctx, feedA, "undated", base.Add(-time.Hour)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := cache.SaveRecentStory(ctx, feedA, []string{"url:1"}, base.Add(-time.Hour)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	exported, err := stateRepo.ExportState(ctx)
+	if err != nil {
+		t.Fatalf("failed to export: %v", err)
+	}
+	if got := exported.Len(); got != 5 {
+		t.Fatalf("expected 5 exported records, got %d: %+v", got, exported)
+	}
+
+	// 書き出した状態をそのまま取り込んでも変わらない
+	if err := stateRepo.ImportState(ctx, exported); err != nil {
+		t.Fatalf("failed to import: %v", err)
+	}
+	reexported, err := stateRepo.ExportState(ctx)
+	if err != nil {
+		t.Fatalf("failed to export: %v", err)
+	}
+	if !reflect.DeepEqual(normalizeSnapshot(exported), normalizeSnapshot(reexported)) {
+		t.Errorf("expected import of own export to be a no-op\nbefore: %+v\nafter:  %+v", exported, reexported)
+	}
+
+	if err := stateRepo.ImportState(ctx, &entity.CacheSnapshot{
+		LatestPublished: []entity.LatestPublished{
+			{FeedURL: feedA, PublishedAt: base},
+			{FeedURL: feedB, PublishedAt: base.Add(-time.Hour)},
+		},
+		Processed: []entity.ProcessedGUID{
+			{FeedURL: feedA, GUID: "guid-1", CanonicalKey: "url:other", ProcessedAt: base.Add(-48 * time.Hour)},
+			{FeedURL: feedB, GUID: "guid-2", ProcessedAt: base.Add(-2 * time.Hour)},
+			{GUID: "legacy-guid", CanonicalKey: "url:legacy", ProcessedAt: base.Add(-3 * time.Hour)},
+		},
+		FirstSeen: []entity.FirstSeen{
+			{FeedURL: feedA, GUID: "undated", SeenAt: base.Add(-2 * time.Hour)},
+			{GUID: "legacy-undated", SeenAt: base.Add(-3 * time.Hour)},
+		},
+		RecentStories: []entity.RecentStory{
+			{Key: "url:1", FeedURL: feedA, PostedAt: base},
+		},
+	}); err != nil {
+		t.Fatalf("failed to import: %v", err)
+	}
+
+	for feedURL, want := range map[string]time.Time{feedA: base, feedB: base} {
+		latest, err := cache.GetLatestPublishedTime(ctx, feedURL)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !latest.Equal(want) {
+			t.Errorf("%s: expected latest published %v, got %v", feedURL, want, latest)
+		}
+	}
+
+	processedTests := []struct {
+		name         string
+		feedURL      string
+		guid         string
+		canonicalKey string
+		want         bool
+	}{
+		{"existing row keeps its canonical key", feedA, "other", "url:1", true},
+		{"existing row is not overwritten", feedA, "other", "url:other", false},
+		{"imported row", feedB, "guid-2", "", true},
+		{"imported row is scoped by feed", feedA, "guid-2", "", false},
+		{"legacy row applies to every feed", feedB, "legacy-guid", "", true},
+		{"legacy canonical key applies to every feed", feedA, "other", "url:legacy", true},
+	}
+	for _, tt := range processedTests {
+		processed, err := cache.IsProcessed(ctx, tt.feedURL, tt.guid, tt.canonicalKey)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tt.name, err)
+		}
+		if processed != tt.want {
+			t.Errorf("%s: expected processed=%v, got %v", tt.name, tt.want, processed)
+		}
+	}
+
+	for _, tt := range []struct {
+		feedURL string
+		guid    string
+		want    time.Time
+	}{
+		{feedA, "undated", base.Add(-2 * time.Hour)},
+		{feedB, "legacy-undated", base.Add(-3 * time.Hour)},
+	} {
+		firstSeen, isNew, err := cache.RecordFirstSeen(ctx, tt.feedURL, tt.guid, base)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if isNew || !firstSeen.Equal(tt.want) {
+			t.Errorf("%s %s: expected earliest first seen %v, got %v (new=%v)", tt.feedURL, tt.guid, tt.want, firstSeen, isNew)
+		}
+	}
+
+	// 取り込んだ新しい投稿日時で重複を判定する
+	posted, err := cache.HasRecentStory(ctx, feedB, []string{"url:1"}, base.Add(-time.Minute))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !posted {
+		t.Error("expected imported recent story to be newer")
+	}
+}
+
+// testPostStates は投稿の状態の記録と、投稿済みにしたときに処理済みと最新の公開日時も更新されることを確認します
+func testPostStates(t *testing.T, cache repository.CacheRepository) {
+	stateRepo, ok := cache.(repository.PostStateRepository)
+	if !ok {
+		t.Skip("cache does not implement PostStateRepository")
+	}
+	ctx := context.Background()
+	base := now()
+
+	pending := &entity.PostRecord{FeedURL: feedA, GUID: "guid-1", Published: base.Add(-time.Hour), State: entity.PostStatePending, UpdatedAt: base}
+	posting := &entity.PostRecord{FeedURL: feedA, GUID: "guid-2", CanonicalKey: "url:2", Published: base, State: entity.PostStatePosting, NoteText: "📰 Article 2", UpdatedAt: base}
+	undated := &entity.PostRecord{FeedURL: feedB, GUID: "undated", State: entity.PostStatePosting, NoteText: "📰 Undated", UpdatedAt: base.Add(time.Second)}
+	for _, record := range []*entity.PostRecord{pending, posting, undated} {
+		if err := stateRepo.SavePostState(ctx, record); err != nil {
+			t.Fatalf("failed to save post state: %v", err)
+		}
+	}
+
+	postingRecords, err := stateRepo.ListPostStates(ctx, entity.PostStatePosting)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(postingRecords) != 2 || postingRecords[0].GUID != "guid-2" || postingRecords[1].GUID != "undated" {
+		t.Fatalf("expected posting records in update order, got %+v", postingRecords)
+	}
+	if got := postingRecords[0]; got.NoteText != posting.NoteText || got.CanonicalKey != "url:2" || !got.Published.Equal(base) {
+		t.Errorf("expected posting record to be kept as saved, got %+v", got)
+	}
+	if !postingRecords[1].Published.IsZero() {
+		t.Errorf("expected undated record to have no published time, got %v", postingRecords[1].Published)
+	}
+
+	assertUnfinishedPosts(t, stateRepo, feedA, "guid-1", "guid-2")
+
+	for _, record := range []*entity.PostRecord{posting, undated} {
+		if err := stateRepo.CompletePost(ctx, record); err != nil {
+			t.Fatalf("failed to complete post: %v", err)
+		}
+	}
+
+	postingRecords, err = stateRepo.ListPostStates(ctx, entity.PostStatePosting)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(postingRecords) != 0 {
+		t.Errorf("expected no posting records after completion, got %+v", postingRecords)
+	}
+	postedRecords, err := stateRepo.ListPostStates(ctx, entity.PostStatePosted)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(postedRecords) != 2 {
+		t.Errorf("expected 2 posted records, got %+v", postedRecords)
+	}
+	pendingRecords, err := stateRepo.ListPostStates(ctx, entity.PostStatePending)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(pendingRecords) != 1 || pendingRecords[0].GUID != "guid-1" {
+		t.Errorf("expected pending record to be kept, got %+v", pendingRecords)
+	}
+	assertUnfinishedPosts(t, stateRepo, feedA, "guid-1")
+	assertUnfinishedPosts(t, stateRepo, feedB)
+
+	for _, tt := range []struct {
+		feedURL string
+		guid    string
+		key     string
+		want    bool
+	}{
+		{feedA, "guid-1", "", false},
+		{feedA, "other", "url:2", true},
+		{feedB, "undated", "", true},
+	} {
+		processed, err := cache.IsProcessed(ctx, tt.feedURL, tt.guid, tt.key)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if processed != tt.want {
+			t.Errorf("%s %s: expected processed=%v, got %v", tt.feedURL, tt.guid, tt.want, processed)
+		}
+	}
+
+	// 日時のないエントリーは最新の公開日時を更新しない
+	for feedURL, want := range map[string]time.Time{feedA: base, feedB: {}} {
+		latest, err := cache.GetLatestPublishedTime(ctx, feedURL)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !latest.Equal(want) {
+			t.Errorf("%s: expected latest published %v, got %v", feedURL, want, latest)
+		}
+	}
+
+	cleaner, ok := cache.(Cleaner)
+	if !ok {
+		return
+	}
+	// 投稿中の記録は古くても投稿履歴と照合するまで削除しない
+	stale := []*entity.PostRecord{
+		{FeedURL: feedB, GUID: "stale-posted", State: entity.PostStatePosted, UpdatedAt: base.Add(-48 * time.Hour)},
+		{FeedURL: feedB, GUID: "stale-posting", State: entity.PostStatePosting, UpdatedAt: base.Add(-48 * time.Hour)},
+	}
+	for _, record := range stale {
+		if err := stateRepo.SavePostState(ctx, record); err != nil {
+			t.Fatalf("failed to save post state: %v", err)
+		}
+	}
+	if _, err := cleaner.CleanupOldGUIDs(ctx, 24*time.Hour); err != nil {
+		t.Fatalf("cleanup failed: %v", err)
+	}
+	for state, wantGUIDs := range map[entity.PostState][]string{
+		entity.PostStatePosted:  {"guid-2", "undated"},
+		entity.PostStatePosting: {"stale-posting"},
+	} {
+		records, err := stateRepo.ListPostStates(ctx, state)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		var guids []string
+		for _, record := range records {
+			guids = append(guids, record.GUID)
+		}
+		if !reflect.DeepEqual(guids, wantGUIDs) {
+			t.Errorf("%s: expected %v after cleanup, got %v", state, wantGUIDs, guids)
+		}
+	}
+}
+
+// assertUnfinishedPosts は feedURL の投稿済みでない記録が wantGUIDs の順に返ることを確認します
+func assertUnfinishedPosts(t *testing.T, stateRepo repository.PostStateRepository, feedURL string, wantGUIDs ...string) {
+	t.Helper()
+	records, err := stateRepo.ListUnfinishedPosts(context.Background(), feedURL)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var guids []string
+	for _, record := range records {
+		guids = append(guids, record.GUID)
+	}
+	if !reflect.DeepEqual(guids, wantGUIDs) {
+		t.Errorf("%s: expected unfinished posts %v, got %v", feedURL, wantGUIDs, guids)
+	}
+}
+
+// normalizeSnapshot は実装による並び順の違いを除いて比較できるようにします
+// testLeases はリースを1つのホルダーだけが持ち、期限切れの後は他のホルダーが引き継げることを確認します
+func testLeases(t *testing.T, cache repository.CacheRepository) {
+	leases, ok := cache.(repository.LeaseRepository)
+	if !ok {
+		t.Skip("cache does not implement LeaseRepository")
+	}
+	ctx := context.Background()
+	const name = "feed-processing"
+
+	lease, acquired, err := leases.AcquireLease(ctx, name, "replica-a", time.Minute)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !acquired || lease.Holder != "replica-a" || !lease.IsHeldBy("replica-a", time.Now()) {
+		t.Fatalf("expected replica-a to acquire the lease, got %+v (acquired=%v)", lease, acquired)
+	}
+
+	lease, acquired, err = leases.AcquireLease(ctx, name, "replica-b", time.Minute)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if acquired || lease.Holder != "replica-a" {
+		t.Errorf("expected replica-b to see the lease held by replica-a, got %+v (acquired=%v)", lease, acquired)
+	}
+
+	if _, acquired, err := leases.AcquireLease(ctx, "other-lease", "replica-b", time.Minute); err != nil || !acquired {
+		t.Errorf("expected leases to be independent by name, got acquired=%v (err=%v)", acquired, err)
+	}
+
+	// 期限の短い更新の後は、他のホルダーが期限切れのリースを引き継げる
+	if _, acquired, err := leases.AcquireLease(ctx, name, "replica-a", 10*time.Millisecond); err != nil || !acquired {
+		t.Fatalf("expected replica-a to renew the lease, got acquired=%v (err=%v)", acquired, err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	lease, acquired, err = leases.AcquireLease(ctx, name, "replica-b", time.Minute)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !acquired || lease.Holder != "replica-b" {
+		t.Errorf("expected replica-b to take over the expired lease, got %+v (acquired=%v)", lease, acquired)
+	}
+
+	// 他のホルダーのリースは解放できない
+	if err := leases.ReleaseLease(ctx, name, "replica-a"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, acquired, err := leases.AcquireLease(ctx, name, "replica-a", time.Minute); err != nil || acquired {
+		t.Errorf("expected release by a non-holder to be ignored, got acquired=%v (err=%v)", acquired, err)
+	}
+
+	if err := leases.ReleaseLease(ctx, name, "replica-b"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, acquired, err := leases.AcquireLease(ctx, name, "replica-a", time.Minute); err != nil || !acquired {
+		t.Errorf("expected replica-a to acquire the released lease, got acquired=%v (err=%v)", acquired, err)
+	}
+}
+
+// testWebSubSubscriptions は WebSub の購読を記録して読み出し、同じIDの記録を置き換えられることを確認します
+func testWebSubSubscriptions(t *testing.T, cache repository.CacheRepository) {
+	subs, ok := cache.(repository.WebSubSubscriptionRepository)
+	if !ok {
+		t.Skip("cache does not implement WebSubSubscriptionRepository")
+	}
+	ctx := context.Background()
+	base := time.UnixMilli(now().UnixMilli())
+
+	if _, ok, err := subs.GetWebSubSubscription(ctx, "missing"); err != nil || ok {
+		t.Fatalf("expected no subscription, got ok=%v (err=%v)", ok, err)
+	}
+
+	pending := entity.WebSubSubscription{
+		ID:          "sub-1",
+		FeedURL:     "https://example.tld/hub.xml",
+		Hub:         "https://hub.example.tld/",
+		Topic:       "https://example.tld/hub.xml#self",
+		Secret:      "secret",
+		State:       entity.WebSubStatePending,
+		RequestedAt: base,
+	}
+	if err := subs.SaveWebSubSubscription(ctx, pending); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got, ok, err := subs.GetWebSubSubscription(ctx, "sub-1")
+	if err != nil || !ok {
+		t.Fatalf("expected the saved subscription, got ok=%v (err=%v)", ok, err)
+	}
+	if !got.RequestedAt.Equal(base) || !got.ExpiresAt.IsZero() {
+		t.Errorf("expected times to round-trip, got %+v", got)
+	}
+	got.RequestedAt, got.ExpiresAt = time.Time{}, time.Time{}
+	want := pending
+	want.RequestedAt = time.Time{}
+	if got != want {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+
+	active := pending
+	active.State = entity.WebSubStateActive
+	active.ExpiresAt = base.Add(24 * time.Hour)
+	active.Missed = true
+	if err := subs.SaveWebSubSubscription(ctx, active); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	other := entity.WebSubSubscription{ID: "sub-2", FeedURL: "https://example.tld/other.xml", State: entity.WebSubStateFailed}
+	if err := subs.SaveWebSubSubscription(ctx, other); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	list, err := subs.ListWebSubSubscriptions(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(list) != 2 || list[0].ID != "sub-1" || list[1].ID != "sub-2" {
+		t.Fatalf("expected 2 subscriptions ordered by feed, got %+v", list)
+	}
+	if list[0].State != entity.WebSubStateActive || !list[0].ExpiresAt.Equal(active.ExpiresAt) || !list[0].Missed {
+		t.Errorf("expected the saved subscription to be replaced, got %+v", list[0])
+	}
+
+	if err := subs.DeleteWebSubSubscription(ctx, "sub-1"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok, err := subs.GetWebSubSubscription(ctx, "sub-1"); err != nil || ok {
+		t.Errorf("expected the subscription to be deleted, got ok=%v (err=%v)", ok, err)
+	}
+}
+
+func normalizeSnapshot(s *entity.CacheSnapshot) *entity.CacheSnapshot {
+	normalized := *s
+	normalized.Processed = append([]entity.ProcessedGUID(nil), s.Processed...)
+	sort.Slice(normalized.Processed, func(i, j int) bool {
+		a, b := normalized.Processed[i], normalized.Processed[j]
+		if a.FeedURL != b.FeedURL {
+			return a.FeedURL < b.FeedURL
+		}
+		return a.GUID < b.GUID
+	})
+	return &normalized
+}
+
+// testContextCancellation はキャンセルしたコンテキストではエラーを返し、何も記録しないことを確認します
+func testContextCancellation(t *testing.T, cache repository.CacheRepository) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	ctx := context.Background()
+	base := now()
+
+	calls := []struct {
+		name string
+		call func() error
+	}{
+		{"GetLatestPublishedTime", func() error {
+			_, err := cache.GetLatestPublishedTime(canceled, feedA)
+			return err
+		}},
+		{"SaveLatestPublishedTime", func() error {
+			return cache.SaveLatestPublishedTime(canceled, feedA, base)
+		}},
+		{"IsProcessed", func() error {
+			_, err := cache.IsProcessed(canceled, feedA, "guid", "")
+			return err
+		}},
+		{"MarkAsProcessed", func() error {
+			return cache.MarkAsProcessed(canceled, feedA, "guid", "")
+		}},
+		{"RecordFirstSeen", func() error {
+			_, _, err := cache.RecordFirstSeen(canceled, feedA, "guid", base)
+			return err
+		}},
+		{"HasRecentStory", func() error {
+			_, err := cache.HasRecentStory(canceled, feedA, []string{"url:1"}, time.Time{})
+			return err
+		}},
+		{"SaveRecentStory", func() error {
+			return cache.SaveRecentStory(canceled, feedB, []string{"url:1"}, base)
+		}},
+	}
+	if cleaner, ok := cache.(Cleaner); ok {
+		calls = append(calls, struct {
+			name string
+			call func() error
+		}{"CleanupOldGUIDs", func() error {
+			_, err := cleaner.CleanupOldGUIDs(canceled, time.Hour)
+			return err
+		}})
+	}
+	for _, c := range calls {
+		if err := c.call(); !errors.Is(err, context.Canceled) {
+			t.Errorf("%s: expected context.Canceled, got %v", c.name, err)
+		}
+	}
+
+	latest, err := cache.GetLatestPublishedTime(ctx, feedA)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !latest.IsZero() {
+		t.Errorf("expected latest published time not to be saved, got %v", latest)
+	}
+	processed, err := cache.IsProcessed(ctx, feedA, "guid", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if processed {
+		t.Error("expected GUID not to be marked as processed")
+	}
+	if _, isNew, err := cache.RecordFirstSeen(ctx, feedA, "guid", base); err != nil || !isNew {
+		t.Errorf("expected first seen time not to be recorded, got new=%v err=%v", isNew, err)
+	}
+	posted, err := cache.HasRecentStory(ctx, feedA, []string{"url:1"}, time.Time{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if posted {
+		t.Error("expected recent story not to be saved")
+	}
+}
@@ -1,27 +1,83 @@
 package storage
 
 import (
+	"container/list"
 	"context"
+	"sort"
 	"sync"
 	"time"
 
+	"misskeyRSSbot/internal/domain/entity"
 	"misskeyRSSbot/internal/domain/repository"
 )
 
 type memoryCache struct {
 	mu              sync.RWMutex
 	latestPublished map[string]time.Time
-	processedGUIDs  map[string]bool
+	// processedGUIDs は processedOrder の要素（*processedEntry）を引くための索引です
+	processedGUIDs map[feedItemKey]*list.Element
+	// processedOrder は処理済みのGUIDを最近参照した順（先頭が最新）に並べます
+	processedOrder *list.List
+	// processedKeys は正規化したキーごとの処理済みのGUIDの数です
+	processedKeys map[feedItemKey]int
+	// maxProcessed は保持する処理済みのGUIDの上限です（0 の場合は上限なし）
+	maxProcessed  int
+	firstSeen     map[feedItemKey]*firstSeenEntry
+	recentStories map[string]map[string]time.Time
+	postStates    map[feedItemKey]entity.PostRecord
+	leases        map[string]entity.Lease
+	websubs       map[string]entity.WebSubSubscription
+	usage         map[usageKey]entity.DailyUsage
 }
 
-func NewMemoryCacheRepository() repository.CacheRepository {
+// feedItemKey はフィードごとに記録するエントリーのキーです
+type feedItemKey struct {
+	feedURL string
+	value   string
+}
+
+// firstSeenEntry は日時のないエントリーの初回検出日時と、最後にフィードで検出した日時です
+type firstSeenEntry struct {
+	seenAt     time.Time
+	lastSeenAt time.Time
+}
+
+// processedEntry は処理済みのGUIDと、記録した日時です
+type processedEntry struct {
+	key          feedItemKey
+	canonicalKey string
+	processedAt  time.Time
+}
+
+type usageKey struct {
+	day      string
+	provider string
+	model    string
+}
+
+// NewMemoryCacheRepository はメモリ上のキャッシュを作成します
+// maxProcessed が正の場合、処理済みのGUIDがこれを超えると最も長く参照されていないものから削除します
+func NewMemoryCacheRepository(maxProcessed int) repository.CacheRepository {
 	return &memoryCache{
 		latestPublished: make(map[string]time.Time),
-		processedGUIDs:  make(map[string]bool),
+		processedGUIDs:  make(map[feedItemKey]*list.Element),
+		processedOrder:  list.New(),
+		processedKeys:   make(map[feedItemKey]int),
+		maxProcessed:    maxProcessed,
+		firstSeen:       make(map[feedItemKey]*firstSeenEntry),
+		recentStories:   make(map[string]map[string]time.Time),
+		postStates:      make(map[feedItemKey]entity.PostRecord),
+		leases:          make(map[string]entity.Lease),
+		websubs:         make(map[string]entity.WebSubSubscription),
+		usage:           make(map[usageKey]entity.DailyUsage),
 	}
 }
 
 func (c *memoryCache) GetLatestPublishedTime(ctx context.Context, rssURL string) (time.Time, error) {
+	if err := ctx.Err(); err != nil {
+		return time.Time{}, err
+	}
+
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
@@ -33,25 +89,521 @@ func (c *memoryCache) GetLatestPublishedTime(ctx context.Context, rssURL string)
 }
 
 func (c *memoryCache) SaveLatestPublishedTime(ctx context.Context, rssURL string, published time.Time) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
+	if current, ok := c.latestPublished[rssURL]; ok && !published.After(current) {
+		return nil
+	}
 	c.latestPublished[rssURL] = published
 	return nil
 }
 
-func (c *memoryCache) IsProcessed(ctx context.Context, guid string) (bool, error) {
+func (c *memoryCache) IsProcessed(ctx context.Context, feedURL, guid, canonicalKey string) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+
+	// 参照した順序を更新するため書き込みロックを取る
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// フィードの記録がない記録（取り込んだ移行前の状態）はすべてのフィードに適用する
+	for _, scope := range []string{feedURL, ""} {
+		if elem, ok := c.processedGUIDs[feedItemKey{feedURL: scope, value: guid}]; ok {
+			c.processedOrder.MoveToFront(elem)
+			return true, nil
+		}
+		if canonicalKey != "" && c.processedKeys[feedItemKey{feedURL: scope, value: canonicalKey}] > 0 {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (c *memoryCache) MarkAsProcessed(ctx context.Context, feedURL, guid, canonicalKey string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.addProcessed(feedURL, guid, canonicalKey, time.Now())
+	return nil
+}
+
+// addProcessed は処理済みのGUIDを記録し、上限を超えた分を最も長く参照されていないものから削除します
+// 呼び出し元で書き込みロックを取得している必要があります
+func (c *memoryCache) addProcessed(feedURL, guid, canonicalKey string, processedAt time.Time) {
+	key := feedItemKey{feedURL: feedURL, value: guid}
+	if elem, ok := c.processedGUIDs[key]; ok {
+		c.processedOrder.MoveToFront(elem)
+		return
+	}
+
+	c.processedGUIDs[key] = c.processedOrder.PushFront(&processedEntry{
+		key:          key,
+		canonicalKey: canonicalKey,
+		processedAt:  processedAt,
+	})
+	if canonicalKey != "" {
+		c.processedKeys[feedItemKey{feedURL: feedURL, value: canonicalKey}]++
+	}
+
+	for c.maxProcessed > 0 && c.processedOrder.Len() > c.maxProcessed {
+		c.removeProcessed(c.processedOrder.Back())
+	}
+}
+
+// removeProcessed は処理済みのGUIDを正規化したキーとともに削除します
+// 呼び出し元で書き込みロックを取得している必要があります
+// hasFirstSeen は key のエントリーの初回検出日時（移行前の feed_url が空のものを含む）があるかどうかを返します
+func (c *memoryCache) hasFirstSeen(key feedItemKey) bool {
+	if _, ok := c.firstSeen[key]; ok {
+		return true
+	}
+	_, ok := c.firstSeen[feedItemKey{value: key.value}]
+	return ok
+}
+
+func (c *memoryCache) removeProcessed(elem *list.Element) {
+	entry := c.processedOrder.Remove(elem).(*processedEntry)
+	delete(c.processedGUIDs, entry.key)
+	if entry.canonicalKey == "" {
+		return
+	}
+	canonical := feedItemKey{feedURL: entry.key.feedURL, value: entry.canonicalKey}
+	if c.processedKeys[canonical]--; c.processedKeys[canonical] <= 0 {
+		delete(c.processedKeys, canonical)
+	}
+}
+
+func (c *memoryCache) RecordFirstSeen(ctx context.Context, feedURL, guid string, seen time.Time) (time.Time, bool, error) {
+	if err := ctx.Err(); err != nil {
+		return time.Time{}, false, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := feedItemKey{feedURL: feedURL, value: guid}
+	for _, scope := range []feedItemKey{key, {value: guid}} {
+		if firstSeen, ok := c.firstSeen[scope]; ok {
+			// フィードに残っている間は掃除で削除しないように、最後に検出した日時を更新する
+			if seen.After(firstSeen.lastSeenAt) {
+				firstSeen.lastSeenAt = seen
+			}
+			return firstSeen.seenAt, false, nil
+		}
+	}
+	c.firstSeen[key] = &firstSeenEntry{seenAt: seen, lastSeenAt: seen}
+	return seen, true, nil
+}
+
+func (c *memoryCache) HasRecentStory(ctx context.Context, feedURL string, keys []string, since time.Time) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
-	processed, ok := c.processedGUIDs[guid]
-	return ok && processed, nil
+	for _, key := range keys {
+		for postedFeed, postedAt := range c.recentStories[key] {
+			if postedFeed != feedURL && !postedAt.Before(since) {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+func (c *memoryCache) SaveRecentStory(ctx context.Context, feedURL string, keys []string, postedAt time.Time) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, key := range keys {
+		feeds, ok := c.recentStories[key]
+		if !ok {
+			feeds = make(map[string]time.Time)
+			c.recentStories[key] = feeds
+		}
+		feeds[feedURL] = postedAt
+	}
+	return nil
+}
+
+// CleanupOldGUIDs は olderThan より前に記録した処理済みのGUID、投稿した記事のキー、投稿の状態を削除します
+// 初回検出日時はフィードで最後に検出してから olderThan が過ぎたものだけを削除し、
+// 残した初回検出日時のエントリーの処理済みのGUIDも残す（日時のないエントリーを再び投稿しないため）
+func (c *memoryCache) CleanupOldGUIDs(ctx context.Context, olderThan time.Duration) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	cutoff := time.Now().Add(-olderThan)
+	var deleted int64
+
+	for key, firstSeen := range c.firstSeen {
+		if firstSeen.lastSeenAt.Before(cutoff) {
+			delete(c.firstSeen, key)
+			deleted++
+		}
+	}
+
+	for elem := c.processedOrder.Front(); elem != nil; {
+		next := elem.Next()
+		entry := elem.Value.(*processedEntry)
+		if entry.processedAt.Before(cutoff) && !c.hasFirstSeen(entry.key) {
+			c.removeProcessed(elem)
+			deleted++
+		}
+		elem = next
+	}
+
+	for storyKey, feeds := range c.recentStories {
+		for feedURL, postedAt := range feeds {
+			if postedAt.Before(cutoff) {
+				delete(feeds, feedURL)
+				deleted++
+			}
+		}
+		if len(feeds) == 0 {
+			delete(c.recentStories, storyKey)
+		}
+	}
+
+	// 投稿中の記録は起動時に投稿履歴と照合するまで残す
+	for key, record := range c.postStates {
+		if record.State != entity.PostStatePosting && record.UpdatedAt.Before(cutoff) {
+			delete(c.postStates, key)
+			deleted++
+		}
+	}
+
+	return deleted, nil
+}
+
+func (c *memoryCache) SavePostState(ctx context.Context, record *entity.PostRecord) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.postStates[feedItemKey{feedURL: record.FeedURL, value: record.GUID}] = *record
+	return nil
 }
 
-func (c *memoryCache) MarkAsProcessed(ctx context.Context, guid string) error {
+func (c *memoryCache) CompletePost(ctx context.Context, record *entity.PostRecord) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	c.processedGUIDs[guid] = true
+	posted := *record
+	posted.State = entity.PostStatePosted
+	c.postStates[feedItemKey{feedURL: record.FeedURL, value: record.GUID}] = posted
+	c.addProcessed(record.FeedURL, record.GUID, record.CanonicalKey, time.Now())
+	if current, ok := c.latestPublished[record.FeedURL]; !record.Published.IsZero() && (!ok || record.Published.After(current)) {
+		c.latestPublished[record.FeedURL] = record.Published
+	}
 	return nil
 }
+
+func (c *memoryCache) ListPostStates(ctx context.Context, state entity.PostState) ([]*entity.PostRecord, error) {
+	return c.listPostRecords(ctx, func(record entity.PostRecord) bool {
+		return record.State == state
+	})
+}
+
+func (c *memoryCache) ListUnfinishedPosts(ctx context.Context, feedURL string) ([]*entity.PostRecord, error) {
+	return c.listPostRecords(ctx, func(record entity.PostRecord) bool {
+		return record.FeedURL == feedURL && record.State != entity.PostStatePosted
+	})
+}
+
+func (c *memoryCache) listPostRecords(ctx context.Context, match func(entity.PostRecord) bool) ([]*entity.PostRecord, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var records []*entity.PostRecord
+	for _, record := range c.postStates {
+		if match(record) {
+			records = append(records, &record)
+		}
+	}
+	sort.Slice(records, func(i, j int) bool {
+		a, b := records[i], records[j]
+		if !a.UpdatedAt.Equal(b.UpdatedAt) {
+			return a.UpdatedAt.Before(b.UpdatedAt)
+		}
+		if a.FeedURL != b.FeedURL {
+			return a.FeedURL < b.FeedURL
+		}
+		return a.GUID < b.GUID
+	})
+	return records, nil
+}
+
+// AcquireLease はプロセス内のリースを取得します
+// メモリ上のキャッシュは他のプロセスと共有しないため、同じプロセスの中での排他のみを行います
+func (c *memoryCache) AcquireLease(ctx context.Context, name, holder string, ttl time.Duration) (entity.Lease, bool, error) {
+	if err := ctx.Err(); err != nil {
+		return entity.Lease{}, false, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	lease, ok := c.leases[name]
+	if ok && lease.Holder != holder && now.Before(lease.ExpiresAt) {
+		return lease, false, nil
+	}
+	lease = entity.Lease{Name: name, Holder: holder, ExpiresAt: now.Add(ttl)}
+	c.leases[name] = lease
+	return lease, true, nil
+}
+
+func (c *memoryCache) ReleaseLease(ctx context.Context, name, holder string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if lease, ok := c.leases[name]; ok && lease.Holder == holder {
+		delete(c.leases, name)
+	}
+	return nil
+}
+
+func (c *memoryCache) SaveWebSubSubscription(ctx context.Context, sub entity.WebSubSubscription) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.websubs[sub.ID] = sub
+	return nil
+}
+
+func (c *memoryCache) GetWebSubSubscription(ctx context.Context, id string) (entity.WebSubSubscription, bool, error) {
+	if err := ctx.Err(); err != nil {
+		return entity.WebSubSubscription{}, false, err
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	sub, ok := c.websubs[id]
+	return sub, ok, nil
+}
+
+func (c *memoryCache) ListWebSubSubscriptions(ctx context.Context) ([]entity.WebSubSubscription, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	subs := make([]entity.WebSubSubscription, 0, len(c.websubs))
+	for _, sub := range c.websubs {
+		subs = append(subs, sub)
+	}
+	sort.Slice(subs, func(i, j int) bool {
+		if subs[i].FeedURL != subs[j].FeedURL {
+			return subs[i].FeedURL < subs[j].FeedURL
+		}
+		return subs[i].ID < subs[j].ID
+	})
+	return subs, nil
+}
+
+func (c *memoryCache) DeleteWebSubSubscription(ctx context.Context, id string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.websubs, id)
+	return nil
+}
+
+func (c *memoryCache) ExportState(ctx context.Context) (*entity.CacheSnapshot, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	snapshot := &entity.CacheSnapshot{}
+	for feedURL, published := range c.latestPublished {
+		snapshot.LatestPublished = append(snapshot.LatestPublished, entity.LatestPublished{FeedURL: feedURL, PublishedAt: published})
+	}
+	// 古いものから並べ、取り込んだときに最近処理したGUIDほど削除されにくくする
+	for elem := c.processedOrder.Back(); elem != nil; elem = elem.Prev() {
+		entry := elem.Value.(*processedEntry)
+		snapshot.Processed = append(snapshot.Processed, entity.ProcessedGUID{
+			FeedURL:      entry.key.feedURL,
+			GUID:         entry.key.value,
+			CanonicalKey: entry.canonicalKey,
+			ProcessedAt:  entry.processedAt,
+		})
+	}
+	for key, firstSeen := range c.firstSeen {
+		snapshot.FirstSeen = append(snapshot.FirstSeen, entity.FirstSeen{FeedURL: key.feedURL, GUID: key.value, SeenAt: firstSeen.seenAt})
+	}
+	for storyKey, feeds := range c.recentStories {
+		for feedURL, postedAt := range feeds {
+			snapshot.RecentStories = append(snapshot.RecentStories, entity.RecentStory{Key: storyKey, FeedURL: feedURL, PostedAt: postedAt})
+		}
+	}
+
+	for _, record := range c.postStates {
+		snapshot.Posts = append(snapshot.Posts, record)
+	}
+
+	sort.Slice(snapshot.Posts, func(i, j int) bool {
+		a, b := snapshot.Posts[i], snapshot.Posts[j]
+		if a.FeedURL != b.FeedURL {
+			return a.FeedURL < b.FeedURL
+		}
+		return a.GUID < b.GUID
+	})
+	sort.Slice(snapshot.LatestPublished, func(i, j int) bool {
+		return snapshot.LatestPublished[i].FeedURL < snapshot.LatestPublished[j].FeedURL
+	})
+	sort.Slice(snapshot.FirstSeen, func(i, j int) bool {
+		a, b := snapshot.FirstSeen[i], snapshot.FirstSeen[j]
+		if a.FeedURL != b.FeedURL {
+			return a.FeedURL < b.FeedURL
+		}
+		return a.GUID < b.GUID
+	})
+	sort.Slice(snapshot.RecentStories, func(i, j int) bool {
+		a, b := snapshot.RecentStories[i], snapshot.RecentStories[j]
+		if a.Key != b.Key {
+			return a.Key < b.Key
+		}
+		return a.FeedURL < b.FeedURL
+	})
+	return snapshot, nil
+}
+
+func (c *memoryCache) ImportState(ctx context.Context, snapshot *entity.CacheSnapshot) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	importedAt := time.Now()
+	for _, latest := range snapshot.LatestPublished {
+		if current, ok := c.latestPublished[latest.FeedURL]; !ok || latest.PublishedAt.After(current) {
+			c.latestPublished[latest.FeedURL] = latest.PublishedAt
+		}
+	}
+	for _, processed := range snapshot.Processed {
+		c.addProcessed(processed.FeedURL, processed.GUID, processed.CanonicalKey, processed.ProcessedAt)
+	}
+	for _, firstSeen := range snapshot.FirstSeen {
+		key := feedItemKey{feedURL: firstSeen.FeedURL, value: firstSeen.GUID}
+		// 取り込んだ初回検出日時は、取り込んだ時点でフィードに残っているものとして扱う
+		current, ok := c.firstSeen[key]
+		if !ok {
+			c.firstSeen[key] = &firstSeenEntry{seenAt: firstSeen.SeenAt, lastSeenAt: importedAt}
+		} else if firstSeen.SeenAt.Before(current.seenAt) {
+			current.seenAt = firstSeen.SeenAt
+		}
+	}
+	for _, story := range snapshot.RecentStories {
+		feeds, ok := c.recentStories[story.Key]
+		if !ok {
+			feeds = make(map[string]time.Time)
+			c.recentStories[story.Key] = feeds
+		}
+		if current, ok := feeds[story.FeedURL]; !ok || story.PostedAt.After(current) {
+			feeds[story.FeedURL] = story.PostedAt
+		}
+	}
+	for _, record := range snapshot.Posts {
+		key := feedItemKey{feedURL: record.FeedURL, value: record.GUID}
+		if current, ok := c.postStates[key]; !ok || record.UpdatedAt.After(current.UpdatedAt) {
+			c.postStates[key] = record
+		}
+	}
+	return nil
+}
+
+func (c *memoryCache) AddTokenUsage(ctx context.Context, day time.Time, usage entity.TokenUsage, cost float64) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := usageKey{day: usageDayKey(day), provider: usage.Provider, model: usage.Model}
+	total := c.usage[key]
+	total.Day = usageDay(day)
+	total.Provider = usage.Provider
+	total.Model = usage.Model
+	total.InputTokens += usage.InputTokens
+	total.OutputTokens += usage.OutputTokens
+	total.Cost += cost
+	c.usage[key] = total
+	return nil
+}
+
+func (c *memoryCache) GetDailyUsage(ctx context.Context, day time.Time) ([]entity.DailyUsage, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	dayKey := usageDayKey(day)
+	var usages []entity.DailyUsage
+	for key, usage := range c.usage {
+		if key.day == dayKey {
+			usages = append(usages, usage)
+		}
+	}
+	sort.Slice(usages, func(i, j int) bool {
+		if usages[i].Provider != usages[j].Provider {
+			return usages[i].Provider < usages[j].Provider
+		}
+		return usages[i].Model < usages[j].Model
+	})
+	return usages, nil
+}
@@ -4,85 +4,105 @@ import (
 	"context"
 	"testing"
 	"time"
+
+	"misskeyRSSbot/internal/domain/repository"
+	"misskeyRSSbot/internal/infrastructure/storage/cachetest"
 )
 
-func TestMemoryCache_LatestPublishedTime(t *testing.T) {
-	cache := NewMemoryCacheRepository()
-	ctx := context.Background()
+const testFeedURL = "https://example.tld/rss"
 
-	rssURL := "https://example.tld/rss"
+func TestMemoryCache_Conformance(t *testing.T) {
+	cachetest.Run(t, func(t *testing.T) repository.CacheRepository {
+		return NewMemoryCacheRepository(0)
+	})
+}
 
-	latest, err := cache.GetLatestPublishedTime(ctx, rssURL)
-	if err != nil {
-		t.Fatalf("unexpected error: %v", err)
+func TestMemoryCache_EvictsLeastRecentlyUsed(t *testing.T) {
+	cache := NewMemoryCacheRepository(3).(*memoryCache)
+	ctx := context.Background()
+
+	for _, guid := range []string{"1", "2", "3"} {
+		if err := cache.MarkAsProcessed(ctx, testFeedURL, guid, "https://example.tld/"+guid); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
 	}
-	if !latest.IsZero() {
-		t.Errorf("expected zero time, got %v", latest)
+	// フィードに残っているGUIDは毎回参照されるため削除されない
+	if processed, _ := cache.IsProcessed(ctx, testFeedURL, "1", ""); !processed {
+		t.Fatal("expected GUID 1 to be processed")
 	}
-
-	now := time.Now()
-	err = cache.SaveLatestPublishedTime(ctx, rssURL, now)
-	if err != nil {
+	if err := cache.MarkAsProcessed(ctx, testFeedURL, "4", "https://example.tld/4"); err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
 
-	latest, err = cache.GetLatestPublishedTime(ctx, rssURL)
-	if err != nil {
-		t.Fatalf("unexpected error: %v", err)
+	tests := []struct {
+		guid string
+		want bool
+	}{
+		{"1", true},
+		{"2", false},
+		{"3", true},
+		{"4", true},
+	}
+	for _, tt := range tests {
+		got, err := cache.IsProcessed(ctx, testFeedURL, "other", "https://example.tld/"+tt.guid)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got != tt.want {
+			t.Errorf("GUID %s: expected processed=%v, got %v", tt.guid, tt.want, got)
+		}
 	}
-	if !latest.Equal(now) {
-		t.Errorf("expected %v, got %v", now, latest)
+	if len(cache.processedGUIDs) != 3 || len(cache.processedKeys) != 3 {
+		t.Errorf("expected 3 entries, got %d GUIDs and %d keys", len(cache.processedGUIDs), len(cache.processedKeys))
 	}
 }
 
-func TestMemoryCache_ProcessedGUIDs(t *testing.T) {
-	cache := NewMemoryCacheRepository()
+func TestMemoryCache_CleanupOldGUIDs(t *testing.T) {
+	cache := NewMemoryCacheRepository(0).(*memoryCache)
 	ctx := context.Background()
 
-	guid := "test-guid-123"
-
-	processed, err := cache.IsProcessed(ctx, guid)
-	if err != nil {
-		t.Fatalf("unexpected error: %v", err)
+	for guid, age := range map[string]time.Duration{
+		"old-guid-1": 48 * time.Hour,
+		"old-guid-2": 25 * time.Hour,
+		"new-guid-1": 1 * time.Hour,
+	} {
+		if err := cache.MarkAsProcessed(ctx, testFeedURL, guid, "https://example.tld/"+guid); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		elem := cache.processedGUIDs[feedItemKey{feedURL: testFeedURL, value: guid}]
+		elem.Value.(*processedEntry).processedAt = time.Now().Add(-age)
 	}
-	if processed {
-		t.Error("expected not processed, but was processed")
-	}
-
-	err = cache.MarkAsProcessed(ctx, guid)
-	if err != nil {
+	// 同じ正規化したキーのGUIDが残っている間はキーも残す
+	if err := cache.MarkAsProcessed(ctx, testFeedURL, "new-guid-2", "https://example.tld/old-guid-1"); err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
 
-	processed, err = cache.IsProcessed(ctx, guid)
+	deleted, err := cache.CleanupOldGUIDs(ctx, 24*time.Hour)
 	if err != nil {
-		t.Fatalf("unexpected error: %v", err)
+		t.Fatalf("cleanup failed: %v", err)
 	}
-	if !processed {
-		t.Error("expected processed, but was not processed")
+	if deleted != 2 {
+		t.Errorf("expected 2 deleted, got %d", deleted)
 	}
-}
-
-func TestMemoryCache_MultipleRSSURLs(t *testing.T) {
-	cache := NewMemoryCacheRepository()
-	ctx := context.Background()
-
-	url1 := "https://example.tld/rss1"
-	url2 := "https://example.tld/rss2"
-
-	time1 := time.Now()
-	time2 := time.Now().Add(1 * time.Hour)
-
-	_ = cache.SaveLatestPublishedTime(ctx, url1, time1)
-	_ = cache.SaveLatestPublishedTime(ctx, url2, time2)
-
-	got1, _ := cache.GetLatestPublishedTime(ctx, url1)
-	got2, _ := cache.GetLatestPublishedTime(ctx, url2)
 
-	if !got1.Equal(time1) {
-		t.Errorf("url1: expected %v, got %v", time1, got1)
+	tests := []struct {
+		guid         string
+		canonicalKey string
+		want         bool
+	}{
+		{guid: "old-guid-1", want: false},
+		{guid: "old-guid-2", want: false},
+		{guid: "new-guid-1", want: true},
+		{guid: "other", canonicalKey: "https://example.tld/old-guid-1", want: true},
+		{guid: "other", canonicalKey: "https://example.tld/old-guid-2", want: false},
 	}
-	if !got2.Equal(time2) {
-		t.Errorf("url2: expected %v, got %v", time2, got2)
+	for _, tt := range tests {
+		got, err := cache.IsProcessed(ctx, testFeedURL, tt.guid, tt.canonicalKey)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got != tt.want {
+			t.Errorf("%s %s: expected processed=%v, got %v", tt.guid, tt.canonicalKey, tt.want, got)
+		}
 	}
 }
@@ -0,0 +1,273 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"misskeyRSSbot/internal/domain/entity"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// migration はキャッシュのスキーマを1つ新しいバージョンに更新します
+// SQL を実行したあとに、SQL だけでは書けないデータの移行があれば afterSQL を同じトランザクションで実行します
+type migration struct {
+	version  int
+	name     string
+	sql      string
+	afterSQL func(ctx context.Context, tx *sql.Tx) error
+}
+
+// migrationHooks はバージョンごとの Go で書いたデータの移行です
+var migrationHooks = map[int]func(ctx context.Context, tx *sql.Tx) error{
+	4: backfillCanonicalKeys,
+}
+
+// loadMigrations は migrations/NNNN_name.sql をバージョン順に読み込みます
+func loadMigrations() ([]migration, error) {
+	return readMigrations(migrationFiles, "migrations", migrationHooks)
+}
+
+// readMigrations は fsys の dir/NNNN_name.sql をバージョン順に読み込みます
+func readMigrations(fsys fs.FS, dir string, hooks map[int]func(ctx context.Context, tx *sql.Tx) error) ([]migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations: %w", err)
+	}
+
+	var migrations []migration
+	for _, entry := range entries {
+		name := strings.TrimSuffix(entry.Name(), ".sql")
+		prefix, label, ok := strings.Cut(name, "_")
+		if !ok {
+			return nil, fmt.Errorf("invalid migration file name: %s", entry.Name())
+		}
+		version, err := strconv.Atoi(prefix)
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("invalid migration version: %s", entry.Name())
+		}
+		body, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %w", entry.Name(), err)
+		}
+		migrations = append(migrations, migration{
+			version:  version,
+			name:     label,
+			sql:      string(body),
+			afterSQL: hooks[version],
+		})
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].version < migrations[j].version
+	})
+	for i, m := range migrations {
+		if m.version != i+1 {
+			return nil, fmt.Errorf("migration versions must be sequential: expected %d, got %d", i+1, m.version)
+		}
+	}
+	return migrations, nil
+}
+
+// migrate は未適用のマイグレーションを順に適用します
+// 既存のデータベースを更新する前に dbPath のバックアップを作成し、各マイグレーションはバージョンの記録とともにトランザクションで適用します
+func migrate(ctx context.Context, db *sql.DB, dbPath string, migrations []migration) error {
+	if _, err := db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_version (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at INTEGER NOT NULL
+	)`); err != nil {
+		return fmt.Errorf("failed to create schema_version table: %w", err)
+	}
+
+	current, err := schemaVersion(ctx, db)
+	if err != nil {
+		return err
+	}
+	if current == 0 {
+		if current, err = adoptLegacySchema(ctx, db, migrations); err != nil {
+			return err
+		}
+	}
+
+	latest := len(migrations)
+	if current > latest {
+		return fmt.Errorf("database schema version %d is newer than supported version %d", current, latest)
+	}
+	if current == latest {
+		return nil
+	}
+
+	if current > 0 {
+		backupPath, err := backupDatabase(ctx, db, dbPath, current)
+		if err != nil {
+			return err
+		}
+		if backupPath != "" {
+			log.Printf("Backed up cache database to %s before migrating from version %d", backupPath, current)
+		}
+	}
+
+	for _, m := range migrations[current:] {
+		if err := applyMigration(ctx, db, m); err != nil {
+			return err
+		}
+		log.Printf("Applied cache schema migration %04d_%s", m.version, m.name)
+	}
+	return nil
+}
+
+func schemaVersion(ctx context.Context, db *sql.DB) (int, error) {
+	var version int
+	if err := db.QueryRowContext(ctx, "SELECT COALESCE(MAX(version), 0) FROM schema_version").Scan(&version); err != nil {
+		return 0, fmt.Errorf("failed to get schema version: %w", err)
+	}
+	return version, nil
+}
+
+func applyMigration(ctx context.Context, db *sql.DB, m migration) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin migration %d: %w", m.version, err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, m.sql); err != nil {
+		return fmt.Errorf("failed to apply migration %04d_%s: %w", m.version, m.name, err)
+	}
+	if m.afterSQL != nil {
+		if err := m.afterSQL(ctx, tx); err != nil {
+			return fmt.Errorf("failed to apply migration %04d_%s: %w", m.version, m.name, err)
+		}
+	}
+	if err := recordVersion(ctx, tx, m); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit migration %d: %w", m.version, err)
+	}
+	return nil
+}
+
+func recordVersion(ctx context.Context, tx *sql.Tx, m migration) error {
+	if _, err := tx.ExecContext(
+		ctx,
+		"INSERT INTO schema_version (version, name, applied_at) VALUES (?, ?, ?)",
+		m.version,
+		m.name,
+		time.Now().Unix(),
+	); err != nil {
+		return fmt.Errorf("failed to record schema version %d: %w", m.version, err)
+	}
+	return nil
+}
+
+// adoptLegacySchema は schema_version がない（バージョン管理を導入する前の）データベースのバージョンをテーブルの構成から判定して記録します
+// 空のデータベースの場合は 0 を返します
+func adoptLegacySchema(ctx context.Context, db *sql.DB, migrations []migration) (int, error) {
+	checks := []struct {
+		version int
+		query   string
+	}{
+		{6, "SELECT COUNT(*) FROM pragma_table_info('processed_guids') WHERE name = 'feed_url'"},
+		{5, "SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'recent_stories'"},
+		{4, "SELECT COUNT(*) FROM pragma_table_info('processed_guids') WHERE name = 'canonical_key'"},
+		{3, "SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'first_seen'"},
+		{2, "SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'llm_usage'"},
+		{1, "SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'processed_guids'"},
+	}
+
+	version := 0
+	for _, check := range checks {
+		var count int
+		if err := db.QueryRowContext(ctx, check.query).Scan(&count); err != nil {
+			return 0, fmt.Errorf("failed to inspect legacy schema: %w", err)
+		}
+		if count > 0 {
+			version = check.version
+			break
+		}
+	}
+	if version == 0 || version > len(migrations) {
+		return version, nil
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, m := range migrations[:version] {
+		if err := recordVersion(ctx, tx, m); err != nil {
+			return 0, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit legacy schema version: %w", err)
+	}
+	log.Printf("Detected unversioned cache schema, recorded as version %d", version)
+	return version, nil
+}
+
+// backupDatabase は VACUUM INTO でデータベースのコピーを dbPath と同じディレクトリに作成します
+// メモリ上のデータベースの場合は何もせず空文字を返します
+func backupDatabase(ctx context.Context, db *sql.DB, dbPath string, version int) (string, error) {
+	if dbPath == "" || dbPath == ":memory:" || strings.Contains(dbPath, "mode=memory") {
+		return "", nil
+	}
+
+	backupPath := fmt.Sprintf("%s.v%d-%s.bak", dbPath, version, time.Now().Format("20060102150405"))
+	if _, err := os.Stat(backupPath); err == nil {
+		return "", fmt.Errorf("backup file already exists: %s", backupPath)
+	}
+	if _, err := db.ExecContext(ctx, "VACUUM INTO ?", backupPath); err != nil {
+		return "", fmt.Errorf("failed to back up database before migration: %w", err)
+	}
+	return backupPath, nil
+}
+
+// backfillCanonicalKeys は canonical_key 列を追加する前に処理済みだったGUIDから正規化したキーを設定します
+func backfillCanonicalKeys(ctx context.Context, tx *sql.Tx) error {
+	rows, err := tx.QueryContext(ctx, "SELECT guid FROM processed_guids WHERE canonical_key = ''")
+	if err != nil {
+		return fmt.Errorf("failed to read processed guids: %w", err)
+	}
+	var guids []string
+	for rows.Next() {
+		var guid string
+		if err := rows.Scan(&guid); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan processed guid: %w", err)
+		}
+		guids = append(guids, guid)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to iterate processed guids: %w", err)
+	}
+
+	for _, guid := range guids {
+		if _, err := tx.ExecContext(
+			ctx,
+			"UPDATE processed_guids SET canonical_key = ? WHERE guid = ?",
+			entity.CanonicalKey(guid),
+			guid,
+		); err != nil {
+			return fmt.Errorf("failed to backfill canonical key: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,310 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"misskeyRSSbot/internal/domain/repository"
+)
+
+func latestSchemaVersion(t *testing.T) int {
+	t.Helper()
+	migrations, err := loadMigrations()
+	if err != nil {
+		t.Fatalf("failed to load migrations: %v", err)
+	}
+	return len(migrations)
+}
+
+func readSchemaVersion(t *testing.T, dbPath string) int {
+	t.Helper()
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	version, err := schemaVersion(context.Background(), db)
+	if err != nil {
+		t.Fatalf("failed to read schema version: %v", err)
+	}
+	return version
+}
+
+func backupFiles(t *testing.T, dbPath string) []string {
+	t.Helper()
+	matches, err := filepath.Glob(dbPath + ".v*.bak")
+	if err != nil {
+		t.Fatalf("failed to list backups: %v", err)
+	}
+	return matches
+}
+
+func TestLoadMigrations(t *testing.T) {
+	migrations, err := loadMigrations()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(migrations) == 0 {
+		t.Fatal("expected embedded migrations")
+	}
+	for i, m := range migrations {
+		if m.version != i+1 || m.name == "" || strings.TrimSpace(m.sql) == "" {
+			t.Errorf("unexpected migration at %d: version=%d name=%q", i, m.version, m.name)
+		}
+	}
+	if migrations[3].afterSQL == nil {
+		t.Error("expected canonical key backfill hook on migration 4")
+	}
+}
+
+func TestSQLiteCache_NewDatabaseIsAtLatestVersion(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	cache, err := NewSQLiteCacheRepository(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create cache: %v", err)
+	}
+	closeSQLiteCache(t, cache)
+
+	if version := readSchemaVersion(t, dbPath); version != latestSchemaVersion(t) {
+		t.Errorf("expected version %d, got %d", latestSchemaVersion(t), version)
+	}
+	if backups := backupFiles(t, dbPath); len(backups) != 0 {
+		t.Errorf("expected no backup for a new database, got %v", backups)
+	}
+
+	// 最新のバージョンのデータベースを開き直してもマイグレーションやバックアップは行わない
+	cache, err = NewSQLiteCacheRepository(dbPath)
+	if err != nil {
+		t.Fatalf("failed to reopen cache: %v", err)
+	}
+	closeSQLiteCache(t, cache)
+	if backups := backupFiles(t, dbPath); len(backups) != 0 {
+		t.Errorf("expected no backup when already up to date, got %v", backups)
+	}
+}
+
+// lastUnversionedSchemaVersion は schema_version を導入する前の最後のバージョンです
+// これより新しいデータベースは TestSQLiteCache_UpgradeFromVersionedDatabases で確認します
+const lastUnversionedSchemaVersion = 6
+
+// TestSQLiteCache_UpgradeFromLegacyFixtures は schema_version を導入する前の各バージョンのデータベースを最新のバージョンに更新します
+func TestSQLiteCache_UpgradeFromLegacyFixtures(t *testing.T) {
+	latest := latestSchemaVersion(t)
+	for version := 1; version <= lastUnversionedSchemaVersion; version++ {
+		t.Run(fmt.Sprintf("v%d", version), func(t *testing.T) {
+			fixture, err := os.ReadFile(filepath.Join("testdata", "migrations", fmt.Sprintf("v%d.sql", version)))
+			if err != nil {
+				t.Fatalf("failed to read fixture: %v", err)
+			}
+			dbPath := filepath.Join(t.TempDir(), "test.db")
+			execSQL(t, dbPath, string(fixture))
+
+			cache, err := NewSQLiteCacheRepository(dbPath)
+			if err != nil {
+				t.Fatalf("failed to upgrade database: %v", err)
+			}
+			defer closeSQLiteCache(t, cache)
+
+			if got := readSchemaVersion(t, dbPath); got != latest {
+				t.Errorf("expected version %d, got %d", latest, got)
+			}
+			assertFixtureData(t, cache, version)
+
+			backups := backupFiles(t, dbPath)
+			if version == latest {
+				if len(backups) != 0 {
+					t.Errorf("expected no backup when already up to date, got %v", backups)
+				}
+				return
+			}
+			if len(backups) != 1 || !strings.Contains(backups[0], fmt.Sprintf(".v%d-", version)) {
+				t.Fatalf("expected a backup of version %d, got %v", version, backups)
+			}
+			if got := readSchemaVersion(t, backups[0]); got != version {
+				t.Errorf("expected backup at version %d, got %d", version, got)
+			}
+		})
+	}
+}
+
+// TestSQLiteCache_UpgradeFromVersionedDatabases はマイグレーションで作成した各バージョンのデータベースを最新のバージョンに更新します
+func TestSQLiteCache_UpgradeFromVersionedDatabases(t *testing.T) {
+	migrations, err := loadMigrations()
+	if err != nil {
+		t.Fatalf("failed to load migrations: %v", err)
+	}
+
+	for version := 1; version < len(migrations); version++ {
+		t.Run(fmt.Sprintf("v%d", version), func(t *testing.T) {
+			ctx := context.Background()
+			dbPath := filepath.Join(t.TempDir(), "test.db")
+			db, err := sql.Open("sqlite", dbPath)
+			if err != nil {
+				t.Fatalf("failed to open database: %v", err)
+			}
+			if err := migrate(ctx, db, dbPath, migrations[:version]); err != nil {
+				t.Fatalf("failed to create version %d: %v", version, err)
+			}
+			if _, err := db.Exec("INSERT INTO processed_guids (guid, processed_at) VALUES ('http://example.tld/1/?utm_source=rss', ?)", time.Now().Unix()); err != nil {
+				t.Fatalf("failed to insert row: %v", err)
+			}
+			db.Close()
+
+			cache, err := NewSQLiteCacheRepository(dbPath)
+			if err != nil {
+				t.Fatalf("failed to upgrade database: %v", err)
+			}
+			defer closeSQLiteCache(t, cache)
+
+			if got := readSchemaVersion(t, dbPath); got != len(migrations) {
+				t.Errorf("expected version %d, got %d", len(migrations), got)
+			}
+			processed, err := cache.IsProcessed(ctx, testFeedURL, "http://example.tld/1/?utm_source=rss", "")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !processed {
+				t.Error("expected existing GUID to remain processed")
+			}
+			if backups := backupFiles(t, dbPath); len(backups) != 1 {
+				t.Errorf("expected 1 backup, got %v", backups)
+			}
+		})
+	}
+}
+
+func TestMigrate_RollsBackFailedMigration(t *testing.T) {
+	ctx := context.Background()
+	migrations, err := loadMigrations()
+	if err != nil {
+		t.Fatalf("failed to load migrations: %v", err)
+	}
+
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close()
+	if err := migrate(ctx, db, dbPath, migrations); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	broken := append(append([]migration(nil), migrations...), migration{
+		version: len(migrations) + 1,
+		name:    "broken",
+		sql:     "CREATE TABLE broken_table (id INTEGER); INSERT INTO missing_table VALUES (1);",
+	})
+	if err := migrate(ctx, db, dbPath, broken); err == nil {
+		t.Fatal("expected error, got nil")
+	}
+
+	if version, err := schemaVersion(ctx, db); err != nil || version != len(migrations) {
+		t.Errorf("expected version %d to be kept, got %d (err=%v)", len(migrations), version, err)
+	}
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE name = 'broken_table'").Scan(&count); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if count != 0 {
+		t.Error("expected partial migration to be rolled back")
+	}
+}
+
+func TestMigrate_RejectsNewerDatabase(t *testing.T) {
+	ctx := context.Background()
+	migrations, err := loadMigrations()
+	if err != nil {
+		t.Fatalf("failed to load migrations: %v", err)
+	}
+
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close()
+	if err := migrate(ctx, db, dbPath, migrations); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := migrate(ctx, db, dbPath, migrations[:len(migrations)-1]); err == nil || !strings.Contains(err.Error(), "newer than supported") {
+		t.Fatalf("expected newer version error, got %v", err)
+	}
+}
+
+func execSQL(t *testing.T, dbPath, query string) {
+	t.Helper()
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close()
+	if _, err := db.Exec(query); err != nil {
+		t.Fatalf("failed to execute fixture: %v", err)
+	}
+}
+
+// assertFixtureData は testdata/migrations のフィクスチャのデータが更新後も残っていることを確認します
+func assertFixtureData(t *testing.T, cache repository.CacheRepository, version int) {
+	t.Helper()
+	ctx := context.Background()
+
+	latest, err := cache.GetLatestPublishedTime(ctx, testFeedURL)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if latest.Unix() != 1700000000 {
+		t.Errorf("expected latest published time to be kept, got %v", latest)
+	}
+
+	// バージョン4より前のGUIDは正規化したキーが設定される
+	processed, err := cache.IsProcessed(ctx, testFeedURL, "https://example.tld/1", "https://example.tld/1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !processed {
+		t.Error("expected existing GUID to remain processed")
+	}
+
+	if version >= 2 {
+		usageRepo, ok := cache.(repository.UsageRepository)
+		if !ok {
+			t.Fatal("expected cache to implement UsageRepository")
+		}
+		usages, err := usageRepo.GetDailyUsage(ctx, time.Date(2024, 5, 1, 0, 0, 0, 0, time.Local))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(usages) != 1 || usages[0].InputTokens != 100 {
+			t.Errorf("expected usage to be kept, got %+v", usages)
+		}
+	}
+
+	if version >= 3 {
+		seen, isNew, err := cache.RecordFirstSeen(ctx, testFeedURL, "guid-undated", time.Now())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if isNew || seen.Unix() != 1700000100 {
+			t.Errorf("expected first seen time to be kept, got %v (new=%v)", seen, isNew)
+		}
+	}
+
+	if version >= 5 {
+		posted, err := cache.HasRecentStory(ctx, "https://other.tld/rss", []string{"url:https://example.tld/1"}, time.Unix(0, 0))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !posted {
+			t.Error("expected recent story to be kept")
+		}
+	}
+}
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS latest_published (
+	rss_url TEXT PRIMARY KEY,
+	published_at INTEGER NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS processed_guids (
+	guid TEXT PRIMARY KEY,
+	processed_at INTEGER NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_processed_guids_processed_at ON processed_guids(processed_at);
@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS llm_usage (
+	day TEXT NOT NULL,
+	provider TEXT NOT NULL,
+	model TEXT NOT NULL,
+	input_tokens INTEGER NOT NULL,
+	output_tokens INTEGER NOT NULL,
+	cost REAL NOT NULL,
+	PRIMARY KEY (day, provider, model)
+);
@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS first_seen (
+	guid TEXT PRIMARY KEY,
+	seen_at INTEGER NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_first_seen_seen_at ON first_seen(seen_at);
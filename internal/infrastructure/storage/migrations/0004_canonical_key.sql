@@ -0,0 +1,4 @@
+-- 既存の行の canonical_key は Go のマイグレーションで GUID から設定する
+ALTER TABLE processed_guids ADD COLUMN canonical_key TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS idx_processed_guids_canonical_key ON processed_guids(canonical_key);
@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS recent_stories (
+	story_key TEXT NOT NULL,
+	feed_url TEXT NOT NULL,
+	posted_at INTEGER NOT NULL,
+	PRIMARY KEY (story_key, feed_url)
+);
+
+CREATE INDEX IF NOT EXISTS idx_recent_stories_posted_at ON recent_stories(posted_at);
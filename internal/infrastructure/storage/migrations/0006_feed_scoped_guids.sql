@@ -0,0 +1,35 @@
+-- processed_guids と first_seen を (feed_url, guid) を主キーとするテーブルに作り直す
+-- 既存の行は feed_url を空にして移行し、すべてのフィードに適用する
+ALTER TABLE processed_guids RENAME TO processed_guids_legacy;
+
+CREATE TABLE processed_guids (
+	feed_url TEXT NOT NULL DEFAULT '',
+	guid TEXT NOT NULL,
+	processed_at INTEGER NOT NULL,
+	canonical_key TEXT NOT NULL DEFAULT '',
+	PRIMARY KEY (feed_url, guid)
+);
+
+INSERT INTO processed_guids (feed_url, guid, processed_at, canonical_key)
+SELECT '', guid, processed_at, canonical_key FROM processed_guids_legacy;
+
+DROP TABLE processed_guids_legacy;
+
+CREATE INDEX idx_processed_guids_processed_at ON processed_guids(processed_at);
+CREATE INDEX idx_processed_guids_feed_canonical_key ON processed_guids(feed_url, canonical_key);
+
+ALTER TABLE first_seen RENAME TO first_seen_legacy;
+
+CREATE TABLE first_seen (
+	feed_url TEXT NOT NULL DEFAULT '',
+	guid TEXT NOT NULL,
+	seen_at INTEGER NOT NULL,
+	PRIMARY KEY (feed_url, guid)
+);
+
+INSERT INTO first_seen (feed_url, guid, seen_at)
+SELECT '', guid, seen_at FROM first_seen_legacy;
+
+DROP TABLE first_seen_legacy;
+
+CREATE INDEX idx_first_seen_seen_at ON first_seen(seen_at);
@@ -0,0 +1,7 @@
+-- 初回検出日時の行は、エントリーがフィードから消えてから保持期間が過ぎるまで残す
+-- last_seen_at はエントリーを最後にフィードで検出した日時で、既存の行は初回検出日時から始める
+ALTER TABLE first_seen ADD COLUMN last_seen_at INTEGER NOT NULL DEFAULT 0;
+
+UPDATE first_seen SET last_seen_at = seen_at;
+
+CREATE INDEX idx_first_seen_last_seen_at ON first_seen(last_seen_at);
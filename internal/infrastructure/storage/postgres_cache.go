@@ -0,0 +1,430 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"misskeyRSSbot/internal/domain/entity"
+	"misskeyRSSbot/internal/domain/repository"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+)
+
+//go:embed postgres_migrations/*.sql
+var postgresMigrationFiles embed.FS
+
+// postgresMigrationLockKey は複数のレプリカが同時にマイグレーションしないように取得するアドバイザリーロックのキーです
+const postgresMigrationLockKey int64 = 0x6d6b7273737362 // "mkrsssb"
+
+type postgresCache struct {
+	db *sql.DB
+}
+
+// NewPostgresCacheRepository は dsn（postgres://...）の PostgreSQL をキャッシュに使います
+// 複数のレプリカから同じデータベースを共有できます
+func NewPostgresCacheRepository(dsn string) (repository.CacheRepository, error) {
+	db, err := sql.Open("pgx", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open postgres database: %w", err)
+	}
+
+	db.SetMaxOpenConns(4)
+	db.SetMaxIdleConns(2)
+	db.SetConnMaxLifetime(time.Hour)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to connect to postgres database: %w", err)
+	}
+
+	cache := &postgresCache{db: db}
+	if err := cache.initSchema(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialize schema: %w", err)
+	}
+
+	return cache, nil
+}
+
+// initSchema は埋め込みのマイグレーションでスキーマを最新のバージョンに更新します
+func (c *postgresCache) initSchema(ctx context.Context) error {
+	migrations, err := readMigrations(postgresMigrationFiles, "postgres_migrations", nil)
+	if err != nil {
+		return err
+	}
+	return migratePostgres(ctx, c.db, migrations)
+}
+
+// migratePostgres は未適用のマイグレーションを1つのトランザクションで適用します
+// PostgreSQL は DDL もロールバックできるため、失敗した場合は元のバージョンのまま残ります
+// 同時に起動したレプリカはアドバイザリーロックで待たせ、ロックを取得したあとにバージョンを確認します
+func migratePostgres(ctx context.Context, db *sql.DB, migrations []migration) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin migration: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock($1)", postgresMigrationLockKey); err != nil {
+		return fmt.Errorf("failed to acquire migration lock: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_version (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at BIGINT NOT NULL
+	)`); err != nil {
+		return fmt.Errorf("failed to create schema_version table: %w", err)
+	}
+
+	var current int
+	if err := tx.QueryRowContext(ctx, "SELECT COALESCE(MAX(version), 0) FROM schema_version").Scan(&current); err != nil {
+		return fmt.Errorf("failed to get schema version: %w", err)
+	}
+
+	latest := len(migrations)
+	if current > latest {
+		return fmt.Errorf("database schema version %d is newer than supported version %d", current, latest)
+	}
+	if current == latest {
+		return nil
+	}
+
+	for _, m := range migrations[current:] {
+		if _, err := tx.ExecContext(ctx, m.sql); err != nil {
+			return fmt.Errorf("failed to apply migration %04d_%s: %w", m.version, m.name, err)
+		}
+		if m.afterSQL != nil {
+			if err := m.afterSQL(ctx, tx); err != nil {
+				return fmt.Errorf("failed to apply migration %04d_%s: %w", m.version, m.name, err)
+			}
+		}
+		if _, err := tx.ExecContext(
+			ctx,
+			"INSERT INTO schema_version (version, name, applied_at) VALUES ($1, $2, $3)",
+			m.version,
+			m.name,
+			time.Now().Unix(),
+		); err != nil {
+			return fmt.Errorf("failed to record schema version %d: %w", m.version, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit migration: %w", err)
+	}
+	for _, m := range migrations[current:] {
+		log.Printf("Applied cache schema migration %04d_%s", m.version, m.name)
+	}
+	return nil
+}
+
+func (c *postgresCache) GetLatestPublishedTime(ctx context.Context, rssURL string) (time.Time, error) {
+	var unixTime int64
+	err := c.db.QueryRowContext(
+		ctx,
+		"SELECT published_at FROM latest_published WHERE rss_url = $1",
+		rssURL,
+	).Scan(&unixTime)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to get latest published time: %w", err)
+	}
+
+	return time.Unix(unixTime, 0), nil
+}
+
+func (c *postgresCache) SaveLatestPublishedTime(ctx context.Context, rssURL string, published time.Time) error {
+	_, err := c.db.ExecContext(
+		ctx,
+		`INSERT INTO latest_published (rss_url, published_at) VALUES ($1, $2)
+		ON CONFLICT (rss_url) DO UPDATE SET published_at = EXCLUDED.published_at
+		WHERE latest_published.published_at < EXCLUDED.published_at`,
+		rssURL,
+		published.Unix(),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save latest published time: %w", err)
+	}
+
+	return nil
+}
+
+func (c *postgresCache) IsProcessed(ctx context.Context, feedURL, guid, canonicalKey string) (bool, error) {
+	var exists int
+	err := c.db.QueryRowContext(
+		ctx,
+		`SELECT 1 FROM processed_guids
+		WHERE feed_url IN ($1, '') AND (guid = $2 OR ($3 <> '' AND canonical_key = $3)) LIMIT 1`,
+		feedURL,
+		guid,
+		canonicalKey,
+	).Scan(&exists)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to check if processed: %w", err)
+	}
+
+	return true, nil
+}
+
+func (c *postgresCache) MarkAsProcessed(ctx context.Context, feedURL, guid, canonicalKey string) error {
+	_, err := c.db.ExecContext(
+		ctx,
+		`INSERT INTO processed_guids (feed_url, guid, processed_at, canonical_key) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (feed_url, guid) DO NOTHING`,
+		feedURL,
+		guid,
+		time.Now().Unix(),
+		canonicalKey,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to mark as processed: %w", err)
+	}
+
+	return nil
+}
+
+func (c *postgresCache) RecordFirstSeen(ctx context.Context, feedURL, guid string, seen time.Time) (time.Time, bool, error) {
+	firstSeen, found, err := c.getFirstSeen(ctx, feedURL, guid)
+	if err != nil {
+		return firstSeen, false, err
+	}
+	if found {
+		// フィードに残っている間は掃除で削除しないように、最後に検出した日時を更新する
+		_, err := c.db.ExecContext(
+			ctx,
+			"UPDATE first_seen SET last_seen_at = $1 WHERE feed_url IN ($2, '') AND guid = $3 AND last_seen_at < $1",
+			seen.Unix(),
+			feedURL,
+			guid,
+		)
+		if err != nil {
+			return firstSeen, false, fmt.Errorf("failed to update last seen time: %w", err)
+		}
+		return firstSeen, false, nil
+	}
+
+	// 他のレプリカと同時に記録しても先に記録した日時を返すため、挿入できなければ既存の行を読む
+	result, err := c.db.ExecContext(
+		ctx,
+		`INSERT INTO first_seen (feed_url, guid, seen_at, last_seen_at) VALUES ($1, $2, $3, $3)
+		ON CONFLICT (feed_url, guid) DO NOTHING`,
+		feedURL,
+		guid,
+		seen.Unix(),
+	)
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("failed to record first seen time: %w", err)
+	}
+	inserted, err := result.RowsAffected()
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if inserted > 0 {
+		return time.Unix(seen.Unix(), 0), true, nil
+	}
+
+	firstSeen, _, err = c.getFirstSeen(ctx, feedURL, guid)
+	return firstSeen, false, err
+}
+
+// getFirstSeen は feedURL の guid の初回検出日時を返します
+// 取り込んだ移行前の行（feed_url が空）があればそれを初回検出日時とする
+func (c *postgresCache) getFirstSeen(ctx context.Context, feedURL, guid string) (time.Time, bool, error) {
+	var unixTime int64
+	err := c.db.QueryRowContext(
+		ctx,
+		"SELECT seen_at FROM first_seen WHERE feed_url IN ($1, '') AND guid = $2 ORDER BY feed_url DESC LIMIT 1",
+		feedURL,
+		guid,
+	).Scan(&unixTime)
+	if errors.Is(err, sql.ErrNoRows) {
+		return time.Time{}, false, nil
+	}
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("failed to get first seen time: %w", err)
+	}
+	return time.Unix(unixTime, 0), true, nil
+}
+
+func (c *postgresCache) HasRecentStory(ctx context.Context, feedURL string, keys []string, since time.Time) (bool, error) {
+	for _, key := range keys {
+		var exists int
+		err := c.db.QueryRowContext(
+			ctx,
+			"SELECT 1 FROM recent_stories WHERE story_key = $1 AND feed_url <> $2 AND posted_at >= $3 LIMIT 1",
+			key,
+			feedURL,
+			since.Unix(),
+		).Scan(&exists)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return false, fmt.Errorf("failed to check recent story: %w", err)
+		}
+		return true, nil
+	}
+	return false, nil
+}
+
+func (c *postgresCache) SaveRecentStory(ctx context.Context, feedURL string, keys []string, postedAt time.Time) error {
+	for _, key := range keys {
+		_, err := c.db.ExecContext(
+			ctx,
+			`INSERT INTO recent_stories (story_key, feed_url, posted_at) VALUES ($1, $2, $3)
+			ON CONFLICT (story_key, feed_url) DO UPDATE SET posted_at = EXCLUDED.posted_at`,
+			key,
+			feedURL,
+			postedAt.Unix(),
+		)
+		if err != nil {
+			return fmt.Errorf("failed to save recent story: %w", err)
+		}
+	}
+	return nil
+}
+
+func (c *postgresCache) Close() error {
+	return c.db.Close()
+}
+
+// CleanupOldGUIDs は olderThan より前に記録した行を削除します
+// 初回検出日時はフィードで最後に検出してから olderThan が過ぎた行だけを削除し、
+// 残した初回検出日時のエントリーの処理済みのGUIDも残す（日時のないエントリーを再び投稿しないため）
+func (c *postgresCache) CleanupOldGUIDs(ctx context.Context, olderThan time.Duration) (int64, error) {
+	cutoff := time.Now().Add(-olderThan).Unix()
+
+	var total int64
+	for _, cleanup := range []struct {
+		query string
+		label string
+	}{
+		{"DELETE FROM first_seen WHERE last_seen_at < $1", "first seen times"},
+		{cleanupProcessedGUIDsQuery, "GUIDs"},
+		{"DELETE FROM recent_stories WHERE posted_at < $1", "recent stories"},
+		// 投稿中の記録は起動時に投稿履歴と照合するまで残す
+		{"DELETE FROM post_states WHERE updated_at < $1 AND state <> 'posting'", "post states"},
+	} {
+		result, err := c.db.ExecContext(ctx, cleanup.query, cutoff)
+		if err != nil {
+			return 0, fmt.Errorf("failed to cleanup old %s: %w", cleanup.label, err)
+		}
+		deleted, err := result.RowsAffected()
+		if err != nil {
+			return 0, fmt.Errorf("failed to get rows affected: %w", err)
+		}
+		total += deleted
+	}
+
+	return total, nil
+}
+
+func (c *postgresCache) SavePostState(ctx context.Context, record *entity.PostRecord) error {
+	return savePostState(ctx, c.db, record)
+}
+
+func (c *postgresCache) CompletePost(ctx context.Context, record *entity.PostRecord) error {
+	return completePost(ctx, c.db, record)
+}
+
+func (c *postgresCache) ListPostStates(ctx context.Context, state entity.PostState) ([]*entity.PostRecord, error) {
+	return listPostStates(ctx, c.db, state)
+}
+
+func (c *postgresCache) ListUnfinishedPosts(ctx context.Context, feedURL string) ([]*entity.PostRecord, error) {
+	return listUnfinishedPosts(ctx, c.db, feedURL)
+}
+
+func (c *postgresCache) AcquireLease(ctx context.Context, name, holder string, ttl time.Duration) (entity.Lease, bool, error) {
+	return acquireLease(ctx, c.db, name, holder, ttl)
+}
+
+func (c *postgresCache) ReleaseLease(ctx context.Context, name, holder string) error {
+	return releaseLease(ctx, c.db, name, holder)
+}
+
+func (c *postgresCache) SaveWebSubSubscription(ctx context.Context, sub entity.WebSubSubscription) error {
+	return saveWebSubSubscription(ctx, c.db, sub)
+}
+
+func (c *postgresCache) GetWebSubSubscription(ctx context.Context, id string) (entity.WebSubSubscription, bool, error) {
+	return getWebSubSubscription(ctx, c.db, id)
+}
+
+func (c *postgresCache) ListWebSubSubscriptions(ctx context.Context) ([]entity.WebSubSubscription, error) {
+	return listWebSubSubscriptions(ctx, c.db)
+}
+
+func (c *postgresCache) DeleteWebSubSubscription(ctx context.Context, id string) error {
+	return deleteWebSubSubscription(ctx, c.db, id)
+}
+
+func (c *postgresCache) ExportState(ctx context.Context) (*entity.CacheSnapshot, error) {
+	return exportSQLState(ctx, c.db)
+}
+
+func (c *postgresCache) ImportState(ctx context.Context, snapshot *entity.CacheSnapshot) error {
+	return importSQLState(ctx, c.db, snapshot)
+}
+
+func (c *postgresCache) AddTokenUsage(ctx context.Context, day time.Time, usage entity.TokenUsage, cost float64) error {
+	_, err := c.db.ExecContext(
+		ctx,
+		`INSERT INTO llm_usage (day, provider, model, input_tokens, output_tokens, cost) VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (day, provider, model) DO UPDATE SET
+			input_tokens = llm_usage.input_tokens + EXCLUDED.input_tokens,
+			output_tokens = llm_usage.output_tokens + EXCLUDED.output_tokens,
+			cost = llm_usage.cost + EXCLUDED.cost`,
+		usageDayKey(day),
+		usage.Provider,
+		usage.Model,
+		usage.InputTokens,
+		usage.OutputTokens,
+		cost,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to add token usage: %w", err)
+	}
+	return nil
+}
+
+func (c *postgresCache) GetDailyUsage(ctx context.Context, day time.Time) ([]entity.DailyUsage, error) {
+	rows, err := c.db.QueryContext(
+		ctx,
+		`SELECT provider, model, input_tokens, output_tokens, cost FROM llm_usage
+		WHERE day = $1 ORDER BY provider, model`,
+		usageDayKey(day),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get daily usage: %w", err)
+	}
+	defer rows.Close()
+
+	var usages []entity.DailyUsage
+	for rows.Next() {
+		usage := entity.DailyUsage{Day: usageDay(day)}
+		if err := rows.Scan(&usage.Provider, &usage.Model, &usage.InputTokens, &usage.OutputTokens, &usage.Cost); err != nil {
+			return nil, fmt.Errorf("failed to scan daily usage: %w", err)
+		}
+		usages = append(usages, usage)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate daily usage: %w", err)
+	}
+	return usages, nil
+}
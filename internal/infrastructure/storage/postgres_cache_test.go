@@ -0,0 +1,189 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"misskeyRSSbot/internal/domain/repository"
+	"misskeyRSSbot/internal/infrastructure/storage/cachetest"
+)
+
+func TestPostgresCache_Conformance(t *testing.T) {
+	cachetest.Run(t, func(t *testing.T) repository.CacheRepository {
+		return newTestPostgresCache(t)
+	})
+}
+
+func TestPostgresCache_CleanupOldGUIDs(t *testing.T) {
+	cache := newTestPostgresCache(t)
+	ctx := context.Background()
+
+	for guid, age := range map[string]time.Duration{
+		"old-guid-1": 48 * time.Hour,
+		"old-guid-2": 25 * time.Hour,
+		"new-guid-1": 1 * time.Hour,
+	} {
+		if _, err := cache.db.ExecContext(ctx,
+			"INSERT INTO processed_guids (feed_url, guid, processed_at) VALUES ($1, $2, $3)",
+			testFeedURL, guid, time.Now().Add(-age).Unix()); err != nil {
+			t.Fatalf("failed to insert %s: %v", guid, err)
+		}
+	}
+	if _, _, err := cache.RecordFirstSeen(ctx, testFeedURL, "old-guid-1", time.Now().Add(-48*time.Hour)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	deleted, err := cache.CleanupOldGUIDs(ctx, 24*time.Hour)
+	if err != nil {
+		t.Fatalf("cleanup failed: %v", err)
+	}
+	if deleted != 3 {
+		t.Errorf("expected 3 deleted, got %d", deleted)
+	}
+
+	for guid, want := range map[string]bool{"old-guid-1": false, "old-guid-2": false, "new-guid-1": true} {
+		processed, err := cache.IsProcessed(ctx, testFeedURL, guid, "")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if processed != want {
+			t.Errorf("%s: expected processed=%v, got %v", guid, want, processed)
+		}
+	}
+}
+
+func TestPostgresCache_LeaseSharedBetweenReplicas(t *testing.T) {
+	dsn := postgresTestDSN(t)
+	ctx := context.Background()
+
+	replicas := make([]*postgresCache, 2)
+	for i := range replicas {
+		repo, err := NewPostgresCacheRepository(dsn)
+		if err != nil {
+			t.Fatalf("failed to open replica %d: %v", i, err)
+		}
+		replicas[i] = repo.(*postgresCache)
+		t.Cleanup(func() { replicas[i].Close() })
+	}
+
+	var wg sync.WaitGroup
+	acquired := make([]bool, len(replicas))
+	for i, replica := range replicas {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, ok, err := replica.AcquireLease(ctx, "feed-processing", fmt.Sprintf("replica-%d", i), time.Minute)
+			if err != nil {
+				t.Errorf("replica %d: unexpected error: %v", i, err)
+			}
+			acquired[i] = ok
+		}()
+	}
+	wg.Wait()
+
+	if acquired[0] == acquired[1] {
+		t.Errorf("expected exactly one replica to acquire the lease, got %v", acquired)
+	}
+}
+
+func TestPostgresCache_PersistenceAndMigrationVersion(t *testing.T) {
+	dsn := postgresTestDSN(t)
+	ctx := context.Background()
+	published := time.Now().Truncate(time.Second)
+
+	// 複数のレプリカが同時に起動してもマイグレーションは1回だけ適用される
+	caches := make([]*postgresCache, 3)
+	errs := make([]error, len(caches))
+	var wg sync.WaitGroup
+	for i := range caches {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			cache, err := NewPostgresCacheRepository(dsn)
+			if err == nil {
+				caches[i] = cache.(*postgresCache)
+			}
+			errs[i] = err
+		}(i)
+	}
+	wg.Wait()
+	for i, err := range errs {
+		if err != nil {
+			t.Fatalf("replica %d failed to start: %v", i, err)
+		}
+	}
+
+	if err := caches[0].SaveLatestPublishedTime(ctx, testFeedURL, published); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := caches[1].MarkAsProcessed(ctx, testFeedURL, "persistent-guid", ""); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var versions int
+	if err := caches[2].db.QueryRowContext(ctx, "SELECT COUNT(*) FROM schema_version").Scan(&versions); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	migrations, err := readMigrations(postgresMigrationFiles, "postgres_migrations", nil)
+	if err != nil {
+		t.Fatalf("failed to load migrations: %v", err)
+	}
+	if versions != len(migrations) {
+		t.Errorf("expected %d schema versions, got %d", len(migrations), versions)
+	}
+	for _, cache := range caches {
+		if err := cache.Close(); err != nil {
+			t.Errorf("failed to close cache: %v", err)
+		}
+	}
+
+	reopened, err := NewPostgresCacheRepository(dsn)
+	if err != nil {
+		t.Fatalf("failed to reopen cache: %v", err)
+	}
+	defer reopened.(*postgresCache).Close()
+
+	latest, err := reopened.GetLatestPublishedTime(ctx, testFeedURL)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !latest.Equal(published) {
+		t.Errorf("expected %v, got %v", published, latest)
+	}
+	processed, err := reopened.IsProcessed(ctx, testFeedURL, "persistent-guid", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !processed {
+		t.Error("expected processed after reconnect, but was not processed")
+	}
+}
+
+func TestPostgresCache_RejectsNewerDatabase(t *testing.T) {
+	cache := newTestPostgresCache(t)
+	ctx := context.Background()
+
+	migrations, err := readMigrations(postgresMigrationFiles, "postgres_migrations", nil)
+	if err != nil {
+		t.Fatalf("failed to load migrations: %v", err)
+	}
+	if _, err := cache.db.ExecContext(ctx,
+		"INSERT INTO schema_version (version, name, applied_at) VALUES ($1, $2, $3)",
+		len(migrations)+1, "future", time.Now().Unix()); err != nil {
+		t.Fatalf("failed to insert version: %v", err)
+	}
+
+	if err := migratePostgres(ctx, cache.db, migrations); err == nil || !strings.Contains(err.Error(), "newer than supported") {
+		t.Fatalf("expected newer version error, got %v", err)
+	}
+}
+
+func TestPostgresCache_InvalidDSN(t *testing.T) {
+	if _, err := NewPostgresCacheRepository("postgres://bot@127.0.0.1:1/cache?sslmode=disable&connect_timeout=1"); err == nil {
+		t.Error("expected error for unreachable database, got nil")
+	}
+}
@@ -0,0 +1,45 @@
+-- PostgreSQL のキャッシュは SQLite の最新のスキーマ（バージョン6）と同じ構成で作成する
+-- 日時は SQLite と同じく Unix 時間（秒）で保存する
+CREATE TABLE IF NOT EXISTS latest_published (
+	rss_url TEXT PRIMARY KEY,
+	published_at BIGINT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS processed_guids (
+	feed_url TEXT NOT NULL,
+	guid TEXT NOT NULL,
+	processed_at BIGINT NOT NULL,
+	canonical_key TEXT NOT NULL DEFAULT '',
+	PRIMARY KEY (feed_url, guid)
+);
+
+CREATE INDEX IF NOT EXISTS idx_processed_guids_processed_at ON processed_guids(processed_at);
+CREATE INDEX IF NOT EXISTS idx_processed_guids_feed_canonical_key ON processed_guids(feed_url, canonical_key);
+
+CREATE TABLE IF NOT EXISTS first_seen (
+	feed_url TEXT NOT NULL,
+	guid TEXT NOT NULL,
+	seen_at BIGINT NOT NULL,
+	PRIMARY KEY (feed_url, guid)
+);
+
+CREATE INDEX IF NOT EXISTS idx_first_seen_seen_at ON first_seen(seen_at);
+
+CREATE TABLE IF NOT EXISTS recent_stories (
+	story_key TEXT NOT NULL,
+	feed_url TEXT NOT NULL,
+	posted_at BIGINT NOT NULL,
+	PRIMARY KEY (story_key, feed_url)
+);
+
+CREATE INDEX IF NOT EXISTS idx_recent_stories_posted_at ON recent_stories(posted_at);
+
+CREATE TABLE IF NOT EXISTS llm_usage (
+	day TEXT NOT NULL,
+	provider TEXT NOT NULL,
+	model TEXT NOT NULL,
+	input_tokens BIGINT NOT NULL,
+	output_tokens BIGINT NOT NULL,
+	cost DOUBLE PRECISION NOT NULL,
+	PRIMARY KEY (day, provider, model)
+);
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS post_states (
+	feed_url TEXT NOT NULL,
+	guid TEXT NOT NULL,
+	canonical_key TEXT NOT NULL DEFAULT '',
+	published_at BIGINT NOT NULL DEFAULT 0,
+	state TEXT NOT NULL,
+	note_text TEXT NOT NULL DEFAULT '',
+	updated_at BIGINT NOT NULL,
+	PRIMARY KEY (feed_url, guid)
+);
+
+CREATE INDEX IF NOT EXISTS idx_post_states_state ON post_states(state, updated_at);
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS websub_subscriptions (
+	id TEXT PRIMARY KEY,
+	feed_url TEXT NOT NULL,
+	hub TEXT NOT NULL,
+	topic TEXT NOT NULL,
+	secret TEXT NOT NULL,
+	state TEXT NOT NULL,
+	requested_at BIGINT NOT NULL,
+	expires_at BIGINT NOT NULL,
+	missed INTEGER NOT NULL DEFAULT 0
+);
@@ -0,0 +1,361 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgproto3"
+	"modernc.org/sqlite"
+)
+
+// postgresTestDSNEnv に PostgreSQL の DSN を設定すると、スタンドインの代わりに実際のサーバーでテストします
+// テストのたびにキャッシュのテーブルを削除するため、使い捨てのデータベースを指定してください
+const postgresTestDSNEnv = "CACHE_TEST_POSTGRES_DSN"
+
+// postgresTestTables はテストの前に削除するテーブルです
+var postgresTestTables = []string{"schema_version", "latest_published", "processed_guids", "first_seen", "recent_stories", "llm_usage", "post_states", "leases"}
+
+func init() {
+	// スタンドインはマイグレーションのアドバイザリーロックを何もしない関数として扱う
+	sqlite.MustRegisterScalarFunction("pg_advisory_xact_lock", 1, func(*sqlite.FunctionContext, []driver.Value) (driver.Value, error) {
+		return nil, nil
+	})
+}
+
+// postgresTestDSN はテスト用の PostgreSQL の DSN を返します
+// postgresTestDSNEnv が設定されていなければ、SQLite で SQL を実行する PostgreSQL ワイヤープロトコルのスタンドインを起動します
+// スタンドインは単純クエリプロトコルのみに対応するため、pgx にはパラメータをクライアント側で埋め込ませます
+func postgresTestDSN(t *testing.T) string {
+	t.Helper()
+	if dsn := os.Getenv(postgresTestDSNEnv); dsn != "" {
+		resetPostgres(t, dsn)
+		return dsn
+	}
+
+	server := startPostgresStandIn(t)
+	return fmt.Sprintf("postgres://bot@%s/cache?sslmode=disable&default_query_exec_mode=simple_protocol", server.addr)
+}
+
+func newTestPostgresCache(t *testing.T) *postgresCache {
+	t.Helper()
+	cache, err := NewPostgresCacheRepository(postgresTestDSN(t))
+	if err != nil {
+		t.Fatalf("failed to create cache: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := cache.(*postgresCache).Close(); err != nil {
+			t.Errorf("failed to close cache: %v", err)
+		}
+	})
+	return cache.(*postgresCache)
+}
+
+func resetPostgres(t *testing.T, dsn string) {
+	t.Helper()
+	db, err := sql.Open("pgx", dsn)
+	if err != nil {
+		t.Fatalf("failed to open postgres database: %v", err)
+	}
+	defer db.Close()
+	if _, err := db.Exec("DROP TABLE IF EXISTS " + strings.Join(postgresTestTables, ", ")); err != nil {
+		t.Fatalf("failed to reset postgres database: %v", err)
+	}
+}
+
+// postgresStandIn は PostgreSQL の単純クエリプロトコルを受け付け、SQL を SQLite で実行するテスト用のサーバーです
+// 接続をまたいでトランザクションが混ざらないよう、トランザクション中は他の接続の SQL を待たせます
+type postgresStandIn struct {
+	addr     string
+	listener net.Listener
+	conn     *sql.Conn
+	mu       sync.Mutex
+	wg       sync.WaitGroup
+}
+
+func startPostgresStandIn(t *testing.T) *postgresStandIn {
+	t.Helper()
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "postgres.db"))
+	if err != nil {
+		t.Fatalf("failed to open stand-in database: %v", err)
+	}
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		t.Fatalf("failed to connect stand-in database: %v", err)
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+
+	server := &postgresStandIn{addr: listener.Addr().String(), listener: listener, conn: conn}
+	server.wg.Add(1)
+	go server.serve()
+	t.Cleanup(func() {
+		listener.Close()
+		server.wg.Wait()
+		conn.Close()
+		db.Close()
+	})
+	return server
+}
+
+func (s *postgresStandIn) serve() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			defer conn.Close()
+			s.handle(conn)
+		}()
+	}
+}
+
+func (s *postgresStandIn) handle(conn net.Conn) {
+	backend := pgproto3.NewBackend(conn, conn)
+	if !s.startup(conn, backend) {
+		return
+	}
+
+	inTx := false
+	failed := false
+	defer func() {
+		if inTx {
+			_, _ = s.conn.ExecContext(context.Background(), "ROLLBACK")
+			s.mu.Unlock()
+		}
+	}()
+
+	for {
+		msg, err := backend.Receive()
+		if err != nil {
+			return
+		}
+		switch msg := msg.(type) {
+		case *pgproto3.Query:
+			command := leadingKeyword(msg.String)
+			if !inTx {
+				s.mu.Lock()
+			}
+			err := s.execute(backend, msg.String, command)
+			switch {
+			case command == "BEGIN" && err == nil:
+				inTx, failed = true, false
+			case command == "COMMIT" || command == "ROLLBACK" || command == "END":
+				inTx, failed = false, false
+			case inTx && err != nil:
+				failed = true
+			}
+			if !inTx {
+				s.mu.Unlock()
+			}
+			if err != nil {
+				backend.Send(&pgproto3.ErrorResponse{Severity: "ERROR", Code: "XX000", Message: err.Error()})
+			}
+			status := byte('I')
+			if inTx {
+				status = 'T'
+				if failed {
+					status = 'E'
+				}
+			}
+			backend.Send(&pgproto3.ReadyForQuery{TxStatus: status})
+		case *pgproto3.Terminate:
+			return
+		default:
+			backend.Send(&pgproto3.ErrorResponse{Severity: "ERROR", Code: "0A000", Message: fmt.Sprintf("stand-in does not support %T", msg)})
+			backend.Send(&pgproto3.ReadyForQuery{TxStatus: 'I'})
+		}
+		if err := backend.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+func (s *postgresStandIn) startup(conn net.Conn, backend *pgproto3.Backend) bool {
+	for {
+		msg, err := backend.ReceiveStartupMessage()
+		if err != nil {
+			return false
+		}
+		switch msg.(type) {
+		case *pgproto3.SSLRequest, *pgproto3.GSSEncRequest:
+			if _, err := conn.Write([]byte("N")); err != nil {
+				return false
+			}
+		case *pgproto3.StartupMessage:
+			backend.Send(&pgproto3.AuthenticationOk{})
+			for name, value := range map[string]string{
+				"server_version":              "16.0",
+				"server_encoding":             "UTF8",
+				"client_encoding":             "UTF8",
+				"standard_conforming_strings": "on",
+				"DateStyle":                   "ISO, MDY",
+				"integer_datetimes":           "on",
+			} {
+				backend.Send(&pgproto3.ParameterStatus{Name: name, Value: value})
+			}
+			backend.Send(&pgproto3.BackendKeyData{ProcessID: 1, SecretKey: 1})
+			backend.Send(&pgproto3.ReadyForQuery{TxStatus: 'I'})
+			return backend.Flush() == nil
+		default:
+			return false
+		}
+	}
+}
+
+// execute は query を SQLite で実行して結果を送ります
+func (s *postgresStandIn) execute(backend *pgproto3.Backend, query, command string) error {
+	ctx := context.Background()
+	switch command {
+	case "":
+		backend.Send(&pgproto3.EmptyQueryResponse{})
+		return nil
+	case "SELECT", "WITH":
+		return s.query(ctx, backend, query)
+	}
+
+	result, err := s.conn.ExecContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	tag := command
+	switch command {
+	case "INSERT", "UPDATE", "DELETE":
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if command == "INSERT" {
+			tag = fmt.Sprintf("INSERT 0 %d", affected)
+		} else {
+			tag = fmt.Sprintf("%s %d", command, affected)
+		}
+	}
+	backend.Send(&pgproto3.CommandComplete{CommandTag: []byte(tag)})
+	return nil
+}
+
+func (s *postgresStandIn) query(ctx context.Context, backend *pgproto3.Backend, query string) error {
+	rows, err := s.conn.QueryContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	columns, err := rows.ColumnTypes()
+	if err != nil {
+		return err
+	}
+	var values [][]any
+	for rows.Next() {
+		row := make([]any, len(columns))
+		dest := make([]any, len(columns))
+		for i := range row {
+			dest[i] = &row[i]
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return err
+		}
+		values = append(values, row)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	fields := make([]pgproto3.FieldDescription, len(columns))
+	for i, column := range columns {
+		fields[i] = pgproto3.FieldDescription{
+			Name:         []byte(column.Name()),
+			DataTypeOID:  standInTypeOID(column, values, i),
+			DataTypeSize: -1,
+			TypeModifier: -1,
+		}
+	}
+	backend.Send(&pgproto3.RowDescription{Fields: fields})
+	for _, row := range values {
+		encoded := make([][]byte, len(row))
+		for i, value := range row {
+			encoded[i] = encodeStandInValue(value)
+		}
+		backend.Send(&pgproto3.DataRow{Values: encoded})
+	}
+	backend.Send(&pgproto3.CommandComplete{CommandTag: []byte(fmt.Sprintf("SELECT %d", len(values)))})
+	return nil
+}
+
+// PostgreSQL の型のOID
+const (
+	standInOIDInt8   = 20
+	standInOIDText   = 25
+	standInOIDFloat8 = 701
+)
+
+// standInTypeOID は SQLite の値（値がなければ列の宣言）から列の型を決めます
+func standInTypeOID(column *sql.ColumnType, values [][]any, index int) uint32 {
+	for _, row := range values {
+		switch row[index].(type) {
+		case int64:
+			return standInOIDInt8
+		case float64:
+			return standInOIDFloat8
+		case string, []byte:
+			return standInOIDText
+		}
+	}
+	switch strings.ToUpper(column.DatabaseTypeName()) {
+	case "INTEGER", "BIGINT":
+		return standInOIDInt8
+	case "REAL", "DOUBLE PRECISION":
+		return standInOIDFloat8
+	}
+	return standInOIDText
+}
+
+func encodeStandInValue(value any) []byte {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case int64:
+		return []byte(strconv.FormatInt(v, 10))
+	case float64:
+		return []byte(strconv.FormatFloat(v, 'g', -1, 64))
+	case []byte:
+		return v
+	case string:
+		return []byte(v)
+	default:
+		return []byte(fmt.Sprint(v))
+	}
+}
+
+// leadingKeyword は query の先頭のコメントを除いた最初のキーワードを大文字で返します
+func leadingKeyword(query string) string {
+	for {
+		query = strings.TrimSpace(query)
+		if !strings.HasPrefix(query, "--") {
+			break
+		}
+		_, rest, found := strings.Cut(query, "\n")
+		if !found {
+			return ""
+		}
+		query = rest
+	}
+	keyword, _, _ := strings.Cut(query, " ")
+	keyword, _, _ = strings.Cut(keyword, "\n")
+	return strings.ToUpper(strings.TrimRight(keyword, ";"))
+}
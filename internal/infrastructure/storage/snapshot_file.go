@@ -0,0 +1,362 @@
+package storage
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"misskeyRSSbot/internal/domain/entity"
+)
+
+const (
+	// snapshotFormat はキャッシュを書き出したファイルであることを示す識別子です
+	snapshotFormat = "misskey-rss-bot-cache"
+	// snapshotVersion は書き出すファイルの形式のバージョンです
+	// 形式を変更した場合は上げ、古いバージョンのファイルも読めるようにします
+	snapshotVersion = 1
+)
+
+// SnapshotFormat はキャッシュを書き出すファイルの形式です
+type SnapshotFormat string
+
+const (
+	// SnapshotFormatJSON は1つのJSONドキュメントに書き出します
+	SnapshotFormatJSON SnapshotFormat = "json"
+	// SnapshotFormatNDJSON はヘッダーと記録を1行ずつ書き出します
+	SnapshotFormatNDJSON SnapshotFormat = "ndjson"
+)
+
+// ParseSnapshotFormat は format を SnapshotFormat に変換します
+func ParseSnapshotFormat(format string) (SnapshotFormat, error) {
+	switch SnapshotFormat(format) {
+	case SnapshotFormatJSON, SnapshotFormatNDJSON:
+		return SnapshotFormat(format), nil
+	default:
+		return "", fmt.Errorf("unsupported snapshot format %q (expected json or ndjson)", format)
+	}
+}
+
+// NDJSON の各行の種類です
+const (
+	recordTypeHeader          = "header"
+	recordTypeLatestPublished = "latest_published"
+	recordTypeProcessed       = "processed"
+	recordTypeFirstSeen       = "first_seen"
+	recordTypeRecentStory     = "recent_story"
+	recordTypePost            = "post"
+)
+
+type snapshotHeader struct {
+	Type       string    `json:"type,omitempty"`
+	Format     string    `json:"format"`
+	Version    int       `json:"version"`
+	ExportedAt time.Time `json:"exported_at"`
+}
+
+type snapshotDocument struct {
+	snapshotHeader
+	LatestPublished []latestPublishedRecord `json:"latest_published"`
+	Processed       []processedRecord       `json:"processed"`
+	FirstSeen       []firstSeenRecord       `json:"first_seen"`
+	RecentStories   []recentStoryRecord     `json:"recent_stories"`
+	Posts           []postRecord            `json:"posts"`
+}
+
+type latestPublishedRecord struct {
+	Type        string    `json:"type,omitempty"`
+	FeedURL     string    `json:"feed_url"`
+	PublishedAt time.Time `json:"published_at"`
+}
+
+type processedRecord struct {
+	Type         string    `json:"type,omitempty"`
+	FeedURL      string    `json:"feed_url"`
+	GUID         string    `json:"guid"`
+	CanonicalKey string    `json:"canonical_key,omitempty"`
+	ProcessedAt  time.Time `json:"processed_at"`
+}
+
+type firstSeenRecord struct {
+	Type    string    `json:"type,omitempty"`
+	FeedURL string    `json:"feed_url"`
+	GUID    string    `json:"guid"`
+	SeenAt  time.Time `json:"seen_at"`
+}
+
+type recentStoryRecord struct {
+	Type     string    `json:"type,omitempty"`
+	Key      string    `json:"key"`
+	FeedURL  string    `json:"feed_url"`
+	PostedAt time.Time `json:"posted_at"`
+}
+
+type postRecord struct {
+	Type         string     `json:"type,omitempty"`
+	FeedURL      string     `json:"feed_url"`
+	GUID         string     `json:"guid"`
+	CanonicalKey string     `json:"canonical_key,omitempty"`
+	PublishedAt  *time.Time `json:"published_at,omitempty"`
+	State        string     `json:"state"`
+	NoteText     string     `json:"note_text,omitempty"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+func newPostRecord(recordType string, record entity.PostRecord) postRecord {
+	r := postRecord{
+		Type:         recordType,
+		FeedURL:      record.FeedURL,
+		GUID:         record.GUID,
+		CanonicalKey: record.CanonicalKey,
+		State:        string(record.State),
+		NoteText:     record.NoteText,
+		UpdatedAt:    record.UpdatedAt.UTC(),
+	}
+	if !record.Published.IsZero() {
+		published := record.Published.UTC()
+		r.PublishedAt = &published
+	}
+	return r
+}
+
+// WriteSnapshot は snapshot を format の形式で w に書き出します
+func WriteSnapshot(w io.Writer, snapshot *entity.CacheSnapshot, format SnapshotFormat) error {
+	header := snapshotHeader{
+		Format:     snapshotFormat,
+		Version:    snapshotVersion,
+		ExportedAt: time.Now().UTC().Truncate(time.Second),
+	}
+
+	switch format {
+	case SnapshotFormatJSON:
+		doc := snapshotDocument{
+			snapshotHeader:  header,
+			LatestPublished: []latestPublishedRecord{},
+			Processed:       []processedRecord{},
+			FirstSeen:       []firstSeenRecord{},
+			RecentStories:   []recentStoryRecord{},
+			Posts:           []postRecord{},
+		}
+		for _, latest := range snapshot.LatestPublished {
+			doc.LatestPublished = append(doc.LatestPublished, latestPublishedRecord{FeedURL: latest.FeedURL, PublishedAt: latest.PublishedAt.UTC()})
+		}
+		for _, processed := range snapshot.Processed {
+			doc.Processed = append(doc.Processed, processedRecord{
+				FeedURL:      processed.FeedURL,
+				GUID:         processed.GUID,
+				CanonicalKey: processed.CanonicalKey,
+				ProcessedAt:  processed.ProcessedAt.UTC(),
+			})
+		}
+		for _, firstSeen := range snapshot.FirstSeen {
+			doc.FirstSeen = append(doc.FirstSeen, firstSeenRecord{FeedURL: firstSeen.FeedURL, GUID: firstSeen.GUID, SeenAt: firstSeen.SeenAt.UTC()})
+		}
+		for _, story := range snapshot.RecentStories {
+			doc.RecentStories = append(doc.RecentStories, recentStoryRecord{Key: story.Key, FeedURL: story.FeedURL, PostedAt: story.PostedAt.UTC()})
+		}
+		for _, record := range snapshot.Posts {
+			doc.Posts = append(doc.Posts, newPostRecord("", record))
+		}
+
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(doc); err != nil {
+			return fmt.Errorf("failed to write snapshot: %w", err)
+		}
+		return nil
+
+	case SnapshotFormatNDJSON:
+		buf := bufio.NewWriter(w)
+		encoder := json.NewEncoder(buf)
+		header.Type = recordTypeHeader
+		records := []any{header}
+		for _, latest := range snapshot.LatestPublished {
+			records = append(records, latestPublishedRecord{Type: recordTypeLatestPublished, FeedURL: latest.FeedURL, PublishedAt: latest.PublishedAt.UTC()})
+		}
+		for _, processed := range snapshot.Processed {
+			records = append(records, processedRecord{
+				Type:         recordTypeProcessed,
+				FeedURL:      processed.FeedURL,
+				GUID:         processed.GUID,
+				CanonicalKey: processed.CanonicalKey,
+				ProcessedAt:  processed.ProcessedAt.UTC(),
+			})
+		}
+		for _, firstSeen := range snapshot.FirstSeen {
+			records = append(records, firstSeenRecord{Type: recordTypeFirstSeen, FeedURL: firstSeen.FeedURL, GUID: firstSeen.GUID, SeenAt: firstSeen.SeenAt.UTC()})
+		}
+		for _, story := range snapshot.RecentStories {
+			records = append(records, recentStoryRecord{Type: recordTypeRecentStory, Key: story.Key, FeedURL: story.FeedURL, PostedAt: story.PostedAt.UTC()})
+		}
+		for _, record := range snapshot.Posts {
+			records = append(records, newPostRecord(recordTypePost, record))
+		}
+		for _, record := range records {
+			if err := encoder.Encode(record); err != nil {
+				return fmt.Errorf("failed to write snapshot: %w", err)
+			}
+		}
+		if err := buf.Flush(); err != nil {
+			return fmt.Errorf("failed to write snapshot: %w", err)
+		}
+		return nil
+
+	default:
+		return fmt.Errorf("unsupported snapshot format %q", format)
+	}
+}
+
+// ReadSnapshot は WriteSnapshot で書き出したファイルを読み込みます
+// 形式は内容から判定するため、JSON と NDJSON のどちらも読めます
+func ReadSnapshot(r io.Reader) (*entity.CacheSnapshot, error) {
+	decoder := json.NewDecoder(bufio.NewReader(r))
+
+	var first json.RawMessage
+	if err := decoder.Decode(&first); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, errors.New("snapshot is empty")
+		}
+		return nil, fmt.Errorf("failed to read snapshot: %w", err)
+	}
+
+	var header snapshotHeader
+	if err := json.Unmarshal(first, &header); err != nil {
+		return nil, fmt.Errorf("failed to read snapshot header: %w", err)
+	}
+	if err := header.validate(); err != nil {
+		return nil, err
+	}
+
+	if header.Type != recordTypeHeader {
+		var doc snapshotDocument
+		if err := json.Unmarshal(first, &doc); err != nil {
+			return nil, fmt.Errorf("failed to read snapshot: %w", err)
+		}
+		if decoder.More() {
+			return nil, errors.New("unexpected data after snapshot document")
+		}
+		return doc.toSnapshot(), nil
+	}
+
+	snapshot := &entity.CacheSnapshot{}
+	for line := 2; ; line++ {
+		var raw json.RawMessage
+		if err := decoder.Decode(&raw); err != nil {
+			if errors.Is(err, io.EOF) {
+				return snapshot, nil
+			}
+			return nil, fmt.Errorf("failed to read snapshot record %d: %w", line, err)
+		}
+		if err := appendSnapshotRecord(snapshot, raw); err != nil {
+			return nil, fmt.Errorf("failed to read snapshot record %d: %w", line, err)
+		}
+	}
+}
+
+func (h snapshotHeader) validate() error {
+	if h.Format != snapshotFormat {
+		return fmt.Errorf("not a cache snapshot (format %q)", h.Format)
+	}
+	if h.Version < 1 || h.Version > snapshotVersion {
+		return fmt.Errorf("snapshot version %d is not supported (supported: 1-%d)", h.Version, snapshotVersion)
+	}
+	return nil
+}
+
+func (d *snapshotDocument) toSnapshot() *entity.CacheSnapshot {
+	snapshot := &entity.CacheSnapshot{}
+	for _, r := range d.LatestPublished {
+		snapshot.LatestPublished = append(snapshot.LatestPublished, r.toEntity())
+	}
+	for _, r := range d.Processed {
+		snapshot.Processed = append(snapshot.Processed, r.toEntity())
+	}
+	for _, r := range d.FirstSeen {
+		snapshot.FirstSeen = append(snapshot.FirstSeen, r.toEntity())
+	}
+	for _, r := range d.RecentStories {
+		snapshot.RecentStories = append(snapshot.RecentStories, r.toEntity())
+	}
+	for _, r := range d.Posts {
+		snapshot.Posts = append(snapshot.Posts, r.toEntity())
+	}
+	return snapshot
+}
+
+func appendSnapshotRecord(snapshot *entity.CacheSnapshot, raw json.RawMessage) error {
+	var typed struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(raw, &typed); err != nil {
+		return err
+	}
+
+	switch typed.Type {
+	case recordTypeLatestPublished:
+		var r latestPublishedRecord
+		if err := json.Unmarshal(raw, &r); err != nil {
+			return err
+		}
+		snapshot.LatestPublished = append(snapshot.LatestPublished, r.toEntity())
+	case recordTypeProcessed:
+		var r processedRecord
+		if err := json.Unmarshal(raw, &r); err != nil {
+			return err
+		}
+		snapshot.Processed = append(snapshot.Processed, r.toEntity())
+	case recordTypeFirstSeen:
+		var r firstSeenRecord
+		if err := json.Unmarshal(raw, &r); err != nil {
+			return err
+		}
+		snapshot.FirstSeen = append(snapshot.FirstSeen, r.toEntity())
+	case recordTypeRecentStory:
+		var r recentStoryRecord
+		if err := json.Unmarshal(raw, &r); err != nil {
+			return err
+		}
+		snapshot.RecentStories = append(snapshot.RecentStories, r.toEntity())
+	case recordTypePost:
+		var r postRecord
+		if err := json.Unmarshal(raw, &r); err != nil {
+			return err
+		}
+		snapshot.Posts = append(snapshot.Posts, r.toEntity())
+	default:
+		return fmt.Errorf("unknown record type %q", typed.Type)
+	}
+	return nil
+}
+
+func (r latestPublishedRecord) toEntity() entity.LatestPublished {
+	return entity.LatestPublished{FeedURL: r.FeedURL, PublishedAt: r.PublishedAt}
+}
+
+func (r processedRecord) toEntity() entity.ProcessedGUID {
+	return entity.ProcessedGUID{FeedURL: r.FeedURL, GUID: r.GUID, CanonicalKey: r.CanonicalKey, ProcessedAt: r.ProcessedAt}
+}
+
+func (r firstSeenRecord) toEntity() entity.FirstSeen {
+	return entity.FirstSeen{FeedURL: r.FeedURL, GUID: r.GUID, SeenAt: r.SeenAt}
+}
+
+func (r recentStoryRecord) toEntity() entity.RecentStory {
+	return entity.RecentStory{Key: r.Key, FeedURL: r.FeedURL, PostedAt: r.PostedAt}
+}
+
+func (r postRecord) toEntity() entity.PostRecord {
+	record := entity.PostRecord{
+		FeedURL:      r.FeedURL,
+		GUID:         r.GUID,
+		CanonicalKey: r.CanonicalKey,
+		State:        entity.PostState(r.State),
+		NoteText:     r.NoteText,
+		UpdatedAt:    r.UpdatedAt,
+	}
+	if r.PublishedAt != nil {
+		record.Published = *r.PublishedAt
+	}
+	return record
+}
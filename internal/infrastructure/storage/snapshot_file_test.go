@@ -0,0 +1,86 @@
+package storage
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"misskeyRSSbot/internal/domain/entity"
+)
+
+func testSnapshot() *entity.CacheSnapshot {
+	base := time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)
+	return &entity.CacheSnapshot{
+		LatestPublished: []entity.LatestPublished{{FeedURL: testFeedURL, PublishedAt: base}},
+		Processed: []entity.ProcessedGUID{
+			{FeedURL: testFeedURL, GUID: "guid-1", CanonicalKey: "url:1", ProcessedAt: base.Add(-time.Hour)},
+			{GUID: "legacy-guid", ProcessedAt: base.Add(-2 * time.Hour)},
+		},
+		FirstSeen:     []entity.FirstSeen{{FeedURL: testFeedURL, GUID: "undated", SeenAt: base.Add(-3 * time.Hour)}},
+		RecentStories: []entity.RecentStory{{Key: "url:1", FeedURL: testFeedURL, PostedAt: base.Add(-time.Hour)}},
+		Posts: []entity.PostRecord{
+			{FeedURL: testFeedURL, GUID: "guid-1", Published: base, State: entity.PostStatePosted, NoteText: "📰 Article 1", UpdatedAt: base},
+			{FeedURL: testFeedURL, GUID: "undated", State: entity.PostStatePosting, UpdatedAt: base},
+		},
+	}
+}
+
+func TestSnapshotFile_RoundTrip(t *testing.T) {
+	for _, format := range []SnapshotFormat{SnapshotFormatJSON, SnapshotFormatNDJSON} {
+		t.Run(string(format), func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := WriteSnapshot(&buf, testSnapshot(), format); err != nil {
+				t.Fatalf("failed to write: %v", err)
+			}
+			if format == SnapshotFormatNDJSON {
+				if lines := strings.Count(buf.String(), "\n"); lines != 8 {
+					t.Errorf("expected header and 7 record lines, got %d lines:\n%s", lines, buf.String())
+				}
+			}
+
+			got, err := ReadSnapshot(&buf)
+			if err != nil {
+				t.Fatalf("failed to read: %v", err)
+			}
+			if !reflect.DeepEqual(got, testSnapshot()) {
+				t.Errorf("expected %+v, got %+v", testSnapshot(), got)
+			}
+		})
+	}
+}
+
+func TestReadSnapshot_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		wantErr string
+	}{
+		{"empty", "", "empty"},
+		{"other format", `{"format":"something-else","version":1}`, "not a cache snapshot"},
+		{"newer version", `{"type":"header","format":"misskey-rss-bot-cache","version":2}`, "version 2 is not supported"},
+		{"unknown record", `{"type":"header","format":"misskey-rss-bot-cache","version":1}
+{"type":"outbox","id":"1"}`, "unknown record type"},
+		{"broken record", `{"type":"header","format":"misskey-rss-bot-cache","version":1}
+{"type":"processed",`, "record 2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ReadSnapshot(strings.NewReader(tt.input))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestParseSnapshotFormat(t *testing.T) {
+	if _, err := ParseSnapshotFormat("csv"); err == nil {
+		t.Error("expected error for unsupported format, got nil")
+	}
+	if format, err := ParseSnapshotFormat("ndjson"); err != nil || format != SnapshotFormatNDJSON {
+		t.Errorf("expected ndjson, got %q (err=%v)", format, err)
+	}
+}
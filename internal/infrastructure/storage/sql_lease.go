@@ -0,0 +1,47 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"misskeyRSSbot/internal/domain/entity"
+)
+
+// acquireLease は SQLite と PostgreSQL に共通のリースの取得です
+// 期限はミリ秒で記録し、同じホルダーの更新か期限切れのリースの引き継ぎのみを1つの文で行います
+func acquireLease(ctx context.Context, db *sql.DB, name, holder string, ttl time.Duration) (entity.Lease, bool, error) {
+	now := time.Now()
+	if _, err := db.ExecContext(
+		ctx,
+		`INSERT INTO leases (name, holder, expires_at) VALUES ($1, $2, $3)
+		ON CONFLICT (name) DO UPDATE SET holder = excluded.holder, expires_at = excluded.expires_at
+		WHERE leases.holder = excluded.holder OR leases.expires_at <= $4`,
+		name,
+		holder,
+		now.Add(ttl).UnixMilli(),
+		now.UnixMilli(),
+	); err != nil {
+		return entity.Lease{}, false, fmt.Errorf("failed to acquire lease: %w", err)
+	}
+
+	lease := entity.Lease{Name: name}
+	var expiresAt int64
+	if err := db.QueryRowContext(
+		ctx,
+		"SELECT holder, expires_at FROM leases WHERE name = $1",
+		name,
+	).Scan(&lease.Holder, &expiresAt); err != nil {
+		return entity.Lease{}, false, fmt.Errorf("failed to get lease: %w", err)
+	}
+	lease.ExpiresAt = time.UnixMilli(expiresAt)
+	return lease, lease.Holder == holder, nil
+}
+
+func releaseLease(ctx context.Context, db *sql.DB, name, holder string) error {
+	if _, err := db.ExecContext(ctx, "DELETE FROM leases WHERE name = $1 AND holder = $2", name, holder); err != nil {
+		return fmt.Errorf("failed to release lease: %w", err)
+	}
+	return nil
+}
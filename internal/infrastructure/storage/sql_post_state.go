@@ -0,0 +1,145 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"misskeyRSSbot/internal/domain/entity"
+)
+
+// savePostState は SQLite と PostgreSQL に共通の投稿の状態の記録です
+func savePostState(ctx context.Context, db *sql.DB, record *entity.PostRecord) error {
+	if _, err := db.ExecContext(ctx, upsertPostStateQuery, postStateArgs(record)...); err != nil {
+		return fmt.Errorf("failed to save post state: %w", err)
+	}
+	return nil
+}
+
+const upsertPostStateQuery = `INSERT INTO post_states (feed_url, guid, canonical_key, published_at, state, note_text, updated_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7)
+	ON CONFLICT (feed_url, guid) DO UPDATE SET
+		canonical_key = excluded.canonical_key,
+		published_at = excluded.published_at,
+		state = excluded.state,
+		note_text = excluded.note_text,
+		updated_at = excluded.updated_at`
+
+func postStateArgs(record *entity.PostRecord) []any {
+	var published int64
+	if !record.Published.IsZero() {
+		published = record.Published.Unix()
+	}
+	return []any{
+		record.FeedURL,
+		record.GUID,
+		record.CanonicalKey,
+		published,
+		string(record.State),
+		record.NoteText,
+		record.UpdatedAt.Unix(),
+	}
+}
+
+// completePost は投稿済みの状態、処理済みのGUID、最新の公開日時を1つのトランザクションで記録します
+func completePost(ctx context.Context, db *sql.DB, record *entity.PostRecord) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	posted := *record
+	posted.State = entity.PostStatePosted
+	if _, err := tx.ExecContext(ctx, upsertPostStateQuery, postStateArgs(&posted)...); err != nil {
+		return fmt.Errorf("failed to save post state: %w", err)
+	}
+	if _, err := tx.ExecContext(
+		ctx,
+		`INSERT INTO processed_guids (feed_url, guid, processed_at, canonical_key) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (feed_url, guid) DO NOTHING`,
+		record.FeedURL,
+		record.GUID,
+		time.Now().Unix(),
+		record.CanonicalKey,
+	); err != nil {
+		return fmt.Errorf("failed to mark as processed: %w", err)
+	}
+	if !record.Published.IsZero() {
+		if _, err := tx.ExecContext(
+			ctx,
+			`INSERT INTO latest_published (rss_url, published_at) VALUES ($1, $2)
+			ON CONFLICT (rss_url) DO UPDATE SET published_at = excluded.published_at
+			WHERE latest_published.published_at < excluded.published_at`,
+			record.FeedURL,
+			record.Published.Unix(),
+		); err != nil {
+			return fmt.Errorf("failed to save latest published time: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit post: %w", err)
+	}
+	return nil
+}
+
+// listPostStates は state の投稿の記録を更新日時の古い順に返します
+func listPostStates(ctx context.Context, db *sql.DB, state entity.PostState) ([]*entity.PostRecord, error) {
+	return queryPostRecords(
+		ctx,
+		db,
+		`SELECT feed_url, guid, canonical_key, published_at, state, note_text, updated_at FROM post_states
+		WHERE state = $1 ORDER BY updated_at, feed_url, guid`,
+		string(state),
+	)
+}
+
+// listUnfinishedPosts は feedURL の投稿済みでない記録を更新日時の古い順に返します
+func listUnfinishedPosts(ctx context.Context, db *sql.DB, feedURL string) ([]*entity.PostRecord, error) {
+	return queryPostRecords(
+		ctx,
+		db,
+		`SELECT feed_url, guid, canonical_key, published_at, state, note_text, updated_at FROM post_states
+		WHERE feed_url = $1 AND state <> $2 ORDER BY updated_at, guid`,
+		feedURL,
+		string(entity.PostStatePosted),
+	)
+}
+
+func queryPostRecords(ctx context.Context, db *sql.DB, query string, args ...any) ([]*entity.PostRecord, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list post states: %w", err)
+	}
+	defer rows.Close()
+
+	var records []*entity.PostRecord
+	for rows.Next() {
+		record, err := scanPostRecord(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan post state: %w", err)
+		}
+		records = append(records, record)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate post states: %w", err)
+	}
+	return records, nil
+}
+
+func scanPostRecord(rows *sql.Rows) (*entity.PostRecord, error) {
+	var record entity.PostRecord
+	var state string
+	var published, updated int64
+	if err := rows.Scan(&record.FeedURL, &record.GUID, &record.CanonicalKey, &published, &state, &record.NoteText, &updated); err != nil {
+		return nil, err
+	}
+	record.State = entity.PostState(state)
+	if published != 0 {
+		record.Published = time.Unix(published, 0)
+	}
+	record.UpdatedAt = time.Unix(updated, 0)
+	return &record, nil
+}
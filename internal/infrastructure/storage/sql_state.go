@@ -0,0 +1,199 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"misskeyRSSbot/internal/domain/entity"
+)
+
+// SQLite と PostgreSQL は同じテーブル構成のため、書き出しと取り込みのクエリを共有します
+// プレースホルダーは両方が受け付ける $N の形式で書きます
+
+// exportSQLState はキャッシュのテーブルをすべて読み出します
+func exportSQLState(ctx context.Context, db *sql.DB) (*entity.CacheSnapshot, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin export: %w", err)
+	}
+	defer tx.Rollback()
+
+	snapshot := &entity.CacheSnapshot{}
+
+	if err := queryRows(ctx, tx, "SELECT rss_url, published_at FROM latest_published ORDER BY rss_url", func(rows *sql.Rows) error {
+		var latest entity.LatestPublished
+		var publishedAt int64
+		if err := rows.Scan(&latest.FeedURL, &publishedAt); err != nil {
+			return err
+		}
+		latest.PublishedAt = time.Unix(publishedAt, 0)
+		snapshot.LatestPublished = append(snapshot.LatestPublished, latest)
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("failed to export latest published times: %w", err)
+	}
+
+	// 古いものから並べ、件数に上限のあるキャッシュに取り込んだときに最近処理したGUIDほど残るようにする
+	if err := queryRows(ctx, tx, `SELECT feed_url, guid, canonical_key, processed_at FROM processed_guids
+		ORDER BY processed_at, feed_url, guid`, func(rows *sql.Rows) error {
+		var processed entity.ProcessedGUID
+		var processedAt int64
+		if err := rows.Scan(&processed.FeedURL, &processed.GUID, &processed.CanonicalKey, &processedAt); err != nil {
+			return err
+		}
+		processed.ProcessedAt = time.Unix(processedAt, 0)
+		snapshot.Processed = append(snapshot.Processed, processed)
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("failed to export processed GUIDs: %w", err)
+	}
+
+	if err := queryRows(ctx, tx, "SELECT feed_url, guid, seen_at FROM first_seen ORDER BY feed_url, guid", func(rows *sql.Rows) error {
+		var firstSeen entity.FirstSeen
+		var seenAt int64
+		if err := rows.Scan(&firstSeen.FeedURL, &firstSeen.GUID, &seenAt); err != nil {
+			return err
+		}
+		firstSeen.SeenAt = time.Unix(seenAt, 0)
+		snapshot.FirstSeen = append(snapshot.FirstSeen, firstSeen)
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("failed to export first seen times: %w", err)
+	}
+
+	if err := queryRows(ctx, tx, "SELECT story_key, feed_url, posted_at FROM recent_stories ORDER BY story_key, feed_url", func(rows *sql.Rows) error {
+		var story entity.RecentStory
+		var postedAt int64
+		if err := rows.Scan(&story.Key, &story.FeedURL, &postedAt); err != nil {
+			return err
+		}
+		story.PostedAt = time.Unix(postedAt, 0)
+		snapshot.RecentStories = append(snapshot.RecentStories, story)
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("failed to export recent stories: %w", err)
+	}
+
+	if err := queryRows(ctx, tx, `SELECT feed_url, guid, canonical_key, published_at, state, note_text, updated_at FROM post_states
+		ORDER BY feed_url, guid`, func(rows *sql.Rows) error {
+		record, err := scanPostRecord(rows)
+		if err != nil {
+			return err
+		}
+		snapshot.Posts = append(snapshot.Posts, *record)
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("failed to export post states: %w", err)
+	}
+
+	return snapshot, nil
+}
+
+func queryRows(ctx context.Context, tx *sql.Tx, query string, scan func(rows *sql.Rows) error) error {
+	rows, err := tx.QueryContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		if err := scan(rows); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// cleanupProcessedGUIDsQuery は $1 より前に処理した行のうち、初回検出日時が残っていないエントリーの行を削除します
+// 初回検出日時は移行前の行（feed_url が空）も含めて照合する
+const cleanupProcessedGUIDsQuery = `DELETE FROM processed_guids WHERE processed_at < $1
+	AND NOT EXISTS (
+		SELECT 1 FROM first_seen
+		WHERE first_seen.guid = processed_guids.guid AND first_seen.feed_url IN (processed_guids.feed_url, '')
+	)`
+
+// importSQLState は snapshot を1つのトランザクションで既存の状態に統合します
+// 途中で失敗した場合は何も取り込みません
+func importSQLState(ctx context.Context, db *sql.DB, snapshot *entity.CacheSnapshot) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin import: %w", err)
+	}
+	defer tx.Rollback()
+
+	// 取り込んだ初回検出日時は、取り込んだ時点でフィードに残っているものとして扱う
+	importedAt := time.Now()
+
+	for _, latest := range snapshot.LatestPublished {
+		if _, err := tx.ExecContext(
+			ctx,
+			`INSERT INTO latest_published (rss_url, published_at) VALUES ($1, $2)
+			ON CONFLICT (rss_url) DO UPDATE SET published_at = excluded.published_at
+			WHERE latest_published.published_at < excluded.published_at`,
+			latest.FeedURL,
+			latest.PublishedAt.Unix(),
+		); err != nil {
+			return fmt.Errorf("failed to import latest published time: %w", err)
+		}
+	}
+
+	for _, processed := range snapshot.Processed {
+		if _, err := tx.ExecContext(
+			ctx,
+			`INSERT INTO processed_guids (feed_url, guid, processed_at, canonical_key) VALUES ($1, $2, $3, $4)
+			ON CONFLICT (feed_url, guid) DO NOTHING`,
+			processed.FeedURL,
+			processed.GUID,
+			processed.ProcessedAt.Unix(),
+			processed.CanonicalKey,
+		); err != nil {
+			return fmt.Errorf("failed to import processed GUID: %w", err)
+		}
+	}
+
+	for _, firstSeen := range snapshot.FirstSeen {
+		if _, err := tx.ExecContext(
+			ctx,
+			`INSERT INTO first_seen (feed_url, guid, seen_at, last_seen_at) VALUES ($1, $2, $3, $4)
+			ON CONFLICT (feed_url, guid) DO UPDATE SET seen_at = excluded.seen_at
+			WHERE first_seen.seen_at > excluded.seen_at`,
+			firstSeen.FeedURL,
+			firstSeen.GUID,
+			firstSeen.SeenAt.Unix(),
+			importedAt.Unix(),
+		); err != nil {
+			return fmt.Errorf("failed to import first seen time: %w", err)
+		}
+	}
+
+	for _, story := range snapshot.RecentStories {
+		if _, err := tx.ExecContext(
+			ctx,
+			`INSERT INTO recent_stories (story_key, feed_url, posted_at) VALUES ($1, $2, $3)
+			ON CONFLICT (story_key, feed_url) DO UPDATE SET posted_at = excluded.posted_at
+			WHERE recent_stories.posted_at < excluded.posted_at`,
+			story.Key,
+			story.FeedURL,
+			story.PostedAt.Unix(),
+		); err != nil {
+			return fmt.Errorf("failed to import recent story: %w", err)
+		}
+	}
+
+	for _, record := range snapshot.Posts {
+		if _, err := tx.ExecContext(
+			ctx,
+			upsertPostStateQuery+" WHERE post_states.updated_at < excluded.updated_at",
+			postStateArgs(&record)...,
+		); err != nil {
+			return fmt.Errorf("failed to import post state: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit import: %w", err)
+	}
+	return nil
+}
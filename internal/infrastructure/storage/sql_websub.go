@@ -0,0 +1,117 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"misskeyRSSbot/internal/domain/entity"
+)
+
+// saveWebSubSubscription は SQLite と PostgreSQL に共通の WebSub の購読の記録です
+// 時刻はリースと同じくミリ秒で記録し、未設定の時刻は 0 にします
+func saveWebSubSubscription(ctx context.Context, db *sql.DB, sub entity.WebSubSubscription) error {
+	var missed int
+	if sub.Missed {
+		missed = 1
+	}
+	if _, err := db.ExecContext(
+		ctx,
+		`INSERT INTO websub_subscriptions (id, feed_url, hub, topic, secret, state, requested_at, expires_at, missed)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		ON CONFLICT (id) DO UPDATE SET
+			feed_url = excluded.feed_url,
+			hub = excluded.hub,
+			topic = excluded.topic,
+			secret = excluded.secret,
+			state = excluded.state,
+			requested_at = excluded.requested_at,
+			expires_at = excluded.expires_at,
+			missed = excluded.missed`,
+		sub.ID,
+		sub.FeedURL,
+		sub.Hub,
+		sub.Topic,
+		sub.Secret,
+		string(sub.State),
+		unixMilli(sub.RequestedAt),
+		unixMilli(sub.ExpiresAt),
+		missed,
+	); err != nil {
+		return fmt.Errorf("failed to save websub subscription: %w", err)
+	}
+	return nil
+}
+
+const selectWebSubSubscriptionsQuery = `SELECT id, feed_url, hub, topic, secret, state, requested_at, expires_at, missed
+	FROM websub_subscriptions`
+
+func getWebSubSubscription(ctx context.Context, db *sql.DB, id string) (entity.WebSubSubscription, bool, error) {
+	sub, err := scanWebSubSubscription(db.QueryRowContext(ctx, selectWebSubSubscriptionsQuery+" WHERE id = $1", id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return entity.WebSubSubscription{}, false, nil
+	}
+	if err != nil {
+		return entity.WebSubSubscription{}, false, fmt.Errorf("failed to get websub subscription: %w", err)
+	}
+	return sub, true, nil
+}
+
+func listWebSubSubscriptions(ctx context.Context, db *sql.DB) ([]entity.WebSubSubscription, error) {
+	rows, err := db.QueryContext(ctx, selectWebSubSubscriptionsQuery+" ORDER BY feed_url, id")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list websub subscriptions: %w", err)
+	}
+	defer rows.Close()
+
+	var subs []entity.WebSubSubscription
+	for rows.Next() {
+		sub, err := scanWebSubSubscription(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan websub subscription: %w", err)
+		}
+		subs = append(subs, sub)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list websub subscriptions: %w", err)
+	}
+	return subs, nil
+}
+
+func deleteWebSubSubscription(ctx context.Context, db *sql.DB, id string) error {
+	if _, err := db.ExecContext(ctx, "DELETE FROM websub_subscriptions WHERE id = $1", id); err != nil {
+		return fmt.Errorf("failed to delete websub subscription: %w", err)
+	}
+	return nil
+}
+
+func scanWebSubSubscription(row interface{ Scan(...any) error }) (entity.WebSubSubscription, error) {
+	var sub entity.WebSubSubscription
+	var state string
+	var requestedAt, expiresAt int64
+	var missed int
+	if err := row.Scan(&sub.ID, &sub.FeedURL, &sub.Hub, &sub.Topic, &sub.Secret, &state, &requestedAt, &expiresAt, &missed); err != nil {
+		return entity.WebSubSubscription{}, err
+	}
+	sub.State = entity.WebSubState(state)
+	sub.RequestedAt = fromUnixMilli(requestedAt)
+	sub.ExpiresAt = fromUnixMilli(expiresAt)
+	sub.Missed = missed != 0
+	return sub, nil
+}
+
+func unixMilli(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixMilli()
+}
+
+func fromUnixMilli(ms int64) time.Time {
+	if ms == 0 {
+		return time.Time{}
+	}
+	return time.UnixMilli(ms)
+}
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"time"
 
+	"misskeyRSSbot/internal/domain/entity"
 	"misskeyRSSbot/internal/domain/repository"
 
 	_ "modernc.org/sqlite"
@@ -36,7 +37,7 @@ func NewSQLiteCacheRepository(dbPath string) (repository.CacheRepository, error)
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	if err := cache.initSchema(ctx); err != nil {
+	if err := cache.initSchema(ctx, dbPath); err != nil {
 		db.Close()
 		return nil, fmt.Errorf("failed to initialize schema: %w", err)
 	}
@@ -44,26 +45,13 @@ func NewSQLiteCacheRepository(dbPath string) (repository.CacheRepository, error)
 	return cache, nil
 }
 
-func (c *sqliteCache) initSchema(ctx context.Context) error {
-	queries := []string{
-		`CREATE TABLE IF NOT EXISTS latest_published (
-			rss_url TEXT PRIMARY KEY,
-			published_at INTEGER NOT NULL
-		)`,
-		`CREATE TABLE IF NOT EXISTS processed_guids (
-			guid TEXT PRIMARY KEY,
-			processed_at INTEGER NOT NULL
-		)`,
-		`CREATE INDEX IF NOT EXISTS idx_processed_guids_processed_at ON processed_guids(processed_at)`,
-	}
-
-	for _, query := range queries {
-		if _, err := c.db.ExecContext(ctx, query); err != nil {
-			return fmt.Errorf("failed to execute schema query: %w", err)
-		}
+// initSchema は埋め込みのマイグレーションでスキーマを最新のバージョンに更新します
+func (c *sqliteCache) initSchema(ctx context.Context, dbPath string) error {
+	migrations, err := loadMigrations()
+	if err != nil {
+		return err
 	}
-
-	return nil
+	return migrate(ctx, c.db, dbPath, migrations)
 }
 
 func (c *sqliteCache) GetLatestPublishedTime(ctx context.Context, rssURL string) (time.Time, error) {
@@ -88,7 +76,8 @@ func (c *sqliteCache) SaveLatestPublishedTime(ctx context.Context, rssURL string
 	_, err := c.db.ExecContext(
 		ctx,
 		`INSERT INTO latest_published (rss_url, published_at) VALUES (?, ?)
-		ON CONFLICT(rss_url) DO UPDATE SET published_at = excluded.published_at`,
+		ON CONFLICT(rss_url) DO UPDATE SET published_at = excluded.published_at
+		WHERE latest_published.published_at < excluded.published_at`,
 		rssURL,
 		published.Unix(),
 	)
@@ -99,12 +88,16 @@ func (c *sqliteCache) SaveLatestPublishedTime(ctx context.Context, rssURL string
 	return nil
 }
 
-func (c *sqliteCache) IsProcessed(ctx context.Context, guid string) (bool, error) {
+func (c *sqliteCache) IsProcessed(ctx context.Context, feedURL, guid, canonicalKey string) (bool, error) {
 	var exists int
 	err := c.db.QueryRowContext(
 		ctx,
-		"SELECT 1 FROM processed_guids WHERE guid = ?",
+		`SELECT 1 FROM processed_guids
+		WHERE feed_url IN (?, '') AND (guid = ? OR (? != '' AND canonical_key = ?)) LIMIT 1`,
+		feedURL,
 		guid,
+		canonicalKey,
+		canonicalKey,
 	).Scan(&exists)
 
 	if errors.Is(err, sql.ErrNoRows) {
@@ -117,13 +110,15 @@ func (c *sqliteCache) IsProcessed(ctx context.Context, guid string) (bool, error
 	return true, nil
 }
 
-func (c *sqliteCache) MarkAsProcessed(ctx context.Context, guid string) error {
+func (c *sqliteCache) MarkAsProcessed(ctx context.Context, feedURL, guid, canonicalKey string) error {
 	_, err := c.db.ExecContext(
 		ctx,
-		`INSERT INTO processed_guids (guid, processed_at) VALUES (?, ?)
-		ON CONFLICT(guid) DO NOTHING`,
+		`INSERT INTO processed_guids (feed_url, guid, processed_at, canonical_key) VALUES (?, ?, ?, ?)
+		ON CONFLICT(feed_url, guid) DO NOTHING`,
+		feedURL,
 		guid,
 		time.Now().Unix(),
+		canonicalKey,
 	)
 	if err != nil {
 		return fmt.Errorf("failed to mark as processed: %w", err)
@@ -132,17 +127,133 @@ func (c *sqliteCache) MarkAsProcessed(ctx context.Context, guid string) error {
 	return nil
 }
 
+func (c *sqliteCache) RecordFirstSeen(ctx context.Context, feedURL, guid string, seen time.Time) (time.Time, bool, error) {
+	firstSeen, found, err := c.getFirstSeen(ctx, feedURL, guid)
+	if err != nil {
+		return firstSeen, false, err
+	}
+	if found {
+		// フィードに残っている間は掃除で削除しないように、最後に検出した日時を更新する
+		_, err := c.db.ExecContext(
+			ctx,
+			"UPDATE first_seen SET last_seen_at = ? WHERE feed_url IN (?, '') AND guid = ? AND last_seen_at < ?",
+			seen.Unix(),
+			feedURL,
+			guid,
+			seen.Unix(),
+		)
+		if err != nil {
+			return firstSeen, false, fmt.Errorf("failed to update last seen time: %w", err)
+		}
+		return firstSeen, false, nil
+	}
+
+	result, err := c.db.ExecContext(
+		ctx,
+		`INSERT INTO first_seen (feed_url, guid, seen_at, last_seen_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT(feed_url, guid) DO NOTHING`,
+		feedURL,
+		guid,
+		seen.Unix(),
+		seen.Unix(),
+	)
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("failed to record first seen time: %w", err)
+	}
+	inserted, err := result.RowsAffected()
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if inserted > 0 {
+		return time.Unix(seen.Unix(), 0), true, nil
+	}
+
+	// 読み込んでから挿入するまでに他の呼び出しが記録した場合はその日時を返す
+	firstSeen, _, err = c.getFirstSeen(ctx, feedURL, guid)
+	return firstSeen, false, err
+}
+
+// getFirstSeen は feedURL の guid の初回検出日時を返します
+// 移行前の行（feed_url が空）があればそれを初回検出日時とする
+func (c *sqliteCache) getFirstSeen(ctx context.Context, feedURL, guid string) (time.Time, bool, error) {
+	var unixTime int64
+	err := c.db.QueryRowContext(
+		ctx,
+		"SELECT seen_at FROM first_seen WHERE feed_url IN (?, '') AND guid = ? ORDER BY feed_url DESC LIMIT 1",
+		feedURL,
+		guid,
+	).Scan(&unixTime)
+	if errors.Is(err, sql.ErrNoRows) {
+		return time.Time{}, false, nil
+	}
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("failed to get first seen time: %w", err)
+	}
+	return time.Unix(unixTime, 0), true, nil
+}
+
+func (c *sqliteCache) HasRecentStory(ctx context.Context, feedURL string, keys []string, since time.Time) (bool, error) {
+	for _, key := range keys {
+		var exists int
+		err := c.db.QueryRowContext(
+			ctx,
+			"SELECT 1 FROM recent_stories WHERE story_key = ? AND feed_url != ? AND posted_at >= ? LIMIT 1",
+			key,
+			feedURL,
+			since.Unix(),
+		).Scan(&exists)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return false, fmt.Errorf("failed to check recent story: %w", err)
+		}
+		return true, nil
+	}
+	return false, nil
+}
+
+func (c *sqliteCache) SaveRecentStory(ctx context.Context, feedURL string, keys []string, postedAt time.Time) error {
+	for _, key := range keys {
+		_, err := c.db.ExecContext(
+			ctx,
+			`INSERT INTO recent_stories (story_key, feed_url, posted_at) VALUES (?, ?, ?)
+			ON CONFLICT(story_key, feed_url) DO UPDATE SET posted_at = excluded.posted_at`,
+			key,
+			feedURL,
+			postedAt.Unix(),
+		)
+		if err != nil {
+			return fmt.Errorf("failed to save recent story: %w", err)
+		}
+	}
+	return nil
+}
+
 func (c *sqliteCache) Close() error {
 	return c.db.Close()
 }
 
+// CleanupOldGUIDs は olderThan より前に記録した行を削除します
+// 初回検出日時はフィードで最後に検出してから olderThan が過ぎた行だけを削除し、
+// 残した初回検出日時のエントリーの処理済みのGUIDも残す（日時のないエントリーを再び投稿しないため）
 func (c *sqliteCache) CleanupOldGUIDs(ctx context.Context, olderThan time.Duration) (int64, error) {
 	cutoff := time.Now().Add(-olderThan).Unix()
 	result, err := c.db.ExecContext(
 		ctx,
-		"DELETE FROM processed_guids WHERE processed_at < ?",
+		"DELETE FROM first_seen WHERE last_seen_at < ?",
 		cutoff,
 	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to cleanup old first seen times: %w", err)
+	}
+
+	deletedFirstSeen, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	result, err = c.db.ExecContext(ctx, cleanupProcessedGUIDsQuery, cutoff)
 	if err != nil {
 		return 0, fmt.Errorf("failed to cleanup old GUIDs: %w", err)
 	}
@@ -152,5 +263,129 @@ func (c *sqliteCache) CleanupOldGUIDs(ctx context.Context, olderThan time.Durati
 		return 0, fmt.Errorf("failed to get rows affected: %w", err)
 	}
 
-	return deleted, nil
+	result, err = c.db.ExecContext(
+		ctx,
+		"DELETE FROM recent_stories WHERE posted_at < ?",
+		cutoff,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to cleanup old recent stories: %w", err)
+	}
+
+	deletedStories, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	// 投稿中の記録は起動時に投稿履歴と照合するまで残す
+	result, err = c.db.ExecContext(
+		ctx,
+		"DELETE FROM post_states WHERE updated_at < ? AND state != 'posting'",
+		cutoff,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to cleanup old post states: %w", err)
+	}
+
+	deletedPosts, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return deleted + deletedFirstSeen + deletedStories + deletedPosts, nil
+}
+
+func (c *sqliteCache) SavePostState(ctx context.Context, record *entity.PostRecord) error {
+	return savePostState(ctx, c.db, record)
+}
+
+func (c *sqliteCache) CompletePost(ctx context.Context, record *entity.PostRecord) error {
+	return completePost(ctx, c.db, record)
+}
+
+func (c *sqliteCache) ListPostStates(ctx context.Context, state entity.PostState) ([]*entity.PostRecord, error) {
+	return listPostStates(ctx, c.db, state)
+}
+
+func (c *sqliteCache) ListUnfinishedPosts(ctx context.Context, feedURL string) ([]*entity.PostRecord, error) {
+	return listUnfinishedPosts(ctx, c.db, feedURL)
+}
+
+func (c *sqliteCache) AcquireLease(ctx context.Context, name, holder string, ttl time.Duration) (entity.Lease, bool, error) {
+	return acquireLease(ctx, c.db, name, holder, ttl)
+}
+
+func (c *sqliteCache) ReleaseLease(ctx context.Context, name, holder string) error {
+	return releaseLease(ctx, c.db, name, holder)
+}
+
+func (c *sqliteCache) SaveWebSubSubscription(ctx context.Context, sub entity.WebSubSubscription) error {
+	return saveWebSubSubscription(ctx, c.db, sub)
+}
+
+func (c *sqliteCache) GetWebSubSubscription(ctx context.Context, id string) (entity.WebSubSubscription, bool, error) {
+	return getWebSubSubscription(ctx, c.db, id)
+}
+
+func (c *sqliteCache) ListWebSubSubscriptions(ctx context.Context) ([]entity.WebSubSubscription, error) {
+	return listWebSubSubscriptions(ctx, c.db)
+}
+
+func (c *sqliteCache) DeleteWebSubSubscription(ctx context.Context, id string) error {
+	return deleteWebSubSubscription(ctx, c.db, id)
+}
+
+func (c *sqliteCache) ExportState(ctx context.Context) (*entity.CacheSnapshot, error) {
+	return exportSQLState(ctx, c.db)
+}
+
+func (c *sqliteCache) ImportState(ctx context.Context, snapshot *entity.CacheSnapshot) error {
+	return importSQLState(ctx, c.db, snapshot)
+}
+
+func (c *sqliteCache) AddTokenUsage(ctx context.Context, day time.Time, usage entity.TokenUsage, cost float64) error {
+	_, err := c.db.ExecContext(
+		ctx,
+		`INSERT INTO llm_usage (day, provider, model, input_tokens, output_tokens, cost) VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT(day, provider, model) DO UPDATE SET
+			input_tokens = input_tokens + excluded.input_tokens,
+			output_tokens = output_tokens + excluded.output_tokens,
+			cost = cost + excluded.cost`,
+		usageDayKey(day),
+		usage.Provider,
+		usage.Model,
+		usage.InputTokens,
+		usage.OutputTokens,
+		cost,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to add token usage: %w", err)
+	}
+	return nil
+}
+
+func (c *sqliteCache) GetDailyUsage(ctx context.Context, day time.Time) ([]entity.DailyUsage, error) {
+	rows, err := c.db.QueryContext(
+		ctx,
+		`SELECT provider, model, input_tokens, output_tokens, cost FROM llm_usage
+		WHERE day = ? ORDER BY provider, model`,
+		usageDayKey(day),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get daily usage: %w", err)
+	}
+	defer rows.Close()
+
+	var usages []entity.DailyUsage
+	for rows.Next() {
+		usage := entity.DailyUsage{Day: usageDay(day)}
+		if err := rows.Scan(&usage.Provider, &usage.Model, &usage.InputTokens, &usage.OutputTokens, &usage.Cost); err != nil {
+			return nil, fmt.Errorf("failed to scan daily usage: %w", err)
+		}
+		usages = append(usages, usage)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate daily usage: %w", err)
+	}
+	return usages, nil
 }
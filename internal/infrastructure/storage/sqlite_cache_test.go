@@ -2,11 +2,15 @@ package storage
 
 import (
 	"context"
+	"database/sql"
 	"io"
 	"os"
 	"path/filepath"
 	"testing"
 	"time"
+
+	"misskeyRSSbot/internal/domain/repository"
+	"misskeyRSSbot/internal/infrastructure/storage/cachetest"
 )
 
 func closeSQLiteCache(t *testing.T, cache interface{}) {
@@ -18,222 +22,209 @@ func closeSQLiteCache(t *testing.T, cache interface{}) {
 	}
 }
 
-func TestSQLiteCache_LatestPublishedTime(t *testing.T) {
+func TestSQLiteCache_Conformance(t *testing.T) {
+	cachetest.Run(t, func(t *testing.T) repository.CacheRepository {
+		cache, err := NewSQLiteCacheRepository(filepath.Join(t.TempDir(), "test.db"))
+		if err != nil {
+			t.Fatalf("failed to create cache: %v", err)
+		}
+		t.Cleanup(func() { closeSQLiteCache(t, cache) })
+		return cache
+	})
+}
+
+func TestSQLiteCache_Persistence(t *testing.T) {
 	dbPath := filepath.Join(t.TempDir(), "test.db")
-	cache, err := NewSQLiteCacheRepository(dbPath)
+	ctx := context.Background()
+
+	rssURL := "https://example.tld/rss"
+	guid := "persistent-guid-456"
+	publishedTime := time.Now().Truncate(time.Second)
+
+	cache1, err := NewSQLiteCacheRepository(dbPath)
 	if err != nil {
 		t.Fatalf("failed to create cache: %v", err)
 	}
-	defer closeSQLiteCache(t, cache)
 
-	ctx := context.Background()
-	rssURL := "https://example.tld/rss"
+	err = cache1.SaveLatestPublishedTime(ctx, rssURL, publishedTime)
+	if err != nil {
+		t.Fatalf("failed to save published time: %v", err)
+	}
 
-	latest, err := cache.GetLatestPublishedTime(ctx, rssURL)
+	err = cache1.MarkAsProcessed(ctx, testFeedURL, guid, "")
 	if err != nil {
-		t.Fatalf("unexpected error: %v", err)
+		t.Fatalf("failed to mark as processed: %v", err)
 	}
-	if !latest.IsZero() {
-		t.Errorf("expected zero time, got %v", latest)
+
+	cache1.(*sqliteCache).Close()
+
+	cache2, err := NewSQLiteCacheRepository(dbPath)
+	if err != nil {
+		t.Fatalf("failed to reopen cache: %v", err)
 	}
+	defer cache2.(*sqliteCache).Close()
 
-	now := time.Now().Truncate(time.Second)
-	err = cache.SaveLatestPublishedTime(ctx, rssURL, now)
+	latest, err := cache2.GetLatestPublishedTime(ctx, rssURL)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
+	if !latest.Equal(publishedTime) {
+		t.Errorf("expected %v, got %v", publishedTime, latest)
+	}
 
-	latest, err = cache.GetLatestPublishedTime(ctx, rssURL)
+	processed, err := cache2.IsProcessed(ctx, testFeedURL, guid, "")
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
-	if !latest.Equal(now) {
-		t.Errorf("expected %v, got %v", now, latest)
+	if !processed {
+		t.Error("expected processed after reopen, but was not processed")
 	}
 }
 
-func TestSQLiteCache_ProcessedGUIDs(t *testing.T) {
-	dbPath := filepath.Join(t.TempDir(), "test.db")
-	cache, err := NewSQLiteCacheRepository(dbPath)
-	if err != nil {
-		t.Fatalf("failed to create cache: %v", err)
+func TestSQLiteCache_InvalidPath(t *testing.T) {
+	_, err := NewSQLiteCacheRepository("/nonexistent/path/test.db")
+	if err == nil {
+		t.Error("expected error for invalid path, got nil")
 	}
-	defer closeSQLiteCache(t, cache)
+}
 
-	ctx := context.Background()
-	guid := "test-guid-123"
+func TestSQLiteCache_FileCreation(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "subdir", "test.db")
 
-	processed, err := cache.IsProcessed(ctx, guid)
+	err := os.MkdirAll(filepath.Dir(dbPath), 0755)
 	if err != nil {
-		t.Fatalf("unexpected error: %v", err)
-	}
-	if processed {
-		t.Error("expected not processed, but was processed")
+		t.Fatalf("failed to create directory: %v", err)
 	}
 
-	err = cache.MarkAsProcessed(ctx, guid)
+	cache, err := NewSQLiteCacheRepository(dbPath)
 	if err != nil {
-		t.Fatalf("unexpected error: %v", err)
+		t.Fatalf("failed to create cache: %v", err)
 	}
+	defer closeSQLiteCache(t, cache)
 
-	processed, err = cache.IsProcessed(ctx, guid)
-	if err != nil {
-		t.Fatalf("unexpected error: %v", err)
-	}
-	if !processed {
-		t.Error("expected processed, but was not processed")
+	if _, err := os.Stat(dbPath); os.IsNotExist(err) {
+		t.Error("database file was not created")
 	}
 }
 
-func TestSQLiteCache_MultipleRSSURLs(t *testing.T) {
+func TestSQLiteCache_RecordFirstSeen(t *testing.T) {
 	dbPath := filepath.Join(t.TempDir(), "test.db")
 	cache, err := NewSQLiteCacheRepository(dbPath)
 	if err != nil {
 		t.Fatalf("failed to create cache: %v", err)
 	}
-	defer closeSQLiteCache(t, cache)
 
 	ctx := context.Background()
-	url1 := "https://example.tld/rss1"
-	url2 := "https://example.tld/rss2"
-
-	time1 := time.Now().Truncate(time.Second)
-	time2 := time.Now().Add(1 * time.Hour).Truncate(time.Second)
+	first := time.Now().Add(-1 * time.Hour).Truncate(time.Second)
 
-	if err := cache.SaveLatestPublishedTime(ctx, url1, time1); err != nil {
-		t.Fatalf("failed to save time1: %v", err)
+	seen, isNew, err := cache.RecordFirstSeen(ctx, testFeedURL, "guid-1", first)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
 	}
-	if err := cache.SaveLatestPublishedTime(ctx, url2, time2); err != nil {
-		t.Fatalf("failed to save time2: %v", err)
+	if !isNew || !seen.Equal(first) {
+		t.Errorf("expected new record at %v, got %v (new=%v)", first, seen, isNew)
 	}
+	closeSQLiteCache(t, cache)
 
-	got1, _ := cache.GetLatestPublishedTime(ctx, url1)
-	got2, _ := cache.GetLatestPublishedTime(ctx, url2)
+	cache, err = NewSQLiteCacheRepository(dbPath)
+	if err != nil {
+		t.Fatalf("failed to reopen cache: %v", err)
+	}
+	defer closeSQLiteCache(t, cache)
 
-	if !got1.Equal(time1) {
-		t.Errorf("url1: expected %v, got %v", time1, got1)
+	seen, isNew, err = cache.RecordFirstSeen(ctx, testFeedURL, "guid-1", time.Now())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
 	}
-	if !got2.Equal(time2) {
-		t.Errorf("url2: expected %v, got %v", time2, got2)
+	if isNew || !seen.Equal(first) {
+		t.Errorf("expected persisted record at %v, got %v (new=%v)", first, seen, isNew)
 	}
 }
 
-func TestSQLiteCache_Persistence(t *testing.T) {
+func TestSQLiteCache_AddsCanonicalKeyToExistingDatabase(t *testing.T) {
 	dbPath := filepath.Join(t.TempDir(), "test.db")
-	ctx := context.Background()
-
-	rssURL := "https://example.tld/rss"
-	guid := "persistent-guid-456"
-	publishedTime := time.Now().Truncate(time.Second)
-
-	cache1, err := NewSQLiteCacheRepository(dbPath)
+	db, err := sql.Open("sqlite", dbPath)
 	if err != nil {
-		t.Fatalf("failed to create cache: %v", err)
+		t.Fatalf("failed to open database: %v", err)
 	}
-
-	err = cache1.SaveLatestPublishedTime(ctx, rssURL, publishedTime)
+	_, err = db.Exec(`CREATE TABLE processed_guids (guid TEXT PRIMARY KEY, processed_at INTEGER NOT NULL);
+		INSERT INTO processed_guids (guid, processed_at) VALUES ('http://example.tld/1/?utm_source=rss', 1), ('guid-2', 1);`)
 	if err != nil {
-		t.Fatalf("failed to save published time: %v", err)
+		t.Fatalf("failed to create legacy schema: %v", err)
 	}
+	db.Close()
 
-	err = cache1.MarkAsProcessed(ctx, guid)
+	cache, err := NewSQLiteCacheRepository(dbPath)
 	if err != nil {
-		t.Fatalf("failed to mark as processed: %v", err)
+		t.Fatalf("failed to open legacy database: %v", err)
 	}
+	defer closeSQLiteCache(t, cache)
 
-	cache1.(*sqliteCache).Close()
-
-	cache2, err := NewSQLiteCacheRepository(dbPath)
-	if err != nil {
-		t.Fatalf("failed to reopen cache: %v", err)
-	}
-	defer cache2.(*sqliteCache).Close()
-
-	latest, err := cache2.GetLatestPublishedTime(ctx, rssURL)
+	ctx := context.Background()
+	processed, err := cache.IsProcessed(ctx, testFeedURL, "https://example.tld/1", "https://example.tld/1")
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
-	if !latest.Equal(publishedTime) {
-		t.Errorf("expected %v, got %v", publishedTime, latest)
+	if !processed {
+		t.Error("expected existing GUID to be backfilled with its canonical key")
 	}
 
-	processed, err := cache2.IsProcessed(ctx, guid)
+	processed, err = cache.IsProcessed(ctx, testFeedURL, "guid-2", "guid-2")
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
 	if !processed {
-		t.Error("expected processed after reopen, but was not processed")
+		t.Error("expected existing GUID to remain processed")
 	}
 }
 
-func TestSQLiteCache_UpdateLatestPublishedTime(t *testing.T) {
+func TestSQLiteCache_ScopesExistingDatabaseByFeed(t *testing.T) {
 	dbPath := filepath.Join(t.TempDir(), "test.db")
-	cache, err := NewSQLiteCacheRepository(dbPath)
+	db, err := sql.Open("sqlite", dbPath)
 	if err != nil {
-		t.Fatalf("failed to create cache: %v", err)
+		t.Fatalf("failed to open database: %v", err)
 	}
-	defer closeSQLiteCache(t, cache)
-
-	ctx := context.Background()
-	rssURL := "https://example.tld/rss"
-
-	time1 := time.Now().Truncate(time.Second)
-	time2 := time1.Add(1 * time.Hour)
-
-	_ = cache.SaveLatestPublishedTime(ctx, rssURL, time1)
-	_ = cache.SaveLatestPublishedTime(ctx, rssURL, time2)
-
-	latest, _ := cache.GetLatestPublishedTime(ctx, rssURL)
-	if !latest.Equal(time2) {
-		t.Errorf("expected updated time %v, got %v", time2, latest)
+	_, err = db.Exec(`CREATE TABLE processed_guids (guid TEXT PRIMARY KEY, processed_at INTEGER NOT NULL, canonical_key TEXT NOT NULL DEFAULT '');
+		CREATE INDEX idx_processed_guids_canonical_key ON processed_guids(canonical_key);
+		INSERT INTO processed_guids (guid, processed_at, canonical_key) VALUES ('guid-1', 100, 'guid-1');
+		CREATE TABLE first_seen (guid TEXT PRIMARY KEY, seen_at INTEGER NOT NULL);
+		INSERT INTO first_seen (guid, seen_at) VALUES ('guid-2', 200);`)
+	if err != nil {
+		t.Fatalf("failed to create legacy schema: %v", err)
 	}
-}
+	db.Close()
 
-func TestSQLiteCache_DuplicateMarkAsProcessed(t *testing.T) {
-	dbPath := filepath.Join(t.TempDir(), "test.db")
 	cache, err := NewSQLiteCacheRepository(dbPath)
 	if err != nil {
-		t.Fatalf("failed to create cache: %v", err)
+		t.Fatalf("failed to open legacy database: %v", err)
 	}
 	defer closeSQLiteCache(t, cache)
 
 	ctx := context.Background()
-	guid := "duplicate-guid"
-
-	err = cache.MarkAsProcessed(ctx, guid)
-	if err != nil {
-		t.Fatalf("first mark failed: %v", err)
+	// 移行前に処理済みだったGUIDはどのフィードでも処理済みとして扱う
+	for _, feedURL := range []string{"https://a.tld/rss", "https://b.tld/rss"} {
+		processed, err := cache.IsProcessed(ctx, feedURL, "guid-1", "")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !processed {
+			t.Errorf("expected existing GUID to remain processed for %s", feedURL)
+		}
 	}
 
-	err = cache.MarkAsProcessed(ctx, guid)
+	seen, isNew, err := cache.RecordFirstSeen(ctx, "https://a.tld/rss", "guid-2", time.Now())
 	if err != nil {
-		t.Fatalf("duplicate mark should not fail: %v", err)
-	}
-}
-
-func TestSQLiteCache_InvalidPath(t *testing.T) {
-	_, err := NewSQLiteCacheRepository("/nonexistent/path/test.db")
-	if err == nil {
-		t.Error("expected error for invalid path, got nil")
+		t.Fatalf("unexpected error: %v", err)
 	}
-}
-
-func TestSQLiteCache_FileCreation(t *testing.T) {
-	dbPath := filepath.Join(t.TempDir(), "subdir", "test.db")
-
-	err := os.MkdirAll(filepath.Dir(dbPath), 0755)
-	if err != nil {
-		t.Fatalf("failed to create directory: %v", err)
+	if isNew || seen.Unix() != 200 {
+		t.Errorf("expected existing first seen time to be kept, got %v (new=%v)", seen, isNew)
 	}
 
-	cache, err := NewSQLiteCacheRepository(dbPath)
-	if err != nil {
-		t.Fatalf("failed to create cache: %v", err)
+	if err := cache.MarkAsProcessed(ctx, "https://a.tld/rss", "guid-3", ""); err != nil {
+		t.Fatalf("unexpected error: %v", err)
 	}
-	defer closeSQLiteCache(t, cache)
-
-	if _, err := os.Stat(dbPath); os.IsNotExist(err) {
-		t.Error("database file was not created")
+	if err := cache.MarkAsProcessed(ctx, "https://b.tld/rss", "guid-3", ""); err != nil {
+		t.Fatalf("expected migrated table to key by feed and GUID: %v", err)
 	}
 }
 
@@ -276,44 +267,18 @@ func TestSQLiteCache_CleanupOldGUIDs(t *testing.T) {
 		t.Errorf("expected 2 deleted, got %d", deleted)
 	}
 
-	processed, _ := cache.IsProcessed(ctx, "old-guid-1")
+	processed, _ := cache.IsProcessed(ctx, testFeedURL, "old-guid-1", "")
 	if processed {
 		t.Error("old-guid-1 should have been deleted")
 	}
 
-	processed, _ = cache.IsProcessed(ctx, "old-guid-2")
+	processed, _ = cache.IsProcessed(ctx, testFeedURL, "old-guid-2", "")
 	if processed {
 		t.Error("old-guid-2 should have been deleted")
 	}
 
-	processed, _ = cache.IsProcessed(ctx, "new-guid-1")
+	processed, _ = cache.IsProcessed(ctx, testFeedURL, "new-guid-1", "")
 	if !processed {
 		t.Error("new-guid-1 should still exist")
 	}
 }
-
-func TestSQLiteCache_CleanupOldGUIDs_NoOldRecords(t *testing.T) {
-	dbPath := filepath.Join(t.TempDir(), "test.db")
-	cache, err := NewSQLiteCacheRepository(dbPath)
-	if err != nil {
-		t.Fatalf("failed to create cache: %v", err)
-	}
-	defer closeSQLiteCache(t, cache)
-
-	ctx := context.Background()
-	sqlCache := cache.(*sqliteCache)
-
-	markErr := cache.MarkAsProcessed(ctx, "recent-guid")
-	if markErr != nil {
-		t.Fatalf("failed to mark as processed: %v", markErr)
-	}
-
-	deleted, err := sqlCache.CleanupOldGUIDs(ctx, 24*time.Hour)
-	if err != nil {
-		t.Fatalf("cleanup failed: %v", err)
-	}
-
-	if deleted != 0 {
-		t.Errorf("expected 0 deleted, got %d", deleted)
-	}
-}
@@ -0,0 +1,6 @@
+-- バージョン1（初期）: schema_version を導入する前のデータベース
+CREATE TABLE latest_published (rss_url TEXT PRIMARY KEY, published_at INTEGER NOT NULL);
+INSERT INTO latest_published (rss_url, published_at) VALUES ('https://example.tld/rss', 1700000000);
+CREATE TABLE processed_guids (guid TEXT PRIMARY KEY, processed_at INTEGER NOT NULL);
+CREATE INDEX idx_processed_guids_processed_at ON processed_guids(processed_at);
+INSERT INTO processed_guids (guid, processed_at) VALUES ('http://example.tld/1/?utm_source=rss', 1700000000);
@@ -0,0 +1,8 @@
+-- バージョン2（llm_usage）: schema_version を導入する前のデータベース
+CREATE TABLE latest_published (rss_url TEXT PRIMARY KEY, published_at INTEGER NOT NULL);
+INSERT INTO latest_published (rss_url, published_at) VALUES ('https://example.tld/rss', 1700000000);
+CREATE TABLE processed_guids (guid TEXT PRIMARY KEY, processed_at INTEGER NOT NULL);
+CREATE INDEX idx_processed_guids_processed_at ON processed_guids(processed_at);
+INSERT INTO processed_guids (guid, processed_at) VALUES ('http://example.tld/1/?utm_source=rss', 1700000000);
+CREATE TABLE llm_usage (day TEXT NOT NULL, provider TEXT NOT NULL, model TEXT NOT NULL, input_tokens INTEGER NOT NULL, output_tokens INTEGER NOT NULL, cost REAL NOT NULL, PRIMARY KEY (day, provider, model));
+INSERT INTO llm_usage (day, provider, model, input_tokens, output_tokens, cost) VALUES ('2024-05-01', 'gemini', 'gemini-2.5-flash', 100, 20, 0.5);
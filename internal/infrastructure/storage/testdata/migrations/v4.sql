@@ -0,0 +1,12 @@
+-- バージョン4（canonical_key）: schema_version を導入する前のデータベース
+CREATE TABLE latest_published (rss_url TEXT PRIMARY KEY, published_at INTEGER NOT NULL);
+INSERT INTO latest_published (rss_url, published_at) VALUES ('https://example.tld/rss', 1700000000);
+CREATE TABLE processed_guids (guid TEXT PRIMARY KEY, processed_at INTEGER NOT NULL, canonical_key TEXT NOT NULL DEFAULT '');
+CREATE INDEX idx_processed_guids_processed_at ON processed_guids(processed_at);
+CREATE INDEX idx_processed_guids_canonical_key ON processed_guids(canonical_key);
+INSERT INTO processed_guids (guid, processed_at, canonical_key) VALUES ('http://example.tld/1/?utm_source=rss', 1700000000, 'https://example.tld/1');
+CREATE TABLE llm_usage (day TEXT NOT NULL, provider TEXT NOT NULL, model TEXT NOT NULL, input_tokens INTEGER NOT NULL, output_tokens INTEGER NOT NULL, cost REAL NOT NULL, PRIMARY KEY (day, provider, model));
+INSERT INTO llm_usage (day, provider, model, input_tokens, output_tokens, cost) VALUES ('2024-05-01', 'gemini', 'gemini-2.5-flash', 100, 20, 0.5);
+CREATE TABLE first_seen (guid TEXT PRIMARY KEY, seen_at INTEGER NOT NULL);
+CREATE INDEX idx_first_seen_seen_at ON first_seen(seen_at);
+INSERT INTO first_seen (guid, seen_at) VALUES ('guid-undated', 1700000100);
@@ -0,0 +1,15 @@
+-- バージョン6（フィードごとのGUID）: schema_version を導入する前のデータベース
+CREATE TABLE latest_published (rss_url TEXT PRIMARY KEY, published_at INTEGER NOT NULL);
+INSERT INTO latest_published (rss_url, published_at) VALUES ('https://example.tld/rss', 1700000000);
+CREATE TABLE processed_guids (feed_url TEXT NOT NULL DEFAULT '', guid TEXT NOT NULL, processed_at INTEGER NOT NULL, canonical_key TEXT NOT NULL DEFAULT '', PRIMARY KEY (feed_url, guid));
+CREATE INDEX idx_processed_guids_processed_at ON processed_guids(processed_at);
+CREATE INDEX idx_processed_guids_feed_canonical_key ON processed_guids(feed_url, canonical_key);
+INSERT INTO processed_guids (feed_url, guid, processed_at, canonical_key) VALUES ('https://example.tld/rss', 'http://example.tld/1/?utm_source=rss', 1700000000, 'https://example.tld/1');
+CREATE TABLE llm_usage (day TEXT NOT NULL, provider TEXT NOT NULL, model TEXT NOT NULL, input_tokens INTEGER NOT NULL, output_tokens INTEGER NOT NULL, cost REAL NOT NULL, PRIMARY KEY (day, provider, model));
+INSERT INTO llm_usage (day, provider, model, input_tokens, output_tokens, cost) VALUES ('2024-05-01', 'gemini', 'gemini-2.5-flash', 100, 20, 0.5);
+CREATE TABLE first_seen (feed_url TEXT NOT NULL DEFAULT '', guid TEXT NOT NULL, seen_at INTEGER NOT NULL, PRIMARY KEY (feed_url, guid));
+CREATE INDEX idx_first_seen_seen_at ON first_seen(seen_at);
+INSERT INTO first_seen (feed_url, guid, seen_at) VALUES ('https://example.tld/rss', 'guid-undated', 1700000100);
+CREATE TABLE recent_stories (story_key TEXT NOT NULL, feed_url TEXT NOT NULL, posted_at INTEGER NOT NULL, PRIMARY KEY (story_key, feed_url));
+CREATE INDEX idx_recent_stories_posted_at ON recent_stories(posted_at);
+INSERT INTO recent_stories (story_key, feed_url, posted_at) VALUES ('url:https://example.tld/1', 'https://example.tld/rss', 1700000000);
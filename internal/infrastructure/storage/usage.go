@@ -0,0 +1,13 @@
+package storage
+
+import "time"
+
+const usageDayLayout = "2006-01-02"
+
+func usageDayKey(day time.Time) string {
+	return day.Format(usageDayLayout)
+}
+
+func usageDay(day time.Time) time.Time {
+	return time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, day.Location())
+}
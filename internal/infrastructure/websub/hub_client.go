@@ -0,0 +1,185 @@
+package websub
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"misskeyRSSbot/internal/domain/entity"
+	"misskeyRSSbot/internal/domain/repository"
+)
+
+// maxDiscoveryBody はハブを探すときに読むフィードの本文の上限です
+const maxDiscoveryBody = 5 << 20
+
+type hubClient struct {
+	client *http.Client
+}
+
+// NewHubRepository は WebSub のハブを探し、購読を要求するリポジトリを作成します
+// ハブのURLはフィードから得た外部のURLのため、client にはフィードの取得と同じクライアントを渡します
+func NewHubRepository(client *http.Client) repository.WebSubHubRepository {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &hubClient{client: client}
+}
+
+// DiscoverHub はレスポンスの Link ヘッダー、フィードの link 要素の順にハブと topic を探します
+// topic が示されていない場合は feedURL を topic とします
+func (c *hubClient) DiscoverHub(ctx context.Context, feedURL string) (string, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, feedURL, nil)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/atom+xml, application/rss+xml, application/xml;q=0.9, */*;q=0.8")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to fetch feed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", "", fmt.Errorf("failed to fetch feed: status %d", resp.StatusCode)
+	}
+
+	hub, topic := parseLinkHeaders(resp.Header.Values("Link"))
+	if hub == "" {
+		hub, topic = parseFeedLinks(io.LimitReader(resp.Body, maxDiscoveryBody))
+	}
+	if hub == "" {
+		return "", "", nil
+	}
+
+	base := resp.Request.URL
+	hubURL, err := base.Parse(hub)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid hub URL %q: %w", hub, err)
+	}
+	if topic == "" {
+		return hubURL.String(), feedURL, nil
+	}
+	topicURL, err := base.Parse(topic)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid topic URL %q: %w", topic, err)
+	}
+	return hubURL.String(), topicURL.String(), nil
+}
+
+// parseLinkHeaders は `<https://hub.example>; rel="hub"` の形式の Link ヘッダーからハブと topic を返します
+func parseLinkHeaders(headers []string) (hub, topic string) {
+	for _, header := range headers {
+		for _, link := range strings.Split(header, ",") {
+			target, params, ok := strings.Cut(link, ";")
+			target = strings.TrimSpace(target)
+			if !ok || !strings.HasPrefix(target, "<") || !strings.HasSuffix(target, ">") {
+				continue
+			}
+			href := target[1 : len(target)-1]
+			for _, param := range strings.Split(params, ";") {
+				name, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+				if !strings.EqualFold(name, "rel") {
+					continue
+				}
+				hub, topic = applyRel(hub, topic, strings.Trim(value, `"`), href)
+			}
+		}
+	}
+	return hub, topic
+}
+
+// parseFeedLinks は RSS の atom:link と Atom の link 要素からハブと topic を返します
+// エントリーの link を拾わないように、最初の item か entry までを読みます
+func parseFeedLinks(body io.Reader) (hub, topic string) {
+	decoder := xml.NewDecoder(body)
+	decoder.Strict = false
+	decoder.CharsetReader = func(_ string, input io.Reader) (io.Reader, error) {
+		return input, nil
+	}
+
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return hub, topic
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		switch start.Name.Local {
+		case "item", "entry":
+			return hub, topic
+		case "link":
+			var rel, href string
+			for _, attr := range start.Attr {
+				switch attr.Name.Local {
+				case "rel":
+					rel = attr.Value
+				case "href":
+					href = attr.Value
+				}
+			}
+			if href != "" {
+				hub, topic = applyRel(hub, topic, rel, href)
+			}
+		}
+	}
+}
+
+// applyRel は空白区切りの rel に hub か self が含まれていれば href を記録します（最初に見つけたものを優先します）
+func applyRel(hub, topic, rel, href string) (string, string) {
+	for _, value := range strings.Fields(rel) {
+		switch strings.ToLower(value) {
+		case "hub":
+			if hub == "" {
+				hub = href
+			}
+		case "self":
+			if topic == "" {
+				topic = href
+			}
+		}
+	}
+	return hub, topic
+}
+
+// Subscribe はハブに hub.mode=subscribe を送信します
+// ハブは 202 Accepted を返した後にコールバックで確認するため、成功は購読の完了を意味しません
+func (c *hubClient) Subscribe(ctx context.Context, sub *entity.WebSubSubscription, callbackURL string, lease time.Duration) error {
+	form := url.Values{
+		"hub.mode":     {"subscribe"},
+		"hub.topic":    {sub.Topic},
+		"hub.callback": {callbackURL},
+	}
+	if lease > 0 {
+		form.Set("hub.lease_seconds", strconv.Itoa(int(lease.Seconds())))
+	}
+	if sub.Secret != "" {
+		form.Set("hub.secret", sub.Secret)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.Hub, strings.NewReader(form.Encode()))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send subscription request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("hub rejected subscription: status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
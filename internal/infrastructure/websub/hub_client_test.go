@@ -0,0 +1,143 @@
+package websub
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"misskeyRSSbot/internal/domain/entity"
+)
+
+func TestHubClient_DiscoverHub(t *testing.T) {
+	tests := []struct {
+		name      string
+		header    string
+		body      string
+		wantHub   string
+		wantTopic string
+	}{
+		{
+			name:      "link header",
+			header:    `<https://hub.example.tld/>; rel="hub", <https://example.tld/feed.xml>; rel="self"`,
+			body:      `<rss version="2.0"><channel><title>Feed</title></channel></rss>`,
+			wantHub:   "https://hub.example.tld/",
+			wantTopic: "https://example.tld/feed.xml",
+		},
+		{
+			name: "atom link in rss",
+			body: `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0" xmlns:atom="http://www.w3.org/2005/Atom">
+	<channel>
+		<title>Feed</title>
+		<atom:link rel="hub" href="https://pubsubhubbub.appspot.com/"/>
+		<atom:link rel="self" type="application/rss+xml" href="https://example.tld/rss"/>
+		<item><title>Article</title></item>
+	</channel>
+</rss>`,
+			wantHub:   "https://pubsubhubbub.appspot.com/",
+			wantTopic: "https://example.tld/rss",
+		},
+		{
+			name: "relative hub in atom without self",
+			body: `<?xml version="1.0" encoding="ISO-8859-1"?>
+<feed xmlns="http://www.w3.org/2005/Atom">
+	<link rel="alternate" href="https://example.tld/"/>
+	<link rel="hub" href="/hub"/>
+</feed>`,
+			wantHub:   "{server}/hub",
+			wantTopic: "{server}/feed",
+		},
+		{
+			name: "entry links are ignored",
+			body: `<feed xmlns="http://www.w3.org/2005/Atom">
+	<entry><link rel="hub" href="https://hub.example.tld/"/></entry>
+</feed>`,
+		},
+		{
+			name: "no hub",
+			body: `<rss version="2.0"><channel><atom:link rel="self" href="https://example.tld/rss"/></channel></rss>`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.header != "" {
+					w.Header().Set("Link", tt.header)
+				}
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			hub, topic, err := NewHubRepository(server.Client()).DiscoverHub(context.Background(), server.URL+"/feed")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			wantHub := strings.ReplaceAll(tt.wantHub, "{server}", server.URL)
+			wantTopic := strings.ReplaceAll(tt.wantTopic, "{server}", server.URL)
+			if hub != wantHub || topic != wantTopic {
+				t.Errorf("expected hub %q and topic %q, got %q and %q", wantHub, wantTopic, hub, topic)
+			}
+		})
+	}
+}
+
+func TestHubClient_DiscoverHub_HTTPError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	if _, _, err := NewHubRepository(server.Client()).DiscoverHub(context.Background(), server.URL); err == nil {
+		t.Error("expected error for 404, got nil")
+	}
+}
+
+func TestHubClient_Subscribe(t *testing.T) {
+	var form url.Values
+	status := http.StatusAccepted
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/x-www-form-urlencoded" {
+			t.Errorf("unexpected request: %s %s", r.Method, r.Header.Get("Content-Type"))
+		}
+		if err := r.ParseForm(); err != nil {
+			t.Errorf("failed to parse form: %v", err)
+		}
+		form = r.PostForm
+		w.WriteHeader(status)
+		w.Write([]byte("topic is not allowed"))
+	}))
+	defer server.Close()
+
+	hubRepo := NewHubRepository(server.Client())
+	sub := &entity.WebSubSubscription{ID: "sub-1", Hub: server.URL, Topic: "https://example.tld/rss", Secret: "secret"}
+	if err := hubRepo.Subscribe(context.Background(), sub, "https://bot.example.tld/websub/sub-1", 24*time.Hour); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := map[string]string{
+		"hub.mode":          "subscribe",
+		"hub.topic":         "https://example.tld/rss",
+		"hub.callback":      "https://bot.example.tld/websub/sub-1",
+		"hub.lease_seconds": "86400",
+		"hub.secret":        "secret",
+	}
+	for key, value := range want {
+		if got := form.Get(key); got != value {
+			t.Errorf("%s: expected %q, got %q", key, value, got)
+		}
+	}
+
+	status = http.StatusBadRequest
+	err := hubRepo.Subscribe(context.Background(), sub, "https://bot.example.tld/websub/sub-1", 0)
+	if err == nil || !strings.Contains(err.Error(), "topic is not allowed") {
+		t.Errorf("expected rejection with the hub's message, got %v", err)
+	}
+	if form.Has("hub.lease_seconds") {
+		t.Error("expected no lease_seconds when lease is 0")
+	}
+}
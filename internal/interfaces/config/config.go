@@ -1,18 +1,55 @@
 package config
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
+	"net/url"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/joho/godotenv"
 	"github.com/kelseyhightower/envconfig"
+
+	"misskeyRSSbot/internal/domain/entity"
 )
 
 type RSSSettings struct {
 	URL      string
 	Keywords []string
+	Summary  SummarySettings
+	// Priority: フィードをまたいで同じ記事が見つかった場合に投稿するフィードを決める優先度（大きいほど優先）
+	Priority int
+	// Tags: フィードの分類（OPML のフォルダー・カテゴリーとして取り込み、書き出す）
+	Tags []string
+	// TagsAsHashtags: Tags をこのフィードのノートにハッシュタグとして付ける
+	TagsAsHashtags bool
+}
+
+type SummarySettings struct {
+	Disabled          bool
+	SystemInstruction string
+	Language          string
+	MaxLength         int
+	Provider          string
+	Model             string
+}
+
+// ExtractionRule は EXTRACTION_RULES_FILE に記述するホストごとの本文抽出ルールです
+type ExtractionRule struct {
+	Host             string   `json:"host"`
+	ContentSelectors []string `json:"content_selectors"`
+	RemoveSelectors  []string `json:"remove_selectors"`
+	FollowAMPHTML    bool     `json:"follow_amphtml"`
+	FollowCanonical  bool     `json:"follow_canonical"`
+}
+
+type extractionRulesFile struct {
+	Sites []ExtractionRule `json:"sites"`
 }
 
 type Config struct {
@@ -28,6 +65,8 @@ type Config struct {
 
 	LocalOnly bool `envconfig:"LOCAL_ONLY" default:"false"`
 
+	NoteTemplate string `envconfig:"NOTE_TEMPLATE" default:""`
+
 	LLMProvider          string `envconfig:"LLM_PROVIDER" default:""`
 	LLMAPIKey            string `envconfig:"LLM_API_KEY"`
 	LLMModel             string `envconfig:"LLM_MODEL"`
@@ -35,16 +74,73 @@ type Config struct {
 	LLMMaxTokens         int    `envconfig:"LLM_MAX_TOKENS" default:"0"`
 	LLMTimeout           int    `envconfig:"LLM_TIMEOUT" default:"30"`
 	LLMSystemInstruction string `envconfig:"LLM_SYSTEM_INSTRUCTION"`
+	LLMLanguage          string `envconfig:"LLM_LANGUAGE" default:""`
+	LLMStructuredOutput  bool   `envconfig:"LLM_STRUCTURED_OUTPUT" default:"false"`
+
+	LLMMaxConcurrency    int `envconfig:"LLM_MAX_CONCURRENCY" default:"2"`
+	LLMRequestsPerMinute int `envconfig:"LLM_REQUESTS_PER_MINUTE" default:"0"`
+	LLMMaxRetries        int `envconfig:"LLM_MAX_RETRIES" default:"3"`
+	LLMRetryBaseDelay    int `envconfig:"LLM_RETRY_BASE_DELAY" default:"1"`
+	LLMRetryMaxDelay     int `envconfig:"LLM_RETRY_MAX_DELAY" default:"30"`
+
+	LLMPrices      string  `envconfig:"LLM_PRICES" default:""`
+	LLMDailyBudget float64 `envconfig:"LLM_DAILY_BUDGET" default:"0"`
+
+	LLMProviderConfigs map[string]LLMConfig         `ignored:"true"`
+	LLMModelPrices     map[string]entity.ModelPrice `ignored:"true"`
+
+	MetricsAddr string `envconfig:"METRICS_ADDR" default:""`
+
+	WebSubCallbackURL  string `envconfig:"WEBSUB_CALLBACK_URL" default:""`
+	WebSubListenAddr   string `envconfig:"WEBSUB_LISTEN_ADDR" default:":8080"`
+	WebSubLeaseSeconds int    `envconfig:"WEBSUB_LEASE_SECONDS" default:"86400"`
+
+	HTTPUserAgent           string `envconfig:"HTTP_USER_AGENT" default:""`
+	HTTPProxyURL            string `envconfig:"HTTP_PROXY_URL" default:""`
+	HTTPTimeout             int    `envconfig:"HTTP_TIMEOUT" default:"30"`
+	HTTPMaxIdleConnsPerHost int    `envconfig:"HTTP_MAX_IDLE_CONNS_PER_HOST" default:"4"`
+	HTTPIdleConnTimeout     int    `envconfig:"HTTP_IDLE_CONN_TIMEOUT" default:"90"`
+	HTTPHostInterval        int    `envconfig:"HTTP_HOST_INTERVAL" default:"1000"`
+	HTTPRespectRobotsTxt    bool   `envconfig:"HTTP_RESPECT_ROBOTS_TXT" default:"false"`
+	FetchAllowedHosts       string `envconfig:"FETCH_ALLOWED_HOSTS" default:""`
+
+	ExtractionRulesFile string           `envconfig:"EXTRACTION_RULES_FILE" default:""`
+	ExtractionRules     []ExtractionRule `ignored:"true"`
+	PDFMaxPages         int              `envconfig:"PDF_MAX_PAGES" default:"20"`
 
 	CacheDBPath string `envconfig:"CACHE_DB_PATH" default:""`
+	CacheDSN    string `envconfig:"CACHE_DSN" default:""`
+
+	MemoryCacheMaxEntries int `envconfig:"MEMORY_CACHE_MAX_ENTRIES" default:"0"`
 
 	CacheCleanupInterval int `envconfig:"CACHE_CLEANUP_INTERVAL" default:"24"`
 
+	LeaderElection bool   `envconfig:"LEADER_ELECTION" default:"true"`
+	LeaderLeaseTTL int    `envconfig:"LEADER_LEASE_TTL" default:"30"`
+	ReplicaID      string `envconfig:"REPLICA_ID" default:""`
+
 	CacheRetentionDays int `envconfig:"CACHE_RETENTION_DAYS" default:"7"`
 
 	FirstRunLatestOnly bool `envconfig:"FIRST_RUN_LATEST_ONLY" default:"true"`
+
+	ResolveCanonicalURL bool `envconfig:"RESOLVE_CANONICAL_URL" default:"false"`
+
+	CrossFeedDedupeWindow int  `envconfig:"CROSS_FEED_DEDUPE_WINDOW" default:"24"`
+	CrossFeedMergeSources bool `envconfig:"CROSS_FEED_MERGE_SOURCES" default:"false"`
+
+	StoryClustering                 string  `envconfig:"STORY_CLUSTERING" default:"off"`
+	StoryClusterWindow              int     `envconfig:"STORY_CLUSTER_WINDOW" default:"24"`
+	StoryClusterSimHashDistance     int     `envconfig:"STORY_CLUSTER_SIMHASH_DISTANCE" default:"10"`
+	StoryClusterEmbeddingSimilarity float64 `envconfig:"STORY_CLUSTER_EMBEDDING_SIMILARITY" default:"0.9"`
+	StoryClusterEmbeddingModel      string  `envconfig:"STORY_CLUSTER_EMBEDDING_MODEL" default:""`
 }
 
+const (
+	StoryClusteringOff       = "off"
+	StoryClusteringSimHash   = "simhash"
+	StoryClusteringEmbedding = "embedding"
+)
+
 func LoadConfig() (*Config, error) {
 	_ = godotenv.Load()
 
@@ -53,7 +149,12 @@ func LoadConfig() (*Config, error) {
 		return nil, err
 	}
 
-	rssSettings := loadRSSURLs()
+	cfg.LLMProvider = strings.ToLower(strings.TrimSpace(cfg.LLMProvider))
+
+	rssSettings, err := loadRSSURLs()
+	if err != nil {
+		return nil, err
+	}
 	if len(rssSettings) > 0 {
 		cfg.RSSURL = rssSettings
 	}
@@ -62,10 +163,72 @@ func LoadConfig() (*Config, error) {
 		return nil, fmt.Errorf("no RSS URLs configured")
 	}
 
+	providerConfigs, err := loadLLMProviderConfigs(&cfg)
+	if err != nil {
+		return nil, err
+	}
+	cfg.LLMProviderConfigs = providerConfigs
+
+	prices, err := parseModelPrices(cfg.LLMPrices)
+	if err != nil {
+		return nil, err
+	}
+	cfg.LLMModelPrices = prices
+
+	switch cfg.StoryClustering {
+	case StoryClusteringOff, StoryClusteringSimHash, StoryClusteringEmbedding:
+	default:
+		return nil, fmt.Errorf("invalid STORY_CLUSTERING: %q (expected off, simhash or embedding)", cfg.StoryClustering)
+	}
+
+	if cfg.CacheDSN != "" && !cfg.IsPostgresCache() {
+		return nil, errInvalidCacheDSN
+	}
+
+	if cfg.IsWebSubEnabled() {
+		callbackURL, err := url.Parse(cfg.WebSubCallbackURL)
+		if err != nil || (callbackURL.Scheme != "http" && callbackURL.Scheme != "https") || callbackURL.Host == "" {
+			return nil, fmt.Errorf("invalid WEBSUB_CALLBACK_URL: %q (expected an absolute http or https URL)", cfg.WebSubCallbackURL)
+		}
+	}
+
+	if cfg.ExtractionRulesFile != "" {
+		rules, err := loadExtractionRules(cfg.ExtractionRulesFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.ExtractionRules = rules
+	}
+
 	return &cfg, nil
 }
 
-func loadRSSURLs() []RSSSettings {
+var errInvalidCacheDSN = errors.New("invalid CACHE_DSN: expected postgres:// or postgresql:// URL")
+
+// CacheConfig はキャッシュの書き出しや取り込みのコマンドで使う接続先の設定です
+// ボットの起動に必要な設定がなくても読み込めます
+type CacheConfig struct {
+	DBPath string `envconfig:"CACHE_DB_PATH" default:""`
+	DSN    string `envconfig:"CACHE_DSN" default:""`
+}
+
+// LoadCacheConfig は環境変数（.env）からキャッシュの接続先だけを読み込みます
+func LoadCacheConfig() (*CacheConfig, error) {
+	_ = godotenv.Load()
+
+	var cfg CacheConfig
+	if err := envconfig.Process("", &cfg); err != nil {
+		return nil, err
+	}
+	if cfg.DSN != "" && !cfg.IsPostgresCache() {
+		return nil, errInvalidCacheDSN
+	}
+	return &cfg, nil
+}
+
+// loadRSSURLs は RSS_URL_N とフィードごとの設定を読み込みます
+// 要約の設定が不正な場合は、意図しない要約で料金が発生しないようにエラーを返します
+func loadRSSURLs() ([]RSSSettings, error) {
 	var settings []RSSSettings
 
 	for i := 1; ; i++ {
@@ -76,21 +239,38 @@ func loadRSSURLs() []RSSSettings {
 		}
 
 		// RSS_URL_1 に対応する RSS_URL_1_FILTER を読み込む
-		keywordsKey := fmt.Sprintf("RSS_URL_%d_FILTER", i)
-		rawKeywords := os.Getenv(keywordsKey)
-		var keywords []string
-		if rawKeywords != "" {
-			for _, k := range strings.Split(rawKeywords, ",") {
-				trimmed := strings.TrimSpace(k)
-				if trimmed != "" {
-					keywords = append(keywords, trimmed)
-				}
+		keywords := splitList(os.Getenv(fmt.Sprintf("RSS_URL_%d_FILTER", i)))
+
+		summary, err := loadSummarySettings(i)
+		if err != nil {
+			return nil, err
+		}
+
+		var priority int
+		if raw := strings.TrimSpace(os.Getenv(key + "_PRIORITY")); raw != "" {
+			priority, err = strconv.Atoi(raw)
+			if err != nil {
+				log.Printf("Warning: ignoring invalid %s_PRIORITY: %q", key, raw)
+				priority = 0
+			}
+		}
+
+		// OPML のフォルダー名から取り込んだタグをそのままハッシュタグにしないように、明示したフィードだけ付ける
+		var tagsAsHashtags bool
+		if raw := strings.TrimSpace(os.Getenv(key + "_HASHTAGS")); raw != "" {
+			tagsAsHashtags, err = strconv.ParseBool(raw)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s_HASHTAGS: %w", key, err)
 			}
 		}
 
 		settings = append(settings, RSSSettings{
-			URL:      url,
-			Keywords: keywords,
+			URL:            url,
+			Keywords:       keywords,
+			Summary:        summary,
+			Priority:       priority,
+			Tags:           splitList(os.Getenv(key + "_TAGS")),
+			TagsAsHashtags: tagsAsHashtags,
 		})
 	}
 
@@ -105,7 +285,146 @@ func loadRSSURLs() []RSSSettings {
 		}
 	}
 
-	return settings
+	return settings, nil
+}
+
+// splitList はカンマ区切りの値を空白を除いて分割します
+func splitList(raw string) []string {
+	var values []string
+	for _, value := range strings.Split(raw, ",") {
+		if trimmed := strings.TrimSpace(value); trimmed != "" {
+			values = append(values, trimmed)
+		}
+	}
+	return values
+}
+
+func loadSummarySettings(index int) (SummarySettings, error) {
+	prefix := fmt.Sprintf("RSS_URL_%d", index)
+	settings := SummarySettings{
+		SystemInstruction: os.Getenv(prefix + "_SYSTEM_INSTRUCTION"),
+		Language:          strings.TrimSpace(os.Getenv(prefix + "_SUMMARY_LANGUAGE")),
+		Provider:          strings.ToLower(strings.TrimSpace(os.Getenv(prefix + "_LLM_PROVIDER"))),
+		Model:             strings.TrimSpace(os.Getenv(prefix + "_LLM_MODEL")),
+	}
+
+	if raw := strings.TrimSpace(os.Getenv(prefix + "_SUMMARIZE")); raw != "" {
+		enabled, err := strconv.ParseBool(raw)
+		if err != nil {
+			return settings, fmt.Errorf("invalid %s_SUMMARIZE: %w", prefix, err)
+		}
+		settings.Disabled = !enabled
+	}
+
+	if raw := strings.TrimSpace(os.Getenv(prefix + "_SUMMARY_MAX_LENGTH")); raw != "" {
+		maxLength, err := strconv.Atoi(raw)
+		if err != nil || maxLength < 0 {
+			return settings, fmt.Errorf("invalid %s_SUMMARY_MAX_LENGTH: %q", prefix, raw)
+		}
+		settings.MaxLength = maxLength
+	}
+
+	return settings, nil
+}
+
+type llmProviderEnv struct {
+	APIKey            string `envconfig:"API_KEY"`
+	Model             string `envconfig:"MODEL"`
+	Region            string `envconfig:"REGION"`
+	MaxTokens         int    `envconfig:"MAX_TOKENS"`
+	Timeout           int    `envconfig:"TIMEOUT"`
+	SystemInstruction string `envconfig:"SYSTEM_INSTRUCTION"`
+}
+
+// フィードで指定されたプロバイダの認証情報を LLM_<PROVIDER>_API_KEY などから読み込む
+func loadLLMProviderConfigs(cfg *Config) (map[string]LLMConfig, error) {
+	configs := make(map[string]LLMConfig)
+	for _, setting := range cfg.RSSURL {
+		provider := setting.Summary.Provider
+		if provider == "" || provider == cfg.LLMProvider {
+			continue
+		}
+		if _, ok := configs[provider]; ok {
+			continue
+		}
+
+		var env llmProviderEnv
+		if err := envconfig.Process("LLM_"+strings.ToUpper(provider), &env); err != nil {
+			return nil, fmt.Errorf("failed to load LLM settings for provider %s: %w", provider, err)
+		}
+
+		llmCfg := cfg.GetLLMConfig()
+		llmCfg.Provider = provider
+		llmCfg.APIKey = env.APIKey
+		llmCfg.Model = env.Model
+		llmCfg.Region = env.Region
+		if env.MaxTokens > 0 {
+			llmCfg.MaxTokens = env.MaxTokens
+		}
+		if env.Timeout > 0 {
+			llmCfg.Timeout = time.Duration(env.Timeout) * time.Second
+		}
+		if env.SystemInstruction != "" {
+			llmCfg.SystemInstruction = env.SystemInstruction
+		}
+		configs[provider] = llmCfg
+	}
+	return configs, nil
+}
+
+// LLM_PRICES=model=入力単価:出力単価,... （100万トークンあたりのUSD）
+func parseModelPrices(raw string) (map[string]entity.ModelPrice, error) {
+	prices := make(map[string]entity.ModelPrice)
+	for _, item := range strings.Split(raw, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		separator := strings.LastIndex(item, "=")
+		if separator <= 0 {
+			return nil, fmt.Errorf("invalid LLM_PRICES entry %q: expected model=input:output", item)
+		}
+		model := strings.TrimSpace(item[:separator])
+
+		inputRaw, outputRaw, ok := strings.Cut(item[separator+1:], ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid LLM_PRICES entry %q: expected model=input:output", item)
+		}
+		input, err := strconv.ParseFloat(strings.TrimSpace(inputRaw), 64)
+		if err != nil || input < 0 {
+			return nil, fmt.Errorf("invalid input price in LLM_PRICES entry %q", item)
+		}
+		output, err := strconv.ParseFloat(strings.TrimSpace(outputRaw), 64)
+		if err != nil || output < 0 {
+			return nil, fmt.Errorf("invalid output price in LLM_PRICES entry %q", item)
+		}
+
+		prices[model] = entity.ModelPrice{InputPerMillion: input, OutputPerMillion: output}
+	}
+	return prices, nil
+}
+
+// loadExtractionRules は本文抽出ルールのJSONファイルを読み込みます
+func loadExtractionRules(path string) ([]ExtractionRule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read EXTRACTION_RULES_FILE: %w", err)
+	}
+
+	var file extractionRulesFile
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&file); err != nil {
+		return nil, fmt.Errorf("failed to parse EXTRACTION_RULES_FILE %s: %w", path, err)
+	}
+
+	for i, rule := range file.Sites {
+		if strings.TrimSpace(rule.Host) == "" {
+			return nil, fmt.Errorf("invalid EXTRACTION_RULES_FILE %s: sites[%d].host is required", path, i)
+		}
+	}
+	return file.Sites, nil
 }
 
 func (c *Config) GetFetchInterval() time.Duration {
@@ -124,6 +443,8 @@ type LLMConfig struct {
 	MaxTokens         int
 	Timeout           time.Duration
 	SystemInstruction string
+	Language          string
+	StructuredOutput  bool
 }
 
 func (c *Config) GetLLMConfig() LLMConfig {
@@ -135,11 +456,127 @@ func (c *Config) GetLLMConfig() LLMConfig {
 		MaxTokens:         c.LLMMaxTokens,
 		Timeout:           time.Duration(c.LLMTimeout) * time.Second,
 		SystemInstruction: c.LLMSystemInstruction,
+		Language:          c.LLMLanguage,
+		StructuredOutput:  c.LLMStructuredOutput,
+	}
+}
+
+type LLMResilienceConfig struct {
+	MaxConcurrency    int
+	RequestsPerMinute int
+	MaxRetries        int
+	RetryBaseDelay    time.Duration
+	RetryMaxDelay     time.Duration
+}
+
+func (c *Config) GetLLMResilienceConfig() LLMResilienceConfig {
+	return LLMResilienceConfig{
+		MaxConcurrency:    c.LLMMaxConcurrency,
+		RequestsPerMinute: c.LLMRequestsPerMinute,
+		MaxRetries:        c.LLMMaxRetries,
+		RetryBaseDelay:    time.Duration(c.LLMRetryBaseDelay) * time.Second,
+		RetryMaxDelay:     time.Duration(c.LLMRetryMaxDelay) * time.Second,
+	}
+}
+
+type HTTPClientConfig struct {
+	UserAgent           string
+	ProxyURL            string
+	Timeout             time.Duration
+	MaxIdleConnsPerHost int
+	IdleConnTimeout     time.Duration
+	HostInterval        time.Duration
+	RespectRobotsTxt    bool
+}
+
+func (c *Config) GetHTTPClientConfig() HTTPClientConfig {
+	return HTTPClientConfig{
+		UserAgent:           c.HTTPUserAgent,
+		ProxyURL:            c.HTTPProxyURL,
+		Timeout:             time.Duration(c.HTTPTimeout) * time.Second,
+		MaxIdleConnsPerHost: c.HTTPMaxIdleConnsPerHost,
+		IdleConnTimeout:     time.Duration(c.HTTPIdleConnTimeout) * time.Second,
+		HostInterval:        time.Duration(c.HTTPHostInterval) * time.Millisecond,
+		RespectRobotsTxt:    c.HTTPRespectRobotsTxt,
+	}
+}
+
+// GetFetchAllowedHosts はSSRF対策の対象外にするホスト名・CIDRの一覧を返します
+func (c *Config) GetFetchAllowedHosts() []string {
+	var hosts []string
+	for _, host := range strings.Split(c.FetchAllowedHosts, ",") {
+		if trimmed := strings.TrimSpace(host); trimmed != "" {
+			hosts = append(hosts, trimmed)
+		}
 	}
+	return hosts
+}
+
+func (c *Config) IsMetricsEnabled() bool {
+	return c.MetricsAddr != ""
+}
+
+// IsWebSubEnabled は WebSub でハブを広告するフィードを購読するかどうかを返します
+func (c *Config) IsWebSubEnabled() bool {
+	return c.WebSubCallbackURL != ""
+}
+
+// GetWebSubLease はハブに要求する購読の期間を返します（0以下の場合は1日）
+func (c *Config) GetWebSubLease() time.Duration {
+	if c.WebSubLeaseSeconds <= 0 {
+		return 24 * time.Hour
+	}
+	return time.Duration(c.WebSubLeaseSeconds) * time.Second
 }
 
 func (c *Config) IsPersistentCache() bool {
-	return c.CacheDBPath != ""
+	return c.CacheDBPath != "" || c.CacheDSN != ""
+}
+
+// IsPostgresCache は CACHE_DSN に PostgreSQL の接続先が設定されているかどうかを返します
+// CACHE_DB_PATH より優先します
+func (c *Config) IsPostgresCache() bool {
+	return isPostgresDSN(c.CacheDSN)
+}
+
+func (c *CacheConfig) IsPersistentCache() bool {
+	return c.DBPath != "" || c.DSN != ""
+}
+
+// IsPostgresCache は DSN に PostgreSQL の接続先が設定されているかどうかを返します
+func (c *CacheConfig) IsPostgresCache() bool {
+	return isPostgresDSN(c.DSN)
+}
+
+func isPostgresDSN(dsn string) bool {
+	return strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://")
+}
+
+// IsLeaderElectionEnabled はリーダー選出を行うかどうかを返します
+// リースは永続化キャッシュに記録するため、メモリ上のキャッシュでは行いません
+func (c *Config) IsLeaderElectionEnabled() bool {
+	return c.LeaderElection && c.IsPersistentCache()
+}
+
+// GetLeaderLeaseTTL はリーダーのリースの有効期間を返します（0以下の場合は30秒）
+func (c *Config) GetLeaderLeaseTTL() time.Duration {
+	if c.LeaderLeaseTTL <= 0 {
+		return 30 * time.Second
+	}
+	return time.Duration(c.LeaderLeaseTTL) * time.Second
+}
+
+// GetReplicaID はリースを持つレプリカの名前を返します
+// 設定されていない場合はホスト名とプロセスIDから作成します
+func (c *Config) GetReplicaID() string {
+	if id := strings.TrimSpace(c.ReplicaID); id != "" {
+		return id
+	}
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "unknown"
+	}
+	return fmt.Sprintf("%s-%d", hostname, os.Getpid())
 }
 
 func (c *Config) GetCacheCleanupInterval() time.Duration {
@@ -149,3 +586,19 @@ func (c *Config) GetCacheCleanupInterval() time.Duration {
 func (c *Config) GetCacheRetentionPeriod() time.Duration {
 	return time.Duration(c.CacheRetentionDays) * 24 * time.Hour
 }
+
+// GetCrossFeedDedupeWindow はフィードをまたいだ重複を判定する期間を返します（0の場合は無効）
+func (c *Config) GetCrossFeedDedupeWindow() time.Duration {
+	if c.CrossFeedDedupeWindow <= 0 {
+		return 0
+	}
+	return time.Duration(c.CrossFeedDedupeWindow) * time.Hour
+}
+
+// GetStoryClusterWindow は近似重複の記事をまとめる期間を返します（クラスタリングが無効な場合は0）
+func (c *Config) GetStoryClusterWindow() time.Duration {
+	if c.StoryClustering == StoryClusteringOff || c.StoryClusterWindow <= 0 {
+		return 0
+	}
+	return time.Duration(c.StoryClusterWindow) * time.Hour
+}
@@ -1,9 +1,14 @@
 package config
 
 import (
+	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
+
+	"misskeyRSSbot/internal/domain/entity"
 )
 
 func TestLoadRSSURLs_Numbered(t *testing.T) {
@@ -14,7 +19,7 @@ func TestLoadRSSURLs_Numbered(t *testing.T) {
 	defer os.Unsetenv("RSS_URL_2")
 	defer os.Unsetenv("RSS_URL_3")
 
-	settings := loadRSSURLs()
+	settings := mustLoadRSSURLs(t)
 
 	if len(settings) != 3 {
 		t.Errorf("expected 3 settings, got %d", len(settings))
@@ -44,7 +49,7 @@ func TestLoadRSSURLs_NumberedWithGap(t *testing.T) {
 	defer os.Unsetenv("RSS_URL_2")
 	defer os.Unsetenv("RSS_URL_4")
 
-	settings := loadRSSURLs()
+	settings := mustLoadRSSURLs(t)
 
 	if len(settings) != 2 {
 		t.Errorf("expected 2 settings, got %d", len(settings))
@@ -52,7 +57,7 @@ func TestLoadRSSURLs_NumberedWithGap(t *testing.T) {
 }
 
 func TestLoadRSSURLs_NoNumbered(t *testing.T) {
-	settings := loadRSSURLs()
+	settings := mustLoadRSSURLs(t)
 
 	if len(settings) != 0 {
 		t.Errorf("expected 0 settings, got %d", len(settings))
@@ -63,7 +68,7 @@ func TestLoadRSSURLs_LegacyFallback(t *testing.T) {
 	os.Setenv("RSS_URL", "https://example.tld/rss1,https://example.tld/rss2,https://example.tld/rss3")
 	defer os.Unsetenv("RSS_URL")
 
-	settings := loadRSSURLs()
+	settings := mustLoadRSSURLs(t)
 
 	if len(settings) != 3 {
 		t.Fatalf("expected 3 settings from legacy format, got %d", len(settings))
@@ -91,7 +96,7 @@ func TestLoadRSSURLs_NumberedTakesPrecedenceOverLegacy(t *testing.T) {
 	defer os.Unsetenv("RSS_URL")
 	defer os.Unsetenv("RSS_URL_1")
 
-	settings := loadRSSURLs()
+	settings := mustLoadRSSURLs(t)
 
 	if len(settings) != 1 {
 		t.Fatalf("expected 1 setting, got %d", len(settings))
@@ -115,7 +120,7 @@ func TestLoadRSSURLs_WithKeywords(t *testing.T) {
 	defer os.Unsetenv("RSS_URL_2_FILTER")
 	defer os.Unsetenv("RSS_URL_3")
 
-	settings := loadRSSURLs()
+	settings := mustLoadRSSURLs(t)
 
 	if len(settings) != 3 {
 		t.Fatalf("expected 3 settings, got %d", len(settings))
@@ -143,7 +148,7 @@ func TestLoadRSSURLs_KeywordsWithSpaces(t *testing.T) {
 	defer os.Unsetenv("RSS_URL_1")
 	defer os.Unsetenv("RSS_URL_1_FILTER")
 
-	settings := loadRSSURLs()
+	settings := mustLoadRSSURLs(t)
 
 	if len(settings) != 1 {
 		t.Fatalf("expected 1 setting, got %d", len(settings))
@@ -161,6 +166,225 @@ func TestLoadRSSURLs_KeywordsWithSpaces(t *testing.T) {
 	}
 }
 
+func TestLoadRSSURLs_Priority(t *testing.T) {
+	os.Setenv("RSS_URL_1", "https://example.tld/rss1")
+	os.Setenv("RSS_URL_2", "https://example.tld/rss2")
+	os.Setenv("RSS_URL_2_PRIORITY", "10")
+	os.Setenv("RSS_URL_3", "https://example.tld/rss3")
+	os.Setenv("RSS_URL_3_PRIORITY", "high")
+	defer os.Unsetenv("RSS_URL_1")
+	defer os.Unsetenv("RSS_URL_2")
+	defer os.Unsetenv("RSS_URL_2_PRIORITY")
+	defer os.Unsetenv("RSS_URL_3")
+	defer os.Unsetenv("RSS_URL_3_PRIORITY")
+
+	settings := mustLoadRSSURLs(t)
+
+	if len(settings) != 3 {
+		t.Fatalf("expected 3 settings, got %d", len(settings))
+	}
+	if settings[0].Priority != 0 {
+		t.Errorf("expected default priority 0, got %d", settings[0].Priority)
+	}
+	if settings[1].Priority != 10 {
+		t.Errorf("expected priority 10, got %d", settings[1].Priority)
+	}
+	if settings[2].Priority != 0 {
+		t.Errorf("expected invalid priority to be ignored, got %d", settings[2].Priority)
+	}
+}
+
+func TestLoadRSSURLs_Tags(t *testing.T) {
+	os.Setenv("RSS_URL_1", "https://example.tld/rss1")
+	os.Setenv("RSS_URL_1_TAGS", "Tech, Go ,,")
+	os.Setenv("RSS_URL_2", "https://example.tld/rss2")
+	defer os.Unsetenv("RSS_URL_1")
+	defer os.Unsetenv("RSS_URL_1_TAGS")
+	defer os.Unsetenv("RSS_URL_2")
+
+	settings := mustLoadRSSURLs(t)
+
+	if len(settings) != 2 {
+		t.Fatalf("expected 2 settings, got %d", len(settings))
+	}
+	if len(settings[0].Tags) != 2 || settings[0].Tags[0] != "Tech" || settings[0].Tags[1] != "Go" {
+		t.Errorf("expected tags [Tech Go], got %v", settings[0].Tags)
+	}
+	if settings[1].Tags != nil {
+		t.Errorf("expected no tags, got %v", settings[1].Tags)
+	}
+	if settings[0].TagsAsHashtags {
+		t.Error("expected tags not to be posted as hashtags by default")
+	}
+}
+
+func TestLoadRSSURLs_TagsAsHashtags(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    bool
+		wantErr bool
+	}{
+		{"enabled", "true", true, false},
+		{"disabled", "false", false, false},
+		{"invalid", "sometimes", false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("RSS_URL_1", "https://example.tld/rss1")
+			t.Setenv("RSS_URL_1_TAGS", "Tech")
+			t.Setenv("RSS_URL_1_HASHTAGS", tt.value)
+
+			settings, err := loadRSSURLs()
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if settings[0].TagsAsHashtags != tt.want {
+				t.Errorf("expected TagsAsHashtags=%v, got %v", tt.want, settings[0].TagsAsHashtags)
+			}
+		})
+	}
+}
+
+func TestLoadRSSURLs_SummarySettings(t *testing.T) {
+	os.Setenv("RSS_URL_1", "https://example.tld/rss1")
+	os.Setenv("RSS_URL_1_SUMMARIZE", "false")
+	os.Setenv("RSS_URL_2", "https://example.tld/rss2")
+	os.Setenv("RSS_URL_2_SYSTEM_INSTRUCTION", "Summarize briefly.")
+	os.Setenv("RSS_URL_2_SUMMARY_LANGUAGE", "English")
+	os.Setenv("RSS_URL_2_SUMMARY_MAX_LENGTH", "200")
+	os.Setenv("RSS_URL_2_LLM_PROVIDER", "bedrock")
+	os.Setenv("RSS_URL_2_LLM_MODEL", "custom-model")
+	defer os.Unsetenv("RSS_URL_1")
+	defer os.Unsetenv("RSS_URL_1_SUMMARIZE")
+	defer os.Unsetenv("RSS_URL_2")
+	defer os.Unsetenv("RSS_URL_2_SYSTEM_INSTRUCTION")
+	defer os.Unsetenv("RSS_URL_2_SUMMARY_LANGUAGE")
+	defer os.Unsetenv("RSS_URL_2_SUMMARY_MAX_LENGTH")
+	defer os.Unsetenv("RSS_URL_2_LLM_PROVIDER")
+	defer os.Unsetenv("RSS_URL_2_LLM_MODEL")
+
+	settings := mustLoadRSSURLs(t)
+
+	if len(settings) != 2 {
+		t.Fatalf("expected 2 settings, got %d", len(settings))
+	}
+
+	if !settings[0].Summary.Disabled {
+		t.Error("expected summary to be disabled for settings[0]")
+	}
+
+	expected := SummarySettings{
+		SystemInstruction: "Summarize briefly.",
+		Language:          "English",
+		MaxLength:         200,
+		Provider:          "bedrock",
+		Model:             "custom-model",
+	}
+	if settings[1].Summary != expected {
+		t.Errorf("expected summary settings %+v, got %+v", expected, settings[1].Summary)
+	}
+}
+
+func TestLoadRSSURLs_ProviderIsCaseInsensitive(t *testing.T) {
+	t.Setenv("RSS_URL_1", "https://example.tld/rss1")
+	t.Setenv("RSS_URL_1_LLM_PROVIDER", " Gemini ")
+
+	settings := mustLoadRSSURLs(t)
+	if settings[0].Summary.Provider != "gemini" {
+		t.Errorf("expected provider gemini, got %q", settings[0].Summary.Provider)
+	}
+}
+
+func TestLoadConfig_InvalidSummarySettings(t *testing.T) {
+	tests := []struct {
+		key   string
+		value string
+	}{
+		{"RSS_URL_1_SUMMARIZE", "nope"},
+		{"RSS_URL_1_SUMMARY_MAX_LENGTH", "abc"},
+		{"RSS_URL_1_SUMMARY_MAX_LENGTH", "-1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key+"="+tt.value, func(t *testing.T) {
+			t.Setenv("MISSKEY_HOST", "misskey.example.tld")
+			t.Setenv("AUTH_TOKEN", "token")
+			t.Setenv("RSS_URL_1", "https://example.tld/rss1")
+			t.Setenv(tt.key, tt.value)
+
+			_, err := LoadConfig()
+			if err == nil || !strings.Contains(err.Error(), tt.key) {
+				t.Errorf("expected an error naming %s, got %v", tt.key, err)
+			}
+		})
+	}
+}
+
+func mustLoadRSSURLs(t *testing.T) []RSSSettings {
+	t.Helper()
+	settings, err := loadRSSURLs()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return settings
+}
+
+func TestLoadConfig_LLMProviderConfigs(t *testing.T) {
+	os.Setenv("MISSKEY_HOST", "test.example.tld")
+	os.Setenv("AUTH_TOKEN", "test_token")
+	os.Setenv("LLM_PROVIDER", "gemini")
+	os.Setenv("LLM_API_KEY", "gemini-key")
+	os.Setenv("LLM_TIMEOUT", "45")
+	os.Setenv("RSS_URL_1", "https://example.tld/rss1")
+	os.Setenv("RSS_URL_1_LLM_PROVIDER", "gemini")
+	os.Setenv("RSS_URL_2", "https://example.tld/rss2")
+	os.Setenv("RSS_URL_2_LLM_PROVIDER", "bedrock")
+	os.Setenv("LLM_BEDROCK_API_KEY", "bedrock-token")
+	os.Setenv("LLM_BEDROCK_MODEL", "bedrock-model")
+	os.Setenv("LLM_BEDROCK_REGION", "us-east-1")
+
+	defer os.Unsetenv("MISSKEY_HOST")
+	defer os.Unsetenv("AUTH_TOKEN")
+	defer os.Unsetenv("LLM_PROVIDER")
+	defer os.Unsetenv("LLM_API_KEY")
+	defer os.Unsetenv("LLM_TIMEOUT")
+	defer os.Unsetenv("RSS_URL_1")
+	defer os.Unsetenv("RSS_URL_1_LLM_PROVIDER")
+	defer os.Unsetenv("RSS_URL_2")
+	defer os.Unsetenv("RSS_URL_2_LLM_PROVIDER")
+	defer os.Unsetenv("LLM_BEDROCK_API_KEY")
+	defer os.Unsetenv("LLM_BEDROCK_MODEL")
+	defer os.Unsetenv("LLM_BEDROCK_REGION")
+
+	cfg, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+
+	if len(cfg.LLMProviderConfigs) != 1 {
+		t.Fatalf("expected 1 additional provider config, got %d", len(cfg.LLMProviderConfigs))
+	}
+
+	bedrock, ok := cfg.LLMProviderConfigs["bedrock"]
+	if !ok {
+		t.Fatal("expected bedrock provider config")
+	}
+	if bedrock.Provider != "bedrock" || bedrock.APIKey != "bedrock-token" || bedrock.Model != "bedrock-model" || bedrock.Region != "us-east-1" {
+		t.Errorf("unexpected bedrock config: %+v", bedrock)
+	}
+	if bedrock.Timeout != 45*time.Second {
+		t.Errorf("expected timeout to fall back to LLM_TIMEOUT, got %v", bedrock.Timeout)
+	}
+}
+
 func TestLoadConfig_NumberedRSSURLs(t *testing.T) {
 	os.Setenv("MISSKEY_HOST", "test.example.tld")
 	os.Setenv("AUTH_TOKEN", "test_token")
@@ -302,20 +526,44 @@ func TestConfig_GetCacheRetentionPeriod(t *testing.T) {
 	}
 }
 
+func TestConfig_GetCrossFeedDedupeWindow(t *testing.T) {
+	tests := []struct {
+		name     string
+		hours    int
+		expected time.Duration
+	}{
+		{"default 24 hours", 24, 24 * time.Hour},
+		{"disabled", 0, 0},
+		{"negative", -1, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{CrossFeedDedupeWindow: tt.hours}
+			result := cfg.GetCrossFeedDedupeWindow()
+			if result != tt.expected {
+				t.Errorf("expected %v, got %v", tt.expected, result)
+			}
+		})
+	}
+}
+
 func TestConfig_IsPersistentCache(t *testing.T) {
 	tests := []struct {
 		name     string
 		dbPath   string
+		dsn      string
 		expected bool
 	}{
-		{"empty path", "", false},
-		{"with path", "./cache.db", true},
-		{"absolute path", "/var/data/cache.db", true},
+		{"empty path", "", "", false},
+		{"with path", "./cache.db", "", true},
+		{"absolute path", "/var/data/cache.db", "", true},
+		{"postgres dsn", "", "postgres://bot@db/cache", true},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			cfg := &Config{CacheDBPath: tt.dbPath}
+			cfg := &Config{CacheDBPath: tt.dbPath, CacheDSN: tt.dsn}
 			result := cfg.IsPersistentCache()
 			if result != tt.expected {
 				t.Errorf("expected %v, got %v", tt.expected, result)
@@ -323,3 +571,346 @@ func TestConfig_IsPersistentCache(t *testing.T) {
 		})
 	}
 }
+
+func TestConfig_LeaderElection(t *testing.T) {
+	tests := []struct {
+		name        string
+		cfg         Config
+		wantEnabled bool
+		wantTTL     time.Duration
+	}{
+		{"sqlite cache", Config{LeaderElection: true, LeaderLeaseTTL: 30, CacheDBPath: "./cache.db"}, true, 30 * time.Second},
+		{"postgres cache", Config{LeaderElection: true, LeaderLeaseTTL: 10, CacheDSN: "postgres://bot@db/cache"}, true, 10 * time.Second},
+		{"in-memory cache", Config{LeaderElection: true, LeaderLeaseTTL: 30}, false, 30 * time.Second},
+		{"disabled", Config{LeaderElection: false, LeaderLeaseTTL: 30, CacheDBPath: "./cache.db"}, false, 30 * time.Second},
+		{"invalid ttl", Config{LeaderElection: true, LeaderLeaseTTL: 0, CacheDBPath: "./cache.db"}, true, 30 * time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.cfg.IsLeaderElectionEnabled(); got != tt.wantEnabled {
+				t.Errorf("expected enabled=%v, got %v", tt.wantEnabled, got)
+			}
+			if got := tt.cfg.GetLeaderLeaseTTL(); got != tt.wantTTL {
+				t.Errorf("expected TTL %v, got %v", tt.wantTTL, got)
+			}
+		})
+	}
+}
+
+func TestConfig_GetReplicaID(t *testing.T) {
+	cfg := &Config{ReplicaID: " bot-1 "}
+	if got := cfg.GetReplicaID(); got != "bot-1" {
+		t.Errorf("expected bot-1, got %q", got)
+	}
+
+	cfg = &Config{}
+	if got := cfg.GetReplicaID(); !strings.HasSuffix(got, fmt.Sprintf("-%d", os.Getpid())) {
+		t.Errorf("expected generated ID to end with the process ID, got %q", got)
+	}
+}
+
+func TestParseModelPrices(t *testing.T) {
+	tests := []struct {
+		name      string
+		raw       string
+		expected  map[string]entity.ModelPrice
+		wantError bool
+	}{
+		{"empty", "", map[string]entity.ModelPrice{}, false},
+		{
+			"multiple models",
+			"gemini-2.0-flash=0.1:0.4, anthropic.claude-3-haiku-20240307-v1:0=0.25:1.25",
+			map[string]entity.ModelPrice{
+				"gemini-2.0-flash":                       {InputPerMillion: 0.1, OutputPerMillion: 0.4},
+				"anthropic.claude-3-haiku-20240307-v1:0": {InputPerMillion: 0.25, OutputPerMillion: 1.25},
+			},
+			false,
+		},
+		{"missing model", "=0.1:0.2", nil, true},
+		{"missing output price", "model=0.1", nil, true},
+		{"invalid price", "model=abc:0.2", nil, true},
+		{"negative price", "model=0.1:-1", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseModelPrices(tt.raw)
+			if tt.wantError {
+				if err == nil {
+					t.Fatalf("expected error, got %v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(got) != len(tt.expected) {
+				t.Fatalf("expected %d prices, got %d", len(tt.expected), len(got))
+			}
+			for model, price := range tt.expected {
+				if got[model] != price {
+					t.Errorf("model %s: expected %+v, got %+v", model, price, got[model])
+				}
+			}
+		})
+	}
+}
+
+func TestLoadConfig_InvalidLLMPrices(t *testing.T) {
+	os.Setenv("MISSKEY_HOST", "test.example.tld")
+	os.Setenv("AUTH_TOKEN", "test_token")
+	os.Setenv("RSS_URL_1", "https://example.tld/rss1")
+	os.Setenv("LLM_PRICES", "model=invalid")
+
+	defer os.Unsetenv("MISSKEY_HOST")
+	defer os.Unsetenv("AUTH_TOKEN")
+	defer os.Unsetenv("RSS_URL_1")
+	defer os.Unsetenv("LLM_PRICES")
+
+	if _, err := LoadConfig(); err == nil {
+		t.Error("expected error for invalid LLM_PRICES, got nil")
+	}
+}
+
+func TestLoadConfig_InvalidStoryClustering(t *testing.T) {
+	os.Setenv("MISSKEY_HOST", "test.example.tld")
+	os.Setenv("AUTH_TOKEN", "test_token")
+	os.Setenv("RSS_URL_1", "https://example.tld/rss1")
+	os.Setenv("STORY_CLUSTERING", "minhash")
+
+	defer os.Unsetenv("MISSKEY_HOST")
+	defer os.Unsetenv("AUTH_TOKEN")
+	defer os.Unsetenv("RSS_URL_1")
+	defer os.Unsetenv("STORY_CLUSTERING")
+
+	if _, err := LoadConfig(); err == nil {
+		t.Error("expected error for invalid STORY_CLUSTERING, got nil")
+	}
+}
+
+func TestLoadConfig_CacheDSN(t *testing.T) {
+	tests := []struct {
+		name      string
+		dsn       string
+		wantError bool
+	}{
+		{"postgres", "postgres://bot:secret@db:5432/cache", false},
+		{"postgresql", "postgresql://bot@db/cache?sslmode=require", false},
+		{"mysql", "mysql://bot@db/cache", true},
+		{"keyword format", "host=db user=bot", true},
+	}
+
+	os.Setenv("MISSKEY_HOST", "test.example.tld")
+	os.Setenv("AUTH_TOKEN", "test_token")
+	os.Setenv("RSS_URL_1", "https://example.tld/rss1")
+
+	defer os.Unsetenv("MISSKEY_HOST")
+	defer os.Unsetenv("AUTH_TOKEN")
+	defer os.Unsetenv("RSS_URL_1")
+	defer os.Unsetenv("CACHE_DSN")
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Setenv("CACHE_DSN", tt.dsn)
+			cfg, err := LoadConfig()
+			if tt.wantError {
+				if err == nil {
+					t.Error("expected error for invalid CACHE_DSN, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !cfg.IsPostgresCache() || !cfg.IsPersistentCache() {
+				t.Errorf("expected postgres persistent cache for %q", tt.dsn)
+			}
+		})
+	}
+}
+
+func TestLoadConfig_WebSubCallbackURL(t *testing.T) {
+	tests := []struct {
+		name      string
+		url       string
+		wantError bool
+	}{
+		{"https", "https://bot.example.tld/websub", false},
+		{"http with port", "http://bot.example.tld:8080/websub", false},
+		{"relative", "/websub", true},
+		{"other scheme", "ftp://bot.example.tld/websub", true},
+	}
+
+	os.Setenv("MISSKEY_HOST", "test.example.tld")
+	os.Setenv("AUTH_TOKEN", "test_token")
+	os.Setenv("RSS_URL_1", "https://example.tld/rss1")
+
+	defer os.Unsetenv("MISSKEY_HOST")
+	defer os.Unsetenv("AUTH_TOKEN")
+	defer os.Unsetenv("RSS_URL_1")
+	defer os.Unsetenv("WEBSUB_CALLBACK_URL")
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Setenv("WEBSUB_CALLBACK_URL", tt.url)
+			cfg, err := LoadConfig()
+			if tt.wantError {
+				if err == nil {
+					t.Error("expected error for invalid WEBSUB_CALLBACK_URL, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !cfg.IsWebSubEnabled() || cfg.GetWebSubLease() != 24*time.Hour {
+				t.Errorf("expected WebSub enabled with the default lease, got enabled=%v lease=%v", cfg.IsWebSubEnabled(), cfg.GetWebSubLease())
+			}
+		})
+	}
+}
+
+func TestLoadCacheConfig(t *testing.T) {
+	// ボットの設定（MISSKEY_HOST など）がなくても読み込める
+	os.Unsetenv("MISSKEY_HOST")
+	os.Unsetenv("AUTH_TOKEN")
+	os.Setenv("CACHE_DB_PATH", "/data/cache.db")
+	defer os.Unsetenv("CACHE_DB_PATH")
+	defer os.Unsetenv("CACHE_DSN")
+
+	cfg, err := LoadCacheConfig()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.DBPath != "/data/cache.db" || !cfg.IsPersistentCache() || cfg.IsPostgresCache() {
+		t.Errorf("unexpected cache config: %+v", cfg)
+	}
+
+	os.Setenv("CACHE_DSN", "postgres://bot@db/cache")
+	cfg, err = LoadCacheConfig()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !cfg.IsPostgresCache() {
+		t.Errorf("expected postgres cache for %q", cfg.DSN)
+	}
+
+	os.Setenv("CACHE_DSN", "mysql://bot@db/cache")
+	if _, err := LoadCacheConfig(); err == nil {
+		t.Error("expected error for invalid CACHE_DSN, got nil")
+	}
+}
+
+func TestConfig_GetStoryClusterWindow(t *testing.T) {
+	tests := []struct {
+		name     string
+		mode     string
+		hours    int
+		expected time.Duration
+	}{
+		{"off", StoryClusteringOff, 24, 0},
+		{"simhash", StoryClusteringSimHash, 24, 24 * time.Hour},
+		{"embedding", StoryClusteringEmbedding, 6, 6 * time.Hour},
+		{"zero window", StoryClusteringSimHash, 0, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{StoryClustering: tt.mode, StoryClusterWindow: tt.hours}
+			result := cfg.GetStoryClusterWindow()
+			if result != tt.expected {
+				t.Errorf("expected %v, got %v", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestConfig_GetLLMResilienceConfig(t *testing.T) {
+	cfg := &Config{
+		LLMMaxConcurrency:    4,
+		LLMRequestsPerMinute: 60,
+		LLMMaxRetries:        5,
+		LLMRetryBaseDelay:    2,
+		LLMRetryMaxDelay:     20,
+	}
+
+	got := cfg.GetLLMResilienceConfig()
+	expected := LLMResilienceConfig{
+		MaxConcurrency:    4,
+		RequestsPerMinute: 60,
+		MaxRetries:        5,
+		RetryBaseDelay:    2 * time.Second,
+		RetryMaxDelay:     20 * time.Second,
+	}
+	if got != expected {
+		t.Errorf("expected %+v, got %+v", expected, got)
+	}
+}
+
+func TestLoadExtractionRules(t *testing.T) {
+	dir := t.TempDir()
+
+	validPath := filepath.Join(dir, "rules.json")
+	valid := `{"sites":[{"host":"news.example.tld","content_selectors":["div.article-body"],"remove_selectors":[".ad",".related"],"follow_amphtml":true}]}`
+	if err := os.WriteFile(validPath, []byte(valid), 0o644); err != nil {
+		t.Fatalf("failed to write rules file: %v", err)
+	}
+
+	rules, err := loadExtractionRules(validPath)
+	if err != nil {
+		t.Fatalf("loadExtractionRules failed: %v", err)
+	}
+	if len(rules) != 1 {
+		t.Fatalf("expected 1 rule, got %d", len(rules))
+	}
+	rule := rules[0]
+	if rule.Host != "news.example.tld" || len(rule.ContentSelectors) != 1 || len(rule.RemoveSelectors) != 2 || !rule.FollowAMPHTML || rule.FollowCanonical {
+		t.Errorf("unexpected rule: %+v", rule)
+	}
+
+	invalidCases := map[string]string{
+		"missing_host.json":  `{"sites":[{"content_selectors":["div"]}]}`,
+		"unknown_field.json": `{"sites":[{"host":"example.tld","selector":"div"}]}`,
+		"broken.json":        `{"sites":[`,
+	}
+	for name, content := range invalidCases {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("failed to write rules file: %v", err)
+		}
+		if _, err := loadExtractionRules(path); err == nil {
+			t.Errorf("%s: expected error, got nil", name)
+		}
+	}
+
+	if _, err := loadExtractionRules(filepath.Join(dir, "missing.json")); err == nil {
+		t.Error("expected error for missing file, got nil")
+	}
+}
+
+func TestConfig_GetHTTPClientConfig(t *testing.T) {
+	cfg := &Config{
+		HTTPUserAgent:           "testbot/1.0",
+		HTTPProxyURL:            "http://proxy.example.tld:3128",
+		HTTPTimeout:             15,
+		HTTPMaxIdleConnsPerHost: 8,
+		HTTPIdleConnTimeout:     60,
+		HTTPHostInterval:        500,
+		HTTPRespectRobotsTxt:    true,
+	}
+
+	got := cfg.GetHTTPClientConfig()
+	expected := HTTPClientConfig{
+		UserAgent:           "testbot/1.0",
+		ProxyURL:            "http://proxy.example.tld:3128",
+		Timeout:             15 * time.Second,
+		MaxIdleConnsPerHost: 8,
+		IdleConnTimeout:     60 * time.Second,
+		HostInterval:        500 * time.Millisecond,
+		RespectRobotsTxt:    true,
+	}
+	if got != expected {
+		t.Errorf("expected %+v, got %+v", expected, got)
+	}
+}
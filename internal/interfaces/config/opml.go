@@ -0,0 +1,289 @@
+package config
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+	"golang.org/x/net/html/charset"
+)
+
+// OPMLFeed は OPML のアウトラインとやり取りするフィードです
+// Tags にはフィードを入れたフォルダーと category 属性のカテゴリーを入れます
+type OPMLFeed struct {
+	Title string
+	URL   string
+	Tags  []string
+}
+
+type opmlDocument struct {
+	XMLName xml.Name `xml:"opml"`
+	Version string   `xml:"version,attr"`
+	Head    opmlHead `xml:"head"`
+	Body    opmlBody `xml:"body"`
+}
+
+type opmlHead struct {
+	Title       string `xml:"title,omitempty"`
+	DateCreated string `xml:"dateCreated,omitempty"`
+}
+
+type opmlBody struct {
+	Outlines []opmlOutline `xml:"outline"`
+}
+
+type opmlOutline struct {
+	Text     string        `xml:"text,attr"`
+	Title    string        `xml:"title,attr,omitempty"`
+	Type     string        `xml:"type,attr,omitempty"`
+	XMLURL   string        `xml:"xmlUrl,attr,omitempty"`
+	Category string        `xml:"category,attr,omitempty"`
+	Outlines []opmlOutline `xml:"outline"`
+}
+
+// ReadOPML は OPML からフィードを読み取ります
+// フォルダーのアウトラインの名前と、category 属性のカテゴリー（「/Tech/Go」のような階層は分割します）をタグにします
+// 同じURLのフィードは1つにまとめ、http/https 以外のURLは読み飛ばします
+func ReadOPML(r io.Reader) ([]OPMLFeed, error) {
+	var doc opmlDocument
+	decoder := xml.NewDecoder(r)
+	decoder.CharsetReader = charset.NewReaderLabel
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("failed to parse OPML: %w", err)
+	}
+
+	var feeds []OPMLFeed
+	index := make(map[string]int)
+	var walk func(outlines []opmlOutline, folders []string)
+	walk = func(outlines []opmlOutline, folders []string) {
+		for _, outline := range outlines {
+			title := strings.TrimSpace(outline.Title)
+			if title == "" {
+				title = strings.TrimSpace(outline.Text)
+			}
+
+			feedURL := strings.TrimSpace(outline.XMLURL)
+			if feedURL == "" {
+				walk(outline.Outlines, appendTags(folders, title))
+				continue
+			}
+			if !isFeedURL(feedURL) {
+				log.Printf("Warning: skipping OPML outline with unsupported URL: %q", feedURL)
+				continue
+			}
+
+			tags := appendTags(folders, splitCategories(outline.Category)...)
+			if i, ok := index[feedURL]; ok {
+				feeds[i].Tags = appendTags(feeds[i].Tags, tags...)
+				continue
+			}
+			index[feedURL] = len(feeds)
+			feeds = append(feeds, OPMLFeed{Title: title, URL: feedURL, Tags: tags})
+		}
+	}
+	walk(doc.Body.Outlines, nil)
+	return feeds, nil
+}
+
+// splitCategories は OPML の category 属性（カンマ区切りの「/」で始まる階層）をカテゴリーに分割します
+func splitCategories(raw string) []string {
+	var categories []string
+	for _, category := range strings.Split(raw, ",") {
+		for _, part := range strings.Split(category, "/") {
+			if trimmed := strings.TrimSpace(part); trimmed != "" {
+				categories = append(categories, trimmed)
+			}
+		}
+	}
+	return categories
+}
+
+// appendTags は tags に含まれていないタグだけを追加した新しいスライスを返します
+func appendTags(tags []string, additions ...string) []string {
+	result := append([]string(nil), tags...)
+	for _, tag := range additions {
+		if tag == "" || containsTag(result, tag) {
+			continue
+		}
+		result = append(result, tag)
+	}
+	return result
+}
+
+func containsTag(tags []string, tag string) bool {
+	for _, existing := range tags {
+		if existing == tag {
+			return true
+		}
+	}
+	return false
+}
+
+func isFeedURL(raw string) bool {
+	parsed, err := url.Parse(raw)
+	return err == nil && (parsed.Scheme == "http" || parsed.Scheme == "https") && parsed.Host != ""
+}
+
+// WriteOPML は feeds を OPML 2.0 で書き出します
+// 最初のタグをフォルダーとし、すべてのタグを category 属性に入れるため、ReadOPML で読み直すと同じタグになります
+func WriteOPML(w io.Writer, title string, feeds []OPMLFeed) error {
+	doc := opmlDocument{
+		Version: "2.0",
+		Head:    opmlHead{Title: title, DateCreated: time.Now().UTC().Format(time.RFC1123Z)},
+	}
+
+	folders := make(map[string]int)
+	for _, feed := range feeds {
+		text := feed.Title
+		if text == "" {
+			text = feed.URL
+		}
+		outline := opmlOutline{
+			Text:     text,
+			Type:     "rss",
+			XMLURL:   feed.URL,
+			Category: strings.Join(feed.Tags, ","),
+		}
+		if len(feed.Tags) == 0 {
+			doc.Body.Outlines = append(doc.Body.Outlines, outline)
+			continue
+		}
+
+		i, ok := folders[feed.Tags[0]]
+		if !ok {
+			i = len(doc.Body.Outlines)
+			folders[feed.Tags[0]] = i
+			doc.Body.Outlines = append(doc.Body.Outlines, opmlOutline{Text: feed.Tags[0]})
+		}
+		doc.Body.Outlines[i].Outlines = append(doc.Body.Outlines[i].Outlines, outline)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(doc); err != nil {
+		return fmt.Errorf("failed to write OPML: %w", err)
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// LoadRSSSettings は環境変数と envFiles（指定しない場合は .env）からフィードの設定だけを読み込みます
+// ボットの起動に必要な設定がなくても読み込めます
+func LoadRSSSettings(envFiles ...string) ([]RSSSettings, error) {
+	if len(envFiles) > 0 {
+		if err := godotenv.Load(envFiles...); err != nil {
+			return nil, fmt.Errorf("failed to load %s: %w", strings.Join(envFiles, ", "), err)
+		}
+	} else {
+		_ = godotenv.Load()
+	}
+
+	settings, err := loadRSSURLs()
+	if err != nil {
+		return nil, err
+	}
+	if len(settings) == 0 {
+		return nil, fmt.Errorf("no RSS URLs configured")
+	}
+	return settings, nil
+}
+
+var numberedRSSURLKey = regexp.MustCompile(`^RSS_URL_(\d+)$`)
+
+// AppendFeedsToEnvFile は feeds を RSS_URL_N と RSS_URL_N_TAGS として path の .env ファイルの末尾に追加し、追加した数を返します
+// すでに設定されているURLは追加しません。ファイルがなければ作成します
+func AppendFeedsToEnvFile(path string, feeds []OPMLFeed) (int, error) {
+	content, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return 0, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	env, err := godotenv.Unmarshal(string(content))
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	// 番号が飛んでいると以降のURLは無視されるため、続きの番号から追加する
+	next := 1
+	existing := make(map[string]bool)
+	for ; env[fmt.Sprintf("RSS_URL_%d", next)] != ""; next++ {
+		existing[strings.TrimSpace(env[fmt.Sprintf("RSS_URL_%d", next)])] = true
+	}
+	for key, value := range env {
+		match := numberedRSSURLKey.FindStringSubmatch(key)
+		if match == nil || value == "" {
+			continue
+		}
+		if index, _ := strconv.Atoi(match[1]); index > next {
+			return 0, fmt.Errorf("%s skips RSS_URL_%d, so %s is ignored; renumber the feeds before importing", path, next, key)
+		}
+	}
+	if next == 1 && env["RSS_URL"] != "" {
+		return 0, fmt.Errorf("%s uses the legacy RSS_URL list, which RSS_URL_N would override; convert it to RSS_URL_N before importing", path)
+	}
+
+	var buf bytes.Buffer
+	if len(content) > 0 && !bytes.HasSuffix(content, []byte("\n")) {
+		buf.WriteString("\n")
+	}
+	added := 0
+	for _, feed := range feeds {
+		if existing[feed.URL] {
+			continue
+		}
+		existing[feed.URL] = true
+
+		if added == 0 {
+			if len(content) > 0 {
+				buf.WriteString("\n")
+			}
+			buf.WriteString("# Imported from OPML\n")
+		}
+		if title := strings.Join(strings.Fields(feed.Title), " "); title != "" {
+			fmt.Fprintf(&buf, "# %s\n", title)
+		}
+		fmt.Fprintf(&buf, "RSS_URL_%d=%s\n", next, envValue(feed.URL))
+		if len(feed.Tags) > 0 {
+			fmt.Fprintf(&buf, "RSS_URL_%d_TAGS=%s\n", next, envValue(strings.Join(feed.Tags, ",")))
+		}
+		next++
+		added++
+	}
+	if added == 0 {
+		return 0, nil
+	}
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	if _, err := file.Write(buf.Bytes()); err != nil {
+		file.Close()
+		return 0, fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := file.Close(); err != nil {
+		return 0, fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return added, nil
+}
+
+// envValue は .env の値として読み直せるように、空白や「#」などを含む値を二重引用符で囲みます
+func envValue(value string) string {
+	if !strings.ContainsAny(value, " \t#\"'`\\$\n") {
+		return value
+	}
+	replacer := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "$", `\$`)
+	return `"` + replacer.Replace(value) + `"`
+}
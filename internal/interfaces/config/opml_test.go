@@ -0,0 +1,199 @@
+package config
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/joho/godotenv"
+)
+
+const testOPML = `<?xml version="1.0" encoding="UTF-8"?>
+<opml version="2.0">
+  <head><title>Subscriptions</title></head>
+  <body>
+    <outline text="Tech">
+      <outline text="Go">
+        <outline type="rss" text="Go Blog" xmlUrl="https://go.dev/blog/feed.atom"/>
+      </outline>
+      <outline type="rss" text="Example" title="Example News" xmlUrl="https://example.tld/rss" category="/News/World,Daily"/>
+    </outline>
+    <outline type="rss" text="Uncategorized" xmlUrl="https://example.tld/other.xml"/>
+    <outline text="Duplicates">
+      <outline type="rss" text="Example again" xmlUrl="https://example.tld/rss"/>
+    </outline>
+    <outline type="rss" text="Local" xmlUrl="file:///etc/passwd"/>
+  </body>
+</opml>`
+
+func TestReadOPML(t *testing.T) {
+	feeds, err := ReadOPML(strings.NewReader(testOPML))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []OPMLFeed{
+		{Title: "Go Blog", URL: "https://go.dev/blog/feed.atom", Tags: []string{"Tech", "Go"}},
+		{Title: "Example News", URL: "https://example.tld/rss", Tags: []string{"Tech", "News", "World", "Daily", "Duplicates"}},
+		{Title: "Uncategorized", URL: "https://example.tld/other.xml", Tags: nil},
+	}
+	if !reflect.DeepEqual(feeds, expected) {
+		t.Errorf("expected %+v, got %+v", expected, feeds)
+	}
+}
+
+func TestReadOPML_Charset(t *testing.T) {
+	// "Café" と "Actualités" を ISO-8859-1 でエンコードした OPML
+	doc := "<?xml version=\"1.0\" encoding=\"ISO-8859-1\"?>\n" +
+		"<opml version=\"2.0\"><body>" +
+		"<outline text=\"Actualit\xe9s\">" +
+		"<outline type=\"rss\" text=\"Caf\xe9\" xmlUrl=\"https://example.tld/rss\"/>" +
+		"</outline></body></opml>"
+
+	feeds, err := ReadOPML(strings.NewReader(doc))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []OPMLFeed{
+		{Title: "Café", URL: "https://example.tld/rss", Tags: []string{"Actualités"}},
+	}
+	if !reflect.DeepEqual(feeds, expected) {
+		t.Errorf("expected %+v, got %+v", expected, feeds)
+	}
+}
+
+func TestReadOPML_Invalid(t *testing.T) {
+	if _, err := ReadOPML(strings.NewReader("<rss></rss>")); err == nil {
+		t.Error("expected an error for a document that is not OPML")
+	}
+}
+
+func TestWriteOPML_RoundTrip(t *testing.T) {
+	feeds := []OPMLFeed{
+		{URL: "https://example.tld/rss1", Tags: []string{"Tech", "Go"}},
+		{URL: "https://example.tld/rss2"},
+		{Title: "News & More", URL: "https://example.tld/rss3?a=1&b=2", Tags: []string{"Tech"}},
+	}
+
+	var buf bytes.Buffer
+	if err := WriteOPML(&buf, "Feeds", feeds); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	read, err := ReadOPML(&buf)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// フォルダーごとにまとめて書き出すため、タグのないフィードはフォルダーの後になる
+	expected := []OPMLFeed{
+		{Title: "https://example.tld/rss1", URL: "https://example.tld/rss1", Tags: []string{"Tech", "Go"}},
+		{Title: "News & More", URL: "https://example.tld/rss3?a=1&b=2", Tags: []string{"Tech"}},
+		{Title: "https://example.tld/rss2", URL: "https://example.tld/rss2", Tags: nil},
+	}
+	if !reflect.DeepEqual(read, expected) {
+		t.Errorf("expected %+v, got %+v", expected, read)
+	}
+}
+
+func TestAppendFeedsToEnvFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".env")
+	initial := "MISSKEY_HOST=misskey.example\nRSS_URL_1=https://example.tld/rss"
+	if err := os.WriteFile(path, []byte(initial), 0o600); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	feeds := []OPMLFeed{
+		{Title: "Existing", URL: "https://example.tld/rss", Tags: []string{"News"}},
+		{Title: "Go  Blog", URL: "https://go.dev/blog/feed.atom", Tags: []string{"Tech", "Go Lang"}},
+		{URL: "https://example.tld/rss#fragment"},
+	}
+	added, err := AppendFeedsToEnvFile(path, feeds)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if added != 2 {
+		t.Errorf("expected 2 feeds to be added, got %d", added)
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(string(content), "# Go Blog\nRSS_URL_2=") {
+		t.Errorf("expected the title as a comment, got:\n%s", content)
+	}
+	env, err := godotenv.Unmarshal(string(content))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := map[string]string{
+		"MISSKEY_HOST":   "misskey.example",
+		"RSS_URL_1":      "https://example.tld/rss",
+		"RSS_URL_2":      "https://go.dev/blog/feed.atom",
+		"RSS_URL_2_TAGS": "Tech,Go Lang",
+		"RSS_URL_3":      "https://example.tld/rss#fragment",
+	}
+	if !reflect.DeepEqual(env, want) {
+		t.Errorf("expected %v, got %v", want, env)
+	}
+
+	// 同じ OPML を読み込み直しても追加しない
+	added, err = AppendFeedsToEnvFile(path, feeds)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if added != 0 {
+		t.Errorf("expected no feeds to be added again, got %d", added)
+	}
+}
+
+func TestAppendFeedsToEnvFile_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"gap", "RSS_URL_1=https://example.tld/rss1\nRSS_URL_3=https://example.tld/rss3\n"},
+		{"legacy", "RSS_URL=https://example.tld/rss1,https://example.tld/rss2\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), ".env")
+			if err := os.WriteFile(path, []byte(tt.content), 0o600); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if _, err := AppendFeedsToEnvFile(path, []OPMLFeed{{URL: "https://go.dev/blog/feed.atom"}}); err == nil {
+				t.Fatal("expected an error")
+			}
+			content, _ := os.ReadFile(path)
+			if string(content) != tt.content {
+				t.Errorf("expected the file to be unchanged, got:\n%s", content)
+			}
+		})
+	}
+}
+
+func TestAppendFeedsToEnvFile_CreatesFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".env")
+
+	added, err := AppendFeedsToEnvFile(path, []OPMLFeed{{URL: "https://example.tld/rss", Tags: []string{"News"}}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if added != 1 {
+		t.Errorf("expected 1 feed to be added, got %d", added)
+	}
+
+	env, err := godotenv.Read(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if env["RSS_URL_1"] != "https://example.tld/rss" || env["RSS_URL_1_TAGS"] != "News" {
+		t.Errorf("unexpected env: %v", env)
+	}
+}
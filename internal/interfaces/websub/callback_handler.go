@@ -0,0 +1,164 @@
+// Package websub は WebSub のハブからの確認と配信を受けるコールバックのエンドポイントです
+package websub
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"hash"
+	"io"
+	"log"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"misskeyRSSbot/internal/domain/entity"
+)
+
+// maxContentSize は配信されたフィードの本文の上限です
+const maxContentSize = 10 << 20
+
+// Subscriptions はコールバックが問い合わせる購読の一覧です
+// 購読を読み込めない場合などのエラーには 503 を返し、ハブに再試行させます
+type Subscriptions interface {
+	VerifyIntent(ctx context.Context, id, mode, topic string, lease time.Duration) (bool, error)
+	Deny(ctx context.Context, id, topic, reason string) error
+	Lookup(ctx context.Context, id string) (entity.WebSubSubscription, bool, error)
+	Deliver(ctx context.Context, id string, body io.Reader) error
+}
+
+type callbackHandler struct {
+	subscriptions Subscriptions
+}
+
+// NewCallbackHandler はURLの最後の要素を購読のIDとして扱うコールバックのハンドラーを作成します
+// GET でハブの確認に hub.challenge を返し、POST で署名を検証してから配信された内容を受け取ります
+func NewCallbackHandler(subscriptions Subscriptions) http.Handler {
+	return &callbackHandler{subscriptions: subscriptions}
+}
+
+func (h *callbackHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	id := path.Base(r.URL.Path)
+	switch r.Method {
+	case http.MethodGet:
+		h.verify(w, r, id)
+	case http.MethodPost:
+		h.receive(w, r, id)
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *callbackHandler) verify(w http.ResponseWriter, r *http.Request, id string) {
+	query := r.URL.Query()
+	mode := query.Get("hub.mode")
+	topic := query.Get("hub.topic")
+
+	if mode == "denied" {
+		if err := h.subscriptions.Deny(r.Context(), id, topic, query.Get("hub.reason")); err != nil {
+			log.Printf("Failed to record denied WebSub subscription [%s]: %v", id, err)
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	challenge := query.Get("hub.challenge")
+	if challenge == "" {
+		http.Error(w, "missing hub.challenge", http.StatusBadRequest)
+		return
+	}
+	var lease time.Duration
+	if seconds, err := strconv.Atoi(query.Get("hub.lease_seconds")); err == nil && seconds > 0 {
+		lease = time.Duration(seconds) * time.Second
+	}
+	verified, err := h.subscriptions.VerifyIntent(r.Context(), id, mode, topic, lease)
+	if err != nil {
+		log.Printf("Failed to verify WebSub subscription [%s]: %v", id, err)
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	if !verified {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain")
+	w.WriteHeader(http.StatusOK)
+	_, _ = io.WriteString(w, challenge)
+}
+
+func (h *callbackHandler) receive(w http.ResponseWriter, r *http.Request, id string) {
+	sub, ok, err := h.subscriptions.Lookup(r.Context(), id)
+	if err != nil {
+		log.Printf("Failed to load WebSub subscription [%s]: %v", id, err)
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	if !ok {
+		// 410 を返すとハブは購読を打ち切るため、購読を共有していないレプリカに届いた場合に備えて再送させる
+		http.Error(w, "unknown subscription", http.StatusServiceUnavailable)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxContentSize+1))
+	if err != nil {
+		http.Error(w, "failed to read body", http.StatusBadRequest)
+		return
+	}
+	if len(body) > maxContentSize {
+		http.Error(w, "content too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	// 署名が一致しない配信も、ハブに再送させないように 2xx を返して無視する
+	if sub.Secret != "" && !validSignature(r.Header.Get("X-Hub-Signature"), sub.Secret, body) {
+		log.Printf("Ignored WebSub content with an invalid signature [%s]", sub.FeedURL)
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	if err := h.subscriptions.Deliver(r.Context(), id, bytes.NewReader(body)); err != nil {
+		http.Error(w, "busy", http.StatusServiceUnavailable)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// validSignature は `sha256=<hex>` の形式の X-Hub-Signature が body の HMAC と一致するかどうかを返します
+func validSignature(header, secret string, body []byte) bool {
+	method, signature, ok := strings.Cut(header, "=")
+	if !ok {
+		return false
+	}
+
+	var newHash func() hash.Hash
+	switch strings.ToLower(method) {
+	case "sha1":
+		newHash = sha1.New
+	case "sha256":
+		newHash = sha256.New
+	case "sha384":
+		newHash = sha512.New384
+	case "sha512":
+		newHash = sha512.New
+	default:
+		return false
+	}
+
+	want, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(newHash, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(mac.Sum(nil), want)
+}
@@ -0,0 +1,316 @@
+package websub
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"misskeyRSSbot/internal/application"
+	"misskeyRSSbot/internal/domain/entity"
+	"misskeyRSSbot/internal/domain/repository"
+	"misskeyRSSbot/internal/infrastructure/rss"
+	"misskeyRSSbot/internal/infrastructure/storage"
+	hubclient "misskeyRSSbot/internal/infrastructure/websub"
+	"misskeyRSSbot/internal/interfaces/config"
+)
+
+// fakeHub はテスト用の WebSub のハブです
+// 購読の要求を受けるとコールバックで確認し、publish で購読者に署名付きの内容を配信します
+type fakeHub struct {
+	t      *testing.T
+	server *httptest.Server
+
+	mu       sync.Mutex
+	callback string
+	secret   string
+	verified chan string
+}
+
+func newFakeHub(t *testing.T) *fakeHub {
+	hub := &fakeHub{t: t, verified: make(chan string, 1)}
+	hub.server = httptest.NewServer(http.HandlerFunc(hub.subscribe))
+	t.Cleanup(hub.server.Close)
+	return hub
+}
+
+func (h *fakeHub) subscribe(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil || r.PostForm.Get("hub.mode") != "subscribe" {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	h.mu.Lock()
+	h.callback = r.PostForm.Get("hub.callback")
+	h.secret = r.PostForm.Get("hub.secret")
+	h.mu.Unlock()
+	w.WriteHeader(http.StatusAccepted)
+
+	// 仕様どおり、要求に応答した後で確認する
+	go func(topic string) {
+		query := url.Values{
+			"hub.mode":          {"subscribe"},
+			"hub.topic":         {topic},
+			"hub.challenge":     {"challenge-123"},
+			"hub.lease_seconds": {"3600"},
+		}
+		resp, err := http.Get(h.callback + "?" + query.Encode())
+		if err != nil {
+			h.verified <- "error: " + err.Error()
+			return
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		h.verified <- fmt.Sprintf("%d %s", resp.StatusCode, body)
+	}(r.PostForm.Get("hub.topic"))
+}
+
+// publish は body を secret の署名付きで購読者に配信し、応答のステータスを返します
+func (h *fakeHub) publish(body, secret string) int {
+	h.mu.Lock()
+	callback := h.callback
+	h.mu.Unlock()
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(body))
+	req, err := http.NewRequest(http.MethodPost, callback, strings.NewReader(body))
+	if err != nil {
+		h.t.Fatalf("failed to create request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/atom+xml")
+	req.Header.Set("X-Hub-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		h.t.Fatalf("failed to publish: %v", err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+type recordingNoteRepository struct {
+	posted chan *entity.Note
+}
+
+func (r *recordingNoteRepository) Post(ctx context.Context, note *entity.Note) error {
+	r.posted <- note
+	return nil
+}
+
+func pushedAtom(id, title string, updated time.Time) string {
+	return fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom">
+	<title>Pushed Feed</title>
+	<entry>
+		<title>%s</title>
+		<link href="https://example.tld/%s"/>
+		<id>%s</id>
+		<updated>%s</updated>
+	</entry>
+</feed>`, title, id, id, updated.UTC().Format(time.RFC3339))
+}
+
+func TestCallbackHandler_WithFakeHub(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hub := newFakeHub(t)
+	feedServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Link", fmt.Sprintf(`<%s>; rel="hub"`, hub.server.URL))
+		w.Write([]byte(`<feed xmlns="http://www.w3.org/2005/Atom"><title>Feed</title></feed>`))
+	}))
+	defer feedServer.Close()
+	feedURL := feedServer.URL + "/atom.xml"
+
+	cacheRepo := storage.NewMemoryCacheRepository(0)
+	if err := cacheRepo.SaveLatestPublishedTime(ctx, feedURL, time.Now().Add(-time.Hour)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	noteRepo := &recordingNoteRepository{posted: make(chan *entity.Note, 1)}
+	feedRepo := rss.NewFeedRepository(feedServer.Client())
+	feeds := application.NewRSSFeedService(feedRepo, noteRepo, cacheRepo, nil)
+
+	var webSub *application.WebSubService
+	callback := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		NewCallbackHandler(webSub).ServeHTTP(w, r)
+	}))
+	defer callback.Close()
+
+	webSub = application.NewWebSubService(
+		hubclient.NewHubRepository(nil),
+		cacheRepo.(repository.WebSubSubscriptionRepository),
+		feedRepo.(repository.FeedParser),
+		feeds,
+		callback.URL+"/websub",
+	)
+	go webSub.Run(ctx)
+
+	settings := []config.RSSSettings{{URL: feedURL}}
+	webSub.Refresh(ctx, settings)
+
+	select {
+	case result := <-hub.verified:
+		if result != "200 challenge-123" {
+			t.Fatalf("expected the challenge to be echoed, got %q", result)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("hub did not verify the subscription")
+	}
+	if polled := webSub.PolledFeeds(ctx, settings); len(polled) != 0 {
+		t.Errorf("expected the subscribed feed not to be polled, got %v", polled)
+	}
+
+	// 署名が一致しない配信は受け付けても投稿しない
+	if status := hub.publish(pushedAtom("forged", "Forged Article", time.Now()), "wrong-secret"); status != http.StatusAccepted {
+		t.Errorf("expected 202 for content with an invalid signature, got %d", status)
+	}
+
+	hub.mu.Lock()
+	secret := hub.secret
+	hub.mu.Unlock()
+	if status := hub.publish(pushedAtom("pushed", "Pushed Article", time.Now()), secret); status != http.StatusAccepted {
+		t.Fatalf("expected 202 for signed content, got %d", status)
+	}
+
+	select {
+	case note := <-noteRepo.posted:
+		if !strings.Contains(note.Text, "Pushed Article") {
+			t.Errorf("expected the pushed entry to be posted, got %q", note.Text)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("pushed entry was not posted")
+	}
+	select {
+	case note := <-noteRepo.posted:
+		t.Errorf("expected only one post, also got %q", note.Text)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+type stubSubscriptions struct {
+	sub       entity.WebSubSubscription
+	delivered []string
+	err       error
+	storeErr  error
+}
+
+func (s *stubSubscriptions) VerifyIntent(ctx context.Context, id, mode, topic string, lease time.Duration) (bool, error) {
+	if s.storeErr != nil {
+		return false, s.storeErr
+	}
+	return id == s.sub.ID && mode == "subscribe" && topic == s.sub.Topic, nil
+}
+
+func (s *stubSubscriptions) Deny(ctx context.Context, id, topic, reason string) error {
+	return s.storeErr
+}
+
+func (s *stubSubscriptions) Lookup(ctx context.Context, id string) (entity.WebSubSubscription, bool, error) {
+	if s.storeErr != nil {
+		return entity.WebSubSubscription{}, false, s.storeErr
+	}
+	return s.sub, id == s.sub.ID, nil
+}
+
+func (s *stubSubscriptions) Deliver(ctx context.Context, id string, body io.Reader) error {
+	if s.err != nil {
+		return s.err
+	}
+	content, _ := io.ReadAll(body)
+	s.delivered = append(s.delivered, string(content))
+	return nil
+}
+
+func TestCallbackHandler(t *testing.T) {
+	sign := func(method string, body string) string {
+		mac := hmac.New(sha256.New, []byte("secret"))
+		mac.Write([]byte(body))
+		return method + "=" + hex.EncodeToString(mac.Sum(nil))
+	}
+
+	tests := []struct {
+		name          string
+		method        string
+		target        string
+		body          string
+		signature     string
+		deliverErr    error
+		wantStatus    int
+		wantBody      string
+		wantDelivered int
+	}{
+		{"verify", http.MethodGet, "/websub/sub-1?hub.mode=subscribe&hub.topic=https://example.tld/rss&hub.challenge=abc", "", "", nil, http.StatusOK, "abc", 0},
+		{"verify other topic", http.MethodGet, "/websub/sub-1?hub.mode=subscribe&hub.topic=https://other.tld/&hub.challenge=abc", "", "", nil, http.StatusNotFound, "", 0},
+		{"verify without challenge", http.MethodGet, "/websub/sub-1?hub.mode=subscribe&hub.topic=https://example.tld/rss", "", "", nil, http.StatusBadRequest, "", 0},
+		{"denied", http.MethodGet, "/websub/sub-1?hub.mode=denied&hub.topic=https://example.tld/rss&hub.reason=nope", "", "", nil, http.StatusOK, "", 0},
+		{"signed content", http.MethodPost, "/websub/sub-1", "<feed/>", sign("sha256", "<feed/>"), nil, http.StatusAccepted, "", 1},
+		{"uppercase method", http.MethodPost, "/websub/sub-1", "<feed/>", sign("SHA256", "<feed/>"), nil, http.StatusAccepted, "", 1},
+		{"wrong signature", http.MethodPost, "/websub/sub-1", "<feed/>", sign("sha256", "<other/>"), nil, http.StatusAccepted, "", 0},
+		{"unsupported method", http.MethodPost, "/websub/sub-1", "<feed/>", sign("md5", "<feed/>"), nil, http.StatusAccepted, "", 0},
+		{"missing signature", http.MethodPost, "/websub/sub-1", "<feed/>", "", nil, http.StatusAccepted, "", 0},
+		{"unknown subscription", http.MethodPost, "/websub/sub-2", "<feed/>", sign("sha256", "<feed/>"), nil, http.StatusServiceUnavailable, "", 0},
+		{"queue full", http.MethodPost, "/websub/sub-1", "<feed/>", sign("sha256", "<feed/>"), context.DeadlineExceeded, http.StatusServiceUnavailable, "", 0},
+		{"other method", http.MethodPut, "/websub/sub-1", "", "", nil, http.StatusMethodNotAllowed, "", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			subs := &stubSubscriptions{
+				sub: entity.WebSubSubscription{ID: "sub-1", Topic: "https://example.tld/rss", Secret: "secret"},
+				err: tt.deliverErr,
+			}
+			req := httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body))
+			if tt.signature != "" {
+				req.Header.Set("X-Hub-Signature", tt.signature)
+			}
+			rec := httptest.NewRecorder()
+
+			NewCallbackHandler(subs).ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("expected status %d, got %d", tt.wantStatus, rec.Code)
+			}
+			if tt.wantBody != "" && rec.Body.String() != tt.wantBody {
+				t.Errorf("expected body %q, got %q", tt.wantBody, rec.Body.String())
+			}
+			if len(subs.delivered) != tt.wantDelivered {
+				t.Errorf("expected %d deliveries, got %d", tt.wantDelivered, len(subs.delivered))
+			}
+		})
+	}
+}
+
+func TestCallbackHandler_StoreUnavailable(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		target string
+	}{
+		{"verify", http.MethodGet, "/websub/sub-1?hub.mode=subscribe&hub.topic=https://example.tld/rss&hub.challenge=abc"},
+		{"denied", http.MethodGet, "/websub/sub-1?hub.mode=denied&hub.topic=https://example.tld/rss&hub.reason=nope"},
+		{"content", http.MethodPost, "/websub/sub-1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			subs := &stubSubscriptions{
+				sub:      entity.WebSubSubscription{ID: "sub-1", Topic: "https://example.tld/rss", Secret: "secret"},
+				storeErr: errors.New("database is locked"),
+			}
+			rec := httptest.NewRecorder()
+			NewCallbackHandler(subs).ServeHTTP(rec, httptest.NewRequest(tt.method, tt.target, strings.NewReader("<feed/>")))
+
+			if rec.Code != http.StatusServiceUnavailable {
+				t.Errorf("expected status %d, got %d", http.StatusServiceUnavailable, rec.Code)
+			}
+		})
+	}
+}
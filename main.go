@@ -2,24 +2,39 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
+	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
 	"misskeyRSSbot/internal/application"
+	"misskeyRSSbot/internal/domain/entity"
 	"misskeyRSSbot/internal/domain/repository"
+	htmlfetcher "misskeyRSSbot/internal/infrastructure/html"
+	"misskeyRSSbot/internal/infrastructure/httpclient"
 	"misskeyRSSbot/internal/infrastructure/llm"
+	"misskeyRSSbot/internal/infrastructure/metrics"
 	"misskeyRSSbot/internal/infrastructure/misskey"
 	"misskeyRSSbot/internal/infrastructure/rss"
 	"misskeyRSSbot/internal/infrastructure/storage"
+	websubclient "misskeyRSSbot/internal/infrastructure/websub"
 	"misskeyRSSbot/internal/interfaces/config"
+	"misskeyRSSbot/internal/interfaces/websub"
 )
 
 func main() {
+	if runSubcommand(os.Args[1:]) {
+		return
+	}
+
 	fmt.Println("Starting Misskey RSS Bot...")
 
 	cfg, err := config.LoadConfig()
@@ -30,13 +45,34 @@ func main() {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	feedRepo := rss.NewFeedRepository()
+	fetchAllowlist, err := httpclient.ParseAllowlist(cfg.GetFetchAllowedHosts())
+	if err != nil {
+		log.Fatal("Invalid FETCH_ALLOWED_HOSTS:", err)
+	}
+	httpCfg := cfg.GetHTTPClientConfig()
+	httpFactory, err := httpclient.NewFactory(httpclient.Config{
+		UserAgent:           httpCfg.UserAgent,
+		ProxyURL:            httpCfg.ProxyURL,
+		Timeout:             httpCfg.Timeout,
+		MaxIdleConnsPerHost: httpCfg.MaxIdleConnsPerHost,
+		IdleConnTimeout:     httpCfg.IdleConnTimeout,
+		HostInterval:        httpCfg.HostInterval,
+		RespectRobotsTxt:    httpCfg.RespectRobotsTxt,
+		Allowlist:           fetchAllowlist,
+	})
+	if err != nil {
+		log.Fatal("Failed to configure HTTP client:", err)
+	}
+	fetchClient := httpFactory.NewFetchClient()
+
+	feedRepo := rss.NewFeedRepository(fetchClient)
 	noteRepo := misskey.NewNoteRepository(misskey.Config{
 		Host:           cfg.MisskeyHost,
 		AuthToken:      cfg.AuthToken,
 		MaxPermits:     cfg.MaxPermits,
 		RefillInterval: cfg.GetRefillInterval(),
 		LocalOnly:      cfg.LocalOnly,
+		Client:         httpFactory.NewAPIClient(),
 	})
 
 	type cacheWithCleanup interface {
@@ -47,39 +83,89 @@ func main() {
 	var cacheCloser io.Closer
 	var cacheCleaner cacheWithCleanup
 	firstRunLatestOnly := cfg.FirstRunLatestOnly
-	if cfg.IsPersistentCache() {
+	if cfg.IsPostgresCache() {
+		postgresCache, cacheErr := storage.NewPostgresCacheRepository(cfg.CacheDSN)
+		if cacheErr != nil {
+			log.Fatal("Failed to initialize PostgreSQL cache:", cacheErr)
+		}
+		cacheRepo = postgresCache
+		log.Println("Using persistent cache: PostgreSQL (CACHE_DSN)")
+	} else if cfg.IsPersistentCache() {
 		sqliteCache, cacheErr := storage.NewSQLiteCacheRepository(cfg.CacheDBPath)
 		if cacheErr != nil {
 			log.Fatal("Failed to initialize SQLite cache:", cacheErr)
 		}
 		cacheRepo = sqliteCache
-		if closer, ok := sqliteCache.(io.Closer); ok {
-			cacheCloser = closer
-		}
-		if cleaner, ok := sqliteCache.(cacheWithCleanup); ok {
-			cacheCleaner = cleaner
-		}
 		log.Printf("Using persistent cache: %s", cfg.CacheDBPath)
 	} else {
-		cacheRepo = storage.NewMemoryCacheRepository()
+		cacheRepo = storage.NewMemoryCacheRepository(cfg.MemoryCacheMaxEntries)
 		log.Println("Using in-memory cache (data will not persist across restarts)")
+		if cfg.MemoryCacheMaxEntries > 0 {
+			log.Printf("In-memory cache keeps up to %d processed GUIDs", cfg.MemoryCacheMaxEntries)
+		}
 		if !cfg.FirstRunLatestOnly {
 			log.Printf("Warning: FIRST_RUN_LATEST_ONLY=%v requires CACHE_DB_PATH to be set when using in-memory cache", cfg.FirstRunLatestOnly)
 			log.Printf("Overriding FIRST_RUN_LATEST_ONLY from %v to true for safety", cfg.FirstRunLatestOnly)
 			firstRunLatestOnly = true
 		}
 	}
+	if closer, ok := cacheRepo.(io.Closer); ok {
+		cacheCloser = closer
+	}
+	if cleaner, ok := cacheRepo.(cacheWithCleanup); ok {
+		cacheCleaner = cleaner
+	}
+
+	var metricsRepo repository.MetricsRepository
+	if cfg.IsMetricsEnabled() {
+		metricsRepo = metrics.NewRegistry()
+	}
+
+	var elector *application.LeaderElector
+	if leases, ok := cacheRepo.(repository.LeaseRepository); ok && cfg.IsLeaderElectionEnabled() {
+		elector = application.NewLeaderElector(
+			leases,
+			cfg.GetReplicaID(),
+			application.WithLeaseTTL(cfg.GetLeaderLeaseTTL()),
+			application.WithLeaderMetrics(metricsRepo),
+		)
+		log.Printf("Leader election enabled [replica: %s, lease TTL: %v]", cfg.GetReplicaID(), cfg.GetLeaderLeaseTTL())
+	}
+
+	if handler, ok := metricsRepo.(http.Handler); ok {
+		startMetricsServer(ctx, cfg.MetricsAddr, handler, elector)
+	}
+
+	var usageTracker *application.UsageTracker
+	if usageRepo, ok := cacheRepo.(repository.UsageRepository); ok {
+		usageTracker = application.NewUsageTracker(
+			usageRepo,
+			application.WithModelPrices(cfg.LLMModelPrices),
+			application.WithDailyBudget(cfg.LLMDailyBudget),
+			application.WithUsageMetrics(metricsRepo),
+		)
+		if cfg.LLMDailyBudget > 0 {
+			log.Printf("LLM daily budget: $%.2f", cfg.LLMDailyBudget)
+		}
+	}
+
+	var usageRecorder repository.UsageRecorder
+	if usageTracker != nil {
+		usageRecorder = usageTracker
+	}
+
+	extractionRules, err := htmlfetcher.NewRules(toSiteRules(cfg.ExtractionRules))
+	if err != nil {
+		log.Fatal("Failed to load extraction rules:", err)
+	}
+	if len(cfg.ExtractionRules) > 0 {
+		log.Printf("Loaded %d extraction rules from %s", len(cfg.ExtractionRules), cfg.ExtractionRulesFile)
+	}
+
+	articleFetcher := htmlfetcher.NewFetcher(fetchClient, extractionRules, htmlfetcher.WithMaxPDFPages(cfg.PDFMaxPages))
 
 	llmCfg := cfg.GetLLMConfig()
-	summarizerRepo, err := llm.NewSummarizerRepository(ctx, llm.Config{
-		Provider:          llmCfg.Provider,
-		APIKey:            llmCfg.APIKey,
-		Model:             llmCfg.Model,
-		Region:            llmCfg.Region,
-		MaxTokens:         llmCfg.MaxTokens,
-		Timeout:           llmCfg.Timeout,
-		SystemInstruction: llmCfg.SystemInstruction,
-	})
+	summarizerRepo, err := llm.NewSummarizerRepository(ctx, toSummarizerConfig(llmCfg, usageRecorder, articleFetcher))
 	if err != nil {
 		log.Printf("Warning: LLM summarizer initialization failed: %v", err)
 		log.Println("Continuing without summarization feature...")
@@ -89,14 +175,98 @@ func main() {
 		}
 	}
 
+	summarizerProviders := make(map[string]repository.SummarizerRepository)
+	for name, providerCfg := range cfg.LLMProviderConfigs {
+		providerRepo, providerErr := llm.NewSummarizerRepository(ctx, toSummarizerConfig(providerCfg, usageRecorder, articleFetcher))
+		if providerErr != nil {
+			log.Printf("Warning: LLM provider %s initialization failed: %v", name, providerErr)
+			continue
+		}
+		summarizerProviders[name] = providerRepo
+	}
+	if len(summarizerProviders) > 0 && llmCfg.Provider != "" {
+		summarizerProviders[llmCfg.Provider] = summarizerRepo
+	}
+	summarizerRepo = llm.NewRouterSummarizer(summarizerRepo, summarizerProviders)
+
+	resilienceCfg := cfg.GetLLMResilienceConfig()
+	resilience := llm.NewResilience(llm.ResilienceConfig{
+		MaxConcurrency:    resilienceCfg.MaxConcurrency,
+		RequestsPerMinute: resilienceCfg.RequestsPerMinute,
+		MaxRetries:        resilienceCfg.MaxRetries,
+		RetryBaseDelay:    resilienceCfg.RetryBaseDelay,
+		RetryMaxDelay:     resilienceCfg.RetryMaxDelay,
+		Metrics:           metricsRepo,
+	})
+	summarizerRepo = resilience.Summarizer(summarizerRepo)
+
+	serviceOpts := []application.RSSFeedServiceOption{
+		application.WithFirstRunLatestOnly(firstRunLatestOnly),
+	}
+	if cfg.NoteTemplate != "" {
+		noteTemplate, tmplErr := entity.NewNoteTemplate(cfg.NoteTemplate)
+		if tmplErr != nil {
+			log.Fatal("Failed to parse NOTE_TEMPLATE:", tmplErr)
+		}
+		serviceOpts = append(serviceOpts, application.WithNoteTemplate(noteTemplate))
+	}
+	if usageTracker != nil {
+		serviceOpts = append(serviceOpts, application.WithUsageTracker(usageTracker))
+	}
+	if cfg.ResolveCanonicalURL {
+		serviceOpts = append(serviceOpts, application.WithCanonicalResolver(articleFetcher))
+	}
+	if postStates, ok := cacheRepo.(repository.PostStateRepository); ok {
+		postHistory, _ := noteRepo.(repository.PostHistoryRepository)
+		serviceOpts = append(serviceOpts, application.WithPostStates(postStates, postHistory))
+	}
+	if window := cfg.GetCrossFeedDedupeWindow(); window > 0 {
+		serviceOpts = append(serviceOpts, application.WithCrossFeedDedupe(window, cfg.CrossFeedMergeSources))
+		log.Printf("Cross-feed dedupe window: %v", window)
+	}
+	if window := cfg.GetStoryClusterWindow(); window > 0 {
+		serviceOpts = append(serviceOpts, application.WithStoryClustering(window, cfg.StoryClusterSimHashDistance))
+		if cfg.StoryClustering == config.StoryClusteringEmbedding {
+			embeddingCfg := toSummarizerConfig(llmCfg, usageRecorder, articleFetcher)
+			embeddingCfg.Model = cfg.StoryClusterEmbeddingModel
+			embedder, embedErr := llm.NewEmbeddingRepository(ctx, embeddingCfg)
+			if embedErr != nil {
+				log.Printf("Warning: embedding initialization failed, clustering with SimHash: %v", embedErr)
+			} else {
+				embedder = resilience.Embedder(embedder, embeddingCfg.Provider)
+				serviceOpts = append(serviceOpts, application.WithStoryEmbeddings(embedder, cfg.StoryClusterEmbeddingSimilarity))
+			}
+		}
+		log.Printf("Story clustering: %s (window: %v)", cfg.StoryClustering, window)
+	}
+
 	service := application.NewRSSFeedService(
 		feedRepo,
 		noteRepo,
 		cacheRepo,
 		summarizerRepo,
-		application.WithFirstRunLatestOnly(firstRunLatestOnly),
+		serviceOpts...,
 	)
 
+	var webSubService *application.WebSubService
+	if cfg.IsWebSubEnabled() {
+		parser, isParser := feedRepo.(repository.FeedParser)
+		subscriptions, isSubscriptionStore := cacheRepo.(repository.WebSubSubscriptionRepository)
+		if isParser && isSubscriptionStore {
+			webSubService = application.NewWebSubService(
+				websubclient.NewHubRepository(fetchClient),
+				subscriptions,
+				parser,
+				service,
+				cfg.WebSubCallbackURL,
+				application.WithWebSubLease(cfg.GetWebSubLease()),
+				application.WithWebSubLeader(elector),
+			)
+			go webSubService.Run(ctx)
+			startWebSubServer(ctx, cfg.WebSubListenAddr, cfg.WebSubCallbackURL, websub.NewCallbackHandler(webSubService))
+		}
+	}
+
 	if firstRunLatestOnly {
 		log.Println("First run mode: post latest entry only")
 	} else {
@@ -125,11 +295,62 @@ func main() {
 		defer cleanupTicker.Stop()
 	}
 
-	log.Println("Fetching RSS feeds...")
-	if err := service.ProcessAllFeeds(ctx, cfg.RSSURL); err != nil {
-		log.Printf("RSS processing error: %v", err)
+	// リーダー選出が有効な場合は、リーダーのレプリカだけが復旧、取得と投稿、掃除を行う
+	electorDone := make(chan struct{})
+	if elector != nil {
+		elector.TryAcquire(ctx)
+		go func() {
+			defer close(electorDone)
+			elector.Run(ctx)
+		}()
+	} else {
+		close(electorDone)
 	}
-	log.Println("RSS feeds fetched")
+	isLeader := func() bool {
+		return elector == nil || elector.IsLeader()
+	}
+
+	// 投稿中のまま停止したエントリーの照合は、リーダーになるたびに投稿を始める前に行う
+	recovered := false
+	processFeeds := func() {
+		if !isLeader() {
+			recovered = false
+			log.Printf("Skipping RSS fetch: not the leader [leader: %s]", elector.Status().Leader)
+			return
+		}
+
+		// 取得と投稿はリースの期間より長くかかることがあるため、途中でリースを失ったら残りを投稿しない
+		feedCtx := ctx
+		if elector != nil {
+			var cancelFeeds context.CancelFunc
+			feedCtx, cancelFeeds = elector.LeaderContext(ctx)
+			defer cancelFeeds()
+		}
+
+		// 照合できなかった投稿は posting のまま残るため、照合できるまで毎回やり直す
+		if !recovered {
+			if err := service.RecoverPosts(feedCtx); err != nil {
+				log.Printf("Failed to recover interrupted posts, will retry: %v", err)
+			} else {
+				recovered = true
+			}
+		}
+
+		// WebSub の購読が有効なフィードは配信を待ち、それ以外のフィードをポーリングする
+		polledFeeds := cfg.RSSURL
+		if webSubService != nil {
+			webSubService.Refresh(feedCtx, cfg.RSSURL)
+			polledFeeds = webSubService.PolledFeeds(feedCtx, cfg.RSSURL)
+		}
+
+		log.Println("Fetching RSS feeds...")
+		if err := service.ProcessAllFeeds(feedCtx, polledFeeds); err != nil {
+			log.Printf("RSS processing error: %v", err)
+		}
+		log.Println("RSS feeds fetched")
+	}
+
+	processFeeds()
 
 	cleanupChan := func() <-chan time.Time {
 		if cleanupTicker != nil {
@@ -142,6 +363,8 @@ func main() {
 		select {
 		case <-ctx.Done():
 			log.Println("Shutting down...")
+			// リースを解放してからキャッシュを閉じる
+			<-electorDone
 			if cacheCloser != nil {
 				if err := cacheCloser.Close(); err != nil {
 					log.Printf("Failed to close cache: %v", err)
@@ -149,12 +372,11 @@ func main() {
 			}
 			return
 		case <-ticker.C:
-			log.Println("Fetching RSS feeds...")
-			if err := service.ProcessAllFeeds(ctx, cfg.RSSURL); err != nil {
-				log.Printf("RSS processing error: %v", err)
-			}
-			log.Println("RSS feeds fetched")
+			processFeeds()
 		case <-cleanupChan:
+			if !isLeader() {
+				continue
+			}
 			retentionPeriod := cfg.GetCacheRetentionPeriod()
 			deleted, err := cacheCleaner.CleanupOldGUIDs(ctx, retentionPeriod)
 			if err != nil {
@@ -165,3 +387,81 @@ func main() {
 		}
 	}
 }
+
+// startMetricsServer は /metrics を公開し、リーダー選出が有効な場合は /leader で現在のリーダーを返します
+func startMetricsServer(ctx context.Context, addr string, handler http.Handler, elector *application.LeaderElector) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", handler)
+	if elector != nil {
+		mux.HandleFunc("/leader", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(elector.Status())
+		})
+	}
+	startHTTPServer(ctx, "Metrics", addr, mux)
+}
+
+// startWebSubServer は callbackURL のパスの下で WebSub のハブからの確認と配信を受けます
+func startWebSubServer(ctx context.Context, addr, callbackURL string, handler http.Handler) {
+	callbackPath := "/"
+	if parsed, err := url.Parse(callbackURL); err == nil {
+		callbackPath = strings.TrimSuffix(parsed.Path, "/") + "/"
+	}
+	mux := http.NewServeMux()
+	mux.Handle(callbackPath, handler)
+	startHTTPServer(ctx, "WebSub callback", addr, mux)
+}
+
+func startHTTPServer(ctx context.Context, name, addr string, handler http.Handler) {
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		log.Printf("%s server listening on %s", name, addr)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("%s server error: %v", name, err)
+		}
+	}()
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			log.Printf("Failed to shutdown %s server: %v", strings.ToLower(name), err)
+		}
+	}()
+}
+
+func toSummarizerConfig(llmCfg config.LLMConfig, usageRecorder repository.UsageRecorder, articleFetcher *htmlfetcher.Fetcher) llm.Config {
+	return llm.Config{
+		Provider:          llmCfg.Provider,
+		APIKey:            llmCfg.APIKey,
+		Model:             llmCfg.Model,
+		Region:            llmCfg.Region,
+		MaxTokens:         llmCfg.MaxTokens,
+		Timeout:           llmCfg.Timeout,
+		SystemInstruction: llmCfg.SystemInstruction,
+		Language:          llmCfg.Language,
+		StructuredOutput:  llmCfg.StructuredOutput,
+		UsageRecorder:     usageRecorder,
+		ArticleFetcher:    articleFetcher,
+	}
+}
+
+func toSiteRules(rules []config.ExtractionRule) []htmlfetcher.SiteRule {
+	siteRules := make([]htmlfetcher.SiteRule, 0, len(rules))
+	for _, rule := range rules {
+		siteRules = append(siteRules, htmlfetcher.SiteRule{
+			Host:             rule.Host,
+			ContentSelectors: rule.ContentSelectors,
+			RemoveSelectors:  rule.RemoveSelectors,
+			FollowAMPHTML:    rule.FollowAMPHTML,
+			FollowCanonical:  rule.FollowCanonical,
+		})
+	}
+	return siteRules
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"misskeyRSSbot/internal/interfaces/config"
+)
+
+// opmlTitle は書き出す OPML の head の title です
+const opmlTitle = "Misskey RSS Bot feeds"
+
+func runExportOPML(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("export-opml", flag.ContinueOnError)
+	file := fs.String("file", "-", "output file (- for stdout)")
+	envFile := fs.String("env", "", "env file to read feeds from (default: .env if present)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	var envFiles []string
+	if *envFile != "" {
+		envFiles = append(envFiles, *envFile)
+	}
+	settings, err := config.LoadRSSSettings(envFiles...)
+	if err != nil {
+		return err
+	}
+
+	feeds := make([]config.OPMLFeed, 0, len(settings))
+	for _, setting := range settings {
+		feeds = append(feeds, config.OPMLFeed{URL: setting.URL, Tags: setting.Tags})
+	}
+
+	if *file == "-" {
+		return config.WriteOPML(os.Stdout, opmlTitle, feeds)
+	}
+	out, err := os.Create(*file)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", *file, err)
+	}
+	if err := config.WriteOPML(out, opmlTitle, feeds); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", *file, err)
+	}
+
+	log.Printf("Exported %d feeds", len(feeds))
+	return nil
+}
+
+func runImportOPML(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("import-opml", flag.ContinueOnError)
+	file := fs.String("file", "-", "input OPML file (- for stdin)")
+	envFile := fs.String("env", ".env", "env file to append RSS_URL_N entries to")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	in := io.Reader(os.Stdin)
+	if *file != "-" {
+		f, err := os.Open(*file)
+		if err != nil {
+			return fmt.Errorf("failed to open %s: %w", *file, err)
+		}
+		defer f.Close()
+		in = f
+	}
+	feeds, err := config.ReadOPML(in)
+	if err != nil {
+		return err
+	}
+
+	added, err := config.AppendFeedsToEnvFile(*envFile, feeds)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Imported %d of %d feeds into %s", added, len(feeds), *envFile)
+	return nil
+}